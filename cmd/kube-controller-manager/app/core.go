@@ -76,6 +76,7 @@ func startServiceController(ctx ControllerContext) (http.Handler, bool, error) {
 		glog.Errorf("Failed to start service controller: %v", err)
 		return nil, false, nil
 	}
+	serviceController.SetNodeSyncConcurrency(int(ctx.ComponentConfig.ServiceController.ConcurrentServiceSyncs))
 	go serviceController.Run(ctx.Stop, int(ctx.ComponentConfig.ServiceController.ConcurrentServiceSyncs))
 	return nil, true, nil
 }
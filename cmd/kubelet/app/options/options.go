@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 
@@ -54,6 +55,34 @@ type KubeletFlags struct {
 	KubeConfig          string
 	BootstrapKubeconfig string
 
+	// BootstrapPreflightOnly causes the kubelet to run the local bootstrap
+	// validations (kubeconfig parse, key verification, apiserver/CA
+	// reachability, clock skew) without submitting a CSR, then exit.
+	BootstrapPreflightOnly bool
+
+	// BootstrapSignerName is the signer asked to issue the bootstrap client
+	// certificate. Empty keeps the legacy kubelet client signer semantics.
+	BootstrapSignerName string
+	// BootstrapCertDuration is the certificate duration requested during
+	// bootstrap. Zero leaves the duration up to the signer.
+	BootstrapCertDuration time.Duration
+	// BootstrapReadOnlyKubeconfig, when set, makes the bootstrap flow write a
+	// second kubeconfig to this path for node-local companion agents. It
+	// authenticates with the token in BootstrapReadOnlyTokenFile instead of
+	// the kubelet's client certificate.
+	BootstrapReadOnlyKubeconfig string
+	// BootstrapReadOnlyTokenFile is the token file referenced by the
+	// read-only kubeconfig. Required with BootstrapReadOnlyKubeconfig.
+	BootstrapReadOnlyTokenFile string
+	// BootstrapStartupJitter, when > 0, delays a needed bootstrap by a random
+	// duration in [0, BootstrapStartupJitter) so a fleet-wide reboot does not
+	// stampede the apiserver with simultaneous CSR creation.
+	BootstrapStartupJitter time.Duration
+	// BootstrapCertExpiryThreshold, when > 0, re-runs the bootstrap CSR flow
+	// at startup if the existing client certificate expires within the
+	// threshold, instead of only when the kubeconfig is missing or invalid.
+	BootstrapCertExpiryThreshold time.Duration
+
 	// Insert a probability of random errors during calls to the master.
 	ChaosChance float64
 	// Crash immediately, rather than eating panics.
@@ -250,6 +279,9 @@ func ValidateKubeletFlags(f *KubeletFlags) error {
 	if f.NodeStatusMaxImages < -1 {
 		return fmt.Errorf("invalid configuration: NodeStatusMaxImages (--node-status-max-images) must be -1 or greater")
 	}
+	if f.BootstrapReadOnlyKubeconfig != "" && f.BootstrapReadOnlyTokenFile == "" {
+		return fmt.Errorf("invalid configuration: --bootstrap-readonly-kubeconfig requires --bootstrap-readonly-token-file")
+	}
 	return nil
 }
 
@@ -345,6 +377,13 @@ func (f *KubeletFlags) AddFlags(mainfs *pflag.FlagSet) {
 		"If the file specified by --kubeconfig does not exist, the bootstrap kubeconfig is used to request a client certificate from the API server. "+
 		"On success, a kubeconfig file referencing the generated client certificate and key is written to the path specified by --kubeconfig. "+
 		"The client certificate and key file will be stored in the directory pointed by --cert-dir.")
+	fs.BoolVar(&f.BootstrapPreflightOnly, "bootstrap-preflight-only", f.BootstrapPreflightOnly, "If true, run the local validations performed by the TLS bootstrap flow (kubeconfig parse, key verification, API server reachability, clock skew) without submitting a CSR, then exit. Requires --bootstrap-kubeconfig.")
+	fs.StringVar(&f.BootstrapSignerName, "bootstrap-signer-name", f.BootstrapSignerName, "Signer asked to issue the client certificate requested during TLS bootstrap. When empty, the legacy kubelet client signer semantics apply.")
+	fs.DurationVar(&f.BootstrapCertDuration, "bootstrap-cert-duration", f.BootstrapCertDuration, "Certificate duration requested during TLS bootstrap. The signer may issue a shorter-lived certificate. When zero, the duration is left up to the signer.")
+	fs.StringVar(&f.BootstrapReadOnlyKubeconfig, "bootstrap-readonly-kubeconfig", f.BootstrapReadOnlyKubeconfig, "If set, the TLS bootstrap flow additionally writes a kubeconfig to this path for node-local companion agents. It references the same cluster as --kubeconfig but authenticates with the token in --bootstrap-readonly-token-file instead of the kubelet's client certificate.")
+	fs.StringVar(&f.BootstrapReadOnlyTokenFile, "bootstrap-readonly-token-file", f.BootstrapReadOnlyTokenFile, "File containing the token referenced by the kubeconfig written to --bootstrap-readonly-kubeconfig. Required when that flag is set.")
+	fs.DurationVar(&f.BootstrapStartupJitter, "bootstrap-startup-jitter", f.BootstrapStartupJitter, "If > 0, delay a needed TLS bootstrap by a random duration up to this value, spreading CSR creation from a fleet-wide reboot over time. Kubelets whose client certificate is still valid are not delayed.")
+	fs.DurationVar(&f.BootstrapCertExpiryThreshold, "bootstrap-cert-expiry-threshold", f.BootstrapCertExpiryThreshold, "If > 0, re-run the TLS bootstrap flow at startup when the existing client certificate expires within this duration, renewing it with the retained private key instead of running into the expiry. When zero, bootstrap only runs if the kubeconfig is missing or invalid.")
 
 	fs.BoolVar(&f.ReallyCrashForTesting, "really-crash-for-testing", f.ReallyCrashForTesting, "If true, when panics occur crash. Intended for testing.")
 	fs.Float64Var(&f.ChaosChance, "chaos-chance", f.ChaosChance, "If > 0.0, introduce random client errors and latency. Intended for testing.")
@@ -538,7 +538,21 @@ func run(s *options.KubeletServer, kubeDeps *kubelet.Dependencies, stopCh <-chan
 	}
 
 	if s.BootstrapKubeconfig != "" {
-		if err := bootstrap.LoadClientCert(s.KubeConfig, s.BootstrapKubeconfig, s.CertDirectory, nodeName); err != nil {
+		if s.BootstrapPreflightOnly {
+			if err := bootstrap.PreflightCheck(s.KubeConfig, s.BootstrapKubeconfig, s.CertDirectory); err != nil {
+				return err
+			}
+			glog.Infof("Bootstrap preflight checks passed, exiting as requested by --bootstrap-preflight-only")
+			return nil
+		}
+		if err := bootstrap.LoadClientCertWithOptions(s.KubeConfig, s.BootstrapKubeconfig, s.CertDirectory, nodeName, bootstrap.CertificateRequestOptions{
+			SignerName:             s.BootstrapSignerName,
+			ExpirationSeconds:      int32(s.BootstrapCertDuration / time.Second),
+			StartupJitter:          s.BootstrapStartupJitter,
+			ReadOnlyKubeconfigPath: s.BootstrapReadOnlyKubeconfig,
+			ReadOnlyTokenFile:      s.BootstrapReadOnlyTokenFile,
+			ExpiryThreshold:        s.BootstrapCertExpiryThreshold,
+		}); err != nil {
 			return err
 		}
 	}
@@ -19,6 +19,7 @@ package resource
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 
 	"k8s.io/api/core/v1"
@@ -55,17 +56,125 @@ func maxResourceList(list, new v1.ResourceList) {
 // PodRequestsAndLimits returns a dictionary of all defined resources summed up for all
 // containers of the pod.
 func PodRequestsAndLimits(pod *v1.Pod) (reqs, limits v1.ResourceList) {
-	reqs, limits = v1.ResourceList{}, v1.ResourceList{}
-	for _, container := range pod.Spec.Containers {
+	return PodSpecRequestsAndLimits(&pod.Spec)
+}
+
+// PodSpecRequestsAndLimits is the PodSpec-level variant of
+// PodRequestsAndLimits, for callers that only hold a pod template.
+func PodSpecRequestsAndLimits(spec *v1.PodSpec) (reqs, limits v1.ResourceList) {
+	return PodSpecRequests(spec), PodSpecLimits(spec)
+}
+
+// PodSpecRequests returns a dictionary of all defined resource requests summed
+// up for all containers of the pod spec.
+func PodSpecRequests(spec *v1.PodSpec) v1.ResourceList {
+	reqs := v1.ResourceList{}
+	for _, container := range spec.Containers {
 		addResourceList(reqs, container.Resources.Requests)
-		addResourceList(limits, container.Resources.Limits)
 	}
 	// init containers define the minimum of any resource
-	for _, container := range pod.Spec.InitContainers {
+	for _, container := range spec.InitContainers {
 		maxResourceList(reqs, container.Resources.Requests)
+	}
+	return reqs
+}
+
+// PodSpecLimits returns a dictionary of all defined resource limits summed up
+// for all containers of the pod spec.
+func PodSpecLimits(spec *v1.PodSpec) v1.ResourceList {
+	limits := v1.ResourceList{}
+	for _, container := range spec.Containers {
+		addResourceList(limits, container.Resources.Limits)
+	}
+	// init containers define the minimum of any resource
+	for _, container := range spec.InitContainers {
 		maxResourceList(limits, container.Resources.Limits)
 	}
-	return
+	return limits
+}
+
+// ResourceRequestExplanation describes, for a single resource, how the pod's
+// effective request was computed: the sum over regular containers, the max
+// over any single init container, and the value actually chosen (the greater
+// of the two).
+type ResourceRequestExplanation struct {
+	// ContainersSum is the sum of the requests of all regular containers.
+	ContainersSum resource.Quantity
+	// InitContainersMax is the largest request of any single init container.
+	InitContainersMax resource.Quantity
+	// Effective is the value used as the pod's effective request.
+	Effective resource.Quantity
+	// FromInitContainers is true when Effective came from an init container
+	// rather than the sum of regular containers.
+	FromInitContainers bool
+}
+
+// PodRequestsExplanation returns, for every resource requested by the pod, a
+// breakdown of how the effective request was computed. Init containers run
+// sequentially before regular containers, so the pod's effective request for
+// a resource is the max of (sum of containers, max of init containers); this
+// is frequently misread as a plain sum.
+func PodRequestsExplanation(pod *v1.Pod) map[v1.ResourceName]ResourceRequestExplanation {
+	containersSum := v1.ResourceList{}
+	initContainersMax := v1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResourceList(containersSum, container.Resources.Requests)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		maxResourceList(initContainersMax, container.Resources.Requests)
+	}
+
+	explanations := map[v1.ResourceName]ResourceRequestExplanation{}
+	for name, quantity := range containersSum {
+		explanations[name] = ResourceRequestExplanation{ContainersSum: *quantity.Copy(), Effective: *quantity.Copy()}
+	}
+	for name, quantity := range initContainersMax {
+		explanation := explanations[name]
+		explanation.InitContainersMax = *quantity.Copy()
+		if quantity.Cmp(explanation.Effective) > 0 {
+			explanation.Effective = *quantity.Copy()
+			explanation.FromInitContainers = true
+		}
+		explanations[name] = explanation
+	}
+	return explanations
+}
+
+// ResourceListEqual returns true if the two lists specify the same quantity
+// of every resource. Unlike reflect.DeepEqual, quantities are compared by
+// value rather than representation (so "1000m" equals "1"), and a resource
+// missing from one list is treated as zero in it, so an explicit zero entry
+// is equal to no entry at all.
+func ResourceListEqual(a, b v1.ResourceList) bool {
+	for name, aQuantity := range a {
+		bQuantity := b[name]
+		if aQuantity.Cmp(bQuantity) != 0 {
+			return false
+		}
+	}
+	for name, bQuantity := range b {
+		if _, found := a[name]; !found && !bQuantity.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// ResourceListLessThanOrEqual returns true if a specifies no more of any
+// resource than b does. A resource missing from either list is treated as
+// zero, so a resource present in a but absent from b counts as exceeding b.
+// When the result is false, the names of the resources in a that exceed b
+// are also returned, sorted for stable messages.
+func ResourceListLessThanOrEqual(a, b v1.ResourceList) (bool, []v1.ResourceName) {
+	var exceeded []v1.ResourceName
+	for name, aQuantity := range a {
+		bQuantity := b[name]
+		if aQuantity.Cmp(bQuantity) > 0 {
+			exceeded = append(exceeded, name)
+		}
+	}
+	sort.Slice(exceeded, func(i, j int) bool { return exceeded[i] < exceeded[j] })
+	return len(exceeded) == 0, exceeded
 }
 
 // GetResourceRequest finds and returns the request for a specific resource.
@@ -121,6 +230,30 @@ func ExtractResourceValueByContainerNameAndNodeAllocatable(fs *v1.ResourceFieldS
 	return ExtractContainerResourceValue(fs, container)
 }
 
+// ValidateFieldSelector checks that a ResourceFieldSelector names a supported
+// container resource and that its divisor makes sense for that resource type,
+// e.g. a divisor of 1m is rejected for memory because memory is measured in
+// whole bytes. An unset divisor is valid and defaults to 1. It is for
+// node-side callers that build selectors programmatically; extraction
+// deliberately does not re-check already-admitted selectors, and admission
+// performs the equivalent check on the internal types.
+func ValidateFieldSelector(fs *v1.ResourceFieldSelector) error {
+	unsetDivisor := resource.Quantity{}
+	switch fs.Resource {
+	case "limits.cpu", "requests.cpu":
+		if unsetDivisor.Cmp(fs.Divisor) != 0 && fs.Divisor.MilliValue() < 1 {
+			return fmt.Errorf("invalid divisor %q for resource %q: divisor must be at least 1m", fs.Divisor.String(), fs.Resource)
+		}
+	case "limits.memory", "requests.memory", "limits.ephemeral-storage", "requests.ephemeral-storage":
+		if unsetDivisor.Cmp(fs.Divisor) != 0 && (fs.Divisor.Value() < 1 || fs.Divisor.MilliValue()%1000 != 0) {
+			return fmt.Errorf("invalid divisor %q for resource %q: divisor must be a whole number of bytes", fs.Divisor.String(), fs.Resource)
+		}
+	default:
+		return fmt.Errorf("unsupported container resource: %v", fs.Resource)
+	}
+	return nil
+}
+
 // ExtractContainerResourceValue extracts the value of a resource
 // in an already known container
 func ExtractContainerResourceValue(fs *v1.ResourceFieldSelector, container *v1.Container) (string, error) {
@@ -146,7 +279,7 @@ func ExtractContainerResourceValue(fs *v1.ResourceFieldSelector, container *v1.C
 		return convertResourceEphemeralStorageToString(container.Resources.Requests.StorageEphemeral(), divisor)
 	}
 
-	return "", fmt.Errorf("unsupported container resource : %v", fs.Resource)
+	return "", fmt.Errorf("unsupported container resource: %v", fs.Resource)
 }
 
 // convertResourceCPUToString converts cpu value to the format of divisor and returns
@@ -17,6 +17,7 @@ limitations under the License.
 package resource
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -150,6 +151,105 @@ func TestExtractResourceValue(t *testing.T) {
 	}
 }
 
+func TestPodRequestsExplanation(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name: "c1",
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{
+							v1.ResourceCPU:    resource.MustParse("100m"),
+							v1.ResourceMemory: resource.MustParse("100Mi"),
+						},
+					},
+				},
+				{
+					Name: "c2",
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{
+							v1.ResourceCPU: resource.MustParse("200m"),
+						},
+					},
+				},
+			},
+			InitContainers: []v1.Container{
+				{
+					Name: "init",
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{
+							v1.ResourceCPU:    resource.MustParse("500m"),
+							v1.ResourceMemory: resource.MustParse("50Mi"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	explanations := PodRequestsExplanation(pod)
+
+	cpu := explanations[v1.ResourceCPU]
+	assert.Equal(t, "300m", cpu.ContainersSum.String())
+	assert.Equal(t, "500m", cpu.InitContainersMax.String())
+	assert.Equal(t, "500m", cpu.Effective.String())
+	assert.True(t, cpu.FromInitContainers)
+
+	memory := explanations[v1.ResourceMemory]
+	assert.Equal(t, "100Mi", memory.ContainersSum.String())
+	assert.Equal(t, "50Mi", memory.InitContainersMax.String())
+	assert.Equal(t, "100Mi", memory.Effective.String())
+	assert.False(t, memory.FromInitContainers)
+}
+
+func TestPodSpecRequestsAndLimits(t *testing.T) {
+	spec := v1.PodSpec{
+		Containers: []v1.Container{
+			{
+				Name: "c1",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("100m"),
+					},
+					Limits: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("200m"),
+					},
+				},
+			},
+			{
+				Name: "c2",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("100m"),
+					},
+				},
+			},
+		},
+		InitContainers: []v1.Container{
+			{
+				Name: "init",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("300m"),
+					},
+					Limits: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("300m"),
+					},
+				},
+			},
+		},
+	}
+
+	reqs, limits := PodSpecRequestsAndLimits(&spec)
+	assert.Equal(t, "300m", reqs.Cpu().String())
+	assert.Equal(t, "300m", limits.Cpu().String())
+
+	// the spec-level variants must agree with the pod-level one
+	podReqs, podLimits := PodRequestsAndLimits(&v1.Pod{Spec: spec})
+	assert.Equal(t, reqs, podReqs)
+	assert.Equal(t, limits, podLimits)
+}
+
 func getPod(cname, cpuRequest, cpuLimit, memoryRequest, memoryLimit string) *v1.Pod {
 	resources := v1.ResourceRequirements{
 		Limits:   make(v1.ResourceList),
@@ -178,3 +278,115 @@ func getPod(cname, cpuRequest, cpuLimit, memoryRequest, memoryLimit string) *v1.
 		},
 	}
 }
+
+func TestValidateFieldSelector(t *testing.T) {
+	cases := []struct {
+		fs          v1.ResourceFieldSelector
+		expectError bool
+	}{
+		{fs: v1.ResourceFieldSelector{Resource: "limits.cpu"}},
+		{fs: v1.ResourceFieldSelector{Resource: "requests.cpu", Divisor: resource.MustParse("100m")}},
+		{fs: v1.ResourceFieldSelector{Resource: "requests.memory", Divisor: resource.MustParse("1Mi")}},
+		{fs: v1.ResourceFieldSelector{Resource: "limits.ephemeral-storage", Divisor: resource.MustParse("1Gi")}},
+		{fs: v1.ResourceFieldSelector{Resource: "limits.memory", Divisor: resource.MustParse("1m")}, expectError: true},
+		{fs: v1.ResourceFieldSelector{Resource: "requests.ephemeral-storage", Divisor: resource.MustParse("500m")}, expectError: true},
+		{fs: v1.ResourceFieldSelector{Resource: "status.podIP"}, expectError: true},
+	}
+	for idx, tc := range cases {
+		err := ValidateFieldSelector(&tc.fs)
+		if tc.expectError && err == nil {
+			t.Errorf("expected test case [%d] to return an error", idx)
+		}
+		if !tc.expectError && err != nil {
+			t.Errorf("expected test case [%d] to not return an error; got %v", idx, err)
+		}
+	}
+}
+
+func TestResourceListEqual(t *testing.T) {
+	cases := []struct {
+		a        v1.ResourceList
+		b        v1.ResourceList
+		expected bool
+	}{
+		{a: nil, b: nil, expected: true},
+		{a: v1.ResourceList{}, b: nil, expected: true},
+		{
+			a:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+			b:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1000m")},
+			expected: true,
+		},
+		{
+			a:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("0")},
+			b:        v1.ResourceList{},
+			expected: true,
+		},
+		{
+			a:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+			b:        v1.ResourceList{},
+			expected: false,
+		},
+		{
+			a:        v1.ResourceList{},
+			b:        v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+			expected: false,
+		},
+		{
+			a:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"), v1.ResourceMemory: resource.MustParse("1Gi")},
+			b:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"), v1.ResourceMemory: resource.MustParse("2Gi")},
+			expected: false,
+		},
+	}
+	for idx, tc := range cases {
+		if actual := ResourceListEqual(tc.a, tc.b); actual != tc.expected {
+			t.Errorf("test case [%d]: expected %t comparing %v and %v, got %t", idx, tc.expected, tc.a, tc.b, actual)
+		}
+	}
+}
+
+func TestResourceListLessThanOrEqual(t *testing.T) {
+	cases := []struct {
+		a                v1.ResourceList
+		b                v1.ResourceList
+		expected         bool
+		expectedExceeded []v1.ResourceName
+	}{
+		{a: nil, b: nil, expected: true},
+		{
+			a:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")},
+			b:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+			expected: true,
+		},
+		{
+			a:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1000m")},
+			b:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+			expected: true,
+		},
+		{
+			a:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("0")},
+			b:        v1.ResourceList{},
+			expected: true,
+		},
+		{
+			a:                v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+			b:                v1.ResourceList{},
+			expected:         false,
+			expectedExceeded: []v1.ResourceName{v1.ResourceCPU},
+		},
+		{
+			a:                v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi"), v1.ResourceCPU: resource.MustParse("2")},
+			b:                v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi"), v1.ResourceCPU: resource.MustParse("1")},
+			expected:         false,
+			expectedExceeded: []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory},
+		},
+	}
+	for idx, tc := range cases {
+		actual, exceeded := ResourceListLessThanOrEqual(tc.a, tc.b)
+		if actual != tc.expected {
+			t.Errorf("test case [%d]: expected %t comparing %v and %v, got %t", idx, tc.expected, tc.a, tc.b, actual)
+		}
+		if !reflect.DeepEqual(exceeded, tc.expectedExceeded) {
+			t.Errorf("test case [%d]: expected exceeded resources %v, got %v", idx, tc.expectedExceeded, exceeded)
+		}
+	}
+}
@@ -26,6 +26,13 @@ import (
 
 const (
 	defaultLoadBalancerSourceRanges = "0.0.0.0/0"
+
+	// AnnotationRetainStaticIP is set by the service controller on the
+	// service copy passed to EnsureLoadBalancerDeleted when the user
+	// pre-allocated a static IP via spec.loadBalancerIP and did not allow
+	// its release. Cloud providers must not release the address when this
+	// annotation is present.
+	AnnotationRetainStaticIP = "alpha.service-controller.kubernetes.io/retain-static-ip"
 )
 
 // IsAllowAll checks whether the netsets.IPNet allows traffic from 0.0.0.0/0
@@ -84,6 +91,12 @@ func NeedsHealthCheck(service *v1.Service) bool {
 	return RequestsOnlyLocalTraffic(service)
 }
 
+// RetainStaticIP reports whether the service controller marked the service's
+// pre-allocated static IP to be kept when its load balancer is deleted.
+func RetainStaticIP(service *v1.Service) bool {
+	return service.Annotations[AnnotationRetainStaticIP] == "true"
+}
+
 // GetServiceHealthCheckPathPort returns the path and nodePort programmed into the Cloud LB Health Check
 func GetServiceHealthCheckPathPort(service *v1.Service) (string, int32) {
 	if !NeedsHealthCheck(service) {
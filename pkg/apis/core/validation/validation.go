@@ -2111,7 +2111,37 @@ func validateContainerResourceFieldSelector(fs *core.ResourceFieldSelector, expr
 	} else if fsResourceIsEphemeralStorage(fs.Resource) && !utilfeature.DefaultFeatureGate.Enabled(features.LocalStorageCapacityIsolation) {
 		allErrs = append(allErrs, field.Forbidden(fldPath, "Containers' ephemeral storage requests/limits disabled by feature-gate for Downward API"))
 	}
-	allErrs = append(allErrs, validateContainerResourceDivisor(fs.Resource, fs.Divisor, fldPath)...)
+	divisorErrs := validateContainerResourceDivisor(fs.Resource, fs.Divisor, fldPath)
+	if len(divisorErrs) == 0 {
+		// Backstop for resources that reach the expression whitelist without a
+		// matching divisor whitelist: reject divisors that make no sense for
+		// the resource type (e.g. a sub-byte divisor for memory).
+		divisorErrs = validateContainerResourceDivisorSanity(fs.Resource, fs.Divisor, fldPath)
+	}
+	allErrs = append(allErrs, divisorErrs...)
+	return allErrs
+}
+
+// validateContainerResourceDivisorSanity rejects divisors that make no sense
+// for the resource type regardless of the per-resource whitelists: CPU is
+// measured in millicores and the byte-measured resources in whole bytes. An
+// unset divisor is valid and defaults to 1.
+func validateContainerResourceDivisorSanity(rName string, divisor resource.Quantity, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	unsetDivisor := resource.Quantity{}
+	if unsetDivisor.Cmp(divisor) == 0 {
+		return allErrs
+	}
+	switch rName {
+	case "limits.cpu", "requests.cpu":
+		if divisor.MilliValue() < 1 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("divisor"), divisor.String(), "divisor must be at least 1m for cpu resources"))
+		}
+	case "limits.memory", "requests.memory", "limits.ephemeral-storage", "requests.ephemeral-storage":
+		if divisor.Value() < 1 || divisor.MilliValue()%1000 != 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("divisor"), divisor.String(), "divisor must be a whole number of bytes"))
+		}
+	}
 	return allErrs
 }
 
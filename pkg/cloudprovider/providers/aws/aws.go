@@ -38,6 +38,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elbv2"
@@ -274,6 +275,7 @@ type Services interface {
 	Autoscaling(region string) (ASG, error)
 	Metadata() (EC2Metadata, error)
 	KeyManagement(region string) (KMS, error)
+	Monitoring(region string) (CW, error)
 }
 
 // EC2 is an abstraction over AWS', to allow mocking/other implementations
@@ -294,6 +296,11 @@ type EC2 interface {
 	// Delete an EBS volume
 	DeleteVolume(*ec2.DeleteVolumeInput) (*ec2.DeleteVolumeOutput, error)
 
+	// Create a snapshot of an EBS volume
+	CreateSnapshot(request *ec2.CreateSnapshotInput) (*ec2.Snapshot, error)
+	// Delete an EBS snapshot
+	DeleteSnapshot(request *ec2.DeleteSnapshotInput) (*ec2.DeleteSnapshotOutput, error)
+
 	ModifyVolume(*ec2.ModifyVolumeInput) (*ec2.ModifyVolumeOutput, error)
 
 	DescribeVolumeModifications(*ec2.DescribeVolumesModificationsInput) ([]*ec2.VolumeModification, error)
@@ -391,6 +398,12 @@ type KMS interface {
 	DescribeKey(*kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error)
 }
 
+// CW is a simple pass-through of the CloudWatch client interface, which
+// allows for testing.
+type CW interface {
+	GetMetricStatistics(input *cloudwatch.GetMetricStatisticsInput) (*cloudwatch.GetMetricStatisticsOutput, error)
+}
+
 // EC2Metadata is an abstraction over the AWS metadata service.
 type EC2Metadata interface {
 	// Query the EC2 metadata service (used to discover instance-id etc)
@@ -429,6 +442,8 @@ type VolumeOptions struct {
 	// fully qualified resource name to the key to use for encryption.
 	// example: arn:aws:kms:us-east-1:012345678910:key/abcd1234-a123-456a-a12b-a123b4cd56ef
 	KmsKeyID string
+	// SnapshotID is the EBS snapshot to restore the new volume from, if set.
+	SnapshotID string
 }
 
 // Volumes is an interface for managing cloud-provisioned volumes
@@ -489,6 +504,7 @@ type Cloud struct {
 	elbv2    ELBV2
 	asg      ASG
 	kms      KMS
+	cw       CW
 	metadata EC2Metadata
 	cfg      *CloudConfig
 	region   string
@@ -515,6 +531,15 @@ type Cloud struct {
 
 	// state of our device allocator for each node
 	deviceAllocators map[types.NodeName]DeviceAllocator
+
+	// awsServices is retained to build additional regional clients, e.g. for
+	// volumes referenced by ARN that live in another region.
+	awsServices Services
+
+	// EC2 clients for volumes owned by other accounts, keyed by
+	// region/accountID. Populated lazily by computeForVolumeARN.
+	crossAccountMutex   sync.Mutex
+	crossAccountClients map[string]EC2
 }
 
 var _ Volumes = &Cloud{}
@@ -538,6 +563,13 @@ type CloudConfig struct {
 		// RoleARN is the IAM role to assume when interaction with AWS APIs.
 		RoleARN string
 
+		// CrossAccountRole is the name of an IAM role to assume in the owning
+		// account of an EBS volume that is referenced by its full ARN and is
+		// shared from another account (e.g. via Resource Access Manager).
+		// Attach, detach and resize calls for such volumes are made with the
+		// assumed role arn:aws:iam::<owningAccount>:role/<CrossAccountRole>.
+		CrossAccountRole string
+
 		// KubernetesClusterTag is the legacy cluster id we'll use to identify our cluster resources
 		KubernetesClusterTag string
 		// KubernetesClusterID is the cluster id we'll use to identify our cluster resources
@@ -746,6 +778,24 @@ func (p *awsSDKProvider) KeyManagement(regionName string) (KMS, error) {
 	return kmsClient, nil
 }
 
+func (p *awsSDKProvider) Monitoring(regionName string) (CW, error) {
+	awsConfig := &aws.Config{
+		Region:      &regionName,
+		Credentials: p.creds,
+	}
+	awsConfig = awsConfig.WithCredentialsChainVerboseErrors(true)
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize AWS session: %v", err)
+	}
+	client := cloudwatch.New(sess)
+
+	p.addHandlers(regionName, &client.Handlers)
+
+	return client, nil
+}
+
 func newEc2Filter(name string, values ...string) *ec2.Filter {
 	filter := &ec2.Filter{
 		Name: aws.String(name),
@@ -862,6 +912,22 @@ func (s *awsSdkEC2) DeleteVolume(request *ec2.DeleteVolumeInput) (*ec2.DeleteVol
 	return resp, err
 }
 
+func (s *awsSdkEC2) CreateSnapshot(request *ec2.CreateSnapshotInput) (*ec2.Snapshot, error) {
+	requestTime := time.Now()
+	resp, err := s.ec2.CreateSnapshot(request)
+	timeTaken := time.Since(requestTime).Seconds()
+	recordAWSMetric("create_snapshot", timeTaken, err)
+	return resp, err
+}
+
+func (s *awsSdkEC2) DeleteSnapshot(request *ec2.DeleteSnapshotInput) (*ec2.DeleteSnapshotOutput, error) {
+	requestTime := time.Now()
+	resp, err := s.ec2.DeleteSnapshot(request)
+	timeTaken := time.Since(requestTime).Seconds()
+	recordAWSMetric("delete_snapshot", timeTaken, err)
+	return resp, err
+}
+
 func (s *awsSdkEC2) ModifyVolume(request *ec2.ModifyVolumeInput) (*ec2.ModifyVolumeOutput, error) {
 	requestTime := time.Now()
 	resp, err := s.ec2.ModifyVolume(request)
@@ -1100,6 +1166,11 @@ func newAWSCloud(cfg CloudConfig, awsServices Services) (*Cloud, error) {
 		return nil, fmt.Errorf("error creating AWS key management client: %v", err)
 	}
 
+	cw, err := awsServices.Monitoring(regionName)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AWS CloudWatch client: %v", err)
+	}
+
 	awsCloud := &Cloud{
 		ec2:      ec2,
 		elb:      elb,
@@ -1107,11 +1178,15 @@ func newAWSCloud(cfg CloudConfig, awsServices Services) (*Cloud, error) {
 		asg:      asg,
 		metadata: metadata,
 		kms:      kms,
+		cw:       cw,
 		cfg:      &cfg,
 		region:   regionName,
 
 		attaching:        make(map[types.NodeName]map[mountDevice]EBSVolumeID),
 		deviceAllocators: make(map[types.NodeName]DeviceAllocator),
+
+		awsServices:         awsServices,
+		crossAccountClients: make(map[string]EC2),
 	}
 	awsCloud.instanceCache.cloud = awsCloud
 
@@ -1708,6 +1783,13 @@ type awsDisk struct {
 }
 
 func newAWSDisk(aws *Cloud, name KubernetesVolumeID) (*awsDisk, error) {
+	if region, accountID, awsID, ok := name.parseARN(); ok {
+		ec2Client, err := aws.computeForVolumeARN(region, accountID)
+		if err != nil {
+			return nil, err
+		}
+		return &awsDisk{ec2: ec2Client, name: name, awsID: awsID}, nil
+	}
 	awsID, err := name.MapToAWSVolumeID()
 	if err != nil {
 		return nil, err
@@ -1716,6 +1798,50 @@ func newAWSDisk(aws *Cloud, name KubernetesVolumeID) (*awsDisk, error) {
 	return disk, nil
 }
 
+// computeForVolumeARN returns an EC2 client suitable for operating on a
+// volume that was referenced by its full ARN. When a cross-account role is
+// configured it is assumed in the volume's owning account; otherwise the
+// provider's own credentials are used, which requires that the share grants
+// them access directly.
+func (c *Cloud) computeForVolumeARN(region string, accountID string) (EC2, error) {
+	if c.cfg.Global.CrossAccountRole == "" {
+		if region == c.region {
+			return c.ec2, nil
+		}
+		return c.awsServices.Compute(region)
+	}
+
+	key := region + "/" + accountID
+	c.crossAccountMutex.Lock()
+	defer c.crossAccountMutex.Unlock()
+	if client, found := c.crossAccountClients[key]; found {
+		return client, nil
+	}
+
+	roleARN := fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, c.cfg.Global.CrossAccountRole)
+	glog.V(2).Infof("Assuming role %s for EBS volumes owned by account %s", roleARN, accountID)
+	sess, err := session.NewSession(&aws.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize AWS session: %v", err)
+	}
+	creds := credentials.NewCredentials(&stscreds.AssumeRoleProvider{
+		Client:  sts.New(sess),
+		RoleARN: roleARN,
+	})
+	awsConfig := &aws.Config{
+		Region:      &region,
+		Credentials: creds,
+	}
+	awsConfig = awsConfig.WithCredentialsChainVerboseErrors(true)
+	sess, err = session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize AWS session for role %s: %v", roleARN, err)
+	}
+	client := &awsSdkEC2{ec2: ec2.New(sess)}
+	c.crossAccountClients[key] = client
+	return client, nil
+}
+
 // Helper function for describeVolume callers. Tries to retype given error to AWS error
 // and returns true in case the AWS error is "InvalidVolume.NotFound", false otherwise
 func isAWSErrorVolumeNotFound(err error) bool {
@@ -2035,7 +2161,7 @@ func (c *Cloud) AttachDisk(diskName KubernetesVolumeID, nodeName types.NodeName)
 			VolumeId:   disk.awsID.awsString(),
 		}
 
-		attachResponse, err := c.ec2.AttachVolume(request)
+		attachResponse, err := disk.ec2.AttachVolume(request)
 		if err != nil {
 			attachEnded = true
 			// TODO: Check if the volume was concurrently attached?
@@ -2115,7 +2241,7 @@ func (c *Cloud) DetachDisk(diskName KubernetesVolumeID, nodeName types.NodeName)
 		VolumeId:   diskInfo.disk.awsID.awsString(),
 	}
 
-	response, err := c.ec2.DetachVolume(&request)
+	response, err := diskInfo.disk.ec2.DetachVolume(&request)
 	if err != nil {
 		return "", fmt.Errorf("error detaching EBS volume %q from %q: %q", diskInfo.disk.awsID, awsInstance.awsID, err)
 	}
@@ -2190,6 +2316,9 @@ func (c *Cloud) CreateDisk(volumeOptions *VolumeOptions) (KubernetesVolumeID, er
 	if iops > 0 {
 		request.Iops = aws.Int64(iops)
 	}
+	if len(volumeOptions.SnapshotID) > 0 {
+		request.SnapshotId = aws.String(volumeOptions.SnapshotID)
+	}
 	response, err := c.ec2.CreateVolume(request)
 	if err != nil {
 		return "", err
@@ -2280,6 +2409,46 @@ func (c *Cloud) DeleteDisk(volumeName KubernetesVolumeID) (bool, error) {
 	return awsDisk.deleteVolume()
 }
 
+// CreateSnapshot creates an EBS snapshot of the given volume and returns the
+// snapshot ID. The snapshot is created asynchronously by AWS; restoring a
+// volume from it is valid as soon as the ID exists.
+func (c *Cloud) CreateSnapshot(volumeName KubernetesVolumeID, description string) (string, error) {
+	awsDisk, err := newAWSDisk(c, volumeName)
+	if err != nil {
+		return "", err
+	}
+
+	request := &ec2.CreateSnapshotInput{
+		VolumeId:    awsDisk.awsID.awsString(),
+		Description: aws.String(description),
+	}
+	// The disk's client accounts for ARN-referenced volumes living in
+	// another account or region.
+	response, err := awsDisk.ec2.CreateSnapshot(request)
+	if err != nil {
+		return "", fmt.Errorf("error creating snapshot of EBS volume %q: %q", volumeName, err)
+	}
+	snapshotID := aws.StringValue(response.SnapshotId)
+	if snapshotID == "" {
+		return "", fmt.Errorf("SnapshotId was not returned by CreateSnapshot for EBS volume %q", volumeName)
+	}
+	return snapshotID, nil
+}
+
+// DeleteSnapshot deletes the EBS snapshot with the given ID. A bare snapshot
+// ID carries no account or region information, so deletion only works for
+// snapshots in the provider's own account and region; snapshots of
+// ARN-referenced cross-account volumes must be deleted by their owner.
+func (c *Cloud) DeleteSnapshot(snapshotID string) error {
+	request := &ec2.DeleteSnapshotInput{
+		SnapshotId: aws.String(snapshotID),
+	}
+	if _, err := c.ec2.DeleteSnapshot(request); err != nil {
+		return fmt.Errorf("error deleting EBS snapshot %q: %q", snapshotID, err)
+	}
+	return nil
+}
+
 func (c *Cloud) checkIfAvailable(disk *awsDisk, opName string, instance string) (bool, error) {
 	info, err := disk.describeVolume()
 
@@ -2363,6 +2532,71 @@ func (c *Cloud) GetVolumeLabels(volumeName KubernetesVolumeID) (map[string]strin
 	return labels, nil
 }
 
+// VolumeCloudWatchStats holds CloudWatch-sourced EBS metrics for a volume.
+// Fields are nil when CloudWatch had no recent datapoint for the metric.
+type VolumeCloudWatchStats struct {
+	// BurstBalance is the remaining gp2/st1/sc1 burst credit balance, in
+	// percent.
+	BurstBalance *float64
+	// QueueLength is the average number of pending I/O requests.
+	QueueLength *float64
+}
+
+// DescribeVolumeCloudWatchStats returns the most recent BurstBalance and
+// VolumeQueueLength datapoints CloudWatch has for the volume.
+func (c *Cloud) DescribeVolumeCloudWatchStats(volumeName KubernetesVolumeID) (VolumeCloudWatchStats, error) {
+	stats := VolumeCloudWatchStats{}
+	awsID, err := volumeName.MapToAWSVolumeID()
+	if err != nil {
+		return stats, err
+	}
+	stats.BurstBalance, err = c.latestVolumeMetric(awsID, "BurstBalance")
+	if err != nil {
+		return stats, err
+	}
+	stats.QueueLength, err = c.latestVolumeMetric(awsID, "VolumeQueueLength")
+	if err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// latestVolumeMetric returns the newest average datapoint CloudWatch has for
+// the given AWS/EBS metric of the volume, or nil if there is none.
+func (c *Cloud) latestVolumeMetric(volumeID EBSVolumeID, metricName string) (*float64, error) {
+	now := time.Now()
+	request := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/EBS"),
+		MetricName: aws.String(metricName),
+		Dimensions: []*cloudwatch.Dimension{
+			{Name: aws.String("VolumeId"), Value: aws.String(string(volumeID))},
+		},
+		// EBS volume metrics are emitted every 5 minutes; look a little
+		// further back so a delayed datapoint is still found.
+		StartTime:  aws.Time(now.Add(-15 * time.Minute)),
+		EndTime:    aws.Time(now),
+		Period:     aws.Int64(300),
+		Statistics: []*string{aws.String("Average")},
+	}
+	output, err := c.cw.GetMetricStatistics(request)
+	if err != nil {
+		return nil, fmt.Errorf("error querying CloudWatch metric %s for volume %s: %q", metricName, volumeID, err)
+	}
+	var latest *cloudwatch.Datapoint
+	for _, datapoint := range output.Datapoints {
+		if datapoint.Timestamp == nil {
+			continue
+		}
+		if latest == nil || datapoint.Timestamp.After(*latest.Timestamp) {
+			latest = datapoint
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	return latest.Average, nil
+}
+
 // GetDiskPath implements Volumes.GetDiskPath
 func (c *Cloud) GetDiskPath(volumeName KubernetesVolumeID) (string, error) {
 	awsDisk, err := newAWSDisk(c, volumeName)
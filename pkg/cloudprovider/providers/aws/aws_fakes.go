@@ -21,6 +21,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elbv2"
@@ -44,6 +45,7 @@ type FakeAWSServices struct {
 	asg      *FakeASG
 	metadata *FakeMetadata
 	kms      *FakeKMS
+	cw       *FakeCloudWatch
 }
 
 // NewFakeAWSServices creates a new FakeAWSServices
@@ -56,6 +58,7 @@ func NewFakeAWSServices(clusterID string) *FakeAWSServices {
 	s.asg = &FakeASG{aws: s}
 	s.metadata = &FakeMetadata{aws: s}
 	s.kms = &FakeKMS{aws: s}
+	s.cw = &FakeCloudWatch{aws: s}
 
 	s.networkInterfacesMacs = []string{"aa:bb:cc:dd:ee:00", "aa:bb:cc:dd:ee:01"}
 	s.networkInterfacesVpcIDs = []string{"vpc-mac0", "vpc-mac1"}
@@ -118,6 +121,11 @@ func (s *FakeAWSServices) KeyManagement(region string) (KMS, error) {
 	return s.kms, nil
 }
 
+// Monitoring returns a fake CloudWatch client
+func (s *FakeAWSServices) Monitoring(region string) (CW, error) {
+	return s.cw, nil
+}
+
 // FakeEC2 is a fake EC2 client used for testing
 type FakeEC2 interface {
 	EC2
@@ -200,6 +208,16 @@ func (ec2i *FakeEC2Impl) DeleteVolume(request *ec2.DeleteVolumeInput) (resp *ec2
 	panic("Not implemented")
 }
 
+// CreateSnapshot is not implemented but is required for interface conformance
+func (ec2i *FakeEC2Impl) CreateSnapshot(request *ec2.CreateSnapshotInput) (*ec2.Snapshot, error) {
+	panic("Not implemented")
+}
+
+// DeleteSnapshot is not implemented but is required for interface conformance
+func (ec2i *FakeEC2Impl) DeleteSnapshot(request *ec2.DeleteSnapshotInput) (*ec2.DeleteSnapshotOutput, error) {
+	panic("Not implemented")
+}
+
 // DescribeSecurityGroups is not implemented but is required for interface
 // conformance
 func (ec2i *FakeEC2Impl) DescribeSecurityGroups(request *ec2.DescribeSecurityGroupsInput) ([]*ec2.SecurityGroup, error) {
@@ -625,6 +643,16 @@ func (kms *FakeKMS) DescribeKey(*kms.DescribeKeyInput) (*kms.DescribeKeyOutput,
 	panic("Not implemented")
 }
 
+// FakeCloudWatch is a fake CloudWatch client used for testing
+type FakeCloudWatch struct {
+	aws *FakeAWSServices
+}
+
+// GetMetricStatistics returns an empty result set
+func (cw *FakeCloudWatch) GetMetricStatistics(input *cloudwatch.GetMetricStatisticsInput) (*cloudwatch.GetMetricStatisticsOutput, error) {
+	return &cloudwatch.GetMetricStatisticsOutput{}, nil
+}
+
 func instanceMatchesFilter(instance *ec2.Instance, filter *ec2.Filter) bool {
 	name := *filter.Name
 	if name == "private-dns-name" {
@@ -31,6 +31,12 @@ import (
 // awsVolumeRegMatch represents Regex Match for AWS volume.
 var awsVolumeRegMatch = regexp.MustCompile("^vol-[^/]*$")
 
+// awsVolumeARNRegMatch matches a full EBS volume ARN, e.g.
+// arn:aws:ec2:us-east-1:123456789012:volume/vol-12345678abcdef01.
+// Volumes shared across accounts (e.g. via Resource Access Manager) are
+// referenced this way so the owning account and region are known.
+var awsVolumeARNRegMatch = regexp.MustCompile(`^arn:aws[\w-]*:ec2:([a-z0-9-]+):([0-9]+):volume/(vol-[^/]*)$`)
+
 // EBSVolumeID represents the ID of the volume in the AWS API, e.g.
 // vol-12345678 The "traditional" format is "vol-12345678" A new longer format
 // is also being introduced: "vol-12345678abcdef01" We should not assume
@@ -47,8 +53,19 @@ func (i EBSVolumeID) awsString() *string {
 //  * aws://<zone>/<awsVolumeId>
 //  * aws:///<awsVolumeId>
 //  * <awsVolumeId>
+//  * arn:aws:ec2:<region>:<accountID>:volume/<awsVolumeId>
 type KubernetesVolumeID string
 
+// parseARN splits a full EBS volume ARN into the region, the owning account
+// and the volume ID. ok is false when the name is not an ARN.
+func (name KubernetesVolumeID) parseARN() (region string, accountID string, volumeID EBSVolumeID, ok bool) {
+	matches := awsVolumeARNRegMatch.FindStringSubmatch(string(name))
+	if matches == nil {
+		return "", "", "", false
+	}
+	return matches[1], matches[2], EBSVolumeID(matches[3]), true
+}
+
 // DiskInfo returns aws disk information in easy to use manner
 type diskInfo struct {
 	ec2Instance     *ec2.Instance
@@ -73,6 +90,11 @@ func (name KubernetesVolumeID) MapToAWSVolumeID() (EBSVolumeID, error) {
 
 	s := string(name)
 
+	// A full volume ARN carries the volume ID in its resource part.
+	if _, _, awsID, ok := name.parseARN(); ok {
+		return awsID, nil
+	}
+
 	if !strings.HasPrefix(s, "aws://") {
 		// Assume a bare aws volume id (vol-1234...)
 		// Build a URL with an empty host (AZ)
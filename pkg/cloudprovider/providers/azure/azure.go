@@ -54,6 +54,10 @@ const (
 	backoffExponentDefault       = 1.5
 	backoffDurationDefault       = 5 // in seconds
 	backoffJitterDefault         = 1.0
+	diskBackoffStepsDefault      = 20
+	diskBackoffExponentDefault   = 1.5
+	diskBackoffDurationDefault   = 2 // in seconds
+	diskBackoffJitterDefault     = 0.5
 	maximumLoadBalancerRuleCount = 148 // According to Azure LB rule default limit
 
 	vmTypeVMSS     = "vmss"
@@ -118,6 +122,14 @@ type Config struct {
 	CloudProviderBackoffDuration int `json:"cloudProviderBackoffDuration" yaml:"cloudProviderBackoffDuration"`
 	// Backoff jitter
 	CloudProviderBackoffJitter float64 `json:"cloudProviderBackoffJitter" yaml:"cloudProviderBackoffJitter"`
+	// Disk operation poll limit (number of polls while waiting for a disk provisioning operation)
+	CloudProviderDiskBackoffSteps int `json:"cloudProviderDiskBackoffSteps" yaml:"cloudProviderDiskBackoffSteps"`
+	// Disk operation poll exponent
+	CloudProviderDiskBackoffExponent float64 `json:"cloudProviderDiskBackoffExponent" yaml:"cloudProviderDiskBackoffExponent"`
+	// Disk operation poll duration (seconds)
+	CloudProviderDiskBackoffDuration int `json:"cloudProviderDiskBackoffDuration" yaml:"cloudProviderDiskBackoffDuration"`
+	// Disk operation poll jitter
+	CloudProviderDiskBackoffJitter float64 `json:"cloudProviderDiskBackoffJitter" yaml:"cloudProviderDiskBackoffJitter"`
 	// Enable rate limiting
 	CloudProviderRateLimit bool `json:"cloudProviderRateLimit" yaml:"cloudProviderRateLimit"`
 	// Rate limit QPS (Read)
@@ -141,6 +153,31 @@ type Config struct {
 
 	// Maximum allowed LoadBalancer Rule Count is the limit enforced by Azure Load balancer
 	MaximumLoadBalancerRuleCount int `json:"maximumLoadBalancerRuleCount" yaml:"maximumLoadBalancerRuleCount"`
+
+	// DiskReaperEnabled enables a periodic reaper that finds managed disks
+	// provisioned by the Azure disk provisioner which are no longer referenced
+	// by any PersistentVolume. Disabled by default.
+	DiskReaperEnabled bool `json:"diskReaperEnabled" yaml:"diskReaperEnabled"`
+	// DiskReaperDryRun makes the disk reaper only report orphaned disks
+	// instead of deleting them.
+	// If not set, it will be default to true.
+	DiskReaperDryRun *bool `json:"diskReaperDryRun" yaml:"diskReaperDryRun"`
+	// DiskReaperTTLInMinutes is the minimum age of an unreferenced disk before
+	// the reaper considers it an orphan.
+	// If not set, it will be default to 60.
+	DiskReaperTTLInMinutes int `json:"diskReaperTTLInMinutes" yaml:"diskReaperTTLInMinutes"`
+	// DiskReaperIntervalInMinutes is the period between reaper scans.
+	// If not set, it will be default to 30.
+	DiskReaperIntervalInMinutes int `json:"diskReaperIntervalInMinutes" yaml:"diskReaperIntervalInMinutes"`
+	// DiskReaperResourceGroups is the list of resource groups the reaper
+	// scans. If empty, the cluster resource group is scanned.
+	DiskReaperResourceGroups []string `json:"diskReaperResourceGroups" yaml:"diskReaperResourceGroups"`
+
+	// BlobDiskMigrationEnabled enables the helper that converts blob-based
+	// (unmanaged) disks referenced by PersistentVolumes into managed disks.
+	// Disabled by default so that clusters can opt into migrating volumes
+	// gradually.
+	BlobDiskMigrationEnabled bool `json:"blobDiskMigrationEnabled" yaml:"blobDiskMigrationEnabled"`
 }
 
 // Cloud holds the config and clients
@@ -159,6 +196,7 @@ type Cloud struct {
 	DisksClient             DisksClient
 	FileClient              FileClient
 	resourceRequestBackoff  wait.Backoff
+	diskOperationBackoff    wait.Backoff
 	metadata                *InstanceMetadataService
 	vmSet                   VMSet
 
@@ -190,10 +228,11 @@ type Cloud struct {
 	eventBroadcaster record.EventBroadcaster
 	eventRecorder    record.EventRecorder
 
-	vmCache  *timedCache
-	lbCache  *timedCache
-	nsgCache *timedCache
-	rtCache  *timedCache
+	vmCache         *timedCache
+	lbCache         *timedCache
+	nsgCache        *timedCache
+	rtCache         *timedCache
+	diskLabelsCache *timedCache
 
 	*BlobDiskController
 	*ManagedDiskController
@@ -328,6 +367,33 @@ func NewCloud(configReader io.Reader) (cloudprovider.Interface, error) {
 			az.CloudProviderBackoffJitter)
 	}
 
+	// Configure the backoff used to poll disk provisioning operations. Jitter
+	// defaults on so large provisioning bursts do not poll the ARM API in
+	// lockstep.
+	if az.CloudProviderDiskBackoffSteps == 0 {
+		az.CloudProviderDiskBackoffSteps = diskBackoffStepsDefault
+	}
+	if az.CloudProviderDiskBackoffExponent == 0 {
+		az.CloudProviderDiskBackoffExponent = diskBackoffExponentDefault
+	}
+	if az.CloudProviderDiskBackoffDuration == 0 {
+		az.CloudProviderDiskBackoffDuration = diskBackoffDurationDefault
+	}
+	if az.CloudProviderDiskBackoffJitter == 0 {
+		az.CloudProviderDiskBackoffJitter = diskBackoffJitterDefault
+	}
+	az.diskOperationBackoff = wait.Backoff{
+		Steps:    az.CloudProviderDiskBackoffSteps,
+		Factor:   az.CloudProviderDiskBackoffExponent,
+		Duration: time.Duration(az.CloudProviderDiskBackoffDuration) * time.Second,
+		Jitter:   az.CloudProviderDiskBackoffJitter,
+	}
+	glog.V(2).Infof("Azure cloudprovider using disk operation backoff: steps=%d, exponent=%f, duration=%d, jitter=%f",
+		az.CloudProviderDiskBackoffSteps,
+		az.CloudProviderDiskBackoffExponent,
+		az.CloudProviderDiskBackoffDuration,
+		az.CloudProviderDiskBackoffJitter)
+
 	az.metadata, err = NewInstanceMetadataService(metadataURL)
 	if err != nil {
 		return nil, err
@@ -366,6 +432,11 @@ func NewCloud(configReader io.Reader) (cloudprovider.Interface, error) {
 		return nil, err
 	}
 
+	az.diskLabelsCache, err = az.newDiskLabelsCache()
+	if err != nil {
+		return nil, err
+	}
+
 	if err := initDiskControllers(&az); err != nil {
 		return nil, err
 	}
@@ -398,6 +469,10 @@ func (az *Cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 	az.eventBroadcaster = record.NewBroadcaster()
 	az.eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: az.kubeClient.CoreV1().Events("")})
 	az.eventRecorder = az.eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "azure-cloud-provider"})
+
+	if az.DiskReaperEnabled {
+		go az.runDiskReaper(stop)
+	}
 }
 
 // LoadBalancer returns a balancer interface. Also returns true if the interface is supported, false otherwise.
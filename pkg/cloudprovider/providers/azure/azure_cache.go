@@ -116,6 +116,14 @@ func (t *timedCache) Get(key string) (interface{}, error) {
 	return entry.data, nil
 }
 
+// Set adds (or overwrites) an item in the cache without invoking the getter.
+func (t *timedCache) Set(key string, data interface{}) {
+	t.store.Add(&cacheEntry{
+		key:  key,
+		data: data,
+	})
+}
+
 // Delete removes an item from the cache.
 func (t *timedCache) Delete(key string) error {
 	return t.store.Delete(&cacheEntry{
@@ -129,6 +129,7 @@ type DisksClient interface {
 	CreateOrUpdate(ctx context.Context, resourceGroupName string, diskName string, diskParameter compute.Disk) (resp *http.Response, err error)
 	Delete(ctx context.Context, resourceGroupName string, diskName string) (resp *http.Response, err error)
 	Get(ctx context.Context, resourceGroupName string, diskName string) (result compute.Disk, err error)
+	ListByResourceGroup(ctx context.Context, resourceGroupName string) (result []compute.Disk, err error)
 }
 
 // VirtualMachineSizesClient defines needed functions for azure compute.VirtualMachineSizesClient
@@ -1333,6 +1334,36 @@ func (az *azDisksClient) Get(ctx context.Context, resourceGroupName string, disk
 	return
 }
 
+func (az *azDisksClient) ListByResourceGroup(ctx context.Context, resourceGroupName string) (result []compute.Disk, err error) {
+	if !az.rateLimiterReader.TryAccept() {
+		err = createRateLimitErr(false, "DiskList")
+		return
+	}
+
+	glog.V(10).Infof("azDisksClient.ListByResourceGroup(%q): start", resourceGroupName)
+	defer func() {
+		glog.V(10).Infof("azDisksClient.ListByResourceGroup(%q): end", resourceGroupName)
+	}()
+
+	mc := newMetricContext("disks", "list_by_resource_group", resourceGroupName, az.client.SubscriptionID)
+	iterator, err := az.client.ListByResourceGroupComplete(ctx, resourceGroupName)
+	mc.Observe(err)
+	if err != nil {
+		return nil, err
+	}
+
+	result = make([]compute.Disk, 0)
+	for ; iterator.NotDone(); err = iterator.Next() {
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, iterator.Value())
+	}
+
+	return result, nil
+}
+
 // azVirtualMachineSizesClient implements VirtualMachineSizesClient.
 type azVirtualMachineSizesClient struct {
 	client            compute.VirtualMachineSizesClient
@@ -18,6 +18,7 @@ package azure
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-04-01/compute"
@@ -38,6 +39,10 @@ const (
 
 	maxLUN               = 64 // max number of LUNs per VM
 	errLeaseFailed       = "AcquireDiskLeaseFailed"
+	// WriteAcceleratorEnabled is the disk tag the provisioner sets when the
+	// StorageClass requests Azure Write Accelerator; the attach path mirrors
+	// it into the VM's data disk settings.
+	WriteAcceleratorEnabled = "writeacceleratorenabled"
 	errLeaseIDMissing    = "LeaseIdMissing"
 	errContainerNotFound = "ContainerNotFound"
 	errDiskBlobNotFound  = "DiskBlobNotFound"
@@ -50,6 +55,16 @@ var defaultBackOff = kwait.Backoff{
 	Jitter:   0.0,
 }
 
+// diskOpBackoff returns the poll backoff for disk provisioning operations,
+// falling back to defaultBackOff when the cloud config did not set one
+// (e.g. when a Cloud is built directly rather than through NewCloud).
+func (c *controllerCommon) diskOpBackoff() kwait.Backoff {
+	if c.cloud.diskOperationBackoff.Steps > 0 {
+		return c.cloud.diskOperationBackoff
+	}
+	return defaultBackOff
+}
+
 type controllerCommon struct {
 	subscriptionID        string
 	location              string
@@ -92,7 +107,27 @@ func (c *controllerCommon) AttachDisk(isManagedDisk bool, diskName, diskURI stri
 		return err
 	}
 
-	return vmset.AttachDisk(isManagedDisk, diskName, diskURI, nodeName, lun, cachingMode)
+	// Managed disks provisioned with the writeacceleratorenabled tag get the
+	// corresponding VM data disk setting, so latency-sensitive workloads can
+	// use Azure Write Accelerator on supported VM sizes. The lookup failing
+	// only disables the accelerator; it does not fail the attach.
+	writeAcceleratorEnabled := false
+	if isManagedDisk {
+		if resourceGroup, err := getResourceGroupFromDiskURI(diskURI); err != nil {
+			glog.Warningf("azureDisk - cannot parse resource group from disk URI %s: %v", diskURI, err)
+		} else {
+			ctx, cancel := getContextWithCancel()
+			disk, err := c.cloud.DisksClient.Get(ctx, resourceGroup, diskName)
+			cancel()
+			if err != nil {
+				glog.Warningf("azureDisk - failed to get disk %s to read its write accelerator tag: %v", diskName, err)
+			} else if v, ok := disk.Tags[WriteAcceleratorEnabled]; ok && v != nil && strings.EqualFold(*v, "true") {
+				writeAcceleratorEnabled = true
+			}
+		}
+	}
+
+	return vmset.AttachDisk(isManagedDisk, diskName, diskURI, nodeName, lun, cachingMode, writeAcceleratorEnabled)
 }
 
 // DetachDiskByName detaches a vhd from host. The vhd can be identified by diskName or diskURI.
@@ -157,6 +192,37 @@ func (c *controllerCommon) GetNextDiskLun(nodeName types.NodeName) (int32, error
 	return -1, fmt.Errorf("all luns are used")
 }
 
+// GetNextDiskLuns returns up to count unused luns on the host in ascending
+// order, listing the vhd attachments only once. Returns an error if no lun is
+// free at all; fewer than count luns is not an error.
+func (c *controllerCommon) GetNextDiskLuns(nodeName types.NodeName, count int) ([]int32, error) {
+	disks, err := c.getNodeDataDisks(nodeName)
+	if err != nil {
+		glog.Errorf("error of getting data disks for node %q: %v", nodeName, err)
+		return nil, err
+	}
+
+	used := make([]bool, maxLUN)
+	for _, disk := range disks {
+		if disk.Lun != nil {
+			used[*disk.Lun] = true
+		}
+	}
+	luns := make([]int32, 0, count)
+	for k, v := range used {
+		if !v {
+			luns = append(luns, int32(k))
+			if len(luns) == count {
+				break
+			}
+		}
+	}
+	if len(luns) == 0 {
+		return nil, fmt.Errorf("all luns are used")
+	}
+	return luns, nil
+}
+
 // DisksAreAttached checks if a list of volumes are attached to the node with the specified NodeName.
 func (c *controllerCommon) DisksAreAttached(diskNames []string, nodeName types.NodeName) (map[string]bool, error) {
 	attached := make(map[string]bool)
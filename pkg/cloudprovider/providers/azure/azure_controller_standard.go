@@ -28,7 +28,7 @@ import (
 
 // AttachDisk attaches a vhd to vm
 // the vhd must exist, can be identified by diskName, diskURI, and lun.
-func (as *availabilitySet) AttachDisk(isManagedDisk bool, diskName, diskURI string, nodeName types.NodeName, lun int32, cachingMode compute.CachingTypes) error {
+func (as *availabilitySet) AttachDisk(isManagedDisk bool, diskName, diskURI string, nodeName types.NodeName, lun int32, cachingMode compute.CachingTypes, writeAcceleratorEnabled bool) error {
 	vm, err := as.getVirtualMachine(nodeName)
 	if err != nil {
 		return err
@@ -42,16 +42,21 @@ func (as *availabilitySet) AttachDisk(isManagedDisk bool, diskName, diskURI stri
 
 	disks := *vm.StorageProfile.DataDisks
 	if isManagedDisk {
-		disks = append(disks,
-			compute.DataDisk{
-				Name:         &diskName,
-				Lun:          &lun,
-				Caching:      cachingMode,
-				CreateOption: "attach",
-				ManagedDisk: &compute.ManagedDiskParameters{
-					ID: &diskURI,
-				},
-			})
+		newDisk := compute.DataDisk{
+			Name:         &diskName,
+			Lun:          &lun,
+			Caching:      cachingMode,
+			CreateOption: "attach",
+			ManagedDisk: &compute.ManagedDiskParameters{
+				ID: &diskURI,
+			},
+		}
+		// Write Accelerator is only valid for managed disks on supported
+		// (M-series) VM sizes; ARM rejects the update elsewhere.
+		if writeAcceleratorEnabled {
+			newDisk.WriteAcceleratorEnabled = &writeAcceleratorEnabled
+		}
+		disks = append(disks, newDisk)
 	} else {
 		disks = append(disks,
 			compute.DataDisk{
@@ -28,7 +28,7 @@ import (
 
 // AttachDisk attaches a vhd to vm
 // the vhd must exist, can be identified by diskName, diskURI, and lun.
-func (ss *scaleSet) AttachDisk(isManagedDisk bool, diskName, diskURI string, nodeName types.NodeName, lun int32, cachingMode compute.CachingTypes) error {
+func (ss *scaleSet) AttachDisk(isManagedDisk bool, diskName, diskURI string, nodeName types.NodeName, lun int32, cachingMode compute.CachingTypes, writeAcceleratorEnabled bool) error {
 	vmName := mapNodeNameToVMName(nodeName)
 	ssName, instanceID, vm, err := ss.getVmssVM(vmName)
 	if err != nil {
@@ -45,16 +45,21 @@ func (ss *scaleSet) AttachDisk(isManagedDisk bool, diskName, diskURI string, nod
 		disks = *vm.StorageProfile.DataDisks
 	}
 	if isManagedDisk {
-		disks = append(disks,
-			compute.DataDisk{
-				Name:         &diskName,
-				Lun:          &lun,
-				Caching:      compute.CachingTypes(cachingMode),
-				CreateOption: "attach",
-				ManagedDisk: &compute.ManagedDiskParameters{
-					ID: &diskURI,
-				},
-			})
+		newDisk := compute.DataDisk{
+			Name:         &diskName,
+			Lun:          &lun,
+			Caching:      compute.CachingTypes(cachingMode),
+			CreateOption: "attach",
+			ManagedDisk: &compute.ManagedDiskParameters{
+				ID: &diskURI,
+			},
+		}
+		// Write Accelerator is only valid for managed disks on supported
+		// (M-series) VM sizes; ARM rejects the update elsewhere.
+		if writeAcceleratorEnabled {
+			newDisk.WriteAcceleratorEnabled = &writeAcceleratorEnabled
+		}
+		disks = append(disks, newDisk)
 	} else {
 		disks = append(disks,
 			compute.DataDisk{
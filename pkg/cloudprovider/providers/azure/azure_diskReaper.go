@@ -0,0 +1,170 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-04-01/compute"
+	"github.com/golang/glog"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// diskCreatedByTag and diskCreatedByTagValue mark managed disks that were
+	// provisioned by the Azure disk provisioner. The reaper never touches
+	// disks without this tag.
+	diskCreatedByTag      = "created-by"
+	diskCreatedByTagValue = "kubernetes-azure-dd"
+
+	// diskReaperDefaultTTL is the minimum age of an unreferenced disk before
+	// the reaper considers it an orphan. It leaves in-flight provisioning
+	// enough time to create the PersistentVolume for a freshly created disk.
+	diskReaperDefaultTTL = time.Hour
+
+	// diskReaperDefaultInterval is the default period between reaper scans.
+	diskReaperDefaultInterval = 30 * time.Minute
+)
+
+// runDiskReaper periodically scans the configured resource groups for managed
+// disks that were provisioned by the Azure disk provisioner but are no longer
+// referenced by any PersistentVolume. Such orphans are left behind when
+// provisioning fails after the disk was created, or when a deletion request
+// never reached ARM. Orphans older than the configured TTL are deleted, or
+// only reported when the reaper runs in dry-run mode.
+func (az *Cloud) runDiskReaper(stop <-chan struct{}) {
+	glog.V(2).Infof("azureDisk - starting orphaned disk reaper (interval: %v, ttl: %v, dryRun: %v)",
+		az.diskReaperInterval(), az.diskReaperTTL(), az.diskReaperDryRun())
+	wait.Until(func() {
+		if err := az.reapOrphanedDisks(); err != nil {
+			glog.Errorf("azureDisk - orphaned disk reaper: %v", err)
+		}
+	}, az.diskReaperInterval(), stop)
+}
+
+// reapOrphanedDisks performs a single reaper scan.
+func (az *Cloud) reapOrphanedDisks() error {
+	ctx, cancel := getContextWithCancel()
+	defer cancel()
+
+	referenced, err := az.referencedDiskURIs()
+	if err != nil {
+		return err
+	}
+
+	resourceGroups := az.DiskReaperResourceGroups
+	if len(resourceGroups) == 0 {
+		resourceGroups = []string{az.ResourceGroup}
+	}
+
+	for _, resourceGroup := range resourceGroups {
+		disks, err := az.DisksClient.ListByResourceGroup(ctx, resourceGroup)
+		if err != nil {
+			return err
+		}
+
+		for _, disk := range disks {
+			if !az.isOrphanedDisk(disk, referenced) {
+				continue
+			}
+
+			if az.diskReaperDryRun() {
+				glog.Warningf("azureDisk - orphaned disk reaper: disk %q is not referenced by any PersistentVolume, not deleting it (dry-run)", *disk.ID)
+				continue
+			}
+
+			glog.V(2).Infof("azureDisk - orphaned disk reaper: deleting disk %q, it is not referenced by any PersistentVolume", *disk.ID)
+			if err := az.DeleteManagedDisk(ctx, *disk.ID); err != nil {
+				glog.Errorf("azureDisk - orphaned disk reaper: failed to delete disk %q: %v", *disk.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isOrphanedDisk reports whether the disk was provisioned by the Azure disk
+// provisioner, is detached, is older than the reaper TTL and is not
+// referenced by any of the given PersistentVolume disk URIs.
+func (az *Cloud) isOrphanedDisk(disk compute.Disk, referenced sets.String) bool {
+	if disk.ID == nil || disk.Name == nil {
+		return false
+	}
+	if disk.Tags == nil {
+		return false
+	}
+	if createdBy := disk.Tags[diskCreatedByTag]; createdBy == nil || *createdBy != diskCreatedByTagValue {
+		return false
+	}
+	// An attached disk is in use no matter what the API server knows about it.
+	if disk.ManagedBy != nil {
+		return false
+	}
+	// Without a creation timestamp the TTL cannot be honored, so err on the
+	// side of keeping the disk.
+	if disk.DiskProperties == nil || disk.TimeCreated == nil {
+		return false
+	}
+	if time.Since(disk.TimeCreated.Time) < az.diskReaperTTL() {
+		return false
+	}
+
+	return !referenced.Has(strings.ToLower(*disk.ID))
+}
+
+// referencedDiskURIs returns the lower-cased disk URIs of all Azure disk
+// PersistentVolumes known to the API server.
+func (az *Cloud) referencedDiskURIs() (sets.String, error) {
+	pvList, err := az.kubeClient.CoreV1().PersistentVolumes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := sets.NewString()
+	for i := range pvList.Items {
+		if azureDisk := pvList.Items[i].Spec.AzureDisk; azureDisk != nil {
+			referenced.Insert(strings.ToLower(azureDisk.DataDiskURI))
+		}
+	}
+
+	return referenced, nil
+}
+
+func (az *Cloud) diskReaperDryRun() bool {
+	if az.DiskReaperDryRun == nil {
+		return true
+	}
+	return *az.DiskReaperDryRun
+}
+
+func (az *Cloud) diskReaperTTL() time.Duration {
+	if az.DiskReaperTTLInMinutes > 0 {
+		return time.Duration(az.DiskReaperTTLInMinutes) * time.Minute
+	}
+	return diskReaperDefaultTTL
+}
+
+func (az *Cloud) diskReaperInterval() time.Duration {
+	if az.DiskReaperIntervalInMinutes > 0 {
+		return time.Duration(az.DiskReaperIntervalInMinutes) * time.Minute
+	}
+	return diskReaperDefaultInterval
+}
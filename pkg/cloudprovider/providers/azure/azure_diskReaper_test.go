@@ -0,0 +1,130 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-04-01/compute"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testDiskURI(diskName string) string {
+	return fmt.Sprintf("/subscriptions/subscription/resourceGroups/rg/providers/Microsoft.Compute/disks/%s", diskName)
+}
+
+func testReaperDisk(diskName string, tagged bool, age time.Duration, managedBy string) compute.Disk {
+	disk := compute.Disk{
+		ID:   to.StringPtr(testDiskURI(diskName)),
+		Name: to.StringPtr(diskName),
+		Tags: map[string]*string{},
+		DiskProperties: &compute.DiskProperties{
+			TimeCreated: &date.Time{Time: time.Now().Add(-age)},
+		},
+	}
+	if tagged {
+		disk.Tags[diskCreatedByTag] = to.StringPtr(diskCreatedByTagValue)
+	}
+	if managedBy != "" {
+		disk.ManagedBy = to.StringPtr(managedBy)
+	}
+	return disk
+}
+
+func TestReapOrphanedDisks(t *testing.T) {
+	disks := map[string]compute.Disk{
+		"referenced": testReaperDisk("referenced", true, 2*time.Hour, ""),
+		"orphan":     testReaperDisk("orphan", true, 2*time.Hour, ""),
+		"young":      testReaperDisk("young", true, time.Minute, ""),
+		"untagged":   testReaperDisk("untagged", false, 2*time.Hour, ""),
+		"attached":   testReaperDisk("attached", true, 2*time.Hour, "vm"),
+	}
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-referenced"},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				AzureDisk: &v1.AzureDiskVolumeSource{
+					DiskName:    "referenced",
+					DataDiskURI: testDiskURI("referenced"),
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		desc    string
+		dryRun  bool
+		deleted []string
+	}{
+		{
+			desc:    "dry-run mode only reports the orphan",
+			dryRun:  true,
+			deleted: []string{},
+		},
+		{
+			desc:    "only the detached, tagged and expired orphan is deleted",
+			dryRun:  false,
+			deleted: []string{"orphan"},
+		},
+	}
+
+	for _, test := range tests {
+		az := getTestCloud()
+		az.kubeClient = fake.NewSimpleClientset(pv)
+		az.ManagedDiskController = &ManagedDiskController{
+			common: &controllerCommon{
+				resourceGroup: az.ResourceGroup,
+				cloud:         az,
+			},
+		}
+		az.DiskReaperDryRun = to.BoolPtr(test.dryRun)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		for diskName, disk := range disks {
+			if _, err := az.DisksClient.CreateOrUpdate(ctx, az.ResourceGroup, diskName, disk); err != nil {
+				t.Fatalf("Test [%s] unexpected error seeding disk %q: %v", test.desc, diskName, err)
+			}
+		}
+
+		if err := az.reapOrphanedDisks(); err != nil {
+			t.Errorf("Test [%s] unexpected error: %v", test.desc, err)
+		}
+
+		deleted := map[string]bool{}
+		for _, diskName := range test.deleted {
+			deleted[diskName] = true
+		}
+		for diskName := range disks {
+			_, err := az.DisksClient.Get(ctx, az.ResourceGroup, diskName)
+			if deleted[diskName] && err == nil {
+				t.Errorf("Test [%s] expected disk %q to be deleted", test.desc, diskName)
+			}
+			if !deleted[diskName] && err != nil {
+				t.Errorf("Test [%s] expected disk %q to be kept, got: %v", test.desc, diskName, err)
+			}
+		}
+		cancel()
+	}
+}
@@ -709,6 +709,14 @@ func (fFC *fakeFileClient) createFileShare(accountName, accountKey, name string,
 	return nil
 }
 
+func (fFC *fakeFileClient) createFileShareWithMetadata(accountName, accountKey, name string, sizeGiB int, metadata map[string]string) error {
+	return nil
+}
+
+func (fFC *fakeFileClient) createFileShareSnapshot(accountName, accountKey, name string) (string, error) {
+	return "", nil
+}
+
 func (fFC *fakeFileClient) deleteFileShare(accountName, accountKey, name string) error {
 	return nil
 }
@@ -856,6 +864,18 @@ func (fDC *fakeDisksClient) Get(ctx context.Context, resourceGroupName string, d
 	}
 }
 
+func (fDC *fakeDisksClient) ListByResourceGroup(ctx context.Context, resourceGroupName string) (result []compute.Disk, err error) {
+	fDC.mutex.Lock()
+	defer fDC.mutex.Unlock()
+
+	result = make([]compute.Disk, 0)
+	for _, disk := range fDC.FakeStore[resourceGroupName] {
+		result = append(result, disk)
+	}
+
+	return result, nil
+}
+
 type fakeVMSet struct {
 	NodeToIP map[string]string
 	Err      error
@@ -906,7 +926,7 @@ func (f *fakeVMSet) EnsureBackendPoolDeleted(service *v1.Service, poolID, vmSetN
 	return fmt.Errorf("unimplemented")
 }
 
-func (f *fakeVMSet) AttachDisk(isManagedDisk bool, diskName, diskURI string, nodeName types.NodeName, lun int32, cachingMode compute.CachingTypes) error {
+func (f *fakeVMSet) AttachDisk(isManagedDisk bool, diskName, diskURI string, nodeName types.NodeName, lun int32, cachingMode compute.CachingTypes, writeAcceleratorEnabled bool) error {
 	return fmt.Errorf("unimplemented")
 }
 
@@ -32,6 +32,8 @@ const (
 // injection.
 type FileClient interface {
 	createFileShare(accountName, accountKey, name string, sizeGiB int) error
+	createFileShareWithMetadata(accountName, accountKey, name string, sizeGiB int, metadata map[string]string) error
+	createFileShareSnapshot(accountName, accountKey, name string) (string, error)
 	deleteFileShare(accountName, accountKey, name string) error
 	resizeFileShare(accountName, accountKey, name string, sizeGiB int) error
 }
@@ -41,14 +43,36 @@ func (az *Cloud) createFileShare(accountName, accountKey, name string, sizeGiB i
 	return az.FileClient.createFileShare(accountName, accountKey, name, sizeGiB)
 }
 
+func (az *Cloud) createFileShareWithMetadata(accountName, accountKey, name string, sizeGiB int, metadata map[string]string) error {
+	return az.FileClient.createFileShareWithMetadata(accountName, accountKey, name, sizeGiB, metadata)
+}
+
 func (az *Cloud) deleteFileShare(accountName, accountKey, name string) error {
 	return az.FileClient.deleteFileShare(accountName, accountKey, name)
 }
 
+// SnapshotFileShare creates a read-only snapshot of a file share and returns
+// the snapshot DateTime identifying it.
+func (az *Cloud) SnapshotFileShare(accountName, accountKey, name string) (string, error) {
+	return az.FileClient.createFileShareSnapshot(accountName, accountKey, name)
+}
+
 func (az *Cloud) resizeFileShare(accountName, accountKey, name string, sizeGiB int) error {
 	return az.FileClient.resizeFileShare(accountName, accountKey, name, sizeGiB)
 }
 
+// ResizeFileShareWithEndpointSuffix resizes a file share in a storage account
+// that may live in a different subscription or cloud environment than the
+// cluster. An empty endpointSuffix means the cluster's own environment.
+func (az *Cloud) ResizeFileShareWithEndpointSuffix(endpointSuffix, accountName, accountKey, name string, sizeGiB int) error {
+	if endpointSuffix == "" || endpointSuffix == az.Environment.StorageEndpointSuffix {
+		return az.resizeFileShare(accountName, accountKey, name, sizeGiB)
+	}
+	env := az.Environment
+	env.StorageEndpointSuffix = endpointSuffix
+	return (&azureFileClient{env: env}).resizeFileShare(accountName, accountKey, name, sizeGiB)
+}
+
 type azureFileClient struct {
 	env azure.Environment
 }
@@ -66,6 +90,33 @@ func (f *azureFileClient) createFileShare(accountName, accountKey, name string,
 	return nil
 }
 
+func (f *azureFileClient) createFileShareWithMetadata(accountName, accountKey, name string, sizeGiB int, metadata map[string]string) error {
+	fileClient, err := f.getFileSvcClient(accountName, accountKey)
+	if err != nil {
+		return err
+	}
+	share := fileClient.GetShareReference(name)
+	share.Properties.Quota = sizeGiB
+	share.Metadata = metadata
+	if err = share.Create(nil); err != nil {
+		return fmt.Errorf("failed to create file share, err: %v", err)
+	}
+	return nil
+}
+
+func (f *azureFileClient) createFileShareSnapshot(accountName, accountKey, name string) (string, error) {
+	fileClient, err := f.getFileSvcClient(accountName, accountKey)
+	if err != nil {
+		return "", err
+	}
+	snapshotTimestamp, err := fileClient.GetShareReference(name).CreateSnapshot(nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot of file share %s, err: %v", name, err)
+	}
+	glog.V(4).Infof("created snapshot %s of file share %s, accountName: %s", snapshotTimestamp, name, accountName)
+	return snapshotTimestamp, nil
+}
+
 // delete a file share
 func (f *azureFileClient) deleteFileShare(accountName, accountKey, name string) error {
 	fileClient, err := f.getFileSvcClient(accountName, accountKey)
@@ -22,9 +22,11 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-04-01/compute"
 	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2018-07-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/golang/glog"
 
 	"k8s.io/api/core/v1"
@@ -56,14 +58,20 @@ type ManagedDiskOptions struct {
 	Tags map[string]string
 	// The SKU of storage account.
 	StorageAccountType storage.SkuName
+	// The performance tier of the disk (e.g. P30), only valid for premium SSD disks.
+	PerformanceTier string
+	// Enable on-demand bursting of the disk, only valid for premium SSD disks.
+	EnableBursting bool
 }
 
 func newManagedDiskController(common *controllerCommon) (*ManagedDiskController, error) {
 	return &ManagedDiskController{common: common}, nil
 }
 
-//CreateManagedDisk : create managed disk
-func (c *ManagedDiskController) CreateManagedDisk(options *ManagedDiskOptions) (string, error) {
+//CreateManagedDisk : create managed disk. The caller context bounds the ARM
+//calls, so a stuck request fails when the caller's deadline expires instead of
+//pinning the goroutine indefinitely.
+func (c *ManagedDiskController) CreateManagedDisk(ctx context.Context, options *ManagedDiskOptions) (string, error) {
 	var err error
 	glog.V(4).Infof("azureDisk - creating new managed Name:%s StorageAccountType:%s Size:%v", options.DiskName, options.StorageAccountType, options.SizeGB)
 
@@ -75,8 +83,8 @@ func (c *ManagedDiskController) CreateManagedDisk(options *ManagedDiskOptions) (
 
 	// insert original tags to newTags
 	newTags := make(map[string]*string)
-	azureDDTag := "kubernetes-azure-dd"
-	newTags["created-by"] = &azureDDTag
+	azureDDTag := diskCreatedByTagValue
+	newTags[diskCreatedByTag] = &azureDDTag
 	if options.Tags != nil {
 		for k, v := range options.Tags {
 			// Azure won't allow / (forward slash) in tags
@@ -86,7 +94,25 @@ func (c *ManagedDiskController) CreateManagedDisk(options *ManagedDiskOptions) (
 		}
 	}
 
-	diskSizeGB := int32(options.SizeGB)
+	diskProperties := compute.DiskProperties{
+		DiskSizeGB:   to.Int32Ptr(int32(options.SizeGB)),
+		CreationData: &compute.CreationData{CreateOption: compute.Empty},
+	}
+
+	isPremium := strings.HasPrefix(string(options.StorageAccountType), "Premium")
+	if options.PerformanceTier != "" {
+		if !isPremium {
+			return "", fmt.Errorf("performance tier %s is only supported for premium SSD disks, current sku: %s", options.PerformanceTier, options.StorageAccountType)
+		}
+		diskProperties.Tier = to.StringPtr(options.PerformanceTier)
+	}
+	if options.EnableBursting {
+		if !isPremium {
+			return "", fmt.Errorf("disk bursting is only supported for premium SSD disks, current sku: %s", options.StorageAccountType)
+		}
+		diskProperties.BurstingEnabled = to.BoolPtr(true)
+	}
+
 	model := compute.Disk{
 		Location: &c.common.location,
 		Tags:     newTags,
@@ -94,27 +120,32 @@ func (c *ManagedDiskController) CreateManagedDisk(options *ManagedDiskOptions) (
 		Sku: &compute.DiskSku{
 			Name: compute.StorageAccountTypes(options.StorageAccountType),
 		},
-		DiskProperties: &compute.DiskProperties{
-			DiskSizeGB:   &diskSizeGB,
-			CreationData: &compute.CreationData{CreateOption: compute.Empty},
-		},
+		DiskProperties: &diskProperties,
 	}
 
 	if options.ResourceGroup == "" {
 		options.ResourceGroup = c.common.resourceGroup
+	} else if options.ResourceGroup != c.common.resourceGroup {
+		// Fail fast with a clear error when the controller identity has no
+		// access to a resource group configured on a StorageClass, instead of
+		// surfacing a late CreateOrUpdate failure.
+		if err := c.validateResourceGroupAccess(ctx, options.ResourceGroup); err != nil {
+			diskProvisionCount.WithLabelValues(options.ResourceGroup, "failure").Inc()
+			return "", err
+		}
 	}
 
-	ctx, cancel := getContextWithCancel()
-	defer cancel()
 	_, err = c.common.cloud.DisksClient.CreateOrUpdate(ctx, options.ResourceGroup, options.DiskName, model)
 	if err != nil {
+		diskProvisionCount.WithLabelValues(options.ResourceGroup, "failure").Inc()
 		return "", err
 	}
+	diskProvisionCount.WithLabelValues(options.ResourceGroup, "success").Inc()
 
 	diskID := ""
 
-	err = kwait.ExponentialBackoff(defaultBackOff, func() (bool, error) {
-		provisionState, id, err := c.getDisk(options.ResourceGroup, options.DiskName)
+	err = kwait.ExponentialBackoff(c.common.diskOpBackoff(), func() (bool, error) {
+		provisionState, id, err := c.getDisk(ctx, options.ResourceGroup, options.DiskName)
 		diskID = id
 		// We are waiting for provisioningState==Succeeded
 		// We don't want to hand-off managed disks to k8s while they are
@@ -137,17 +168,25 @@ func (c *ManagedDiskController) CreateManagedDisk(options *ManagedDiskOptions) (
 	return diskID, nil
 }
 
+// validateResourceGroupAccess probes a non-default resource group with a
+// cheap read call so that missing role assignments surface as a clear
+// provisioning error. A pass does not guarantee write access, but the common
+// misconfiguration is an identity with no access to the group at all.
+func (c *ManagedDiskController) validateResourceGroupAccess(ctx context.Context, resourceGroup string) error {
+	if _, err := c.common.cloud.DisksClient.ListByResourceGroup(ctx, resourceGroup); err != nil {
+		return fmt.Errorf("cannot provision disks in resource group %q: %v; verify the role assignments of the cluster identity in that resource group", resourceGroup, err)
+	}
+	return nil
+}
+
 //DeleteManagedDisk : delete managed disk
-func (c *ManagedDiskController) DeleteManagedDisk(diskURI string) error {
+func (c *ManagedDiskController) DeleteManagedDisk(ctx context.Context, diskURI string) error {
 	diskName := path.Base(diskURI)
 	resourceGroup, err := getResourceGroupFromDiskURI(diskURI)
 	if err != nil {
 		return err
 	}
 
-	ctx, cancel := getContextWithCancel()
-	defer cancel()
-
 	_, err = c.common.cloud.DisksClient.Delete(ctx, resourceGroup, diskName)
 	if err != nil {
 		return err
@@ -155,16 +194,118 @@ func (c *ManagedDiskController) DeleteManagedDisk(diskURI string) error {
 	// We don't need poll here, k8s will immediately stop referencing the disk
 	// the disk will be eventually deleted - cleanly - by ARM
 
+	if c.common.cloud.diskLabelsCache != nil {
+		c.common.cloud.diskLabelsCache.Delete(diskURI)
+	}
+
 	glog.V(2).Infof("azureDisk - deleted a managed disk: %s", diskURI)
 
 	return nil
 }
 
-// return: disk provisionState, diskID, error
-func (c *ManagedDiskController) getDisk(resourceGroup, diskName string) (string, string, error) {
-	ctx, cancel := getContextWithCancel()
-	defer cancel()
+// MigrateBlobDisk imports an unmanaged VHD blob into a new managed disk and
+// returns the managed disk URI. The source blob is left in place so the
+// caller can roll back until the new disk reference has been persisted.
+func (c *ManagedDiskController) MigrateBlobDisk(ctx context.Context, blobURI string, options *ManagedDiskOptions) (string, error) {
+	glog.V(4).Infof("azureDisk - migrating blob disk %s to managed disk %s", blobURI, options.DiskName)
+
+	newTags := make(map[string]*string)
+	azureDDTag := diskCreatedByTagValue
+	newTags[diskCreatedByTag] = &azureDDTag
+	// Azure won't allow / (forward slash) in tags
+	newTags["migratedFrom"] = to.StringPtr(strings.Replace(blobURI, "/", "-", -1))
 
+	model := compute.Disk{
+		Location: &c.common.location,
+		Tags:     newTags,
+		Sku: &compute.DiskSku{
+			Name: compute.StorageAccountTypes(options.StorageAccountType),
+		},
+		DiskProperties: &compute.DiskProperties{
+			// The disk size is taken from the imported VHD.
+			CreationData: &compute.CreationData{
+				CreateOption: compute.Import,
+				SourceURI:    &blobURI,
+			},
+		},
+	}
+
+	if options.ResourceGroup == "" {
+		options.ResourceGroup = c.common.resourceGroup
+	}
+
+	_, err := c.common.cloud.DisksClient.CreateOrUpdate(ctx, options.ResourceGroup, options.DiskName, model)
+	if err != nil {
+		return "", err
+	}
+
+	diskID := ""
+
+	err = kwait.ExponentialBackoff(c.common.diskOpBackoff(), func() (bool, error) {
+		provisionState, id, err := c.getDisk(ctx, options.ResourceGroup, options.DiskName)
+		diskID = id
+		// The import copies the blob contents, so the disk must not be handed
+		// out before provisioningState==Succeeded.
+		if err != nil {
+			return false, err
+		}
+		if strings.ToLower(provisionState) == "succeeded" {
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not confirm import of blob %s into managed disk %s: %v", blobURI, options.DiskName, err)
+	}
+
+	glog.V(2).Infof("azureDisk - migrated blob disk %s to managed disk %s", blobURI, diskID)
+	return diskID, nil
+}
+
+// MigrateBlobDiskToManagedDisk converts the blob-based (unmanaged) disk
+// referenced by pv into a managed disk and rewrites the PV's volume source to
+// point at the managed copy. It is gated by the blobDiskMigrationEnabled
+// cloud config flag so clusters can opt into migrating volumes gradually.
+// The source blob is not deleted.
+func (c *Cloud) MigrateBlobDiskToManagedDisk(ctx context.Context, pv *v1.PersistentVolume) (*v1.PersistentVolume, error) {
+	if !c.BlobDiskMigrationEnabled {
+		return nil, fmt.Errorf("blob disk migration is disabled, set blobDiskMigrationEnabled in the cloud config to enable it")
+	}
+	azureDisk := pv.Spec.AzureDisk
+	if azureDisk == nil {
+		return nil, fmt.Errorf("PV %s is not an Azure disk volume", pv.Name)
+	}
+	if azureDisk.Kind != nil && *azureDisk.Kind == v1.AzureManagedDisk {
+		return nil, fmt.Errorf("PV %s already references a managed disk", pv.Name)
+	}
+	if c.kubeClient == nil {
+		return nil, fmt.Errorf("no kube client available to update PV %s", pv.Name)
+	}
+
+	diskName := strings.TrimSuffix(azureDisk.DiskName, ".vhd") + "-managed"
+	diskID, err := c.ManagedDiskController.MigrateBlobDisk(ctx, azureDisk.DataDiskURI, &ManagedDiskOptions{
+		DiskName:           diskName,
+		StorageAccountType: storage.StandardLRS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate blob disk %s of PV %s: %v", azureDisk.DataDiskURI, pv.Name, err)
+	}
+
+	kind := v1.AzureManagedDisk
+	newPV := pv.DeepCopy()
+	newPV.Spec.AzureDisk.Kind = &kind
+	newPV.Spec.AzureDisk.DiskName = diskName
+	newPV.Spec.AzureDisk.DataDiskURI = diskID
+	updatedPV, err := c.kubeClient.CoreV1().PersistentVolumes().Update(newPV)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update source of PV %s to managed disk %s: %v", pv.Name, diskID, err)
+	}
+	glog.V(2).Infof("azureDisk - PV %s now references managed disk %s instead of blob %s", pv.Name, diskID, azureDisk.DataDiskURI)
+	return updatedPV, nil
+}
+
+// return: disk provisionState, diskID, error
+func (c *ManagedDiskController) getDisk(ctx context.Context, resourceGroup, diskName string) (string, string, error) {
 	result, err := c.common.cloud.DisksClient.Get(ctx, resourceGroup, diskName)
 	if err != nil {
 		return "", "", err
@@ -178,10 +319,7 @@ func (c *ManagedDiskController) getDisk(resourceGroup, diskName string) (string,
 }
 
 // ResizeDisk Expand the disk to new size
-func (c *ManagedDiskController) ResizeDisk(diskURI string, oldSize resource.Quantity, newSize resource.Quantity) (resource.Quantity, error) {
-	ctx, cancel := getContextWithCancel()
-	defer cancel()
-
+func (c *ManagedDiskController) ResizeDisk(ctx context.Context, diskURI string, oldSize resource.Quantity, newSize resource.Quantity) (resource.Quantity, error) {
 	diskName := path.Base(diskURI)
 	resourceGroup, err := getResourceGroupFromDiskURI(diskURI)
 	if err != nil {
@@ -210,17 +348,76 @@ func (c *ManagedDiskController) ResizeDisk(diskURI string, oldSize resource.Quan
 
 	result.DiskProperties.DiskSizeGB = &requestGiB
 
-	ctx, cancel = getContextWithCancel()
-	defer cancel()
 	if _, err := c.common.cloud.DisksClient.CreateOrUpdate(ctx, resourceGroup, diskName, result); err != nil {
 		return oldSize, err
 	}
 
+	if c.common.cloud.diskLabelsCache != nil {
+		c.common.cloud.diskLabelsCache.Delete(diskURI)
+	}
+
 	glog.V(2).Infof("azureDisk - resize disk(%s) with new size(%d) completed", diskName, requestGiB)
 
 	return newSizeQuant, nil
 }
 
+// premiumDiskCaps maps the maximum size of each premium SSD tier (P4..P50) to
+// the IOPS and bandwidth caps Azure enforces for it, as documented at
+// https://azure.microsoft.com/en-us/pricing/details/managed-disks/.
+var premiumDiskCaps = []struct {
+	maxSizeGB      int32
+	iops           int64
+	throughputMBps int64
+}{
+	{32, 120, 25},
+	{64, 240, 50},
+	{128, 500, 100},
+	{256, 1100, 125},
+	{512, 2300, 150},
+	{1024, 5000, 200},
+	{2048, 7500, 250},
+	{4095, 7500, 250},
+}
+
+// Caps of standard HDD and standard SSD managed disks, which are flat across
+// sizes in the ranges relevant to data disks.
+const (
+	standardDiskMaxIOPS           = 500
+	standardDiskMaxThroughputMBps = 60
+)
+
+// GetDiskPerformanceCaps returns the IOPS and bandwidth (MB/s) caps that Azure
+// enforces for a managed disk, derived from its SKU and size. IO beyond these
+// caps is throttled by the platform, not by the guest.
+func (c *ManagedDiskController) GetDiskPerformanceCaps(ctx context.Context, diskURI string) (int64, int64, error) {
+	diskName := path.Base(diskURI)
+	resourceGroup, err := getResourceGroupFromDiskURI(diskURI)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	disk, err := c.common.cloud.DisksClient.Get(ctx, resourceGroup, diskName)
+	if err != nil {
+		return 0, 0, err
+	}
+	if disk.DiskProperties == nil || disk.DiskProperties.DiskSizeGB == nil {
+		return 0, 0, fmt.Errorf("DiskProperties of disk(%s) is nil", diskName)
+	}
+
+	if disk.Sku != nil && strings.HasPrefix(string(disk.Sku.Name), "Premium") {
+		sizeGB := *disk.DiskProperties.DiskSizeGB
+		for _, tier := range premiumDiskCaps {
+			if sizeGB <= tier.maxSizeGB {
+				return tier.iops, tier.throughputMBps, nil
+			}
+		}
+		largest := premiumDiskCaps[len(premiumDiskCaps)-1]
+		return largest.iops, largest.throughputMBps, nil
+	}
+
+	return standardDiskMaxIOPS, standardDiskMaxThroughputMBps, nil
+}
+
 // get resource group name from a managed disk URI, e.g. return {group-name} according to
 // /subscriptions/{sub-id}/resourcegroups/{group-name}/providers/microsoft.compute/disks/{disk-id}
 // according to https://docs.microsoft.com/en-us/rest/api/compute/disks/get
@@ -247,8 +444,33 @@ func (c *Cloud) GetLabelsForVolume(ctx context.Context, pv *v1.PersistentVolume)
 	return c.GetAzureDiskLabels(pv.Spec.AzureDisk.DataDiskURI)
 }
 
-// GetAzureDiskLabels gets availability zone labels for Azuredisk.
+// diskLabelsCacheTTL bounds how long zone labels of a disk are cached. The
+// zone of a disk never changes, so the TTL mainly limits memory of deleted
+// disks.
+const diskLabelsCacheTTL = 30 * time.Minute
+
+// GetAzureDiskLabels gets availability zone labels for Azuredisk. Results are
+// cached by disk URI, so PV syncs in clusters with thousands of disks do not
+// hammer the Disks.Get API.
 func (c *Cloud) GetAzureDiskLabels(diskURI string) (map[string]string, error) {
+	if c.diskLabelsCache == nil {
+		return c.getAzureDiskLabels(diskURI)
+	}
+	cached, err := c.diskLabelsCache.Get(diskURI)
+	if err != nil {
+		return nil, err
+	}
+	labels := cached.(map[string]string)
+	if len(labels) == 0 {
+		// Not-zoned disks are cached as an empty map.
+		return nil, nil
+	}
+	return labels, nil
+}
+
+// getAzureDiskLabels reads the zone labels of a disk from the API, bypassing
+// the cache.
+func (c *Cloud) getAzureDiskLabels(diskURI string) (map[string]string, error) {
 	// Get disk's resource group.
 	diskName := path.Base(diskURI)
 	resourceGroup, err := getResourceGroupFromDiskURI(diskURI)
@@ -266,6 +488,11 @@ func (c *Cloud) GetAzureDiskLabels(diskURI string) (map[string]string, error) {
 		return nil, err
 	}
 
+	return c.diskLabels(disk, diskName)
+}
+
+// diskLabels derives the zone labels from an already fetched disk.
+func (c *Cloud) diskLabels(disk compute.Disk, diskName string) (map[string]string, error) {
 	// Check whether availability zone is specified.
 	if disk.Zones == nil || len(*disk.Zones) == 0 {
 		glog.V(4).Infof("Azure disk %q is not zoned", diskName)
@@ -286,3 +513,54 @@ func (c *Cloud) GetAzureDiskLabels(diskURI string) (map[string]string, error) {
 	}
 	return labels, nil
 }
+
+// newDiskLabelsCache builds the disk URI keyed cache of zone labels.
+func (az *Cloud) newDiskLabelsCache() (*timedCache, error) {
+	getter := func(key string) (interface{}, error) {
+		labels, err := az.getAzureDiskLabels(key)
+		if err != nil {
+			return nil, err
+		}
+		if labels == nil {
+			// timedCache refetches entries with nil data, so cache
+			// not-zoned disks as an empty map.
+			labels = map[string]string{}
+		}
+		return labels, nil
+	}
+
+	return newTimedcache(diskLabelsCacheTTL, getter)
+}
+
+// WarmDiskLabelsCache primes the disk labels cache with a single paged list
+// call per resource group, so controller start does not issue one Disks.Get
+// per PV.
+func (c *Cloud) WarmDiskLabelsCache() error {
+	if c.diskLabelsCache == nil {
+		return nil
+	}
+
+	ctx, cancel := getContextWithCancel()
+	defer cancel()
+	disks, err := c.DisksClient.ListByResourceGroup(ctx, c.ResourceGroup)
+	if err != nil {
+		return fmt.Errorf("failed to list disks in resource group %s: %v", c.ResourceGroup, err)
+	}
+
+	for _, disk := range disks {
+		if disk.ID == nil || disk.Name == nil {
+			continue
+		}
+		labels, err := c.diskLabels(disk, *disk.Name)
+		if err != nil {
+			glog.Warningf("Skipping disk %s during label cache warm-up: %v", *disk.Name, err)
+			continue
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		c.diskLabelsCache.Set(*disk.ID, labels)
+	}
+	glog.V(2).Infof("Warmed disk labels cache with %d disks from resource group %s", len(disks), c.ResourceGroup)
+	return nil
+}
@@ -35,6 +35,11 @@ var (
 	}
 
 	apiMetrics = registerAPIMetrics(metricLabels...)
+
+	// diskProvisionCount counts managed disk provisioning attempts per
+	// resource group so admins can see where disks are landing and which
+	// resource groups are failing.
+	diskProvisionCount = registerDiskProvisionMetric()
 )
 
 type metricContext struct {
@@ -80,3 +85,17 @@ func registerAPIMetrics(attributes ...string) *apiCallMetrics {
 
 	return metrics
 }
+
+func registerDiskProvisionMetric() *prometheus.CounterVec {
+	metric := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cloudprovider_azure_managed_disk_provision_total",
+			Help: "Number of managed disk provisioning attempts, partitioned by resource group and result",
+		},
+		[]string{"resource_group", "result"},
+	)
+
+	prometheus.MustRegister(metric)
+
+	return metric
+}
@@ -34,11 +34,21 @@ const (
 // CreateFileShare creates a file share, using a matching storage account type, account kind, etc.
 // storage account will be created if specified account is not found
 func (az *Cloud) CreateFileShare(shareName, accountName, accountType, accountKind, resourceGroup, location string, requestGiB int) (string, string, error) {
+	return az.createFileShareAccount(shareName, accountName, accountType, accountKind, resourceGroup, location, requestGiB, false)
+}
+
+// CreateFileShareInVnet creates a file share in a newly created storage
+// account whose firewall only allows traffic from the cluster VNET subnet.
+func (az *Cloud) CreateFileShareInVnet(shareName, accountType, accountKind, resourceGroup, location string, requestGiB int) (string, string, error) {
+	return az.createFileShareAccount(shareName, "", accountType, accountKind, resourceGroup, location, requestGiB, true)
+}
+
+func (az *Cloud) createFileShareAccount(shareName, accountName, accountType, accountKind, resourceGroup, location string, requestGiB int, restrictToVnet bool) (string, string, error) {
 	if resourceGroup == "" {
 		resourceGroup = az.resourceGroup
 	}
 
-	account, key, err := az.ensureStorageAccount(accountName, accountType, accountKind, resourceGroup, location, fileShareAccountNamePrefix)
+	account, key, err := az.ensureStorageAccount(accountName, accountType, accountKind, resourceGroup, location, fileShareAccountNamePrefix, restrictToVnet)
 	if err != nil {
 		return "", "", fmt.Errorf("could not get storage key for storage account %s: %v", accountName, err)
 	}
@@ -50,6 +60,18 @@ func (az *Cloud) CreateFileShare(shareName, accountName, accountType, accountKin
 	return account, key, nil
 }
 
+// CreateFileShareInAccount creates a file share in a pre-existing storage
+// account using the given access key. The account itself is never created or
+// modified, so it may be one that is only reachable over a private endpoint.
+// metadata is attached to the new share.
+func (az *Cloud) CreateFileShareInAccount(accountName, accountKey, shareName string, requestGiB int, metadata map[string]string) error {
+	if err := az.createFileShareWithMetadata(accountName, accountKey, shareName, requestGiB, metadata); err != nil {
+		return fmt.Errorf("failed to create share %s in account %s: %v", shareName, accountName, err)
+	}
+	glog.V(4).Infof("created share %s in account %s", shareName, accountName)
+	return nil
+}
+
 // DeleteFileShare deletes a file share using storage account name and key
 func (az *Cloud) DeleteFileShare(accountName, accountKey, shareName string) error {
 	if err := az.deleteFileShare(accountName, accountKey, shareName); err != nil {
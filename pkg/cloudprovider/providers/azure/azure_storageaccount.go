@@ -89,18 +89,25 @@ func (az *Cloud) getStorageAccesskey(account, resourceGroup string) (string, err
 	return "", fmt.Errorf("no valid keys")
 }
 
-// ensureStorageAccount search storage account, create one storage account(with genAccountNamePrefix) if not found, return accountName, accountKey
-func (az *Cloud) ensureStorageAccount(accountName, accountType, accountKind, resourceGroup, location, genAccountNamePrefix string) (string, string, error) {
+// ensureStorageAccount search storage account, create one storage account(with genAccountNamePrefix) if not found, return accountName, accountKey.
+// With restrictToVnet, a new account is always created with a network rule
+// set that denies traffic from outside the cluster subnet; existing accounts
+// are not reused since their network rules are unknown. The cluster subnet
+// must have the Microsoft.Storage service endpoint enabled for the rule to
+// take effect.
+func (az *Cloud) ensureStorageAccount(accountName, accountType, accountKind, resourceGroup, location, genAccountNamePrefix string, restrictToVnet bool) (string, string, error) {
 	if len(accountName) == 0 {
 		// find a storage account that matches accountType
-		accounts, err := az.getStorageAccounts(accountType, accountKind, resourceGroup, location)
-		if err != nil {
-			return "", "", fmt.Errorf("could not list storage accounts for account type %s: %v", accountType, err)
-		}
+		if !restrictToVnet {
+			accounts, err := az.getStorageAccounts(accountType, accountKind, resourceGroup, location)
+			if err != nil {
+				return "", "", fmt.Errorf("could not list storage accounts for account type %s: %v", accountType, err)
+			}
 
-		if len(accounts) > 0 {
-			accountName = accounts[0].Name
-			glog.V(4).Infof("found a matching account %s type %s location %s", accounts[0].Name, accounts[0].StorageType, accounts[0].Location)
+			if len(accounts) > 0 {
+				accountName = accounts[0].Name
+				glog.V(4).Infof("found a matching account %s type %s location %s", accounts[0].Name, accounts[0].StorageType, accounts[0].Location)
+			}
 		}
 
 		if len(accountName) == 0 {
@@ -126,6 +133,17 @@ func (az *Cloud) ensureStorageAccount(accountName, accountType, accountKind, res
 				AccountPropertiesCreateParameters: &storage.AccountPropertiesCreateParameters{EnableHTTPSTrafficOnly: to.BoolPtr(true)},
 				Tags:                              map[string]*string{"created-by": to.StringPtr("azure")},
 				Location:                          &location}
+			if restrictToVnet {
+				cp.AccountPropertiesCreateParameters.NetworkRuleSet = &storage.NetworkRuleSet{
+					DefaultAction: storage.DefaultActionDeny,
+					VirtualNetworkRules: &[]storage.VirtualNetworkRule{
+						{
+							VirtualNetworkResourceID: to.StringPtr(az.vnetSubnetID()),
+							Action:                   storage.Allow,
+						},
+					},
+				}
+			}
 
 			ctx, cancel := getContextWithCancel()
 			defer cancel()
@@ -144,3 +162,13 @@ func (az *Cloud) ensureStorageAccount(accountName, accountType, accountKind, res
 
 	return accountName, accountKey, nil
 }
+
+// vnetSubnetID returns the full resource ID of the cluster subnet.
+func (az *Cloud) vnetSubnetID() string {
+	rg := az.VnetResourceGroup
+	if rg == "" {
+		rg = az.ResourceGroup
+	}
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s/subnets/%s",
+		az.SubscriptionID, rg, az.VnetName, az.SubnetName)
+}
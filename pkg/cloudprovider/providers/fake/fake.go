@@ -45,6 +45,12 @@ type FakeUpdateBalancerCall struct {
 	Hosts   []*v1.Node
 }
 
+// FakeZoneAffinityCall records a call to EnsureLoadBalancerZoneAffinity.
+type FakeZoneAffinityCall struct {
+	Service        *v1.Service
+	ZoneNodeCounts map[string]int
+}
+
 // FakeCloud is a test-double implementation of Interface, LoadBalancer, Instances, and Routes. It is useful for testing.
 type FakeCloud struct {
 	Exists bool
@@ -67,6 +73,8 @@ type FakeCloud struct {
 	ExternalIP    net.IP
 	Balancers     map[string]FakeBalancer
 	UpdateCalls   []FakeUpdateBalancerCall
+	updateCallMux sync.Mutex
+	AffinityCalls []FakeZoneAffinityCall
 	RouteMap      map[string]*FakeRoute
 	Lock          sync.Mutex
 	Provider      string
@@ -189,7 +197,20 @@ func (f *FakeCloud) EnsureLoadBalancer(ctx context.Context, clusterName string,
 // It adds an entry "update" into the internal method call record.
 func (f *FakeCloud) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) error {
 	f.addCall("update")
+	f.updateCallMux.Lock()
 	f.UpdateCalls = append(f.UpdateCalls, FakeUpdateBalancerCall{service, nodes})
+	f.updateCallMux.Unlock()
+	return f.Err
+}
+
+// EnsureLoadBalancerZoneAffinity is a test-spy implementation of
+// ZoneAffinityLoadBalancer.EnsureLoadBalancerZoneAffinity.
+// It adds an entry "zone-affinity" into the internal method call record.
+func (f *FakeCloud) EnsureLoadBalancerZoneAffinity(ctx context.Context, clusterName string, service *v1.Service, zoneNodeCounts map[string]int) error {
+	f.addCall("zone-affinity")
+	f.updateCallMux.Lock()
+	f.AffinityCalls = append(f.AffinityCalls, FakeZoneAffinityCall{service, zoneNodeCounts})
+	f.updateCallMux.Unlock()
 	return f.Err
 }
 
@@ -67,7 +67,29 @@ type diskServiceManager interface {
 		sizeGb int64,
 		tagsStr string,
 		diskType string,
-		zone string) error
+		zone string,
+		multiWriter bool) error
+
+	// Creates a new persistent disk on GCE cloned from the given source disk.
+	CreateClonedDiskOnCloudProvider(
+		name string,
+		sizeGb int64,
+		tagsStr string,
+		diskType string,
+		zone string,
+		sourceDisk *GCEDisk) error
+
+	// Creates a new persistent disk on GCE restored from the given snapshot.
+	CreateSnapshotRestoredDiskOnCloudProvider(
+		name string,
+		sizeGb int64,
+		tagsStr string,
+		diskType string,
+		zone string,
+		snapshot *compute.Snapshot) error
+
+	// Gets the snapshot from GCE with the given snapshotName.
+	GetSnapshotFromCloudProvider(snapshotName string) (*compute.Snapshot, error)
 
 	// Creates a new regional persistent disk on GCE with the given disk spec.
 	CreateRegionalDiskOnCloudProvider(
@@ -117,18 +139,77 @@ func (manager *gceServiceManager) CreateDiskOnCloudProvider(
 	sizeGb int64,
 	tagsStr string,
 	diskType string,
-	zone string) error {
+	zone string,
+	multiWriter bool) error {
+	diskTypeURI, err := manager.getDiskTypeURI(
+		manager.gce.region /* diskRegion */, singleZone{zone}, diskType, false /* useBetaAPI */)
+	if err != nil {
+		return err
+	}
+
+	diskToCreateV1 := &compute.Disk{
+		Name:        name,
+		SizeGb:      sizeGb,
+		Description: tagsStr,
+		Type:        diskTypeURI,
+		MultiWriter: multiWriter,
+	}
+
+	ctx, cancel := cloud.ContextWithCallTimeout()
+	defer cancel()
+	return manager.gce.c.Disks().Insert(ctx, meta.ZonalKey(name, zone), diskToCreateV1)
+}
+
+func (manager *gceServiceManager) CreateClonedDiskOnCloudProvider(
+	name string,
+	sizeGb int64,
+	tagsStr string,
+	diskType string,
+	zone string,
+	sourceDisk *GCEDisk) error {
 	diskTypeURI, err := manager.getDiskTypeURI(
 		manager.gce.region /* diskRegion */, singleZone{zone}, diskType, false /* useBetaAPI */)
 	if err != nil {
 		return err
 	}
 
+	sourceDiskURI, err := manager.getDiskSourceURI(sourceDisk)
+	if err != nil {
+		return err
+	}
+
 	diskToCreateV1 := &compute.Disk{
 		Name:        name,
 		SizeGb:      sizeGb,
 		Description: tagsStr,
 		Type:        diskTypeURI,
+		SourceDisk:  sourceDiskURI,
+	}
+
+	ctx, cancel := cloud.ContextWithCallTimeout()
+	defer cancel()
+	return manager.gce.c.Disks().Insert(ctx, meta.ZonalKey(name, zone), diskToCreateV1)
+}
+
+func (manager *gceServiceManager) CreateSnapshotRestoredDiskOnCloudProvider(
+	name string,
+	sizeGb int64,
+	tagsStr string,
+	diskType string,
+	zone string,
+	snapshot *compute.Snapshot) error {
+	diskTypeURI, err := manager.getDiskTypeURI(
+		manager.gce.region /* diskRegion */, singleZone{zone}, diskType, false /* useBetaAPI */)
+	if err != nil {
+		return err
+	}
+
+	diskToCreateV1 := &compute.Disk{
+		Name:           name,
+		SizeGb:         sizeGb,
+		Description:    tagsStr,
+		Type:           diskTypeURI,
+		SourceSnapshot: snapshot.SelfLink,
 	}
 
 	ctx, cancel := cloud.ContextWithCallTimeout()
@@ -136,6 +217,13 @@ func (manager *gceServiceManager) CreateDiskOnCloudProvider(
 	return manager.gce.c.Disks().Insert(ctx, meta.ZonalKey(name, zone), diskToCreateV1)
 }
 
+func (manager *gceServiceManager) GetSnapshotFromCloudProvider(
+	snapshotName string) (*compute.Snapshot, error) {
+	// Snapshots are global resources and are not covered by the generated
+	// cloud wrappers, so the raw compute service is used here.
+	return manager.gce.service.Snapshots.Get(manager.gce.projectID, snapshotName).Do()
+}
+
 func (manager *gceServiceManager) CreateRegionalDiskOnCloudProvider(
 	name string,
 	sizeGb int64,
@@ -642,6 +730,14 @@ func (gce *GCECloud) DisksAreAttached(diskNames []string, nodeName types.NodeNam
 // JSON in Description field.
 func (gce *GCECloud) CreateDisk(
 	name string, diskType string, zone string, sizeGb int64, tags map[string]string) error {
+	return gce.CreateMultiWriterDisk(name, diskType, zone, sizeGb, tags, false)
+}
+
+// CreateMultiWriterDisk creates a new Persistent Disk like CreateDisk, but
+// allows requesting a multi-writer disk that can be attached read-write to
+// more than one instance. Only some disk types (e.g. pd-ssd) support it.
+func (gce *GCECloud) CreateMultiWriterDisk(
+	name string, diskType string, zone string, sizeGb int64, tags map[string]string, multiWriter bool) error {
 	// Do not allow creation of PDs in zones that are do not have nodes. Such PDs
 	// are not currently usable.
 	curZones, err := gce.GetAllCurrentZones()
@@ -665,7 +761,108 @@ func (gce *GCECloud) CreateDisk(
 	mc := newDiskMetricContextZonal("create", gce.region, zone)
 
 	err = gce.manager.CreateDiskOnCloudProvider(
-		name, sizeGb, tagsStr, diskType, zone)
+		name, sizeGb, tagsStr, diskType, zone, multiWriter)
+
+	mc.Observe(err)
+	if isGCEError(err, "alreadyExists") {
+		glog.Warningf("GCE PD %q already exists, reusing", name)
+		return nil
+	}
+	return err
+}
+
+// CreateClonedDisk creates a new Persistent Disk like CreateDisk, but
+// initialized with the contents of the named source PD. The clone is created
+// in the source disk's zone and must not be smaller than the source.
+func (gce *GCECloud) CreateClonedDisk(
+	name string, diskType string, sizeGb int64, tags map[string]string, sourceDiskName string) error {
+	sourceDisk, err := gce.GetDiskByNameUnknownZone(sourceDiskName)
+	if err != nil {
+		return fmt.Errorf("failed to get source disk %q: %v", sourceDiskName, err)
+	}
+
+	zoneInfo, ok := sourceDisk.ZoneInfo.(singleZone)
+	if !ok || zoneInfo.zone == "" {
+		return fmt.Errorf("source disk %q is not a zonal PD, cloning is not supported", sourceDiskName)
+	}
+	zone := zoneInfo.zone
+
+	if sizeGb < sourceDisk.SizeGb {
+		return fmt.Errorf("requested size %d GB is smaller than source disk %q (%d GB)", sizeGb, sourceDiskName, sourceDisk.SizeGb)
+	}
+
+	// Do not allow creation of PDs in zones that are do not have nodes. Such PDs
+	// are not currently usable.
+	curZones, err := gce.GetAllCurrentZones()
+	if err != nil {
+		return err
+	}
+	if !curZones.Has(zone) {
+		return fmt.Errorf("kubernetes does not have a node in zone %q", zone)
+	}
+
+	tagsStr, err := gce.encodeDiskTags(tags)
+	if err != nil {
+		return err
+	}
+
+	diskType, err = getDiskType(diskType)
+	if err != nil {
+		return err
+	}
+
+	mc := newDiskMetricContextZonal("create", gce.region, zone)
+
+	err = gce.manager.CreateClonedDiskOnCloudProvider(
+		name, sizeGb, tagsStr, diskType, zone, sourceDisk)
+
+	mc.Observe(err)
+	if isGCEError(err, "alreadyExists") {
+		glog.Warningf("GCE PD %q already exists, reusing", name)
+		return nil
+	}
+	return err
+}
+
+// CreateSnapshotRestoredDisk creates a new Persistent Disk like CreateDisk,
+// but initialized with the contents of the named GCE snapshot. The disk must
+// not be smaller than the disk the snapshot was taken from; snapshots are
+// global, so any zone with nodes is acceptable.
+func (gce *GCECloud) CreateSnapshotRestoredDisk(
+	name string, diskType string, zone string, sizeGb int64, tags map[string]string, snapshotName string) error {
+	snapshot, err := gce.manager.GetSnapshotFromCloudProvider(snapshotName)
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot %q: %v", snapshotName, err)
+	}
+
+	if sizeGb < snapshot.DiskSizeGb {
+		return fmt.Errorf("requested size %d GB is smaller than snapshot %q (%d GB)", sizeGb, snapshotName, snapshot.DiskSizeGb)
+	}
+
+	// Do not allow creation of PDs in zones that are do not have nodes. Such PDs
+	// are not currently usable.
+	curZones, err := gce.GetAllCurrentZones()
+	if err != nil {
+		return err
+	}
+	if !curZones.Has(zone) {
+		return fmt.Errorf("kubernetes does not have a node in zone %q", zone)
+	}
+
+	tagsStr, err := gce.encodeDiskTags(tags)
+	if err != nil {
+		return err
+	}
+
+	diskType, err = getDiskType(diskType)
+	if err != nil {
+		return err
+	}
+
+	mc := newDiskMetricContextZonal("create", gce.region, zone)
+
+	err = gce.manager.CreateSnapshotRestoredDiskOnCloudProvider(
+		name, sizeGb, tagsStr, diskType, zone, snapshot)
 
 	mc.Observe(err)
 	if isGCEError(err, "alreadyExists") {
@@ -671,7 +671,8 @@ func (manager *FakeServiceManager) CreateDiskOnCloudProvider(
 	sizeGb int64,
 	tagsStr string,
 	diskType string,
-	zone string) error {
+	zone string,
+	multiWriter bool) error {
 	manager.createDiskCalled = true
 
 	switch t := manager.targetAPI; t {
@@ -713,6 +714,31 @@ func (manager *FakeServiceManager) CreateDiskOnCloudProvider(
 	}
 }
 
+func (manager *FakeServiceManager) CreateClonedDiskOnCloudProvider(
+	name string,
+	sizeGb int64,
+	tagsStr string,
+	diskType string,
+	zone string,
+	sourceDisk *GCEDisk) error {
+	return manager.CreateDiskOnCloudProvider(name, sizeGb, tagsStr, diskType, zone, false)
+}
+
+func (manager *FakeServiceManager) CreateSnapshotRestoredDiskOnCloudProvider(
+	name string,
+	sizeGb int64,
+	tagsStr string,
+	diskType string,
+	zone string,
+	snapshot *compute.Snapshot) error {
+	return manager.CreateDiskOnCloudProvider(name, sizeGb, tagsStr, diskType, zone, false)
+}
+
+func (manager *FakeServiceManager) GetSnapshotFromCloudProvider(
+	snapshotName string) (*compute.Snapshot, error) {
+	return &compute.Snapshot{Name: snapshotName}, nil
+}
+
 /**
  * Upon disk creation, disk info is stored in FakeServiceManager
  * to be used by other tested methods.
@@ -327,6 +327,10 @@ func (gce *GCECloud) ensureExternalLoadBalancerDeleted(clusterName, clusterID st
 		// possible that EnsureLoadBalancer left one around in a failed
 		// creation/update attempt, so make sure we clean it up here just in case.
 		func() error {
+			if apiservice.RetainStaticIP(service) {
+				glog.Infof("ensureExternalLoadBalancerDeleted(%s): Retaining IP address per the %s annotation.", lbRefStr, apiservice.AnnotationRetainStaticIP)
+				return nil
+			}
 			glog.Infof("ensureExternalLoadBalancerDeleted(%s): Deleting IP address.", lbRefStr)
 			return ignoreNotFound(gce.DeleteRegionAddress(loadBalancerName, gce.region))
 		},
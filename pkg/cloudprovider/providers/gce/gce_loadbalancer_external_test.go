@@ -31,6 +31,7 @@ import (
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	apiservice "k8s.io/kubernetes/pkg/api/v1/service"
 	"k8s.io/kubernetes/pkg/cloudprovider/providers/gce/cloud"
 	"k8s.io/kubernetes/pkg/cloudprovider/providers/gce/cloud/meta"
 	"k8s.io/kubernetes/pkg/cloudprovider/providers/gce/cloud/mock"
@@ -383,6 +384,30 @@ func TestEnsureExternalLoadBalancerDeleted(t *testing.T) {
 	assertExternalLbResourcesDeleted(t, gce, svc, vals, true)
 }
 
+func TestEnsureExternalLoadBalancerDeletedRetainsStaticIP(t *testing.T) {
+	t.Parallel()
+
+	vals := DefaultTestClusterValues()
+	gce, err := fakeGCECloud(vals)
+	require.NoError(t, err)
+
+	svc := fakeLoadbalancerService("")
+	svc.Annotations = map[string]string{apiservice.AnnotationRetainStaticIP: "true"}
+	_, err = createExternalLoadBalancer(gce, svc, []string{"test-node-1"}, vals.ClusterName, vals.ClusterID, vals.ZoneName)
+	assert.NoError(t, err)
+
+	lbName := gce.GetLoadBalancerName(context.TODO(), vals.ClusterName, svc)
+	require.NoError(t, gce.ReserveRegionAddress(&compute.Address{Name: lbName, Address: "1.2.3.4"}, gce.region))
+
+	err = gce.ensureExternalLoadBalancerDeleted(vals.ClusterName, vals.ClusterID, svc)
+	assert.NoError(t, err)
+
+	// The address marked for retention must survive the deletion.
+	addr, err := gce.GetRegionAddress(lbName, gce.region)
+	assert.NoError(t, err)
+	assert.NotNil(t, addr)
+}
+
 func TestLoadBalancerWrongTierResourceDeletion(t *testing.T) {
 	t.Parallel()
 
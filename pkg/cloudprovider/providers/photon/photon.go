@@ -15,8 +15,8 @@ limitations under the License.
 */
 
 // This version of Photon cloud provider supports the disk interface
-// for Photon persistent disk volume plugin. LoadBalancer, Routes, and
-// Zones are currently not supported.
+// for Photon persistent disk volume plugin. LoadBalancer and Routes
+// are currently not supported.
 // The use of Photon cloud provider requires to start kubelet, kube-apiserver,
 // and kube-controller-manager with config flag: '--cloud-provider=photon
 // --cloud-config=[path_to_config_file]'. When running multi-node kubernetes
@@ -26,13 +26,18 @@ package photon
 import (
 	"bufio"
 	"context"
-	"errors"
+	"crypto/x509"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"os"
+	"os/exec"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/vmware/photon-controller-go-sdk/photon"
@@ -48,6 +53,16 @@ const (
 	DiskSpecKind = "persistent-disk"
 	MAC_OUI_VC   = "00:50:56"
 	MAC_OUI_ESX  = "00:0c:29"
+	// TTL for cached node-name/IP to VM ID lookups.
+	vmIDCacheTTL = 5 * time.Minute
+	// guestInfoVMIDKey is the guestinfo key under which deployment tooling
+	// can publish the Photon VM ID of this node.
+	guestInfoVMIDKey = "guestinfo.vmid"
+	// ovfEnvKey is the guestinfo key holding the OVF environment document
+	// of the VM.
+	ovfEnvKey = "guestinfo.ovfEnv"
+	// ovfVMIDProperty is the OVF environment property carrying the VM ID.
+	ovfVMIDProperty = "vmid"
 )
 
 // overrideIP indicates if the hostname is overridden by IP address, such as when
@@ -70,6 +85,53 @@ type PCCloud struct {
 	cloudprovider.Zone
 	photonClient *photon.Client
 	logger       *log.Logger
+	// Cache of node-name/IP to VM ID lookups, shared across Instances and Disks operations.
+	vmIDCache *vmIDCache
+	// svc, when set, overrides the SDK-backed photonService; used by unit tests.
+	svc *photonService
+}
+
+type vmIDCacheEntry struct {
+	vmID       string
+	expiration time.Time
+}
+
+// vmIDCache caches the results of getVMIDbyNodename/getVMIDbyIP, which
+// otherwise list every VM in the project on each call. Entries expire after
+// vmIDCacheTTL and are invalidated explicitly when an instance is not found.
+type vmIDCache struct {
+	mutex   sync.Mutex
+	entries map[string]vmIDCacheEntry
+}
+
+func newVMIDCache() *vmIDCache {
+	return &vmIDCache{entries: make(map[string]vmIDCacheEntry)}
+}
+
+func (c *vmIDCache) get(name string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, found := c.entries[name]
+	if !found {
+		return "", false
+	}
+	if time.Now().After(entry.expiration) {
+		delete(c.entries, name)
+		return "", false
+	}
+	return entry.vmID, true
+}
+
+func (c *vmIDCache) set(name string, vmID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[name] = vmIDCacheEntry{vmID: vmID, expiration: time.Now().Add(vmIDCacheTTL)}
+}
+
+func (c *vmIDCache) invalidate(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, name)
 }
 
 type PCConfig struct {
@@ -85,6 +147,17 @@ type PCConfig struct {
 		VMID string `gcfg:"vmID"`
 		// Authentication enabled or not
 		AuthEnabled bool `gcfg:"authentication"`
+		// Path to a file holding the username and password on separate
+		// lines, e.g. a mounted secret. When unset, the default
+		// /etc/kubernetes/pc_login_info is used.
+		LoginInfoPath string `gcfg:"login-info-path"`
+		// Path to a PEM encoded CA bundle used to verify the Photon
+		// Controller endpoint certificate. When unset, the system roots
+		// are used.
+		CACertFile string `gcfg:"ca-file"`
+		// Disable server certificate verification. Insecure; intended only
+		// for development setups and must be opted into explicitly.
+		IgnoreCertificate bool `gcfg:"insecure-flag"`
 	}
 }
 
@@ -144,7 +217,7 @@ func init() {
 
 // Retrieve the Photon VM ID from the Photon Controller endpoint based on the node name
 func getVMIDbyNodename(pc *PCCloud, nodeName string) (string, error) {
-	photonClient, err := getPhotonClient(pc)
+	photonClient, err := pc.service()
 	if err != nil {
 		glog.Errorf("Photon Cloud Provider: Failed to get photon client for getVMIDbyNodename, error: [%v]", err)
 		return "", err
@@ -167,7 +240,7 @@ func getVMIDbyNodename(pc *PCCloud, nodeName string) (string, error) {
 
 // Retrieve the Photon VM ID from the Photon Controller endpoint based on the IP address
 func getVMIDbyIP(pc *PCCloud, IPAddress string) (string, error) {
-	photonClient, err := getPhotonClient(pc)
+	photonClient, err := pc.service()
 	if err != nil {
 		glog.Errorf("failed to get photon client for getVMIDbyNodename, error: [%v]", err)
 		return "", err
@@ -206,36 +279,100 @@ func getVMIDbyIP(pc *PCCloud, IPAddress string) (string, error) {
 	return "", fmt.Errorf("no matching VM is found with IP %s", IPAddress)
 }
 
+// getClientOptions builds photon.ClientOptions from the cloud config. Server
+// certificates are verified against the configured CA bundle, or the system
+// roots when none is given; insecure mode is an explicit opt-in.
+func getClientOptions(pc *PCCloud) (*photon.ClientOptions, error) {
+	options := &photon.ClientOptions{
+		IgnoreCertificate: pc.cfg.Global.IgnoreCertificate,
+	}
+	if len(pc.cfg.Global.CACertFile) > 0 {
+		if options.IgnoreCertificate {
+			return nil, fmt.Errorf("both ca-file and insecure-flag are set in cloud config")
+		}
+		pem, err := ioutil.ReadFile(pc.cfg.Global.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle %s: %v", pc.cfg.Global.CACertFile, err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", pc.cfg.Global.CACertFile)
+		}
+		options.RootCAs = roots
+	}
+	return options, nil
+}
+
+// defaultLoginInfoPath is the legacy location of the credential file, kept as
+// the fallback so existing deployments keep working.
+const defaultLoginInfoPath = "/etc/kubernetes/pc_login_info"
+
+// getCredentials resolves the username and password used to authenticate with
+// the Photon Controller. Sources are tried in priority order: the
+// PHOTON_USERNAME/PHOTON_PASSWORD environment variables, the login-info-path
+// configured in the cloud config (e.g. a mounted secret), and finally the
+// legacy default path. Only the source is logged, never the credentials.
+func getCredentials(pc *PCCloud) (string, string, error) {
+	if username := os.Getenv("PHOTON_USERNAME"); len(username) > 0 {
+		password := os.Getenv("PHOTON_PASSWORD")
+		if len(password) == 0 {
+			return "", "", fmt.Errorf("PHOTON_USERNAME is set but PHOTON_PASSWORD is empty")
+		}
+		glog.V(2).Info("Photon Cloud Provider: using credentials from environment variables")
+		return username, password, nil
+	}
+
+	path := pc.cfg.Global.LoginInfoPath
+	if len(path) == 0 {
+		path = defaultLoginInfoPath
+	}
+	username, password, err := readLoginInfoFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	glog.V(2).Infof("Photon Cloud Provider: using credentials from %s", path)
+	return username, password, nil
+}
+
+// readLoginInfoFile reads a credential file holding the username on the first
+// line and the password on the second.
+func readLoginInfoFile(path string) (string, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("authentication is enabled but no credentials were found in the environment or at %s: %v", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() || len(scanner.Text()) == 0 {
+		return "", "", fmt.Errorf("empty username in %s", path)
+	}
+	username := scanner.Text()
+	if !scanner.Scan() || len(scanner.Text()) == 0 {
+		return "", "", fmt.Errorf("empty password in %s", path)
+	}
+	return username, scanner.Text(), nil
+}
+
 func getPhotonClient(pc *PCCloud) (*photon.Client, error) {
 	var err error
 	if len(pc.cfg.Global.CloudTarget) == 0 {
 		return nil, fmt.Errorf("endpoint was not specified")
 	}
 
-	options := &photon.ClientOptions{
-		IgnoreCertificate: true,
+	options, err := getClientOptions(pc)
+	if err != nil {
+		glog.Errorf("Photon Cloud Provider: invalid TLS configuration. Error[%v]", err)
+		return nil, err
 	}
 
 	pc.photonClient = photon.NewClient(pc.cfg.Global.CloudTarget, options, pc.logger)
 	if pc.cfg.Global.AuthEnabled == true {
-		// work around before metadata is available
-		file, err := os.Open("/etc/kubernetes/pc_login_info")
+		username, password, err := getCredentials(pc)
 		if err != nil {
-			glog.Errorf("Photon Cloud Provider: Authentication is enabled but found no username/password at /etc/kubernetes/pc_login_info. Error[%v]", err)
+			glog.Errorf("Photon Cloud Provider: unable to resolve credentials. Error[%v]", err)
 			return nil, err
 		}
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		if !scanner.Scan() {
-			glog.Error("Photon Cloud Provider: Empty username inside /etc/kubernetes/pc_login_info.")
-			return nil, fmt.Errorf("failed to create authentication enabled client with invalid username")
-		}
-		username := scanner.Text()
-		if !scanner.Scan() {
-			glog.Error("Photon Cloud Provider: Empty password set inside /etc/kubernetes/pc_login_info.")
-			return nil, fmt.Errorf("failed to create authentication enabled client with invalid password")
-		}
-		password := scanner.Text()
 
 		token_options, err := pc.photonClient.Auth.GetTokensByPassword(username, password)
 		if err != nil {
@@ -243,11 +380,8 @@ func getPhotonClient(pc *PCCloud) (*photon.Client, error) {
 			return nil, err
 		}
 
-		options = &photon.ClientOptions{
-			IgnoreCertificate: true,
-			TokenOptions: &photon.TokenOptions{
-				AccessToken: token_options.AccessToken,
-			},
+		options.TokenOptions = &photon.TokenOptions{
+			AccessToken: token_options.AccessToken,
 		}
 		pc.photonClient = photon.NewClient(pc.cfg.Global.CloudTarget, options, pc.logger)
 	}
@@ -262,9 +396,66 @@ func getPhotonClient(pc *PCCloud) (*photon.Client, error) {
 	return pc.photonClient, nil
 }
 
+// guestInfoGet reads a guestinfo key through the VMware guest tools. It is a
+// variable so unit tests can run without the tools installed.
+var guestInfoGet = func(key string) (string, error) {
+	out, err := exec.Command("vmware-rpctool", "info-get "+key).Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s: %v", key, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ovfEnvironment mirrors the part of the OVF environment document needed to
+// extract deployment properties.
+type ovfEnvironment struct {
+	Properties []struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"PropertySection>Property"`
+}
+
+// vmIDFromOVFEnv extracts the vmid property from an OVF environment document.
+func vmIDFromOVFEnv(doc string) (string, error) {
+	var env ovfEnvironment
+	if err := xml.Unmarshal([]byte(doc), &env); err != nil {
+		return "", fmt.Errorf("unable to parse OVF environment: %v", err)
+	}
+	for _, property := range env.Properties {
+		if property.Key == ovfVMIDProperty && property.Value != "" {
+			return property.Value, nil
+		}
+	}
+	return "", fmt.Errorf("no %s property in the OVF environment", ovfVMIDProperty)
+}
+
+// discoverLocalVMID resolves the VM ID of this node from the hypervisor: the
+// guestinfo.vmid key if the deployment stamped one, otherwise the vmid
+// property of the OVF environment. This removes the need to write a per-node
+// vmID into the cloud config.
+func discoverLocalVMID() (string, error) {
+	if id, err := guestInfoGet(guestInfoVMIDKey); err == nil && id != "" {
+		return id, nil
+	}
+	ovfEnv, err := guestInfoGet(ovfEnvKey)
+	if err != nil {
+		return "", err
+	}
+	return vmIDFromOVFEnv(ovfEnv)
+}
+
 func newPCCloud(cfg PCConfig) (*PCCloud, error) {
 	projID := cfg.Global.Project
 	vmID := cfg.Global.VMID
+	if vmID == "" {
+		discoveredID, err := discoverLocalVMID()
+		if err != nil {
+			glog.V(2).Infof("Photon Cloud Provider: no local VM ID discovered, falling back to lookup by node name. Error[%v]", err)
+		} else {
+			glog.V(2).Infof("Photon Cloud Provider: discovered local VM ID %s", discoveredID)
+			vmID = discoveredID
+		}
+	}
 
 	// Get local hostname
 	hostname, err := os.Hostname()
@@ -278,6 +469,7 @@ func newPCCloud(cfg PCConfig) (*PCCloud, error) {
 		localHostname:    hostname,
 		localK8sHostname: "",
 		projID:           projID,
+		vmIDCache:        newVMIDCache(),
 	}
 
 	overrideIP = cfg.Global.OverrideIP
@@ -352,7 +544,7 @@ func (pc *PCCloud) NodeAddresses(ctx context.Context, nodeName k8stypes.NodeName
 		return nodeAddrs, err
 	}
 
-	photonClient, err := getPhotonClient(pc)
+	photonClient, err := pc.service()
 	if err != nil {
 		glog.Errorf("Photon Cloud Provider: Failed to get photon client for NodeAddresses, error: [%v]", err)
 		return nodeAddrs, err
@@ -438,6 +630,10 @@ func getInstanceID(pc *PCCloud, name string) (string, error) {
 	var vmID string
 	var err error
 
+	if vmID, found := pc.vmIDCache.get(name); found {
+		return vmID, nil
+	}
+
 	if overrideIP == true {
 		vmID, err = getVMIDbyIP(pc, name)
 	} else {
@@ -448,10 +644,12 @@ func getInstanceID(pc *PCCloud, name string) (string, error) {
 	}
 
 	if vmID == "" {
-		err = cloudprovider.InstanceNotFound
+		pc.vmIDCache.invalidate(name)
+		return vmID, cloudprovider.InstanceNotFound
 	}
 
-	return vmID, err
+	pc.vmIDCache.set(name, vmID)
+	return vmID, nil
 }
 
 // InstanceExistsByProviderID returns true if the instance with the given provider id still exists and is running.
@@ -503,6 +701,10 @@ func (pc *PCCloud) ProviderName() string {
 }
 
 // LoadBalancer returns an implementation of LoadBalancer for Photon Controller.
+// Photon Controller has no load balancer resource in its public API (the
+// vendored SDK only surfaces the deployment-wide LoadBalancerAddress used for
+// the management plane), so ServiceType=LoadBalancer cannot be supported until
+// the platform grows such an API.
 func (pc *PCCloud) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
 	return nil, false
 }
@@ -513,21 +715,91 @@ func (pc *PCCloud) Zones() (cloudprovider.Zones, bool) {
 }
 
 func (pc *PCCloud) GetZone(ctx context.Context) (cloudprovider.Zone, error) {
+	if len(pc.Zone.FailureDomain) > 0 {
+		return pc.Zone, nil
+	}
+	zone, err := getZoneByVMID(pc, pc.localInstanceID)
+	if err != nil {
+		glog.Errorf("Photon Cloud Provider: getZoneByVMID failed for GetZone with VM ID %s. Error[%v]", pc.localInstanceID, err)
+		return pc.Zone, err
+	}
+	pc.Zone = zone
 	return pc.Zone, nil
 }
 
+// getZoneByVMID resolves the placement of a VM into a cloudprovider.Zone: the
+// VM record names the ESX host it runs on and the host record carries the
+// fault domain (availability zone) it was registered with. When the host has
+// no availability zone configured, its address is used as the failure domain
+// so workloads still spread across hosts.
+func getZoneByVMID(pc *PCCloud, vmID string) (cloudprovider.Zone, error) {
+	zone := cloudprovider.Zone{Region: pc.Region}
+	photonClient, err := pc.service()
+	if err != nil {
+		glog.Errorf("Photon Cloud Provider: Failed to get photon client for getZoneByVMID, error: [%v]", err)
+		return zone, err
+	}
+
+	vm, err := photonClient.VMs.Get(vmID)
+	if err != nil {
+		glog.Errorf("Photon Cloud Provider: Failed to Get VM with ID %s. Error[%v]", vmID, err)
+		return zone, err
+	}
+	if len(vm.Host) == 0 {
+		// The endpoint does not expose the placement of this VM.
+		return zone, nil
+	}
+
+	hostList, err := photonClient.Hosts.GetAll()
+	if err != nil {
+		glog.Errorf("Photon Cloud Provider: Failed to GetAll hosts for getZoneByVMID. Error[%v]", err)
+		return zone, err
+	}
+	for _, host := range hostList.Items {
+		if host.Address == vm.Host || host.ID == vm.Host {
+			if len(host.AvailabilityZone) > 0 {
+				zone.FailureDomain = host.AvailabilityZone
+			} else {
+				zone.FailureDomain = host.Address
+			}
+			break
+		}
+	}
+
+	return zone, nil
+}
+
+// vmIDFromProviderID strips the provider scheme from a node's providerID
+// ("photon://<vm-id>"). IDs recorded without the scheme are accepted as-is.
+func vmIDFromProviderID(providerID string) (string, error) {
+	vmID := strings.TrimPrefix(providerID, ProviderName+"://")
+	if vmID == "" {
+		return "", fmt.Errorf("invalid provider ID %q", providerID)
+	}
+	return vmID, nil
+}
+
 // GetZoneByProviderID implements Zones.GetZoneByProviderID
 // This is particularly useful in external cloud providers where the kubelet
 // does not initialize node data.
 func (pc *PCCloud) GetZoneByProviderID(ctx context.Context, providerID string) (cloudprovider.Zone, error) {
-	return cloudprovider.Zone{}, errors.New("not implemented")
+	vmID, err := vmIDFromProviderID(providerID)
+	if err != nil {
+		return cloudprovider.Zone{}, err
+	}
+	return getZoneByVMID(pc, vmID)
 }
 
 // GetZoneByNodeName implements Zones.GetZoneByNodeName
 // This is particularly useful in external cloud providers where the kubelet
 // does not initialize node data.
 func (pc *PCCloud) GetZoneByNodeName(ctx context.Context, nodeName k8stypes.NodeName) (cloudprovider.Zone, error) {
-	return cloudprovider.Zone{}, errors.New("not imeplemented")
+	vmID, err := getInstanceID(pc, string(nodeName))
+	if err != nil {
+		glog.Errorf("Photon Cloud Provider: getInstanceID failed for GetZoneByNodeName. Error[%v]", err)
+		return cloudprovider.Zone{}, err
+	}
+	return getZoneByVMID(pc, vmID)
 }
 
 // Routes returns a false since the interface is not supported for photon controller.
@@ -542,7 +814,7 @@ func (pc *PCCloud) HasClusterID() bool {
 
 // Attaches given virtual disk volume to the compute running kubelet.
 func (pc *PCCloud) AttachDisk(ctx context.Context, pdID string, nodeName k8stypes.NodeName) error {
-	photonClient, err := getPhotonClient(pc)
+	photonClient, err := pc.service()
 	if err != nil {
 		glog.Errorf("Photon Cloud Provider: Failed to get photon client for AttachDisk, error: [%v]", err)
 		return err
@@ -575,7 +847,7 @@ func (pc *PCCloud) AttachDisk(ctx context.Context, pdID string, nodeName k8stype
 
 // Detaches given virtual disk volume from the compute running kubelet.
 func (pc *PCCloud) DetachDisk(ctx context.Context, pdID string, nodeName k8stypes.NodeName) error {
-	photonClient, err := getPhotonClient(pc)
+	photonClient, err := pc.service()
 	if err != nil {
 		glog.Errorf("Photon Cloud Provider: Failed to get photon client for DetachDisk, error: [%v]", err)
 		return err
@@ -608,7 +880,7 @@ func (pc *PCCloud) DetachDisk(ctx context.Context, pdID string, nodeName k8stype
 
 // DiskIsAttached returns if disk is attached to the VM using controllers supported by the plugin.
 func (pc *PCCloud) DiskIsAttached(ctx context.Context, pdID string, nodeName k8stypes.NodeName) (bool, error) {
-	photonClient, err := getPhotonClient(pc)
+	photonClient, err := pc.service()
 	if err != nil {
 		glog.Errorf("Photon Cloud Provider: Failed to get photon client for DiskIsAttached, error: [%v]", err)
 		return false, err
@@ -622,6 +894,7 @@ func (pc *PCCloud) DiskIsAttached(ctx context.Context, pdID string, nodeName k8s
 
 	vmID, err := pc.InstanceID(ctx, nodeName)
 	if err == cloudprovider.InstanceNotFound {
+		pc.vmIDCache.invalidate(string(nodeName))
 		glog.Infof("Instance %q does not exist, disk %s will be detached automatically.", nodeName, pdID)
 		return false, nil
 	}
@@ -642,7 +915,7 @@ func (pc *PCCloud) DiskIsAttached(ctx context.Context, pdID string, nodeName k8s
 // DisksAreAttached returns if disks are attached to the VM using controllers supported by the plugin.
 func (pc *PCCloud) DisksAreAttached(ctx context.Context, pdIDs []string, nodeName k8stypes.NodeName) (map[string]bool, error) {
 	attached := make(map[string]bool)
-	photonClient, err := getPhotonClient(pc)
+	photonClient, err := pc.service()
 	if err != nil {
 		glog.Errorf("Photon Cloud Provider: Failed to get photon client for DisksAreAttached, error: [%v]", err)
 		return attached, err
@@ -654,6 +927,7 @@ func (pc *PCCloud) DisksAreAttached(ctx context.Context, pdIDs []string, nodeNam
 
 	vmID, err := pc.InstanceID(ctx, nodeName)
 	if err == cloudprovider.InstanceNotFound {
+		pc.vmIDCache.invalidate(string(nodeName))
 		glog.Infof("Instance %q does not exist, its disks will be detached automatically.", nodeName)
 		// make all the disks as detached.
 		return attached, nil
@@ -663,17 +937,43 @@ func (pc *PCCloud) DisksAreAttached(ctx context.Context, pdIDs []string, nodeNam
 		return attached, err
 	}
 
-	for _, pdID := range pdIDs {
-		disk, err := photonClient.Disks.Get(pdID)
-		if err != nil {
-			glog.Warningf("Photon Cloud Provider: failed to get VMs for persistent disk %s, err [%v]", pdID, err)
-		} else {
-			for _, vm := range disk.VMs {
-				if vm == vmID {
-					attached[pdID] = true
+	// List the project's disks once instead of issuing one Disks.Get per
+	// disk; the attach/detach controller calls this periodically for every
+	// node, so the per-disk version does not scale.
+	diskList, err := photonClient.Projects.GetDisks(pc.projID, nil)
+	if err != nil {
+		glog.Warningf("Photon Cloud Provider: Failed to GetDisks for project %s, falling back to per-disk lookup. Error[%v]", pc.projID, err)
+		for _, pdID := range pdIDs {
+			disk, err := photonClient.Disks.Get(pdID)
+			if err != nil {
+				glog.Warningf("Photon Cloud Provider: failed to get VMs for persistent disk %s, err [%v]", pdID, err)
+			} else {
+				for _, vm := range disk.VMs {
+					if vm == vmID {
+						attached[pdID] = true
+					}
 				}
 			}
 		}
+		return attached, nil
+	}
+
+	disksByID := make(map[string]photon.PersistentDisk)
+	for _, disk := range diskList.Items {
+		disksByID[disk.ID] = disk
+	}
+
+	for _, pdID := range pdIDs {
+		disk, found := disksByID[pdID]
+		if !found {
+			glog.Warningf("Photon Cloud Provider: persistent disk %s not found in project %s", pdID, pc.projID)
+			continue
+		}
+		for _, vm := range disk.VMs {
+			if vm == vmID {
+				attached[pdID] = true
+			}
+		}
 	}
 
 	return attached, nil
@@ -681,7 +981,7 @@ func (pc *PCCloud) DisksAreAttached(ctx context.Context, pdIDs []string, nodeNam
 
 // Create a volume of given size (in GB).
 func (pc *PCCloud) CreateDisk(volumeOptions *VolumeOptions) (pdID string, err error) {
-	photonClient, err := getPhotonClient(pc)
+	photonClient, err := pc.service()
 	if err != nil {
 		glog.Errorf("Photon Cloud Provider: Failed to get photon client for CreateDisk, error: [%v]", err)
 		return "", err
@@ -710,7 +1010,7 @@ func (pc *PCCloud) CreateDisk(volumeOptions *VolumeOptions) (pdID string, err er
 
 // Deletes a volume given volume name.
 func (pc *PCCloud) DeleteDisk(pdID string) error {
-	photonClient, err := getPhotonClient(pc)
+	photonClient, err := pc.service()
 	if err != nil {
 		glog.Errorf("Photon Cloud Provider: Failed to get photon client for DeleteDisk, error: [%v]", err)
 		return err
@@ -0,0 +1,88 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package photon
+
+import (
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// The interfaces below describe the part of the photon SDK surface this
+// provider actually calls, so unit tests can substitute fakes for a live
+// Photon Controller endpoint. The SDK API types satisfy them directly.
+
+// projectsClient is the used subset of the SDK Projects API.
+type projectsClient interface {
+	GetVMs(projectID string, options *photon.VmGetOptions) (*photon.VMs, error)
+	GetDisks(projectID string, options *photon.DiskGetOptions) (*photon.DiskList, error)
+	CreateDisk(projectID string, spec *photon.DiskCreateSpec) (*photon.Task, error)
+}
+
+// vmsClient is the used subset of the SDK VMs API.
+type vmsClient interface {
+	Get(id string) (*photon.VM, error)
+	GetNetworks(id string) (*photon.Task, error)
+	AttachDisk(id string, op *photon.VmDiskOperation) (*photon.Task, error)
+	DetachDisk(id string, op *photon.VmDiskOperation) (*photon.Task, error)
+}
+
+// disksClient is the used subset of the SDK Disks API.
+type disksClient interface {
+	Get(diskID string) (*photon.PersistentDisk, error)
+	Delete(diskID string) (*photon.Task, error)
+}
+
+// tasksClient is the used subset of the SDK Tasks API.
+type tasksClient interface {
+	Wait(id string) (*photon.Task, error)
+}
+
+// hostsClient is the used subset of the SDK Hosts API.
+type hostsClient interface {
+	GetAll() (*photon.Hosts, error)
+}
+
+// photonService bundles the SDK clients the provider calls. Production code
+// gets one backed by a real photon.Client from (*PCCloud).service; unit tests
+// inject one built from fakes.
+type photonService struct {
+	Projects projectsClient
+	VMs      vmsClient
+	Disks    disksClient
+	Tasks    tasksClient
+	Hosts    hostsClient
+}
+
+// service returns the photonService to use for endpoint calls, creating a new
+// authenticated SDK client unless a service (e.g. a fake in unit tests) was
+// injected.
+func (pc *PCCloud) service() (*photonService, error) {
+	if pc.svc != nil {
+		return pc.svc, nil
+	}
+
+	client, err := getPhotonClient(pc)
+	if err != nil {
+		return nil, err
+	}
+	return &photonService{
+		Projects: client.Projects,
+		VMs:      client.VMs,
+		Disks:    client.Disks,
+		Tasks:    client.Tasks,
+		Hosts:    client.Hosts,
+	}, nil
+}
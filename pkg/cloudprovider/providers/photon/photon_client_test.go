@@ -0,0 +1,299 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package photon
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vmware/photon-controller-go-sdk/photon"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Fakes for the used SDK surface. A nil err makes each call succeed with the
+// canned data; a non-nil err makes every call of the fake fail with it.
+
+type fakeProjects struct {
+	vms   *photon.VMs
+	disks *photon.DiskList
+	err   error
+}
+
+func (f *fakeProjects) GetVMs(projectID string, options *photon.VmGetOptions) (*photon.VMs, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.vms, nil
+}
+
+func (f *fakeProjects) GetDisks(projectID string, options *photon.DiskGetOptions) (*photon.DiskList, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.disks, nil
+}
+
+func (f *fakeProjects) CreateDisk(projectID string, spec *photon.DiskCreateSpec) (*photon.Task, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &photon.Task{ID: "task-create-" + spec.Name}, nil
+}
+
+type fakeVMs struct {
+	networksTask *photon.Task
+	attached     []string
+	detached     []string
+	err          error
+}
+
+func (f *fakeVMs) Get(id string) (*photon.VM, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &photon.VM{ID: id}, nil
+}
+
+func (f *fakeVMs) GetNetworks(id string) (*photon.Task, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.networksTask, nil
+}
+
+func (f *fakeVMs) AttachDisk(id string, op *photon.VmDiskOperation) (*photon.Task, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.attached = append(f.attached, op.DiskID)
+	return &photon.Task{ID: "task-attach-" + op.DiskID}, nil
+}
+
+func (f *fakeVMs) DetachDisk(id string, op *photon.VmDiskOperation) (*photon.Task, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.detached = append(f.detached, op.DiskID)
+	return &photon.Task{ID: "task-detach-" + op.DiskID}, nil
+}
+
+type fakeDisks struct {
+	disk *photon.PersistentDisk
+	err  error
+}
+
+func (f *fakeDisks) Get(diskID string) (*photon.PersistentDisk, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.disk, nil
+}
+
+func (f *fakeDisks) Delete(diskID string) (*photon.Task, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &photon.Task{ID: "task-delete-" + diskID}, nil
+}
+
+type fakeTasks struct {
+	task *photon.Task
+	err  error
+}
+
+func (f *fakeTasks) Wait(id string) (*photon.Task, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.task != nil {
+		return f.task, nil
+	}
+	return &photon.Task{ID: id, State: "COMPLETED"}, nil
+}
+
+type fakeHosts struct {
+	hosts *photon.Hosts
+	err   error
+}
+
+func (f *fakeHosts) GetAll() (*photon.Hosts, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.hosts, nil
+}
+
+// fakePCCloud returns a PCCloud wired to the given fake service. The local
+// hostname is "local-node", so any other node name exercises the endpoint
+// paths.
+func fakePCCloud(svc *photonService) *PCCloud {
+	return &PCCloud{
+		cfg:              &PCConfig{},
+		localInstanceID:  "local-vm",
+		localHostname:    "local-node",
+		localK8sHostname: "local-node",
+		projID:           "test-project",
+		vmIDCache:        newVMIDCache(),
+		svc:              svc,
+	}
+}
+
+func testVMList() *photon.VMs {
+	return &photon.VMs{
+		Items: []photon.VM{
+			{ID: "vm-1", Name: "node-1"},
+			{ID: "vm-2", Name: "node-2"},
+		},
+	}
+}
+
+func TestAttachDetachDisk(t *testing.T) {
+	vms := &fakeVMs{}
+	pc := fakePCCloud(&photonService{
+		Projects: &fakeProjects{vms: testVMList()},
+		VMs:      vms,
+		Tasks:    &fakeTasks{},
+	})
+
+	if err := pc.AttachDisk(context.TODO(), "disk-1", types.NodeName("node-1")); err != nil {
+		t.Fatalf("AttachDisk failed: %v", err)
+	}
+	if len(vms.attached) != 1 || vms.attached[0] != "disk-1" {
+		t.Errorf("expected disk-1 to be attached, got %v", vms.attached)
+	}
+
+	if err := pc.DetachDisk(context.TODO(), "disk-1", types.NodeName("node-1")); err != nil {
+		t.Fatalf("DetachDisk failed: %v", err)
+	}
+	if len(vms.detached) != 1 || vms.detached[0] != "disk-1" {
+		t.Errorf("expected disk-1 to be detached, got %v", vms.detached)
+	}
+}
+
+func TestAttachDiskErrors(t *testing.T) {
+	sdkErr := errors.New("photon endpoint unavailable")
+
+	// The attach call itself fails.
+	pc := fakePCCloud(&photonService{
+		Projects: &fakeProjects{vms: testVMList()},
+		VMs:      &fakeVMs{err: sdkErr},
+		Tasks:    &fakeTasks{},
+	})
+	if err := pc.AttachDisk(context.TODO(), "disk-1", types.NodeName("node-1")); err == nil {
+		t.Errorf("expected AttachDisk to fail when the SDK attach call fails")
+	}
+
+	// The VM lookup fails, so the node cannot be resolved.
+	pc = fakePCCloud(&photonService{
+		Projects: &fakeProjects{err: sdkErr},
+		VMs:      &fakeVMs{},
+		Tasks:    &fakeTasks{},
+	})
+	if err := pc.AttachDisk(context.TODO(), "disk-1", types.NodeName("node-1")); err == nil {
+		t.Errorf("expected AttachDisk to fail when the VM lookup fails")
+	}
+}
+
+func TestDetachDiskTaskWaitError(t *testing.T) {
+	pc := fakePCCloud(&photonService{
+		Projects: &fakeProjects{vms: testVMList()},
+		VMs:      &fakeVMs{},
+		Tasks:    &fakeTasks{err: errors.New("task failed")},
+	})
+	if err := pc.DetachDisk(context.TODO(), "disk-1", types.NodeName("node-1")); err == nil {
+		t.Errorf("expected DetachDisk to fail when waiting for the detach task fails")
+	}
+}
+
+func TestNodeAddressesFromEndpoint(t *testing.T) {
+	networksTask := &photon.Task{
+		ID: "task-networks",
+		ResourceProperties: map[string]interface{}{
+			"networkConnections": []interface{}{
+				map[string]interface{}{
+					"ipAddress":  "10.0.0.5",
+					"macAddress": "00:1a:2b:3c:4d:5e",
+				},
+			},
+		},
+	}
+	pc := fakePCCloud(&photonService{
+		Projects: &fakeProjects{vms: testVMList()},
+		VMs:      &fakeVMs{networksTask: networksTask},
+		Tasks:    &fakeTasks{task: networksTask},
+	})
+
+	addrs, err := pc.NodeAddresses(context.TODO(), types.NodeName("node-1"))
+	if err != nil {
+		t.Fatalf("NodeAddresses failed: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0].Type != v1.NodeInternalIP || addrs[0].Address != "10.0.0.5" {
+		t.Errorf("unexpected node addresses: %v", addrs)
+	}
+}
+
+func TestNodeAddressesErrors(t *testing.T) {
+	sdkErr := errors.New("photon endpoint unavailable")
+
+	// Listing the project VMs fails.
+	pc := fakePCCloud(&photonService{
+		Projects: &fakeProjects{err: sdkErr},
+		VMs:      &fakeVMs{},
+		Tasks:    &fakeTasks{},
+	})
+	if _, err := pc.NodeAddresses(context.TODO(), types.NodeName("node-1")); err == nil {
+		t.Errorf("expected NodeAddresses to fail when listing VMs fails")
+	}
+
+	// The node does not exist in the project.
+	pc = fakePCCloud(&photonService{
+		Projects: &fakeProjects{vms: testVMList()},
+		VMs:      &fakeVMs{},
+		Tasks:    &fakeTasks{},
+	})
+	if _, err := pc.NodeAddresses(context.TODO(), types.NodeName("no-such-node")); err == nil {
+		t.Errorf("expected NodeAddresses to fail for an unknown node")
+	}
+}
+
+func TestDiskIsAttachedWithFakes(t *testing.T) {
+	disk := &photon.PersistentDisk{ID: "disk-1", VMs: []string{"vm-1"}}
+	pc := fakePCCloud(&photonService{
+		Projects: &fakeProjects{vms: testVMList()},
+		VMs:      &fakeVMs{},
+		Disks:    &fakeDisks{disk: disk},
+		Tasks:    &fakeTasks{},
+	})
+
+	attached, err := pc.DiskIsAttached(context.TODO(), "disk-1", types.NodeName("node-1"))
+	if err != nil {
+		t.Fatalf("DiskIsAttached failed: %v", err)
+	}
+	if !attached {
+		t.Errorf("expected disk-1 to be reported attached to node-1")
+	}
+
+	attached, err = pc.DiskIsAttached(context.TODO(), "disk-1", types.NodeName("node-2"))
+	if err != nil {
+		t.Fatalf("DiskIsAttached failed: %v", err)
+	}
+	if attached {
+		t.Errorf("expected disk-1 to be reported detached from node-2")
+	}
+}
@@ -200,3 +200,80 @@ func TestVolumes(t *testing.T) {
 		t.Fatalf("Cannot delete persisten disk(%s): %v", pdID, err)
 	}
 }
+
+func TestVMIDFromOVFEnv(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<Environment xmlns="http://schemas.dmtf.org/ovf/environment/1"
+             xmlns:oe="http://schemas.dmtf.org/ovf/environment/1">
+  <PropertySection>
+    <Property oe:key="hostname" oe:value="node-1"/>
+    <Property oe:key="vmid" oe:value="12345678-90ab-cdef-1234-567890abcdef"/>
+  </PropertySection>
+</Environment>`
+	vmID, err := vmIDFromOVFEnv(doc)
+	if err != nil {
+		t.Fatalf("Failed to extract VM ID from OVF environment: %v", err)
+	}
+	if vmID != "12345678-90ab-cdef-1234-567890abcdef" {
+		t.Errorf("Unexpected VM ID extracted from OVF environment: %s", vmID)
+	}
+
+	if _, err = vmIDFromOVFEnv(`<Environment><PropertySection/></Environment>`); err == nil {
+		t.Errorf("Should fail when the OVF environment has no vmid property")
+	}
+
+	if _, err = vmIDFromOVFEnv("not xml"); err == nil {
+		t.Errorf("Should fail on a malformed OVF environment")
+	}
+}
+
+func TestDiscoverLocalVMID(t *testing.T) {
+	origGuestInfoGet := guestInfoGet
+	defer func() { guestInfoGet = origGuestInfoGet }()
+
+	// Deployment stamped the VM ID directly into guestinfo.
+	guestInfoGet = func(key string) (string, error) {
+		if key == guestInfoVMIDKey {
+			return "vmid-from-guestinfo", nil
+		}
+		return "", nil
+	}
+	vmID, err := discoverLocalVMID()
+	if err != nil {
+		t.Fatalf("Failed to discover VM ID from guestinfo: %v", err)
+	}
+	if vmID != "vmid-from-guestinfo" {
+		t.Errorf("Unexpected VM ID discovered from guestinfo: %s", vmID)
+	}
+
+	// No direct guestinfo key, fall back to the OVF environment.
+	guestInfoGet = func(key string) (string, error) {
+		if key == ovfEnvKey {
+			return `<Environment><PropertySection><Property key="vmid" value="vmid-from-ovf"/></PropertySection></Environment>`, nil
+		}
+		return "", nil
+	}
+	vmID, err = discoverLocalVMID()
+	if err != nil {
+		t.Fatalf("Failed to discover VM ID from the OVF environment: %v", err)
+	}
+	if vmID != "vmid-from-ovf" {
+		t.Errorf("Unexpected VM ID discovered from the OVF environment: %s", vmID)
+	}
+}
+
+func TestVMIDFromProviderID(t *testing.T) {
+	vmID, err := vmIDFromProviderID("photon://12345-abcde")
+	if err != nil || vmID != "12345-abcde" {
+		t.Errorf("expected the scheme to be stripped, got %q (%v)", vmID, err)
+	}
+
+	vmID, err = vmIDFromProviderID("12345-abcde")
+	if err != nil || vmID != "12345-abcde" {
+		t.Errorf("expected a bare VM ID to be accepted, got %q (%v)", vmID, err)
+	}
+
+	if _, err := vmIDFromProviderID("photon://"); err == nil {
+		t.Errorf("expected an error for an empty VM ID")
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// loadBalancerConditionsAnnotation carries the load balancer
+	// provisioning conditions of a service as a JSON array. The v1 Service
+	// API of this release has no status.conditions field, so the conditions
+	// are persisted as an annotation with the shape a status condition would
+	// have; controllers and CD tooling can watch this stable field instead
+	// of scraping events, and old clients round-trip it untouched.
+	loadBalancerConditionsAnnotation = "service.kubernetes.io/load-balancer-conditions"
+
+	// conditionLoadBalancerProvisioning is True while the cloud provider is
+	// ensuring the load balancer and False once the ensure finished, whether
+	// it succeeded or failed.
+	conditionLoadBalancerProvisioning = "LoadBalancerProvisioning"
+	// conditionLoadBalancerFailed is True when the last ensure failed, with
+	// the cloud provider error as the message, and False after a successful
+	// ensure. It is left in place while a retry is in flight so the last
+	// error stays visible.
+	conditionLoadBalancerFailed = "LoadBalancerFailed"
+
+	// Reasons used on the conditions above.
+	reasonEnsuringLoadBalancer     = "EnsuringLoadBalancer"
+	reasonEnsuredLoadBalancer      = "EnsuredLoadBalancer"
+	reasonEnsureLoadBalancerFailed = "EnsureLoadBalancerFailed"
+)
+
+// serviceCondition mirrors the shape of the condition types elsewhere in the
+// v1 API (e.g. NodeCondition), serialized into the conditions annotation.
+type serviceCondition struct {
+	Type               string             `json:"type"`
+	Status             v1.ConditionStatus `json:"status"`
+	Reason             string             `json:"reason,omitempty"`
+	Message            string             `json:"message,omitempty"`
+	LastTransitionTime metav1.Time        `json:"lastTransitionTime,omitempty"`
+}
+
+// loadBalancerConditions returns the conditions recorded on the service. A
+// missing or malformed annotation reads as no conditions, so a corrupted
+// annotation is rewritten on the next update rather than wedging the sync.
+func loadBalancerConditions(service *v1.Service) []serviceCondition {
+	raw, ok := service.Annotations[loadBalancerConditionsAnnotation]
+	if !ok {
+		return nil
+	}
+	conditions := []serviceCondition{}
+	if err := json.Unmarshal([]byte(raw), &conditions); err != nil {
+		return nil
+	}
+	return conditions
+}
+
+// mergeServiceCondition folds updated into the condition list, keyed by Type.
+// LastTransitionTime is preserved when the status did not change. The second
+// return value reports whether the list changed at all.
+func mergeServiceCondition(conditions []serviceCondition, updated serviceCondition) ([]serviceCondition, bool) {
+	for i, condition := range conditions {
+		if condition.Type != updated.Type {
+			continue
+		}
+		if condition.Status == updated.Status && condition.Reason == updated.Reason && condition.Message == updated.Message {
+			return conditions, false
+		}
+		if condition.Status == updated.Status {
+			updated.LastTransitionTime = condition.LastTransitionTime
+		}
+		conditions[i] = updated
+		return conditions, true
+	}
+	return append(conditions, updated), true
+}
+
+// setLoadBalancerConditions records the provisioning state of the service's
+// load balancer. While provisioning only the LoadBalancerProvisioning
+// condition is touched, so the error of a previous attempt stays visible
+// until the retry resolves it one way or the other.
+func (s *ServiceController) setLoadBalancerConditions(service *v1.Service, provisioning bool, ensureErr error) error {
+	now := metav1.Now()
+	var updates []serviceCondition
+	switch {
+	case provisioning:
+		updates = []serviceCondition{{
+			Type:               conditionLoadBalancerProvisioning,
+			Status:             v1.ConditionTrue,
+			Reason:             reasonEnsuringLoadBalancer,
+			Message:            "The cloud provider is provisioning the load balancer",
+			LastTransitionTime: now,
+		}}
+	case ensureErr != nil:
+		updates = []serviceCondition{{
+			Type:               conditionLoadBalancerProvisioning,
+			Status:             v1.ConditionFalse,
+			Reason:             reasonEnsureLoadBalancerFailed,
+			LastTransitionTime: now,
+		}, {
+			Type:               conditionLoadBalancerFailed,
+			Status:             v1.ConditionTrue,
+			Reason:             reasonEnsureLoadBalancerFailed,
+			Message:            ensureErr.Error(),
+			LastTransitionTime: now,
+		}}
+	default:
+		updates = []serviceCondition{{
+			Type:               conditionLoadBalancerProvisioning,
+			Status:             v1.ConditionFalse,
+			Reason:             reasonEnsuredLoadBalancer,
+			LastTransitionTime: now,
+		}, {
+			Type:               conditionLoadBalancerFailed,
+			Status:             v1.ConditionFalse,
+			Reason:             reasonEnsuredLoadBalancer,
+			LastTransitionTime: now,
+		}}
+	}
+
+	// Work against the latest copy: the same sync may record conditions
+	// several times (provisioning, then the outcome), and an update based on
+	// the informer's stale copy would conflict with the first write.
+	latest, err := s.kubeClient.CoreV1().Services(service.Namespace).Get(service.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	service = latest
+
+	conditions := loadBalancerConditions(service)
+	changed := false
+	for _, update := range updates {
+		var conditionChanged bool
+		conditions, conditionChanged = mergeServiceCondition(conditions, update)
+		changed = changed || conditionChanged
+	}
+	if !changed {
+		return nil
+	}
+
+	data, err := json.Marshal(conditions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal load balancer conditions for service '%s/%s': %v", service.Namespace, service.Name, err)
+	}
+	if service.Annotations == nil {
+		service.Annotations = map[string]string{}
+	}
+	service.Annotations[loadBalancerConditionsAnnotation] = string(data)
+	_, err = s.kubeClient.CoreV1().Services(service.Namespace).Update(service)
+	return err
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeServiceCondition(t *testing.T) {
+	transition := metav1.NewTime(time.Now().Add(-time.Hour))
+	existing := []serviceCondition{{
+		Type:               conditionLoadBalancerProvisioning,
+		Status:             v1.ConditionTrue,
+		Reason:             reasonEnsuringLoadBalancer,
+		LastTransitionTime: transition,
+	}}
+
+	// An identical update is a no-op.
+	merged, changed := mergeServiceCondition(existing, existing[0])
+	if changed {
+		t.Errorf("expected no change for an identical condition, got %v", merged)
+	}
+
+	// A reason change with the same status keeps the transition time.
+	update := existing[0]
+	update.Reason = "SomethingElse"
+	update.LastTransitionTime = metav1.Now()
+	merged, changed = mergeServiceCondition(existing, update)
+	if !changed {
+		t.Errorf("expected a change for an updated reason")
+	}
+	if !merged[0].LastTransitionTime.Equal(&transition) {
+		t.Errorf("expected the transition time to be preserved while the status is unchanged, got %v", merged[0].LastTransitionTime)
+	}
+
+	// A status change moves the transition time.
+	update = existing[0]
+	update.Status = v1.ConditionFalse
+	update.LastTransitionTime = metav1.Now()
+	merged, _ = mergeServiceCondition(existing, update)
+	if merged[0].LastTransitionTime.Equal(&transition) {
+		t.Errorf("expected the transition time to move with the status change")
+	}
+
+	// A new type is appended.
+	merged, changed = mergeServiceCondition(existing, serviceCondition{Type: conditionLoadBalancerFailed, Status: v1.ConditionTrue})
+	if !changed || len(merged) != 2 {
+		t.Errorf("expected the new condition type to be appended, got %v", merged)
+	}
+}
+
+func TestSetLoadBalancerConditions(t *testing.T) {
+	controller, _, client := newController()
+	service := defaultExternalService()
+	if _, err := client.CoreV1().Services(service.Namespace).Create(service); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	conditionsByType := func() map[string]serviceCondition {
+		latest, err := client.CoreV1().Services(service.Namespace).Get(service.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		result := map[string]serviceCondition{}
+		for _, condition := range loadBalancerConditions(latest) {
+			result[condition.Type] = condition
+		}
+		return result
+	}
+
+	// Provisioning starts.
+	if err := controller.setLoadBalancerConditions(service, true, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conditions := conditionsByType()
+	if got := conditions[conditionLoadBalancerProvisioning]; got.Status != v1.ConditionTrue || got.Reason != reasonEnsuringLoadBalancer {
+		t.Errorf("expected provisioning condition to be true, got %+v", got)
+	}
+
+	// Provisioning fails: the cloud provider error is surfaced and the
+	// provisioning condition resolves.
+	if err := controller.setLoadBalancerConditions(service, false, errors.New("quota exhausted")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conditions = conditionsByType()
+	if got := conditions[conditionLoadBalancerProvisioning]; got.Status != v1.ConditionFalse || got.Reason != reasonEnsureLoadBalancerFailed {
+		t.Errorf("expected provisioning condition to be false after a failure, got %+v", got)
+	}
+	if got := conditions[conditionLoadBalancerFailed]; got.Status != v1.ConditionTrue || got.Message != "quota exhausted" {
+		t.Errorf("expected failed condition with the provider error, got %+v", got)
+	}
+
+	// A retry in flight leaves the last error visible.
+	if err := controller.setLoadBalancerConditions(service, true, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conditions = conditionsByType()
+	if got := conditions[conditionLoadBalancerFailed]; got.Status != v1.ConditionTrue {
+		t.Errorf("expected the failure to stay visible while retrying, got %+v", got)
+	}
+
+	// Success clears the failure.
+	if err := controller.setLoadBalancerConditions(service, false, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conditions = conditionsByType()
+	if got := conditions[conditionLoadBalancerProvisioning]; got.Status != v1.ConditionFalse || got.Reason != reasonEnsuredLoadBalancer {
+		t.Errorf("expected provisioning condition to resolve, got %+v", got)
+	}
+	if got := conditions[conditionLoadBalancerFailed]; got.Status != v1.ConditionFalse {
+		t.Errorf("expected failed condition to clear on success, got %+v", got)
+	}
+}
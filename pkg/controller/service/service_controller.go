@@ -18,7 +18,11 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,8 +31,12 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/util/wait"
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	coreinformers "k8s.io/client-go/informers/core/v1"
@@ -40,9 +48,12 @@ import (
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	cloudprovider "k8s.io/cloud-provider"
+	apiservice "k8s.io/kubernetes/pkg/api/v1/service"
 	v1helper "k8s.io/kubernetes/pkg/apis/core/v1/helper"
 	"k8s.io/kubernetes/pkg/controller"
 	kubefeatures "k8s.io/kubernetes/pkg/features"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
+	hashutil "k8s.io/kubernetes/pkg/util/hash"
 	"k8s.io/kubernetes/pkg/util/metrics"
 )
 
@@ -58,9 +69,69 @@ const (
 	minRetryDelay = 5 * time.Second
 	maxRetryDelay = 300 * time.Second
 
+	// quotaRetryDelay is how long to wait before retrying a service whose
+	// load balancer failed because the cloud provider quota is exhausted.
+	// Hot-looping the regular backoff only worsens the quota situation, so
+	// these retries are spaced much further apart.
+	quotaRetryDelay = 10 * time.Minute
+
+	// prerequisiteRetryDelay is how soon to retry a service whose load
+	// balancer failed because a cloud prerequisite (subnet tag, security
+	// group) does not exist yet. Such resources are usually eventually
+	// consistent, so the retry is quicker than the exponential backoff
+	// would eventually become.
+	prerequisiteRetryDelay = 15 * time.Second
+
+	// serviceQuotaExceededAnnotation marks a service whose load balancer
+	// could not be created because of cloud provider quota exhaustion. The
+	// value is the time of the last quota failure in RFC3339 form; the
+	// annotation is removed once the load balancer is ensured successfully.
+	serviceQuotaExceededAnnotation = "alpha.service-controller.kubernetes.io/quota-exceeded"
+
 	clientRetryCount    = 5
 	clientRetryInterval = 5 * time.Second
 
+	// defaultReconcileTimeout bounds a single cloud provider call so a hung
+	// provider SDK call cannot block a worker forever.
+	defaultReconcileTimeout = 10 * time.Minute
+
+	// defaultNodeSyncWorkers is how many load balancers a node sync updates
+	// in parallel unless configured otherwise.
+	defaultNodeSyncWorkers = 1
+
+	// loadBalancerSpecHashAnnotation holds a hash of the load balancer
+	// relevant service spec and node set from the last successful
+	// EnsureLoadBalancer, together with the time it was ensured. A restarted
+	// controller can then skip cloud calls for services that are unchanged.
+	loadBalancerSpecHashAnnotation = "alpha.service-controller.kubernetes.io/last-applied-hash"
+	// forcedEnsurePeriod is how long a matching hash is honored. After this
+	// long a full EnsureLoadBalancer is forced even for an unchanged service,
+	// so drift introduced out of band in the cloud resources is corrected.
+	forcedEnsurePeriod = 1 * time.Hour
+
+	// allowStaticIPReleaseAnnotation opts a service out of the static IP
+	// protection: when set to "true" by the user, deleting the load
+	// balancer may release the pre-allocated IP in spec.loadBalancerIP.
+	allowStaticIPReleaseAnnotation = "alpha.service-controller.kubernetes.io/allow-static-ip-release"
+
+	// loadBalancerClassAnnotation designates a service for an external load
+	// balancer implementation. The in-tree controller ignores services whose
+	// class is set to anything but the empty string, so a provider-specific
+	// operator can manage them without two controllers fighting over the
+	// same cloud resources.
+	loadBalancerClassAnnotation = "service.kubernetes.io/load-balancer-class"
+
+	// nodeWeightLabel lets admins assign an explicit traffic weight to a
+	// node, overriding the default capacity-based weight for providers that
+	// support weighted load balancer backends.
+	nodeWeightLabel = "alpha.service-controller.kubernetes.io/node-weight"
+
+	// topologyAwareHintsAnnotation lets a service explicitly request
+	// zone-affine load balancer backends on providers that implement
+	// cloudprovider.ZoneAffinityLoadBalancer. Services with
+	// externalTrafficPolicy=Local request it implicitly.
+	topologyAwareHintsAnnotation = "alpha.service-controller.kubernetes.io/topology-aware-hints"
+
 	// LabelNodeRoleMaster specifies that a node is a master
 	// It's copied over to kubeadm until it's merged in core: https://github.com/kubernetes/kubernetes/pull/39112
 	LabelNodeRoleMaster = "node-role.kubernetes.io/master"
@@ -96,10 +167,42 @@ type ServiceController struct {
 	eventRecorder       record.EventRecorder
 	nodeLister          corelisters.NodeLister
 	nodeListerSynced    cache.InformerSynced
+	// maxHostRemovalFraction, when > 0, is the largest fraction of the
+	// currently known hosts that a single node sync is allowed to remove
+	// from load balancers. Syncs that would exceed it are skipped with a
+	// warning instead, so mass node flapping (e.g. a network partition)
+	// doesn't blackhole traffic by emptying every backend pool at once.
+	maxHostRemovalFraction float64
+	// reconcileTimeout is the deadline applied to each cloud provider load
+	// balancer call. Zero disables the deadline.
+	reconcileTimeout time.Duration
+	// nodeWeight, when set, computes a relative traffic weight per node,
+	// which is passed to cloud providers implementing
+	// cloudprovider.WeightedNodesLoadBalancer.
+	nodeWeight NodeWeightFunc
+	// shadowBalancer, when set, is a secondary provider implementation
+	// consulted read-only after each reconcile; divergences from the primary
+	// are logged and counted but never fail the reconcile. See
+	// SetShadowBalancer.
+	shadowBalancer cloudprovider.LoadBalancer
+	// nodeSyncWorkers is how many load balancers are updated in parallel
+	// when the set of cluster nodes changes.
+	nodeSyncWorkers int
+	// namespaceAllowlist, when non-empty, restricts the controller to load
+	// balancer services in the listed namespaces; services elsewhere are
+	// left untouched for an external load balancer operator.
+	// namespaceDenylist is the inverse: services in the listed namespaces
+	// are never managed. See SetNamespaceAllowlist and SetNamespaceDenylist.
+	namespaceAllowlist sets.String
+	namespaceDenylist  sets.String
 	// services that need to be synced
 	queue workqueue.RateLimitingInterface
 }
 
+// NodeWeightFunc computes the relative traffic weight of a node for providers
+// that support weighted load balancer backends.
+type NodeWeightFunc func(node *v1.Node) int32
+
 // New returns a new service controller to keep cloud provider service resources
 // (like load balancers) in sync with the registry.
 func New(
@@ -130,6 +233,8 @@ func New(
 		eventRecorder:    recorder,
 		nodeLister:       nodeInformer.Lister(),
 		nodeListerSynced: nodeInformer.Informer().HasSynced,
+		reconcileTimeout: defaultReconcileTimeout,
+		nodeSyncWorkers:  defaultNodeSyncWorkers,
 		queue:            workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "service"),
 	}
 
@@ -156,6 +261,75 @@ func New(
 	return s, nil
 }
 
+// SetMaxHostRemovalFraction configures the node readiness safeguard: a node
+// sync that would remove more than the given fraction (0 < f <= 1) of the
+// known hosts from load balancers is skipped with a warning event instead.
+// A value of 0 disables the safeguard. Must be called before Run.
+func (s *ServiceController) SetMaxHostRemovalFraction(fraction float64) {
+	s.maxHostRemovalFraction = fraction
+}
+
+// SetReconcileTimeout configures the deadline applied to each cloud provider
+// load balancer call. A value of 0 disables the deadline. Must be called
+// before Run.
+func (s *ServiceController) SetReconcileTimeout(timeout time.Duration) {
+	s.reconcileTimeout = timeout
+}
+
+// SetNodeSyncConcurrency configures how many load balancers are updated in
+// parallel when the set of cluster nodes changes. Values below 1 are ignored.
+// Must be called before Run.
+func (s *ServiceController) SetNodeSyncConcurrency(workers int) {
+	if workers > 0 {
+		s.nodeSyncWorkers = workers
+	}
+}
+
+// SetNamespaceAllowlist restricts the controller to load balancer services in
+// the given namespaces. Services in other namespaces are neither created nor
+// deleted by this controller, so an external load balancer operator can own
+// them without the two fighting over cloud resources. An empty list removes
+// the restriction. Must be called before Run.
+func (s *ServiceController) SetNamespaceAllowlist(namespaces []string) {
+	s.namespaceAllowlist = sets.NewString(namespaces...)
+}
+
+// SetNamespaceDenylist excludes load balancer services in the given namespaces
+// from this controller. It may be combined with an allowlist, in which case a
+// namespace must be on the allowlist and not on the denylist to be managed.
+// Must be called before Run.
+func (s *ServiceController) SetNamespaceDenylist(namespaces []string) {
+	s.namespaceDenylist = sets.NewString(namespaces...)
+}
+
+// managesNamespace reports whether load balancer services in the given
+// namespace are managed by this controller.
+func (s *ServiceController) managesNamespace(namespace string) bool {
+	if s.namespaceAllowlist.Len() > 0 && !s.namespaceAllowlist.Has(namespace) {
+		return false
+	}
+	return !s.namespaceDenylist.Has(namespace)
+}
+
+// reconcileContext returns the context passed to cloud provider load balancer
+// calls, carrying the configured reconcile timeout.
+func (s *ServiceController) reconcileContext() (context.Context, context.CancelFunc) {
+	if s.reconcileTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), s.reconcileTimeout)
+}
+
+// recordIfTimeout emits a distinct warning event when a cloud provider call
+// failed because the reconcile timeout expired, so hung provider calls are
+// distinguishable from ordinary cloud errors.
+func (s *ServiceController) recordIfTimeout(ctx context.Context, service *v1.Service, op string) {
+	if ctx.Err() == context.DeadlineExceeded {
+		s.eventRecorder.Eventf(service, v1.EventTypeWarning, "CloudCallTimeout",
+			"%s did not complete within %v", op, s.reconcileTimeout)
+	}
+}
+
 // obj could be an *v1.Service, or a DeletionFinalStateUnknown marker item.
 func (s *ServiceController) enqueueService(obj interface{}) {
 	key, err := controller.KeyFunc(obj)
@@ -216,11 +390,121 @@ func (s *ServiceController) processNextWorkItem() bool {
 		return true
 	}
 
+	if isQuotaError(err) {
+		// Do not feed quota errors into the exponential backoff; retry on
+		// the dedicated, much slower schedule instead.
+		runtime.HandleError(fmt.Errorf("quota exhausted processing service %v (will retry in %v): %v", key, quotaRetryDelay, err))
+		s.queue.Forget(key)
+		s.queue.AddAfter(key, quotaRetryDelay)
+		return true
+	}
+
+	if delay, ok := prerequisiteRequeueDelay(err); ok {
+		// A missing prerequisite (subnet tag, security group, ...) is not
+		// fixed by backing off harder; requeue on the hinted schedule
+		// instead of feeding the exponential backoff.
+		runtime.HandleError(fmt.Errorf("missing prerequisite processing service %v (will retry in %v): %v", key, delay, err))
+		s.queue.Forget(key)
+		s.queue.AddAfter(key, delay)
+		return true
+	}
+
 	runtime.HandleError(fmt.Errorf("error processing service %v (will retry): %v", key, err))
 	s.queue.AddRateLimited(key)
 	return true
 }
 
+// PrerequisiteErrorMatcher classifies an error returned by the cloud provider
+// as a missing-prerequisite failure and returns how soon the service should be
+// retried. Prerequisites known to be eventually consistent (a subnet tag being
+// applied by a provisioning pipeline, a security group still propagating)
+// should return a short delay; prerequisites that need operator action should
+// return a long one.
+type PrerequisiteErrorMatcher func(err error) (time.Duration, bool)
+
+var prerequisiteErrorMatchers = []PrerequisiteErrorMatcher{matchCommonPrerequisiteErrors}
+
+// RegisterPrerequisiteErrorMatcher adds a provider-specific prerequisite error
+// matcher. It must be called before the controller is started.
+func RegisterPrerequisiteErrorMatcher(matcher PrerequisiteErrorMatcher) {
+	prerequisiteErrorMatchers = append(prerequisiteErrorMatchers, matcher)
+}
+
+// prerequisiteRequeueDelay returns the requeue hint for a missing-prerequisite
+// error. Errors carrying their own hint take precedence over the registered
+// matchers.
+func prerequisiteRequeueDelay(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	if hinted, ok := err.(interface{ RequeueAfter() time.Duration }); ok {
+		return hinted.RequeueAfter(), true
+	}
+	for _, matcher := range prerequisiteErrorMatchers {
+		if delay, ok := matcher(err); ok {
+			return delay, true
+		}
+	}
+	return 0, false
+}
+
+// matchCommonPrerequisiteErrors covers the error strings the major cloud
+// providers use when a subnet or security group the load balancer needs does
+// not exist yet. These resources are typically created or tagged by a separate
+// provisioning pipeline, so a quick retry usually succeeds.
+func matchCommonPrerequisiteErrors(err error) (time.Duration, bool) {
+	message := err.Error()
+	for _, token := range []string{
+		"InvalidSubnetID.NotFound",
+		"InvalidGroup.NotFound",
+		"could not find any suitable subnets",
+		"security group not found",
+		"subnet not found",
+	} {
+		if strings.Contains(message, token) {
+			return prerequisiteRetryDelay, true
+		}
+	}
+	return 0, false
+}
+
+// QuotaErrorMatcher reports whether an error returned by the cloud provider
+// indicates quota exhaustion. Providers whose SDK errors are not covered by
+// the built-in matcher can register their own via RegisterQuotaErrorMatcher.
+type QuotaErrorMatcher func(err error) bool
+
+var quotaErrorMatchers = []QuotaErrorMatcher{matchCommonQuotaErrors}
+
+// RegisterQuotaErrorMatcher adds a provider-specific quota error matcher. It
+// must be called before the controller is started.
+func RegisterQuotaErrorMatcher(matcher QuotaErrorMatcher) {
+	quotaErrorMatchers = append(quotaErrorMatchers, matcher)
+}
+
+func isQuotaError(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, matcher := range quotaErrorMatchers {
+		if matcher(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCommonQuotaErrors covers the error strings the major cloud providers
+// use for quota exhaustion.
+func matchCommonQuotaErrors(err error) bool {
+	message := err.Error()
+	for _, token := range []string{"QUOTA_EXCEEDED", "QuotaExceeded", "LimitExceeded", "quota exceeded"} {
+		if strings.Contains(message, token) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *ServiceController) init() error {
 	if s.cloud == nil {
 		return fmt.Errorf("no cloud provider provided, services of type LoadBalancer will fail")
@@ -246,6 +530,21 @@ func (s *ServiceController) processServiceUpdate(cachedService *cachedService, s
 			}
 		}
 	}
+	if hasExternalLoadBalancerClass(service) {
+		// The service now belongs to an external load balancer
+		// implementation. Clean up the load balancer this controller
+		// created earlier, if any, but leave the externally managed one
+		// alone from here on.
+		if cachedService.state != nil && !hasExternalLoadBalancerClass(cachedService.state) {
+			if err := s.processLoadBalancerDelete(cachedService, key); err != nil {
+				return err
+			}
+		}
+		glog.V(4).Infof("Ignoring service %s with load balancer class %q", key, service.Annotations[loadBalancerClassAnnotation])
+		cachedService.state = service
+		s.cache.set(key, cachedService)
+		return nil
+	}
 	// cache the service, we need the info for service deletion
 	cachedService.state = service
 	err := s.createLoadBalancerIfNeeded(key, service)
@@ -255,11 +554,20 @@ func (s *ServiceController) processServiceUpdate(cachedService *cachedService, s
 		if !wantsLoadBalancer(service) {
 			eventType = "CleanupLoadBalancerFailed"
 			message = "Error cleaning up load balancer (will retry): "
+		} else if isQuotaError(err) {
+			eventType = "LoadBalancerQuotaExceeded"
+			message = fmt.Sprintf("Cloud provider quota exhausted (will retry in %v): ", quotaRetryDelay)
+			if markErr := s.setQuotaExceededMark(service, true); markErr != nil {
+				glog.Warningf("Failed to mark service %s as quota exceeded: %v", key, markErr)
+			}
 		}
 		message += err.Error()
 		s.eventRecorder.Event(service, v1.EventTypeWarning, eventType, message)
 		return err
 	}
+	if err := s.setQuotaExceededMark(service, false); err != nil {
+		glog.Warningf("Failed to clear quota exceeded mark on service %s: %v", key, err)
+	}
 	// Always update the cache upon success.
 	// NOTE: Since we update the cached service if and only if we successfully
 	// processed it, a cached service being nil implies that it hasn't yet
@@ -279,17 +587,21 @@ func (s *ServiceController) createLoadBalancerIfNeeded(key string, service *v1.S
 	// Save the state so we can avoid a write if it doesn't change
 	previousState := v1helper.LoadBalancerStatusDeepCopy(&service.Status.LoadBalancer)
 	var newState *v1.LoadBalancerStatus
-	var err error
 
 	if !wantsLoadBalancer(service) {
-		_, exists, err := s.balancer.GetLoadBalancer(context.TODO(), s.clusterName, service)
+		ctx, cancel := s.reconcileContext()
+		defer cancel()
+
+		_, exists, err := s.balancer.GetLoadBalancer(ctx, s.clusterName, service)
 		if err != nil {
+			s.recordIfTimeout(ctx, service, "GetLoadBalancer")
 			return fmt.Errorf("error getting LB for service %s: %v", key, err)
 		}
 		if exists {
 			glog.Infof("Deleting existing load balancer for service %s that no longer needs a load balancer.", key)
 			s.eventRecorder.Event(service, v1.EventTypeNormal, "DeletingLoadBalancer", "Deleting load balancer")
-			if err := s.balancer.EnsureLoadBalancerDeleted(context.TODO(), s.clusterName, service); err != nil {
+			if err := s.balancer.EnsureLoadBalancerDeleted(ctx, s.clusterName, service); err != nil {
+				s.recordIfTimeout(ctx, service, "EnsureLoadBalancerDeleted")
 				return err
 			}
 			s.eventRecorder.Event(service, v1.EventTypeNormal, "DeletedLoadBalancer", "Deleted load balancer")
@@ -299,16 +611,52 @@ func (s *ServiceController) createLoadBalancerIfNeeded(key string, service *v1.S
 	} else {
 		glog.V(2).Infof("Ensuring LB for service %s", key)
 
-		// TODO: We could do a dry-run here if wanted to avoid the spurious cloud-calls & events when we restart
+		nodes, err := s.nodeLister.ListWithPredicate(getNodeConditionPredicate())
+		if err != nil {
+			return err
+		}
+
+		// A pre-allocated static IP must not be claimed by two services;
+		// ensuring the second would make the provider steal the address
+		// back and forth between the two load balancers.
+		if usesStaticLoadBalancerIP(service) {
+			if conflict := s.findStaticIPConflict(service); conflict != "" {
+				s.eventRecorder.Eventf(service, v1.EventTypeWarning, "StaticIPConflict",
+					"Load balancer IP %s is already claimed by service %s", service.Spec.LoadBalancerIP, conflict)
+				return fmt.Errorf("load balancer IP %s for service %s is already claimed by service %s", service.Spec.LoadBalancerIP, key, conflict)
+			}
+		}
+
+		hash := serviceLoadBalancerHash(service, nodes)
+		if s.canSkipEnsure(service, hash) {
+			glog.V(2).Infof("Skipping EnsureLoadBalancer for service %s: spec and node set unchanged since last ensure", key)
+			return nil
+		}
 
 		s.eventRecorder.Event(service, v1.EventTypeNormal, "EnsuringLoadBalancer", "Ensuring load balancer")
-		newState, err = s.ensureLoadBalancer(service)
+		if condErr := s.setLoadBalancerConditions(service, true, nil); condErr != nil {
+			glog.Warningf("Failed to record provisioning condition on service %s: %v", key, condErr)
+		}
+		newState, err = s.ensureLoadBalancer(service, nodes)
 		if err != nil {
+			if condErr := s.setLoadBalancerConditions(service, false, err); condErr != nil {
+				glog.Warningf("Failed to record failure condition on service %s: %v", key, condErr)
+			}
 			return fmt.Errorf("failed to ensure load balancer for service %s: %v", key, err)
 		}
 		s.eventRecorder.Event(service, v1.EventTypeNormal, "EnsuredLoadBalancer", "Ensured load balancer")
+		if condErr := s.setLoadBalancerConditions(service, false, nil); condErr != nil {
+			glog.Warningf("Failed to record provisioned condition on service %s: %v", key, condErr)
+		}
+
+		if err := s.setLoadBalancerHash(service, hash); err != nil {
+			glog.Warningf("Failed to update load balancer hash annotation for service %s: %v", key, err)
+		}
 	}
 
+	// Diff the shadow provider's view against what was just reconciled.
+	s.runShadowComparison(service, wantsLoadBalancer(service), newState)
+
 	// Write the state if changed
 	// TODO: Be careful here ... what if there were other changes to the service?
 	if !v1helper.LoadBalancerStatusEqual(previousState, newState) {
@@ -318,11 +666,7 @@ func (s *ServiceController) createLoadBalancerIfNeeded(key string, service *v1.S
 		// Update the status on the copy
 		service.Status.LoadBalancer = *newState
 
-		if err := s.persistUpdate(service); err != nil {
-			// TODO: This logic needs to be revisited. We might want to retry on all the errors, not just conflicts.
-			if errors.IsConflict(err) {
-				return fmt.Errorf("not persisting update to service '%s/%s' that has been changed since we received it: %v", service.Namespace, service.Name, err)
-			}
+		if err := s.patchStatus(service, previousState); err != nil {
 			runtime.HandleError(fmt.Errorf("failed to persist service %q updated status to apiserver, even after retries. Giving up: %v", key, err))
 			return nil
 		}
@@ -333,10 +677,27 @@ func (s *ServiceController) createLoadBalancerIfNeeded(key string, service *v1.S
 	return nil
 }
 
-func (s *ServiceController) persistUpdate(service *v1.Service) error {
-	var err error
+// patchStatus persists a load balancer status change with a strategic merge
+// patch against the status subresource. Unlike UpdateStatus, the patch carries
+// no resourceVersion precondition, so it cannot fail with a conflict when the
+// service is modified concurrently under heavy churn.
+func (s *ServiceController) patchStatus(service *v1.Service, previousStatus *v1.LoadBalancerStatus) error {
+	oldData, err := json.Marshal(&v1.Service{Status: v1.ServiceStatus{LoadBalancer: *previousStatus}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the previous status of service '%s/%s': %v", service.Namespace, service.Name, err)
+	}
+	newData, err := json.Marshal(&v1.Service{Status: service.Status})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the new status of service '%s/%s': %v", service.Namespace, service.Name, err)
+	}
+	patchBytes, err := strategicpatch.CreateTwoWayMergePatch(oldData, newData, v1.Service{})
+	if err != nil {
+		return fmt.Errorf("failed to create a status patch for service '%s/%s': %v", service.Namespace, service.Name, err)
+	}
+
+	var lastErr error
 	for i := 0; i < clientRetryCount; i++ {
-		_, err = s.kubeClient.CoreV1().Services(service.Namespace).UpdateStatus(service)
+		_, err := s.kubeClient.CoreV1().Services(service.Namespace).Patch(service.Name, types.StrategicMergePatchType, patchBytes, "status")
 		if err == nil {
 			return nil
 		}
@@ -348,33 +709,141 @@ func (s *ServiceController) persistUpdate(service *v1.Service) error {
 				service.Namespace, service.Name, err)
 			return nil
 		}
-		// TODO: Try to resolve the conflict if the change was unrelated to load
-		// balancer status. For now, just pass it up the stack.
-		if errors.IsConflict(err) {
-			return err
-		}
-		glog.Warningf("Failed to persist updated LoadBalancerStatus to service '%s/%s' after creating its load balancer: %v",
+		lastErr = err
+		glog.Warningf("Failed to patch updated LoadBalancerStatus to service '%s/%s' after creating its load balancer: %v",
 			service.Namespace, service.Name, err)
 		time.Sleep(clientRetryInterval)
 	}
-	return err
+	return lastErr
 }
 
-func (s *ServiceController) ensureLoadBalancer(service *v1.Service) (*v1.LoadBalancerStatus, error) {
-	nodes, err := s.nodeLister.ListWithPredicate(getNodeConditionPredicate())
-	if err != nil {
-		return nil, err
-	}
-
+func (s *ServiceController) ensureLoadBalancer(service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
 	// If there are no available nodes for LoadBalancer service, make a EventTypeWarning event for it.
 	if len(nodes) == 0 {
 		s.eventRecorder.Eventf(service, v1.EventTypeWarning, "UnAvailableLoadBalancer", "There are no available nodes for LoadBalancer service %s/%s", service.Namespace, service.Name)
 	}
 
+	ctx, cancel := s.reconcileContext()
+	defer cancel()
+
 	// - Only one protocol supported per service
 	// - Not all cloud providers support all protocols and the next step is expected to return
 	//   an error for unsupported protocols
-	return s.balancer.EnsureLoadBalancer(context.TODO(), s.clusterName, service, nodes)
+	status, err := s.balancer.EnsureLoadBalancer(ctx, s.clusterName, service, nodes)
+	if err != nil {
+		s.recordIfTimeout(ctx, service, "EnsureLoadBalancer")
+		return status, err
+	}
+
+	if err := s.ensureZoneAffinity(ctx, service, nodes); err != nil {
+		s.recordIfTimeout(ctx, service, "EnsureLoadBalancerZoneAffinity")
+		return status, err
+	}
+	return status, nil
+}
+
+// serviceLoadBalancerHash hashes the parts of the service spec, annotations
+// and node set that influence the cloud load balancer. The hash annotation
+// itself is excluded so writing it does not change the hash.
+func serviceLoadBalancerHash(service *v1.Service, hosts []*v1.Node) string {
+	annotations := make(map[string]string, len(service.Annotations))
+	for k, v := range service.Annotations {
+		if k != loadBalancerSpecHashAnnotation && k != serviceQuotaExceededAnnotation {
+			annotations[k] = v
+		}
+	}
+
+	hasher := fnv.New32a()
+	hashutil.DeepHashObject(hasher, &struct {
+		Type                     v1.ServiceType
+		Ports                    []v1.ServicePort
+		LoadBalancerIP           string
+		LoadBalancerSourceRanges []string
+		ExternalIPs              []string
+		SessionAffinity          v1.ServiceAffinity
+		ExternalTrafficPolicy    v1.ServiceExternalTrafficPolicyType
+		HealthCheckNodePort      int32
+		Annotations              map[string]string
+		Hosts                    []string
+	}{
+		Type:                     service.Spec.Type,
+		Ports:                    service.Spec.Ports,
+		LoadBalancerIP:           service.Spec.LoadBalancerIP,
+		LoadBalancerSourceRanges: service.Spec.LoadBalancerSourceRanges,
+		ExternalIPs:              service.Spec.ExternalIPs,
+		SessionAffinity:          service.Spec.SessionAffinity,
+		ExternalTrafficPolicy:    service.Spec.ExternalTrafficPolicy,
+		HealthCheckNodePort:      service.Spec.HealthCheckNodePort,
+		Annotations:              annotations,
+		Hosts:                    nodeNames(hosts).List(),
+	})
+	return fmt.Sprintf("%x", hasher.Sum32())
+}
+
+// canSkipEnsure reports whether the cloud EnsureLoadBalancer call can be
+// skipped because the previous successful ensure covered the same hash and
+// happened recently enough. It never skips for services that have not been
+// ensured by this controller before.
+func (s *ServiceController) canSkipEnsure(service *v1.Service, hash string) bool {
+	value, ok := service.Annotations[loadBalancerSpecHashAnnotation]
+	if !ok {
+		return false
+	}
+	parts := strings.Fields(value)
+	if len(parts) != 2 || parts[0] != hash {
+		return false
+	}
+	lastEnsured, err := time.Parse(time.RFC3339, parts[1])
+	if err != nil {
+		return false
+	}
+	return time.Since(lastEnsured) < forcedEnsurePeriod
+}
+
+// setLoadBalancerHash records the hash of the just-ensured load balancer
+// configuration on the service. It works against the latest copy from the
+// apiserver: earlier writes in the same sync (e.g. the provisioning
+// conditions) have already bumped the resourceVersion, so an update based on
+// the informer's stale copy would always conflict.
+func (s *ServiceController) setLoadBalancerHash(service *v1.Service, hash string) error {
+	latest, err := s.kubeClient.CoreV1().Services(service.Namespace).Get(service.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if latest.Annotations == nil {
+		latest.Annotations = map[string]string{}
+	}
+	latest.Annotations[loadBalancerSpecHashAnnotation] = hash + " " + time.Now().UTC().Format(time.RFC3339)
+	_, err = s.kubeClient.CoreV1().Services(latest.Namespace).Update(latest)
+	return err
+}
+
+// setQuotaExceededMark adds or removes the quota-exceeded annotation on the
+// service. Removal is a no-op when the annotation is not present. Like
+// setLoadBalancerHash it updates the latest copy from the apiserver rather
+// than the informer's stale one, which earlier writes in the same sync would
+// make conflict.
+func (s *ServiceController) setQuotaExceededMark(service *v1.Service, exceeded bool) error {
+	if _, ok := service.Annotations[serviceQuotaExceededAnnotation]; !ok && !exceeded {
+		return nil
+	}
+	latest, err := s.kubeClient.CoreV1().Services(service.Namespace).Get(service.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if exceeded {
+		if latest.Annotations == nil {
+			latest.Annotations = map[string]string{}
+		}
+		latest.Annotations[serviceQuotaExceededAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	} else {
+		if _, ok := latest.Annotations[serviceQuotaExceededAnnotation]; !ok {
+			return nil
+		}
+		delete(latest.Annotations, serviceQuotaExceededAnnotation)
+	}
+	_, err = s.kubeClient.CoreV1().Services(latest.Namespace).Update(latest)
+	return err
 }
 
 // ListKeys implements the interface required by DeltaFIFO to list the keys we
@@ -445,6 +914,16 @@ func (s *ServiceController) needsUpdate(oldService *v1.Service, newService *v1.S
 	if !wantsLoadBalancer(oldService) && !wantsLoadBalancer(newService) {
 		return false
 	}
+	if hasExternalLoadBalancerClass(oldService) != hasExternalLoadBalancerClass(newService) {
+		s.eventRecorder.Eventf(newService, v1.EventTypeNormal, "LoadBalancerClass", "%q -> %q",
+			oldService.Annotations[loadBalancerClassAnnotation], newService.Annotations[loadBalancerClassAnnotation])
+		return true
+	}
+	if hasExternalLoadBalancerClass(newService) {
+		// Both versions are designated for an external implementation;
+		// nothing here is this controller's business.
+		return false
+	}
 	if wantsLoadBalancer(oldService) != wantsLoadBalancer(newService) {
 		s.eventRecorder.Eventf(newService, v1.EventTypeNormal, "Type", "%v -> %v",
 			oldService.Spec.Type, newService.Spec.Type)
@@ -457,7 +936,14 @@ func (s *ServiceController) needsUpdate(oldService *v1.Service, newService *v1.S
 		return true
 	}
 
-	if !portsEqualForLB(oldService, newService) || oldService.Spec.SessionAffinity != newService.Spec.SessionAffinity {
+	if !portsEqualForLB(oldService, newService) {
+		s.eventRecorder.Eventf(newService, v1.EventTypeNormal, "Ports", "Count: %v -> %v",
+			len(oldService.Spec.Ports), len(newService.Spec.Ports))
+		return true
+	}
+	if oldService.Spec.SessionAffinity != newService.Spec.SessionAffinity {
+		s.eventRecorder.Eventf(newService, v1.EventTypeNormal, "SessionAffinity", "%v -> %v",
+			oldService.Spec.SessionAffinity, newService.Spec.SessionAffinity)
 		return true
 	}
 	if !loadBalancerIPsAreEqual(oldService, newService) {
@@ -478,6 +964,8 @@ func (s *ServiceController) needsUpdate(oldService *v1.Service, newService *v1.S
 		}
 	}
 	if !reflect.DeepEqual(oldService.Annotations, newService.Annotations) {
+		s.eventRecorder.Eventf(newService, v1.EventTypeNormal, "Annotations", "Changed: %v",
+			changedAnnotationKeys(oldService.Annotations, newService.Annotations))
 		return true
 	}
 	if oldService.UID != newService.UID {
@@ -499,6 +987,24 @@ func (s *ServiceController) needsUpdate(oldService *v1.Service, newService *v1.S
 	return false
 }
 
+// changedAnnotationKeys returns the sorted keys whose values differ between the
+// old and new annotation maps, so that update events can name the exact
+// annotations that triggered a load balancer re-ensure.
+func changedAnnotationKeys(oldAnnotations, newAnnotations map[string]string) []string {
+	keys := sets.NewString()
+	for k, v := range oldAnnotations {
+		if newValue, ok := newAnnotations[k]; !ok || newValue != v {
+			keys.Insert(k)
+		}
+	}
+	for k := range newAnnotations {
+		if _, ok := oldAnnotations[k]; !ok {
+			keys.Insert(k)
+		}
+	}
+	return keys.List()
+}
+
 func (s *ServiceController) loadBalancerName(service *v1.Service) string {
 	return s.balancer.GetLoadBalancerName(context.TODO(), "", service)
 }
@@ -639,6 +1145,19 @@ func (s *ServiceController) nodeSyncLoop() {
 	glog.Infof("Detected change in list of current cluster nodes. New node set: %v",
 		nodeNames(newHosts))
 
+	if s.maxHostRemovalFraction > 0 && len(s.knownHosts) > 0 {
+		removed := nodeNames(s.knownHosts).Difference(nodeNames(newHosts))
+		if fraction := float64(removed.Len()) / float64(len(s.knownHosts)); fraction > s.maxHostRemovalFraction {
+			glog.Warningf("Skipping load balancer host update: %d of %d known nodes (%.0f%%) became ineligible at once, exceeding the configured threshold of %.0f%%",
+				removed.Len(), len(s.knownHosts), fraction*100, s.maxHostRemovalFraction*100)
+			for _, service := range s.cache.allServices() {
+				s.eventRecorder.Eventf(service, v1.EventTypeWarning, "LoadBalancerHostUpdateSkipped",
+					"Skipped removing %d of %d nodes from load balancer: too many nodes became ineligible at once", removed.Len(), len(s.knownHosts))
+			}
+			return
+		}
+	}
+
 	// Try updating all services, and save the ones that fail to try again next
 	// round.
 	s.servicesToUpdate = s.cache.allServices()
@@ -651,32 +1170,58 @@ func (s *ServiceController) nodeSyncLoop() {
 }
 
 // updateLoadBalancerHosts updates all existing load balancers so that
-// they will match the list of hosts provided.
+// they will match the list of hosts provided. The load balancers are updated
+// by up to nodeSyncWorkers goroutines in parallel, so large fleets of
+// LoadBalancer services react to node membership changes without iterating
+// them one cloud call at a time.
 // Returns the list of services that couldn't be updated.
 func (s *ServiceController) updateLoadBalancerHosts(services []*v1.Service, hosts []*v1.Node) (servicesToRetry []*v1.Service) {
-	for _, service := range services {
-		func() {
-			if service == nil {
-				return
-			}
-			if err := s.lockedUpdateLoadBalancerHosts(service, hosts); err != nil {
-				glog.Errorf("External error while updating load balancer: %v.", err)
-				servicesToRetry = append(servicesToRetry, service)
-			}
-		}()
+	workers := s.nodeSyncWorkers
+	if workers < 1 {
+		workers = 1
 	}
+	var retryLock sync.Mutex
+	workqueue.ParallelizeUntil(context.TODO(), workers, len(services), func(piece int) {
+		service := services[piece]
+		if service == nil {
+			return
+		}
+		if err := s.lockedUpdateLoadBalancerHosts(service, hosts); err != nil {
+			glog.Errorf("External error while updating load balancer: %v.", err)
+			// Requeue the service through the rate-limited queue as well, so
+			// it is retried with per-service backoff instead of waiting a
+			// full node sync period.
+			if key, keyErr := controller.KeyFunc(service); keyErr == nil {
+				s.queue.AddRateLimited(key)
+			}
+			retryLock.Lock()
+			defer retryLock.Unlock()
+			servicesToRetry = append(servicesToRetry, service)
+		}
+	})
 	return servicesToRetry
 }
 
 // Updates the load balancer of a service, assuming we hold the mutex
 // associated with the service.
 func (s *ServiceController) lockedUpdateLoadBalancerHosts(service *v1.Service, hosts []*v1.Node) error {
-	if !wantsLoadBalancer(service) {
+	if !wantsLoadBalancer(service) || hasExternalLoadBalancerClass(service) {
 		return nil
 	}
 
+	ctx, cancel := s.reconcileContext()
+	defer cancel()
+
 	// This operation doesn't normally take very long (and happens pretty often), so we only record the final event
-	err := s.balancer.UpdateLoadBalancer(context.TODO(), s.clusterName, service, hosts)
+	var err error
+	if weighted, ok := s.balancer.(cloudprovider.WeightedNodesLoadBalancer); ok && s.nodeWeight != nil {
+		err = weighted.UpdateLoadBalancerWithWeights(ctx, s.clusterName, service, hosts, nodeWeights(hosts, s.nodeWeight))
+	} else {
+		err = s.balancer.UpdateLoadBalancer(ctx, s.clusterName, service, hosts)
+	}
+	if err == nil {
+		err = s.ensureZoneAffinity(ctx, service, hosts)
+	}
 	if err == nil {
 		// If there are no available nodes for LoadBalancer service, make a EventTypeWarning event for it.
 		if len(hosts) == 0 {
@@ -684,9 +1229,12 @@ func (s *ServiceController) lockedUpdateLoadBalancerHosts(service *v1.Service, h
 		} else {
 			s.eventRecorder.Event(service, v1.EventTypeNormal, "UpdatedLoadBalancer", "Updated load balancer with new hosts")
 		}
+		s.runShadowComparison(service, true, &service.Status.LoadBalancer)
 		return nil
 	}
 
+	s.recordIfTimeout(ctx, service, "UpdateLoadBalancer")
+
 	// It's only an actual error if the load balancer still exists.
 	if _, exists, err := s.balancer.GetLoadBalancer(context.TODO(), s.clusterName, service); err != nil {
 		glog.Errorf("External error while checking if load balancer %q exists: name, %v", s.balancer.GetLoadBalancerName(context.TODO(), s.clusterName, service), err)
@@ -698,10 +1246,132 @@ func (s *ServiceController) lockedUpdateLoadBalancerHosts(service *v1.Service, h
 	return err
 }
 
+// SetNodeWeightFunc configures how nodes are weighted for cloud providers
+// that implement cloudprovider.WeightedNodesLoadBalancer. Passing nil (the
+// default) keeps the unweighted UpdateLoadBalancer path.
+func (s *ServiceController) SetNodeWeightFunc(weight NodeWeightFunc) {
+	s.nodeWeight = weight
+}
+
+// DefaultNodeWeight weights a node by the node-weight label when present,
+// falling back to its allocatable CPU in whole cores, so larger nodes in
+// heterogeneous pools receive proportionally more traffic.
+func DefaultNodeWeight(node *v1.Node) int32 {
+	if v, ok := node.Labels[nodeWeightLabel]; ok {
+		if weight, err := strconv.Atoi(v); err == nil && weight > 0 {
+			return int32(weight)
+		}
+		glog.Warningf("Ignoring invalid %s label value %q on node %s", nodeWeightLabel, v, node.Name)
+	}
+	if cpu, ok := node.Status.Allocatable[v1.ResourceCPU]; ok {
+		if cores := int32(cpu.Value()); cores > 0 {
+			return cores
+		}
+	}
+	return 1
+}
+
+// nodeWeights computes the weight of each host with the configured weight
+// function, keyed by node name.
+func nodeWeights(hosts []*v1.Node, weight NodeWeightFunc) map[types.NodeName]int32 {
+	weights := make(map[types.NodeName]int32, len(hosts))
+	for _, host := range hosts {
+		weights[types.NodeName(host.Name)] = weight(host)
+	}
+	return weights
+}
+
+// ensureZoneAffinity passes the zone distribution of the backend nodes to
+// cloud providers implementing cloudprovider.ZoneAffinityLoadBalancer, for
+// services that request topology-aware routing. It is a no-op for other
+// providers and services.
+func (s *ServiceController) ensureZoneAffinity(ctx context.Context, service *v1.Service, nodes []*v1.Node) error {
+	affinityBalancer, ok := s.balancer.(cloudprovider.ZoneAffinityLoadBalancer)
+	if !ok || !wantsZoneAffinity(service) {
+		return nil
+	}
+	return affinityBalancer.EnsureLoadBalancerZoneAffinity(ctx, s.clusterName, service, zoneNodeCounts(nodes))
+}
+
+// wantsZoneAffinity reports whether the service asks for traffic to stay
+// within the client's zone, either explicitly through the topology-aware
+// hints annotation or implicitly through externalTrafficPolicy=Local.
+func wantsZoneAffinity(service *v1.Service) bool {
+	if service.Annotations[topologyAwareHintsAnnotation] == "true" {
+		return true
+	}
+	return service.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal
+}
+
+// zoneNodeCounts counts the backend nodes per zone, keyed by the node's
+// failure-domain zone label. Nodes without the label are counted under the
+// empty key.
+func zoneNodeCounts(nodes []*v1.Node) map[string]int {
+	counts := make(map[string]int, len(nodes))
+	for _, node := range nodes {
+		counts[node.Labels[kubeletapis.LabelZoneFailureDomain]]++
+	}
+	return counts
+}
+
 func wantsLoadBalancer(service *v1.Service) bool {
 	return service.Spec.Type == v1.ServiceTypeLoadBalancer
 }
 
+// hasExternalLoadBalancerClass reports whether the service is designated for an
+// external load balancer implementation via the load balancer class
+// annotation. Such services are skipped entirely by this controller.
+func hasExternalLoadBalancerClass(service *v1.Service) bool {
+	return service.Annotations[loadBalancerClassAnnotation] != ""
+}
+
+// usesStaticLoadBalancerIP reports whether the user pre-allocated a static IP
+// for the service via spec.loadBalancerIP.
+func usesStaticLoadBalancerIP(service *v1.Service) bool {
+	return service.Spec.LoadBalancerIP != ""
+}
+
+// allowsStaticIPRelease reports whether the user explicitly allowed the
+// pre-allocated IP to be released together with the load balancer.
+func allowsStaticIPRelease(service *v1.Service) bool {
+	return service.Annotations[allowStaticIPReleaseAnnotation] == "true"
+}
+
+// findStaticIPConflict returns the key of another load balancer service that
+// claims the same spec.loadBalancerIP and wins it, or the empty string if
+// this service may keep the address. The oldest claimant wins (ties broken by
+// key), so exactly one of the conflicting services keeps working instead of
+// both syncs failing forever.
+func (s *ServiceController) findStaticIPConflict(service *v1.Service) string {
+	others, err := s.serviceLister.List(labels.Everything())
+	if err != nil {
+		glog.Errorf("Failed to list services while checking static IP conflicts: %v", err)
+		return ""
+	}
+	for _, other := range others {
+		if other.Namespace == service.Namespace && other.Name == service.Name {
+			continue
+		}
+		if !wantsLoadBalancer(other) || other.Spec.LoadBalancerIP != service.Spec.LoadBalancerIP {
+			continue
+		}
+		if staticIPClaimWins(other, service) {
+			return other.Namespace + "/" + other.Name
+		}
+	}
+	return ""
+}
+
+// staticIPClaimWins reports whether service a beats service b in a fight over
+// the same pre-allocated static IP: the older service (by creationTimestamp,
+// then by key) keeps the address.
+func staticIPClaimWins(a, b *v1.Service) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+}
+
 func loadBalancerIPsAreEqual(oldService, newService *v1.Service) bool {
 	return oldService.Spec.LoadBalancerIP == newService.Spec.LoadBalancerIP
 }
@@ -720,6 +1390,10 @@ func (s *ServiceController) syncService(key string) error {
 	if err != nil {
 		return err
 	}
+	if !s.managesNamespace(namespace) {
+		glog.V(4).Infof("Skipping service %v: namespace is not managed by this controller", key)
+		return nil
+	}
 
 	// service holds the latest service info from apiserver
 	service, err := s.serviceLister.Services(namespace).Get(name)
@@ -752,13 +1426,30 @@ func (s *ServiceController) processServiceDeletion(key string) error {
 
 func (s *ServiceController) processLoadBalancerDelete(cachedService *cachedService, key string) error {
 	service := cachedService.state
-	// delete load balancer info only if the service type is LoadBalancer
-	if !wantsLoadBalancer(service) {
+	// delete load balancer info only if the service type is LoadBalancer;
+	// load balancers of externally classed services belong to another
+	// controller and must never be deleted here.
+	if !wantsLoadBalancer(service) || hasExternalLoadBalancerClass(service) {
 		return nil
 	}
 	s.eventRecorder.Event(service, v1.EventTypeNormal, "DeletingLoadBalancer", "Deleting load balancer")
-	err := s.balancer.EnsureLoadBalancerDeleted(context.TODO(), s.clusterName, service)
+	ctx, cancel := s.reconcileContext()
+	defer cancel()
+	// A pre-allocated static IP stays allocated across load balancer
+	// deletion unless the user explicitly allowed its release; mark the
+	// service copy handed to the provider accordingly.
+	if usesStaticLoadBalancerIP(service) && !allowsStaticIPRelease(service) {
+		service = service.DeepCopy()
+		if service.Annotations == nil {
+			service.Annotations = map[string]string{}
+		}
+		service.Annotations[apiservice.AnnotationRetainStaticIP] = "true"
+		s.eventRecorder.Eventf(service, v1.EventTypeNormal, "RetainingStaticIP",
+			"Pre-allocated IP %s will not be released with the load balancer; set %s=true to allow release", service.Spec.LoadBalancerIP, allowStaticIPReleaseAnnotation)
+	}
+	err := s.balancer.EnsureLoadBalancerDeleted(ctx, s.clusterName, service)
 	if err != nil {
+		s.recordIfTimeout(ctx, service, "EnsureLoadBalancerDeleted")
 		s.eventRecorder.Eventf(service, v1.EventTypeWarning, "DeletingLoadBalancerFailed", "Error deleting load balancer (will retry): %v", err)
 		return err
 	}
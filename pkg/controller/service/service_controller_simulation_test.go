@@ -0,0 +1,189 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	fakecloud "k8s.io/kubernetes/pkg/cloudprovider/providers/fake"
+	"k8s.io/kubernetes/pkg/controller"
+)
+
+// simCluster is a small in-package simulation harness: it drives a
+// ServiceController over a fake cloud provider with injected per-call
+// latency, so node churn scenarios across many LoadBalancer services can be
+// replayed deterministically and held to call-count and convergence budgets.
+type simCluster struct {
+	controller *ServiceController
+	cloud      *fakecloud.FakeCloud
+	nodeStore  cache.Store
+	nodes      []*v1.Node
+	nextNode   int
+}
+
+func newSimCluster(services int, providerLatency time.Duration, workers int) *simCluster {
+	cloud := &fakecloud.FakeCloud{}
+	cloud.Region = region
+	cloud.RequestDelay = providerLatency
+
+	client := fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(client, controller.NoResyncPeriodFunc())
+	serviceInformer := informerFactory.Core().V1().Services()
+	nodeInformer := informerFactory.Core().V1().Nodes()
+
+	c, _ := New(cloud, client, serviceInformer, nodeInformer, "test-cluster")
+	c.nodeListerSynced = alwaysReady
+	c.serviceListerSynced = alwaysReady
+	// The simulation emits far more events than a FakeRecorder buffer can
+	// hold without blocking, so events are discarded outright.
+	c.eventRecorder = &record.FakeRecorder{}
+	c.SetNodeSyncConcurrency(workers)
+	c.init()
+	cloud.Calls = nil
+
+	sim := &simCluster{
+		controller: c,
+		cloud:      cloud,
+		nodeStore:  nodeInformer.Informer().GetStore(),
+	}
+	for i := 0; i < services; i++ {
+		svc := newService(fmt.Sprintf("sim-lb-%d", i), types.UID(fmt.Sprintf("sim-uid-%d", i)), v1.ServiceTypeLoadBalancer)
+		c.cache.getOrCreate(svc.Namespace + "/" + svc.Name).state = svc
+	}
+	return sim
+}
+
+// addNodes joins count new ready nodes to the simulated cluster.
+func (c *simCluster) addNodes(count int) {
+	for i := 0; i < count; i++ {
+		node := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("sim-node-%d", c.nextNode)},
+			Status: v1.NodeStatus{
+				Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			},
+		}
+		c.nextNode++
+		c.nodes = append(c.nodes, node)
+		c.nodeStore.Add(node)
+	}
+}
+
+// removeNodes drops the oldest count nodes from the simulated cluster.
+func (c *simCluster) removeNodes(count int) {
+	for i := 0; i < count && len(c.nodes) > 0; i++ {
+		node := c.nodes[0]
+		c.nodes = c.nodes[1:]
+		c.nodeStore.Delete(node)
+	}
+}
+
+// sync runs one pass of the controller's node sync loop, as the periodic
+// nodeSyncLoop goroutine would.
+func (c *simCluster) sync() {
+	c.controller.nodeSyncLoop()
+}
+
+// TestNodeChurnSimulation replays node join/leave churn against fleets of
+// LoadBalancer services and holds the controller to an exact provider call
+// budget: every node membership change updates every load balancer exactly
+// once, no-op syncs make no provider calls at all, and the whole run
+// converges within a wall clock budget despite the injected provider
+// latency. A regression that re-lists or re-updates services per node event
+// blows the call budget immediately.
+func TestNodeChurnSimulation(t *testing.T) {
+	scenarios := []struct {
+		name            string
+		services        int
+		initialNodes    int
+		churnEvents     int
+		providerLatency time.Duration
+		workers         int
+		timeBudget      time.Duration
+	}{
+		{
+			name:         "small fleet, serial updates",
+			services:     50,
+			initialNodes: 10,
+			churnEvents:  10,
+			workers:      1,
+			timeBudget:   30 * time.Second,
+		},
+		{
+			name:            "large fleet, slow provider, parallel updates",
+			services:        200,
+			initialNodes:    50,
+			churnEvents:     20,
+			providerLatency: 100 * time.Microsecond,
+			workers:         8,
+			timeBudget:      30 * time.Second,
+		},
+	}
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			sim := newSimCluster(scenario.services, scenario.providerLatency, scenario.workers)
+			sim.addNodes(scenario.initialNodes)
+
+			start := time.Now()
+			sim.sync()
+			for i := 0; i < scenario.churnEvents; i++ {
+				if i%2 == 0 {
+					sim.addNodes(1)
+				} else {
+					sim.removeNodes(1)
+				}
+				sim.sync()
+			}
+			elapsed := time.Since(start)
+
+			// Every node membership change must touch every load balancer
+			// exactly once - no retries and no duplicate updates.
+			expectedUpdates := (scenario.churnEvents + 1) * scenario.services
+			if got := len(sim.cloud.UpdateCalls); got != expectedUpdates {
+				t.Errorf("expected %d load balancer updates for %d node changes across %d services, got %d",
+					expectedUpdates, scenario.churnEvents+1, scenario.services, got)
+			} else {
+				// After the last sync every load balancer must have converged
+				// on the final node set.
+				for _, call := range sim.cloud.UpdateCalls[len(sim.cloud.UpdateCalls)-scenario.services:] {
+					if len(call.Hosts) != len(sim.nodes) {
+						t.Errorf("load balancer for service %s converged on %d hosts, want %d",
+							call.Service.Name, len(call.Hosts), len(sim.nodes))
+					}
+				}
+			}
+			if elapsed > scenario.timeBudget {
+				t.Errorf("convergence took %v, over the %v budget", elapsed, scenario.timeBudget)
+			}
+
+			// A sync without node changes must not call the provider at all.
+			sim.cloud.ClearCalls()
+			sim.sync()
+			if calls := len(sim.cloud.Calls); calls != 0 {
+				t.Errorf("expected no provider calls on a no-op node sync, got %d: %v", calls, sim.cloud.Calls)
+			}
+		})
+	}
+}
@@ -17,24 +17,32 @@ limitations under the License.
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/api/testapi"
 	fakecloud "k8s.io/kubernetes/pkg/cloudprovider/providers/fake"
 	"k8s.io/kubernetes/pkg/controller"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
 )
 
 const region = "us-central"
@@ -185,12 +193,12 @@ func TestCreateExternalLoadBalancer(t *testing.T) {
 			}
 			actionFound := false
 			for _, action := range actions {
-				if action.GetVerb() == "update" && action.GetResource().Resource == "services" {
+				if action.GetVerb() == "patch" && action.GetResource().Resource == "services" && action.GetSubresource() == "status" {
 					actionFound = true
 				}
 			}
 			if !actionFound {
-				t.Errorf("expected updated service to be sent to client, got these actions instead: %v", actions)
+				t.Errorf("expected service status patch to be sent to client, got these actions instead: %v", actions)
 			}
 		}
 	}
@@ -281,6 +289,56 @@ func TestUpdateNodesInExternalLoadBalancer(t *testing.T) {
 	}
 }
 
+func TestEnsureZoneAffinity(t *testing.T) {
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node0", Labels: map[string]string{kubeletapis.LabelZoneFailureDomain: "us-central1-a"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{kubeletapis.LabelZoneFailureDomain: "us-central1-a"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node2", Labels: map[string]string{kubeletapis.LabelZoneFailureDomain: "us-central1-b"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node3"}},
+	}
+	expectedCounts := map[string]int{"us-central1-a": 2, "us-central1-b": 1, "": 1}
+
+	localService := newService("s0", "111", v1.ServiceTypeLoadBalancer)
+	localService.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeLocal
+	hintedService := newService("s1", "222", v1.ServiceTypeLoadBalancer)
+	hintedService.Annotations = map[string]string{topologyAwareHintsAnnotation: "true"}
+
+	table := []struct {
+		service               *v1.Service
+		expectedAffinityCalls []fakecloud.FakeZoneAffinityCall
+	}{
+		{
+			// The default traffic policy does not request zone affinity.
+			service:               newService("s2", "333", v1.ServiceTypeLoadBalancer),
+			expectedAffinityCalls: nil,
+		},
+		{
+			// externalTrafficPolicy=Local requests zone affinity implicitly.
+			service: localService,
+			expectedAffinityCalls: []fakecloud.FakeZoneAffinityCall{
+				{Service: localService, ZoneNodeCounts: expectedCounts},
+			},
+		},
+		{
+			// The topology-aware hints annotation requests it explicitly.
+			service: hintedService,
+			expectedAffinityCalls: []fakecloud.FakeZoneAffinityCall{
+				{Service: hintedService, ZoneNodeCounts: expectedCounts},
+			},
+		},
+	}
+	for _, item := range table {
+		controller, cloud, _ := newController()
+
+		if err := controller.lockedUpdateLoadBalancerHosts(item.service, nodes); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(item.expectedAffinityCalls, cloud.AffinityCalls) {
+			t.Errorf("expected zone affinity calls mismatch, expected %+v, got %+v", item.expectedAffinityCalls, cloud.AffinityCalls)
+		}
+	}
+}
+
 func TestGetNodeConditionPredicate(t *testing.T) {
 	tests := []struct {
 		node         v1.Node
@@ -415,35 +473,35 @@ func TestProcessServiceUpdate(t *testing.T) {
 
 }
 
-// TestConflictWhenProcessServiceUpdate tests if processServiceUpdate will
-// retry creating the load balancer if the update operation returns a conflict
-// error.
+// TestConflictWhenProcessServiceUpdate tests that concurrent modifications of
+// the service no longer fail the status write: the status is persisted with a
+// patch that carries no resourceVersion precondition, so update conflicts
+// cannot occur.
 func TestConflictWhenProcessServiceUpdate(t *testing.T) {
 	svcName := "conflict-lb"
 	svc := newService(svcName, types.UID("123"), v1.ServiceTypeLoadBalancer)
 	controller, _, client := newController()
+	if _, err := client.CoreV1().Services(svc.Namespace).Create(svc); err != nil {
+		t.Fatal(err)
+	}
+	// Fail every update with a conflict, as if the service were modified
+	// concurrently; the status patch must not be affected.
 	client.PrependReactor("update", "services", func(action core.Action) (bool, runtime.Object, error) {
 		update := action.(core.UpdateAction)
 		return true, update.GetObject(), apierrors.NewConflict(action.GetResource().GroupResource(), svcName, errors.New("object changed"))
 	})
 
 	svcCache := controller.cache.getOrCreate(svcName)
-	if err := controller.processServiceUpdate(svcCache, svc, svcName); err == nil {
-		t.Fatalf("controller.processServiceUpdate() = nil, want error")
+	if err := controller.processServiceUpdate(svcCache, svc, svcName); err != nil {
+		t.Fatalf("controller.processServiceUpdate() = %v, want nil", err)
 	}
 
-	retryMsg := "Error creating load balancer (will retry)"
-	if gotEvent := func() bool {
-		events := controller.eventRecorder.(*record.FakeRecorder).Events
-		for len(events) > 0 {
-			e := <-events
-			if strings.Contains(e, retryMsg) {
-				return true
-			}
-		}
-		return false
-	}(); !gotEvent {
-		t.Errorf("controller.processServiceUpdate() = can't find retry creating lb event, want event contains %q", retryMsg)
+	patched, err := client.CoreV1().Services(svc.Namespace).Get(svcName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patched.Status.LoadBalancer.Ingress) != 1 {
+		t.Errorf("expected the load balancer status to be persisted despite the conflicting updates, got %v", patched.Status.LoadBalancer)
 	}
 }
 
@@ -848,3 +906,404 @@ func TestNodeSlicesEqualForLB(t *testing.T) {
 		t.Errorf("nodeSlicesEqualForLB() expected=false obtained=true")
 	}
 }
+
+func TestIsQuotaError(t *testing.T) {
+	if isQuotaError(nil) {
+		t.Errorf("nil error should not match")
+	}
+	if isQuotaError(fmt.Errorf("googleapi: Error 400: Invalid value")) {
+		t.Errorf("non-quota error should not match")
+	}
+	for _, message := range []string{
+		"googleapi: Error 403: QUOTA_EXCEEDED",
+		"TooManyLoadBalancers: LimitExceeded",
+		"compute quota exceeded for project",
+	} {
+		if !isQuotaError(fmt.Errorf(message)) {
+			t.Errorf("expected %q to be detected as a quota error", message)
+		}
+	}
+
+	RegisterQuotaErrorMatcher(func(err error) bool {
+		return strings.Contains(err.Error(), "custom-quota-token")
+	})
+	defer func() { quotaErrorMatchers = []QuotaErrorMatcher{matchCommonQuotaErrors} }()
+	if !isQuotaError(fmt.Errorf("custom-quota-token")) {
+		t.Errorf("registered matcher was not consulted")
+	}
+}
+
+type hintedError struct {
+	error
+	after time.Duration
+}
+
+func (e hintedError) RequeueAfter() time.Duration { return e.after }
+
+func TestPrerequisiteRequeueDelay(t *testing.T) {
+	if _, ok := prerequisiteRequeueDelay(nil); ok {
+		t.Errorf("nil error should not match")
+	}
+	if _, ok := prerequisiteRequeueDelay(fmt.Errorf("some other failure")); ok {
+		t.Errorf("generic error should not match")
+	}
+	for _, message := range []string{
+		"InvalidSubnetID.NotFound: the subnet ID 'subnet-1' does not exist",
+		"InvalidGroup.NotFound: the security group 'sg-1' does not exist",
+		"could not find any suitable subnets for creating the ELB",
+	} {
+		delay, ok := prerequisiteRequeueDelay(fmt.Errorf(message))
+		if !ok {
+			t.Errorf("expected %q to be detected as a prerequisite error", message)
+		} else if delay != prerequisiteRetryDelay {
+			t.Errorf("expected delay %v for %q, got %v", prerequisiteRetryDelay, message, delay)
+		}
+	}
+
+	if delay, ok := prerequisiteRequeueDelay(hintedError{fmt.Errorf("slow"), time.Minute}); !ok || delay != time.Minute {
+		t.Errorf("expected the error-carried hint to win, got ok=%v delay=%v", ok, delay)
+	}
+
+	RegisterPrerequisiteErrorMatcher(func(err error) (time.Duration, bool) {
+		if strings.Contains(err.Error(), "custom-prereq-token") {
+			return 42 * time.Second, true
+		}
+		return 0, false
+	})
+	defer func() {
+		prerequisiteErrorMatchers = []PrerequisiteErrorMatcher{matchCommonPrerequisiteErrors}
+	}()
+	if delay, ok := prerequisiteRequeueDelay(fmt.Errorf("custom-prereq-token")); !ok || delay != 42*time.Second {
+		t.Errorf("registered matcher was not consulted, got ok=%v delay=%v", ok, delay)
+	}
+}
+
+func TestFindStaticIPConflict(t *testing.T) {
+	controller, _, _ := newController()
+
+	now := metav1.Now()
+	other := defaultExternalService()
+	other.Name = "other-balancer"
+	other.Spec.LoadBalancerIP = "10.10.10.10"
+	other.CreationTimestamp = metav1.NewTime(now.Add(-time.Hour))
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(other)
+	controller.serviceLister = corelisters.NewServiceLister(indexer)
+
+	table := []struct {
+		desc             string
+		loadBalancerIP   string
+		expectedConflict string
+	}{
+		{
+			desc:             "same static IP as an older service",
+			loadBalancerIP:   "10.10.10.10",
+			expectedConflict: "default/other-balancer",
+		},
+		{
+			desc:             "unclaimed static IP",
+			loadBalancerIP:   "10.10.10.11",
+			expectedConflict: "",
+		},
+	}
+	for _, item := range table {
+		svc := defaultExternalService()
+		svc.Spec.LoadBalancerIP = item.loadBalancerIP
+		svc.CreationTimestamp = now
+		if conflict := controller.findStaticIPConflict(svc); conflict != item.expectedConflict {
+			t.Errorf("%s: expected conflict %q, got %q", item.desc, item.expectedConflict, conflict)
+		}
+	}
+
+	// The oldest claimant wins: a service created before the other one keeps
+	// the address and syncs normally.
+	svc := defaultExternalService()
+	svc.Spec.LoadBalancerIP = "10.10.10.10"
+	svc.CreationTimestamp = metav1.NewTime(now.Add(-2 * time.Hour))
+	if conflict := controller.findStaticIPConflict(svc); conflict != "" {
+		t.Errorf("expected the older service to keep the static IP, got conflict %q", conflict)
+	}
+
+	// Equal timestamps fall back to the key, so the outcome stays
+	// deterministic instead of both services failing.
+	svc.CreationTimestamp = other.CreationTimestamp
+	if conflict := controller.findStaticIPConflict(svc); conflict != "" {
+		t.Errorf("expected %q to win the key tie-break, got conflict %q", "default/external-balancer", conflict)
+	}
+
+	// A service never conflicts with itself.
+	other.Spec.LoadBalancerIP = "10.10.10.10"
+	if conflict := controller.findStaticIPConflict(other); conflict != "" {
+		t.Errorf("expected no conflict for the claiming service itself, got %q", conflict)
+	}
+}
+
+func TestDefaultNodeWeight(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		node     *v1.Node
+		expected int32
+	}{
+		{
+			desc: "weight label wins over capacity",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node0", Labels: map[string]string{nodeWeightLabel: "7"}},
+				Status: v1.NodeStatus{
+					Allocatable: v1.ResourceList{v1.ResourceCPU: resource.MustParse("16")},
+				},
+			},
+			expected: 7,
+		},
+		{
+			desc: "invalid label falls back to allocatable CPU",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{nodeWeightLabel: "heavy"}},
+				Status: v1.NodeStatus{
+					Allocatable: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")},
+				},
+			},
+			expected: 4,
+		},
+		{
+			desc:     "no label and no capacity defaults to 1",
+			node:     &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2"}},
+			expected: 1,
+		},
+	}
+	for _, tc := range testCases {
+		if weight := DefaultNodeWeight(tc.node); weight != tc.expected {
+			t.Errorf("%s: expected weight %d, got %d", tc.desc, tc.expected, weight)
+		}
+	}
+}
+
+func TestExternalLoadBalancerClass(t *testing.T) {
+	controller, cloud, _ := newController()
+
+	classed := defaultExternalService()
+	classed.Annotations = map[string]string{loadBalancerClassAnnotation: "example.com/custom"}
+
+	// Designated services are skipped entirely: no cloud calls, but the
+	// service is still cached.
+	if err := controller.processServiceUpdate(&cachedService{}, classed, "external-balancer"); err != nil {
+		t.Errorf("unexpected error processing classed service: %v", err)
+	}
+	if len(cloud.Calls) != 0 {
+		t.Errorf("expected no cloud calls for classed service, got %v", cloud.Calls)
+	}
+	if _, ok := controller.cache.get("external-balancer"); !ok {
+		t.Errorf("expected classed service to be cached")
+	}
+
+	// Setting the class on a previously managed service must clean up the
+	// load balancer this controller created.
+	cached := &cachedService{state: defaultExternalService()}
+	if err := controller.processServiceUpdate(cached, classed, "external-balancer"); err != nil {
+		t.Errorf("unexpected error processing class transition: %v", err)
+	}
+	if !reflect.DeepEqual(cloud.Calls, []string{"delete"}) {
+		t.Errorf("expected the controller's own load balancer to be deleted on class transition, got %v", cloud.Calls)
+	}
+
+	// Deleting a classed service must not touch the external load balancer.
+	cloud.Calls = nil
+	controller.cache.set("external-balancer", &cachedService{state: classed})
+	if err := controller.processServiceDeletion("external-balancer"); err != nil {
+		t.Errorf("unexpected error deleting classed service: %v", err)
+	}
+	if len(cloud.Calls) != 0 {
+		t.Errorf("expected no cloud calls deleting classed service, got %v", cloud.Calls)
+	}
+
+	// Class transitions trigger a sync; services that keep a class set do not.
+	unclassed := defaultExternalService()
+	if !controller.needsUpdate(unclassed, classed) {
+		t.Errorf("expected needsUpdate to be true when the class is set")
+	}
+	changed := defaultExternalService()
+	changed.Annotations = map[string]string{loadBalancerClassAnnotation: "example.com/custom"}
+	changed.Spec.LoadBalancerIP = "1.2.3.4"
+	if controller.needsUpdate(classed, changed) {
+		t.Errorf("expected needsUpdate to be false while the class stays set")
+	}
+}
+
+func TestCompareWithShadow(t *testing.T) {
+	primaryStatus := &v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}}
+
+	table := []struct {
+		desc     string
+		shadow   *fakecloud.FakeCloud
+		wantsLB  bool
+		status   *v1.LoadBalancerStatus
+		expected string
+	}{
+		{
+			desc:     "shadow agrees on an existing load balancer",
+			shadow:   &fakecloud.FakeCloud{Exists: true, ExternalIP: net.ParseIP("1.2.3.4")},
+			wantsLB:  true,
+			status:   primaryStatus,
+			expected: "",
+		},
+		{
+			desc:     "shadow fails to get the load balancer",
+			shadow:   &fakecloud.FakeCloud{Err: errors.New("shadow unavailable")},
+			wantsLB:  true,
+			status:   primaryStatus,
+			expected: shadowMismatchError,
+		},
+		{
+			desc:     "shadow does not see the load balancer",
+			shadow:   &fakecloud.FakeCloud{Exists: false, ExternalIP: net.ParseIP("1.2.3.4")},
+			wantsLB:  true,
+			status:   primaryStatus,
+			expected: shadowMismatchExistence,
+		},
+		{
+			desc:     "shadow reports a different status",
+			shadow:   &fakecloud.FakeCloud{Exists: true, ExternalIP: net.ParseIP("5.6.7.8")},
+			wantsLB:  true,
+			status:   primaryStatus,
+			expected: shadowMismatchStatus,
+		},
+		{
+			desc:     "shadow agrees the load balancer is gone",
+			shadow:   &fakecloud.FakeCloud{Exists: false, ExternalIP: net.ParseIP("1.2.3.4")},
+			wantsLB:  false,
+			status:   &v1.LoadBalancerStatus{},
+			expected: "",
+		},
+	}
+
+	for _, item := range table {
+		controller, _, _ := newController()
+		controller.SetShadowBalancer(item.shadow)
+		mismatch := controller.compareWithShadow(context.TODO(), defaultExternalService(), item.wantsLB, item.status)
+		if mismatch != item.expected {
+			t.Errorf("%s: expected mismatch %q, got %q", item.desc, item.expected, mismatch)
+		}
+	}
+}
+
+func TestUpdateLoadBalancerHostsParallel(t *testing.T) {
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node0"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+	}
+	services := []*v1.Service{
+		newService("s0", "111", v1.ServiceTypeLoadBalancer),
+		nil,
+		newService("s1", "222", v1.ServiceTypeLoadBalancer),
+		newService("s2", "333", v1.ServiceTypeNodePort),
+		newService("s3", "444", v1.ServiceTypeLoadBalancer),
+	}
+
+	controller, cloud, _ := newController()
+	controller.SetNodeSyncConcurrency(3)
+
+	servicesToRetry := controller.updateLoadBalancerHosts(services, nodes)
+	if len(servicesToRetry) != 0 {
+		t.Errorf("unexpected services to retry: %v", servicesToRetry)
+	}
+
+	// The updates run concurrently, so compare the set of updated services
+	// rather than their order.
+	updated := sets.NewString()
+	for _, call := range cloud.UpdateCalls {
+		updated.Insert(call.Service.Name)
+		if !reflect.DeepEqual(call.Hosts, nodes) {
+			t.Errorf("unexpected hosts for service %s: %v", call.Service.Name, call.Hosts)
+		}
+	}
+	if expected := sets.NewString("s0", "s1", "s3"); !updated.Equal(expected) {
+		t.Errorf("expected updates for %v, got %v", expected.List(), updated.List())
+	}
+
+	// A failing provider reports every load balancer service for retry.
+	controller, cloud, _ = newController()
+	controller.SetNodeSyncConcurrency(3)
+	cloud.Err = errors.New("cloud unavailable")
+
+	servicesToRetry = controller.updateLoadBalancerHosts(services, nodes)
+	if len(servicesToRetry) != 3 {
+		t.Errorf("expected 3 services to retry, got %v", servicesToRetry)
+	}
+}
+
+func TestManagesNamespace(t *testing.T) {
+	table := []struct {
+		desc      string
+		allowlist []string
+		denylist  []string
+		namespace string
+		expected  bool
+	}{
+		{
+			desc:      "no lists configured manages everything",
+			namespace: "default",
+			expected:  true,
+		},
+		{
+			desc:      "allowlisted namespace is managed",
+			allowlist: []string{"default", "kube-system"},
+			namespace: "default",
+			expected:  true,
+		},
+		{
+			desc:      "namespace outside the allowlist is not managed",
+			allowlist: []string{"default"},
+			namespace: "external",
+			expected:  false,
+		},
+		{
+			desc:      "denylisted namespace is not managed",
+			denylist:  []string{"external"},
+			namespace: "external",
+			expected:  false,
+		},
+		{
+			desc:      "namespace outside the denylist is managed",
+			denylist:  []string{"external"},
+			namespace: "default",
+			expected:  true,
+		},
+		{
+			desc:      "denylist wins over allowlist",
+			allowlist: []string{"default"},
+			denylist:  []string{"default"},
+			namespace: "default",
+			expected:  false,
+		},
+	}
+	for _, item := range table {
+		controller, _, _ := newController()
+		controller.SetNamespaceAllowlist(item.allowlist)
+		controller.SetNamespaceDenylist(item.denylist)
+		if got := controller.managesNamespace(item.namespace); got != item.expected {
+			t.Errorf("%v: expected %v, got %v", item.desc, item.expected, got)
+		}
+	}
+}
+
+func TestSyncServiceSkipsUnmanagedNamespace(t *testing.T) {
+	controller, cloud, _ := newController()
+	controller.SetNamespaceDenylist([]string{"external"})
+
+	svc := defaultExternalService()
+	svc.Namespace = "external"
+	key := "external/" + svc.Name
+	cachedSvc := controller.cache.getOrCreate(key)
+	cachedSvc.state = svc
+
+	// The service is absent from the lister, so without the namespace gate
+	// the sync would treat it as deleted and tear down the load balancer.
+	if err := controller.syncService(key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cloud.Calls) != 0 {
+		t.Errorf("unexpected cloud provider calls: %v", cloud.Calls)
+	}
+	if _, ok := controller.cache.get(key); !ok {
+		t.Errorf("expected the cached service to be left untouched")
+	}
+}
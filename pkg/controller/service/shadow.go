@@ -0,0 +1,124 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/api/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
+	v1helper "k8s.io/kubernetes/pkg/apis/core/v1/helper"
+)
+
+const serviceControllerSubsystem = "service_controller"
+
+// Mismatch classifications reported by the shadow comparison.
+const (
+	shadowMismatchError     = "error"
+	shadowMismatchName      = "name"
+	shadowMismatchExistence = "existence"
+	shadowMismatchStatus    = "status"
+)
+
+var (
+	shadowComparisons = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: serviceControllerSubsystem,
+			Name:      "shadow_provider_comparisons",
+			Help:      "Number of reconciles compared against the shadow load balancer provider.",
+		},
+	)
+	shadowMismatches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: serviceControllerSubsystem,
+			Name:      "shadow_provider_mismatches",
+			Help:      "Number of divergences between the primary and the shadow load balancer provider, by kind of mismatch.",
+		},
+		[]string{"mismatch"},
+	)
+)
+
+var registerShadowMetricsOnce sync.Once
+
+func registerShadowMetrics() {
+	registerShadowMetricsOnce.Do(func() {
+		prometheus.MustRegister(shadowComparisons)
+		prometheus.MustRegister(shadowMismatches)
+	})
+}
+
+// SetShadowBalancer configures a secondary load balancer implementation that
+// is consulted read-only after every reconcile and whose view of the world is
+// diffed against the primary's result. Divergences are logged and counted in
+// the shadow_provider_mismatches metric but never fail the reconcile, so an
+// out-of-tree implementation can be validated against the in-tree one before
+// traffic management is handed over. Must be called before Run.
+func (s *ServiceController) SetShadowBalancer(balancer cloudprovider.LoadBalancer) {
+	s.shadowBalancer = balancer
+	registerShadowMetrics()
+}
+
+// runShadowComparison diffs the shadow provider's view of the service's load
+// balancer against the primary's reconcile result and publishes the outcome.
+// It makes only read calls against the shadow provider.
+func (s *ServiceController) runShadowComparison(service *v1.Service, wantsLB bool, primaryStatus *v1.LoadBalancerStatus) {
+	if s.shadowBalancer == nil {
+		return
+	}
+	ctx, cancel := s.reconcileContext()
+	defer cancel()
+
+	shadowComparisons.Inc()
+	mismatch := s.compareWithShadow(ctx, service, wantsLB, primaryStatus)
+	if mismatch == "" {
+		return
+	}
+	shadowMismatches.WithLabelValues(mismatch).Inc()
+}
+
+// compareWithShadow returns the kind of divergence between the shadow
+// provider and the primary's reconcile result, or the empty string when the
+// two agree. Details of the divergence are logged here.
+func (s *ServiceController) compareWithShadow(ctx context.Context, service *v1.Service, wantsLB bool, primaryStatus *v1.LoadBalancerStatus) string {
+	key := service.Namespace + "/" + service.Name
+
+	primaryName := s.balancer.GetLoadBalancerName(ctx, s.clusterName, service)
+	shadowName := s.shadowBalancer.GetLoadBalancerName(ctx, s.clusterName, service)
+	if primaryName != shadowName {
+		glog.Warningf("Shadow provider disagrees on the load balancer name for service %s: primary %q, shadow %q", key, primaryName, shadowName)
+		return shadowMismatchName
+	}
+
+	shadowStatus, shadowExists, err := s.shadowBalancer.GetLoadBalancer(ctx, s.clusterName, service)
+	if err != nil {
+		glog.Warningf("Shadow provider failed to get the load balancer for service %s: %v", key, err)
+		return shadowMismatchError
+	}
+	if shadowExists != wantsLB {
+		glog.Warningf("Shadow provider disagrees on load balancer existence for service %s: wanted %t, shadow reports %t", key, wantsLB, shadowExists)
+		return shadowMismatchExistence
+	}
+	if wantsLB && !v1helper.LoadBalancerStatusEqual(primaryStatus, shadowStatus) {
+		glog.Warningf("Shadow provider disagrees on the load balancer status for service %s: primary %+v, shadow %+v", key, primaryStatus, shadowStatus)
+		return shadowMismatchStatus
+	}
+	return ""
+}
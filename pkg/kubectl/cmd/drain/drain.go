@@ -17,10 +17,14 @@ limitations under the License.
 package drain
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -36,9 +40,12 @@ import (
 	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/flowcontrol"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -54,18 +61,44 @@ type DrainOptions struct {
 	PrintFlags *genericclioptions.PrintFlags
 	ToPrinter  func(string) (printers.ResourcePrinterFunc, error)
 
-	Namespace          string
-	client             kubernetes.Interface
-	restClient         *restclient.RESTClient
-	Force              bool
-	DryRun             bool
-	GracePeriodSeconds int
-	IgnoreDaemonsets   bool
-	Timeout            time.Duration
-	DeleteLocalData    bool
-	Selector           string
-	PodSelector        string
-	nodeInfos          []*resource.Info
+	Namespace           string
+	client              kubernetes.Interface
+	restClient          *restclient.RESTClient
+	restConfig          *restclient.Config
+	Force               bool
+	DryRun              bool
+	GracePeriodSeconds  int
+	IgnoreDaemonsets    bool
+	Timeout             time.Duration
+	TimeoutPerPod       time.Duration
+	DeleteLocalData     bool
+	LocalDataBackupDir  string
+	Selector            string
+	PodSelector         string
+	TaintBased          bool
+	TolerationSeconds   int
+	WaitForReplacements bool
+	EvictionRate        float32
+	EvictionBurst       int
+	Coordinate          bool
+	nodeInfos           []*resource.Info
+
+	// leaseHolder identifies this drain invocation in coordination leases
+	// taken on PodDisruptionBudgets when --coordinate is set.
+	leaseHolder string
+
+	// jsonProgress switches the per-pod output from the human readable
+	// printer to one JSON progress event per line; progressMutex keeps
+	// events from the concurrent eviction goroutines from interleaving.
+	jsonProgress  bool
+	progressMutex sync.Mutex
+
+	// evictionLimiter and namespaceLimiters throttle evictions when
+	// --eviction-rate is set. namespaceLimiters is guarded by limiterMutex
+	// because evictions run concurrently per owner group.
+	evictionLimiter   flowcontrol.RateLimiter
+	limiterMutex      sync.Mutex
+	namespaceLimiters map[string]flowcontrol.RateLimiter
 
 	genericclioptions.IOStreams
 }
@@ -90,6 +123,25 @@ const (
 	kLocalStorageWarning = "Deleting pods with local storage"
 	kUnmanagedFatal      = "pods not managed by ReplicationController, ReplicaSet, Job, DaemonSet or StatefulSet (use --force to override)"
 	kUnmanagedWarning    = "Deleting pods not managed by ReplicationController, ReplicaSet, Job, DaemonSet or StatefulSet"
+
+	// drainTaintKey is the NoExecute taint applied by --taint-based drains
+	// and removed again by uncordon.
+	drainTaintKey = "node.kubernetes.io/kubectl-drain"
+
+	// drainLeaseAnnotation carries the coordination lease --coordinate takes
+	// on a PodDisruptionBudget while evicting one of the pods it covers, so
+	// concurrent drains of different nodes serialize evictions of the same
+	// workload instead of racing the budget.
+	drainLeaseAnnotation = "kubectl.kubernetes.io/drain-lease"
+	// drainLeaseTTL is how long a lease is honored without renewal; a drain
+	// that crashed while holding a lease stops blocking others after this.
+	drainLeaseTTL = 2 * time.Minute
+	// drainLeasePollInterval is how often a blocked drain rechecks a lease.
+	drainLeasePollInterval = 2 * time.Second
+	// taintDrainWaitBuffer is added on top of --toleration-seconds when no
+	// explicit --timeout is set, to cover taint manager propagation and pod
+	// termination grace periods.
+	taintDrainWaitBuffer = 60 * time.Second
 )
 
 var (
@@ -186,7 +238,10 @@ var (
 		$ kubectl drain foo --force
 
 		# As above, but abort if there are pods not managed by a ReplicationController, ReplicaSet, Job, DaemonSet or StatefulSet, and use a grace period of 15 minutes.
-		$ kubectl drain foo --grace-period=900`))
+		$ kubectl drain foo --grace-period=900
+
+		# Drain only the batch-tier pods from node "foo", leaving everything else running.
+		$ kubectl drain foo --pod-selector=tier=batch`))
 )
 
 func NewDrainOptions(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *DrainOptions {
@@ -195,6 +250,8 @@ func NewDrainOptions(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *
 
 		IOStreams:          ioStreams,
 		GracePeriodSeconds: -1,
+		TolerationSeconds:  60,
+		EvictionBurst:      1,
 	}
 }
 
@@ -215,10 +272,18 @@ func NewCmdDrain(f cmdutil.Factory, ioStreams genericclioptions.IOStreams) *cobr
 	cmd.Flags().BoolVar(&options.Force, "force", options.Force, "Continue even if there are pods not managed by a ReplicationController, ReplicaSet, Job, DaemonSet or StatefulSet.")
 	cmd.Flags().BoolVar(&options.IgnoreDaemonsets, "ignore-daemonsets", options.IgnoreDaemonsets, "Ignore DaemonSet-managed pods.")
 	cmd.Flags().BoolVar(&options.DeleteLocalData, "delete-local-data", options.DeleteLocalData, "Continue even if there are pods using emptyDir (local data that will be deleted when the node is drained).")
+	cmd.Flags().StringVar(&options.LocalDataBackupDir, "local-data-backup-dir", options.LocalDataBackupDir, "With --delete-local-data, archive the emptyDir contents of each pod as tar files into this local directory before the pods are deleted. The drain is aborted if any backup fails.")
 	cmd.Flags().IntVar(&options.GracePeriodSeconds, "grace-period", options.GracePeriodSeconds, "Period of time in seconds given to each pod to terminate gracefully. If negative, the default value specified in the pod will be used.")
 	cmd.Flags().DurationVar(&options.Timeout, "timeout", options.Timeout, "The length of time to wait before giving up, zero means infinite")
+	cmd.Flags().DurationVar(&options.TimeoutPerPod, "timeout-per-pod", options.TimeoutPerPod, "The length of time to wait for a single pod to be evicted and terminate before giving up on it, zero means infinite. A pod stuck on its disruption budget then fails alone instead of consuming the whole --timeout.")
 	cmd.Flags().StringVarP(&options.Selector, "selector", "l", options.Selector, "Selector (label query) to filter on")
 	cmd.Flags().StringVarP(&options.PodSelector, "pod-selector", "", options.PodSelector, "Label selector to filter pods on the node")
+	cmd.Flags().BoolVar(&options.TaintBased, "taint-based", options.TaintBased, "Apply a NoExecute taint and let the taint manager evict pods gradually instead of evicting them directly.")
+	cmd.Flags().IntVar(&options.TolerationSeconds, "toleration-seconds", options.TolerationSeconds, "With --taint-based, how long tolerating pods are expected to stay before the node is considered stuck. Ignored when --timeout is set.")
+	cmd.Flags().BoolVar(&options.WaitForReplacements, "wait-for-replacements", options.WaitForReplacements, "Wait until the controllers owning the evicted pods report full availability again before finishing.")
+	cmd.Flags().Float32Var(&options.EvictionRate, "eviction-rate", options.EvictionRate, "Maximum number of evictions issued per second, enforced both overall and within each namespace. Zero means unlimited.")
+	cmd.Flags().IntVar(&options.EvictionBurst, "burst", options.EvictionBurst, "With --eviction-rate, how many evictions may be issued at once before the rate limit kicks in.")
+	cmd.Flags().BoolVar(&options.Coordinate, "coordinate", options.Coordinate, "Serialize evictions with other coordinated drains by taking a short-lived lease annotation on the pod's PodDisruptionBudget before each eviction.")
 
 	cmdutil.AddDryRunFlag(cmd)
 	return cmd
@@ -251,11 +316,43 @@ func (o *DrainOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []st
 		}
 	}
 
+	if o.TolerationSeconds < 0 {
+		return errors.New("--toleration-seconds must not be negative")
+	}
+
+	if o.TimeoutPerPod < 0 {
+		return errors.New("--timeout-per-pod must not be negative")
+	}
+
+	if len(o.LocalDataBackupDir) > 0 && !o.DeleteLocalData {
+		return errors.New("--local-data-backup-dir requires --delete-local-data")
+	}
+
+	if o.EvictionRate < 0 {
+		return errors.New("--eviction-rate must not be negative")
+	}
+	if o.EvictionRate > 0 {
+		if o.EvictionBurst < 1 {
+			return errors.New("--burst must be at least 1")
+		}
+		o.evictionLimiter = flowcontrol.NewTokenBucketRateLimiter(o.EvictionRate, o.EvictionBurst)
+		o.namespaceLimiters = map[string]flowcontrol.RateLimiter{}
+	}
+
+	if o.Coordinate {
+		o.leaseHolder = string(uuid.NewUUID())
+	}
+
 	o.restClient, err = f.RESTClient()
 	if err != nil {
 		return err
 	}
 
+	o.restConfig, err = f.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
 	o.nodeInfos = []*resource.Info{}
 
 	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
@@ -263,6 +360,8 @@ func (o *DrainOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []st
 		return err
 	}
 
+	o.jsonProgress = o.PrintFlags.OutputFormat != nil && *o.PrintFlags.OutputFormat == "json"
+
 	o.ToPrinter = func(operation string) (printers.ResourcePrinterFunc, error) {
 		o.PrintFlags.NamePrintFlags.Operation = operation
 		if o.DryRun {
@@ -325,7 +424,11 @@ func (o *DrainOptions) RunDrain() error {
 	for _, info := range o.nodeInfos {
 		var err error
 		if !o.DryRun {
-			err = o.deleteOrEvictPodsSimple(info)
+			if o.TaintBased {
+				err = o.taintBasedDrain(info)
+			} else {
+				err = o.deleteOrEvictPodsSimple(info)
+			}
 		}
 		if err == nil || o.DryRun {
 			drainedNodes.Insert(info.Name)
@@ -370,10 +473,226 @@ func (o *DrainOptions) deleteOrEvictPodsSimple(nodeInfo *resource.Info) error {
 		for _, pendingPod := range pendingPods {
 			fmt.Fprintf(o.ErrOut, "%s/%s\n", "pod", pendingPod.Name)
 		}
+		return err
+	}
+	if o.WaitForReplacements {
+		return o.waitForReplacements(pods)
+	}
+	return nil
+}
+
+// taintBasedDrain applies a NoExecute taint to the node and waits for the
+// taint manager to evict its pods, instead of evicting them directly. Pods
+// that tolerate the taint with a bounded tolerationSeconds are removed
+// gradually, which is friendlier to workloads that tolerate short disruptions.
+func (o *DrainOptions) taintBasedDrain(nodeInfo *resource.Info) error {
+	pods, err := o.remainingDrainPods(nodeInfo)
+	if err != nil {
+		return err
+	}
+	if len(o.LocalDataBackupDir) > 0 {
+		if err := o.backupLocalData(pods); err != nil {
+			return err
+		}
+	}
+	if err := o.applyDrainTaint(nodeInfo); err != nil {
+		return err
+	}
+	if err := o.waitForTaintEvictions(nodeInfo); err != nil {
+		return err
+	}
+	if o.WaitForReplacements {
+		return o.waitForReplacements(pods)
+	}
+	return nil
+}
+
+// applyDrainTaint patches the node to add the drain NoExecute taint. It is a
+// no-op if the taint is already present.
+func (o *DrainOptions) applyDrainTaint(nodeInfo *resource.Info) error {
+	obj, err := scheme.Scheme.ConvertToVersion(nodeInfo.Object, nodeInfo.Mapping.GroupVersionKind.GroupVersion())
+	if err != nil {
+		return err
+	}
+	oldData, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return fmt.Errorf("unexpected Type%T, expected Node", obj)
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == drainTaintKey && taint.Effect == corev1.TaintEffectNoExecute {
+			return nil
+		}
+	}
+	now := metav1.Now()
+	node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{
+		Key:       drainTaintKey,
+		Effect:    corev1.TaintEffectNoExecute,
+		TimeAdded: &now,
+	})
+	newData, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	patchBytes, err := strategicpatch.CreateTwoWayMergePatch(oldData, newData, obj)
+	if err != nil {
+		return err
+	}
+	helper := resource.NewHelper(o.restClient, nodeInfo.Mapping)
+	_, err = helper.Patch(o.Namespace, nodeInfo.Name, types.StrategicMergePatchType, patchBytes, nil)
+	return err
+}
+
+// waitForTaintEvictions polls until the taint manager has emptied the node of
+// all pods other than mirror pods and DaemonSet-managed pods, which ignore
+// the taint. The wait is bounded by --timeout when set, otherwise by
+// --toleration-seconds plus a fixed buffer.
+func (o *DrainOptions) waitForTaintEvictions(nodeInfo *resource.Info) error {
+	timeout := o.Timeout
+	if timeout == 0 {
+		timeout = time.Duration(o.TolerationSeconds)*time.Second + taintDrainWaitBuffer
+	}
+
+	var remaining []corev1.Pod
+	err := wait.PollImmediate(5*time.Second, timeout, func() (bool, error) {
+		var err error
+		remaining, err = o.remainingDrainPods(nodeInfo)
+		if err != nil {
+			return false, err
+		}
+		return len(remaining) == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		for _, pod := range remaining {
+			fmt.Fprintf(o.ErrOut, "pod %s/%s was not evicted by the taint manager\n", pod.Namespace, pod.Name)
+		}
+		return fmt.Errorf("node %q did not empty within %v", nodeInfo.Name, timeout)
 	}
 	return err
 }
 
+// remainingDrainPods lists the pods on the node that a taint-based drain is
+// still waiting on.
+func (o *DrainOptions) remainingDrainPods(nodeInfo *resource.Info) ([]corev1.Pod, error) {
+	labelSelector, err := labels.Parse(o.PodSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	podList, err := o.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		LabelSelector: labelSelector.String(),
+		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": nodeInfo.Name}).String()})
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := []corev1.Pod{}
+	for _, pod := range podList.Items {
+		if _, found := pod.ObjectMeta.Annotations[corev1.MirrorPodAnnotationKey]; found {
+			continue
+		}
+		if controllerRef := metav1.GetControllerOf(&pod); controllerRef != nil && controllerRef.Kind == "DaemonSet" {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		remaining = append(remaining, pod)
+	}
+	return remaining, nil
+}
+
+// drainedController identifies a controller whose pods were evicted by the
+// drain and whose availability is awaited by --wait-for-replacements.
+type drainedController struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// waitForReplacements blocks until every controller that owned an evicted pod
+// reports its replicas as available again, so maintenance automation only
+// proceeds once capacity is truly restored. Controllers without a meaningful
+// availability signal (Jobs, DaemonSets, bare pods) are skipped.
+func (o *DrainOptions) waitForReplacements(pods []corev1.Pod) error {
+	controllers := map[drainedController]bool{}
+	for _, pod := range pods {
+		ref := o.getPodController(pod)
+		if ref == nil {
+			continue
+		}
+		switch ref.Kind {
+		case "ReplicationController", "ReplicaSet", "StatefulSet", "Deployment":
+			controllers[drainedController{kind: ref.Kind, namespace: pod.Namespace, name: ref.Name}] = true
+		}
+	}
+	if len(controllers) == 0 {
+		return nil
+	}
+
+	timeout := o.Timeout
+	if timeout == 0 {
+		timeout = time.Duration(math.MaxInt64)
+	}
+	fmt.Fprintf(o.Out, "waiting for %d controller(s) to regain full availability\n", len(controllers))
+	var waiting []drainedController
+	err := wait.PollImmediate(5*time.Second, timeout, func() (bool, error) {
+		waiting = waiting[:0]
+		for c := range controllers {
+			replaced, err := o.controllerReplaced(c)
+			if err != nil {
+				return false, err
+			}
+			if !replaced {
+				waiting = append(waiting, c)
+			}
+		}
+		return len(waiting) == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		for _, c := range waiting {
+			fmt.Fprintf(o.ErrOut, "%s %s/%s has not regained full availability\n", strings.ToLower(c.kind), c.namespace, c.name)
+		}
+		return fmt.Errorf("evicted workloads were not rescheduled within %v", timeout)
+	}
+	return err
+}
+
+// controllerReplaced reports whether the controller's observed status shows
+// all desired replicas available again.
+func (o *DrainOptions) controllerReplaced(c drainedController) (bool, error) {
+	switch c.kind {
+	case "ReplicationController":
+		rc, err := o.client.CoreV1().ReplicationControllers(c.namespace).Get(c.name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return rc.Status.ObservedGeneration >= rc.Generation && rc.Spec.Replicas != nil && rc.Status.ReadyReplicas >= *rc.Spec.Replicas, nil
+	case "ReplicaSet":
+		rs, err := o.client.AppsV1().ReplicaSets(c.namespace).Get(c.name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return rs.Status.ObservedGeneration >= rs.Generation && rs.Spec.Replicas != nil && rs.Status.ReadyReplicas >= *rs.Spec.Replicas, nil
+	case "StatefulSet":
+		sts, err := o.client.AppsV1().StatefulSets(c.namespace).Get(c.name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return sts.Status.ObservedGeneration >= sts.Generation && sts.Spec.Replicas != nil && sts.Status.ReadyReplicas >= *sts.Spec.Replicas, nil
+	case "Deployment":
+		d, err := o.client.AppsV1().Deployments(c.namespace).Get(c.name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return d.Status.ObservedGeneration >= d.Generation && d.Spec.Replicas != nil && d.Status.AvailableReplicas >= *d.Spec.Replicas, nil
+	}
+	return true, nil
+}
+
 func (o *DrainOptions) getPodController(pod corev1.Pod) *metav1.OwnerReference {
 	return metav1.GetControllerOf(&pod)
 }
@@ -471,6 +790,34 @@ func (ps podStatuses) Message() string {
 	return strings.Join(msgs, "; ")
 }
 
+// drainProgressEvent is one machine readable progress record, written as a
+// single JSON line when -o json is requested.
+type drainProgressEvent struct {
+	// Event is one of "evicted", "deleted", "skipped" or "error".
+	Event     string      `json:"event"`
+	Node      string      `json:"node,omitempty"`
+	Namespace string      `json:"namespace,omitempty"`
+	Pod       string      `json:"pod,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	Time      metav1.Time `json:"time"`
+}
+
+// emitProgress writes a progress event to the output stream. It is a no-op
+// unless -o json was given.
+func (o *DrainOptions) emitProgress(event drainProgressEvent) {
+	if !o.jsonProgress {
+		return
+	}
+	event.Time = metav1.Now()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	o.progressMutex.Lock()
+	defer o.progressMutex.Unlock()
+	fmt.Fprintln(o.Out, string(data))
+}
+
 // getPodsForDeletion receives resource info for a node, and returns all the pods from the given node that we
 // are planning on deleting. If there are any pods preventing us from deleting, we return that list in an error.
 func (o *DrainOptions) getPodsForDeletion(nodeInfo *resource.Info) (pods []corev1.Pod, err error) {
@@ -495,6 +842,13 @@ func (o *DrainOptions) getPodsForDeletion(nodeInfo *resource.Info) (pods []corev
 			filterOk, w, f := filt(pod)
 
 			podOk = podOk && filterOk
+			if !filterOk && f == nil {
+				reason := "pod is not a drain candidate"
+				if w != nil {
+					reason = w.string
+				}
+				o.emitProgress(drainProgressEvent{Event: "skipped", Node: nodeInfo.Name, Namespace: pod.Namespace, Pod: pod.Name, Reason: reason})
+			}
 			if w != nil {
 				ws[w.string] = append(ws[w.string], pod.Name)
 			}
@@ -553,12 +907,105 @@ func (o *DrainOptions) evictPod(pod corev1.Pod, policyGroupVersion string) error
 	return o.client.PolicyV1beta1().Evictions(eviction.Namespace).Evict(eviction)
 }
 
+// backupLocalData archives the emptyDir contents of the given pods into
+// --local-data-backup-dir before they are deleted. The archives are produced
+// by running tar inside a container that mounts the volume, so pods that are
+// no longer running or whose containers lack a tar binary fail the backup.
+func (o *DrainOptions) backupLocalData(pods []corev1.Pod) error {
+	if err := os.MkdirAll(o.LocalDataBackupDir, 0755); err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, pod := range pods {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.EmptyDir == nil {
+				continue
+			}
+			containerName, mountPath := findVolumeMount(pod, volume.Name)
+			if containerName == "" {
+				// An emptyDir no container mounts holds no data.
+				continue
+			}
+			if pod.Status.Phase != corev1.PodRunning {
+				errs = append(errs, fmt.Errorf("cannot back up emptyDir %q of pod %s/%s: pod is not running", volume.Name, pod.Namespace, pod.Name))
+				continue
+			}
+			target := filepath.Join(o.LocalDataBackupDir, fmt.Sprintf("%s_%s_%s.tar", pod.Namespace, pod.Name, volume.Name))
+			if err := o.backupVolume(pod, containerName, mountPath, target); err != nil {
+				errs = append(errs, fmt.Errorf("error backing up emptyDir %q of pod %s/%s: %v", volume.Name, pod.Namespace, pod.Name, err))
+				continue
+			}
+			fmt.Fprintf(o.Out, "backed up emptyDir %q of pod %s/%s to %s\n", volume.Name, pod.Namespace, pod.Name, target)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// findVolumeMount returns the name of a container mounting the named volume
+// and the path it is mounted at, or empty strings if no container mounts it.
+func findVolumeMount(pod corev1.Pod, volumeName string) (string, string) {
+	for _, container := range pod.Spec.Containers {
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == volumeName {
+				return container.Name, mount.MountPath
+			}
+		}
+	}
+	return "", ""
+}
+
+// backupVolume streams `tar cf -` of mountPath from the given container into
+// a local tar file. Partial files left by a failed transfer are removed.
+func (o *DrainOptions) backupVolume(pod corev1.Pod, containerName, mountPath, target string) error {
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	req := o.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod.Name).
+		Namespace(pod.Namespace).
+		SubResource("exec").
+		Param("container", containerName)
+	req.VersionedParams(&corev1.PodExecOptions{
+		Container: containerName,
+		Command:   []string{"tar", "cf", "-", "-C", mountPath, "."},
+		Stdout:    true,
+		Stderr:    true,
+	}, scheme.ParameterCodec)
+
+	var stderr bytes.Buffer
+	exec, err := remotecommand.NewSPDYExecutor(o.restConfig, "POST", req.URL())
+	if err == nil {
+		err = exec.Stream(remotecommand.StreamOptions{Stdout: f, Stderr: &stderr})
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
 // deleteOrEvictPods deletes or evicts the pods on the api server
 func (o *DrainOptions) deleteOrEvictPods(pods []corev1.Pod) error {
 	if len(pods) == 0 {
 		return nil
 	}
 
+	if len(o.LocalDataBackupDir) > 0 {
+		if err := o.backupLocalData(pods); err != nil {
+			return err
+		}
+	}
+
 	policyGroupVersion, err := SupportEviction(o.client)
 	if err != nil {
 		return err
@@ -575,35 +1022,99 @@ func (o *DrainOptions) deleteOrEvictPods(pods []corev1.Pod) error {
 	}
 }
 
+// groupPodsByOwner groups drain candidates by their controlling owner so that
+// evictions can be interleaved across workloads: groups are drained in
+// parallel, but pods within one group are evicted one at a time, so a
+// workload with several replicas on the node loses at most one pod at a time.
+// Pods without a controller each form their own group.
+func groupPodsByOwner(pods []corev1.Pod) [][]corev1.Pod {
+	groupIndex := map[string]int{}
+	groups := [][]corev1.Pod{}
+	for _, pod := range pods {
+		controllerRef := metav1.GetControllerOf(&pod)
+		if controllerRef == nil {
+			groups = append(groups, []corev1.Pod{pod})
+			continue
+		}
+		key := pod.Namespace + "/" + string(controllerRef.UID)
+		if i, ok := groupIndex[key]; ok {
+			groups[i] = append(groups[i], pod)
+		} else {
+			groupIndex[key] = len(groups)
+			groups = append(groups, []corev1.Pod{pod})
+		}
+	}
+	return groups
+}
+
 func (o *DrainOptions) evictPods(pods []corev1.Pod, policyGroupVersion string, getPodFn func(namespace, name string) (*corev1.Pod, error)) error {
 	returnCh := make(chan error, 1)
 
-	for _, pod := range pods {
-		go func(pod corev1.Pod, returnCh chan error) {
-			var err error
-			for {
-				err = o.evictPod(pod, policyGroupVersion)
+	// 0 per-pod timeout means infinite, we use MaxInt64 to represent it.
+	podTimeout := time.Duration(math.MaxInt64)
+	if o.TimeoutPerPod > 0 {
+		podTimeout = o.TimeoutPerPod
+	}
+
+	for _, group := range groupPodsByOwner(pods) {
+		go func(pods []corev1.Pod, returnCh chan error) {
+			for _, pod := range pods {
+				var err error
+				o.waitForEvictionSlot(pod.Namespace)
+				release := func() {}
+				if o.Coordinate {
+					release, err = o.acquireDrainLeases(pod)
+					if err != nil {
+						err = fmt.Errorf("error coordinating eviction of pod %q: %v", pod.Name, err)
+						o.emitProgress(drainProgressEvent{Event: "error", Node: pod.Spec.NodeName, Namespace: pod.Namespace, Pod: pod.Name, Reason: err.Error()})
+						returnCh <- err
+						continue
+					}
+				}
+				// The per-pod budget starts once this pod's turn comes, so
+				// time spent rate limited or waiting for a lease does not
+				// count against it.
+				podDeadline := time.Now().Add(podTimeout)
+				for {
+					err = o.evictPod(pod, policyGroupVersion)
+					if err == nil {
+						break
+					} else if apierrors.IsNotFound(err) {
+						err = nil
+						break
+					} else if apierrors.IsTooManyRequests(err) {
+						if time.Now().Add(5 * time.Second).After(podDeadline) {
+							err = fmt.Errorf("pod %q was not evicted within %v: %v", pod.Name, o.TimeoutPerPod, err)
+							break
+						}
+						fmt.Fprintf(o.ErrOut, "error when evicting pod %q (will retry after 5s): %v\n", pod.Name, err)
+						time.Sleep(5 * time.Second)
+					} else {
+						err = fmt.Errorf("error when evicting pod %q: %v", pod.Name, err)
+						break
+					}
+				}
+				release()
+				if err != nil {
+					o.emitProgress(drainProgressEvent{Event: "error", Node: pod.Spec.NodeName, Namespace: pod.Namespace, Pod: pod.Name, Reason: err.Error()})
+					returnCh <- err
+					continue
+				}
+				podArray := []corev1.Pod{pod}
+				_, err = o.waitForDelete(podArray, 1*time.Second, podDeadline.Sub(time.Now()), true, getPodFn)
 				if err == nil {
-					break
-				} else if apierrors.IsNotFound(err) {
 					returnCh <- nil
-					return
-				} else if apierrors.IsTooManyRequests(err) {
-					fmt.Fprintf(o.ErrOut, "error when evicting pod %q (will retry after 5s): %v\n", pod.Name, err)
-					time.Sleep(5 * time.Second)
 				} else {
-					returnCh <- fmt.Errorf("error when evicting pod %q: %v", pod.Name, err)
-					return
+					if err == wait.ErrWaitTimeout {
+						err = fmt.Errorf("pod %q did not terminate within %v", pod.Name, o.TimeoutPerPod)
+					} else {
+						err = fmt.Errorf("error when waiting for pod %q terminating: %v", pod.Name, err)
+					}
+					o.emitProgress(drainProgressEvent{Event: "error", Node: pod.Spec.NodeName, Namespace: pod.Namespace, Pod: pod.Name, Reason: err.Error()})
+					returnCh <- err
 				}
 			}
-			podArray := []corev1.Pod{pod}
-			_, err = o.waitForDelete(podArray, 1*time.Second, time.Duration(math.MaxInt64), true, getPodFn)
-			if err == nil {
-				returnCh <- nil
-			} else {
-				returnCh <- fmt.Errorf("error when waiting for pod %q terminating: %v", pod.Name, err)
-			}
-		}(pod, returnCh)
+		}(group, returnCh)
 	}
 
 	doneCount := 0
@@ -632,6 +1143,144 @@ func (o *DrainOptions) evictPods(pods []corev1.Pod, policyGroupVersion string, g
 	return utilerrors.NewAggregate(errors)
 }
 
+// waitForEvictionSlot blocks until both the overall and the per-namespace
+// rate limiters allow another eviction. Enforcing the rate per namespace as
+// well keeps sequential evictions in one namespace spaced out even when the
+// overall limiter has accumulated burst. It is a no-op without
+// --eviction-rate.
+func (o *DrainOptions) waitForEvictionSlot(namespace string) {
+	if o.evictionLimiter == nil {
+		return
+	}
+	o.limiterMutex.Lock()
+	nsLimiter, ok := o.namespaceLimiters[namespace]
+	if !ok {
+		nsLimiter = flowcontrol.NewTokenBucketRateLimiter(o.EvictionRate, o.EvictionBurst)
+		o.namespaceLimiters[namespace] = nsLimiter
+	}
+	o.limiterMutex.Unlock()
+	o.evictionLimiter.Accept()
+	nsLimiter.Accept()
+}
+
+// drainLease is the JSON payload of the drainLeaseAnnotation.
+type drainLease struct {
+	// Holder identifies the drain invocation holding the lease.
+	Holder string `json:"holder"`
+	// Renewed is when the lease was last written; a lease older than
+	// drainLeaseTTL is treated as abandoned.
+	Renewed metav1.Time `json:"renewed"`
+}
+
+// acquireDrainLeases takes the coordination lease on every PodDisruptionBudget
+// covering the pod, blocking while another coordinated drain holds one. The
+// returned function gives the leases back; the PodDisruptionBudget itself
+// still enforces the budget, the lease only keeps concurrent drains from
+// issuing simultaneous evictions against it.
+func (o *DrainOptions) acquireDrainLeases(pod corev1.Pod) (func(), error) {
+	pdbs, err := o.pdbsForPod(pod)
+	if err != nil {
+		return nil, err
+	}
+	acquired := []policyv1beta1.PodDisruptionBudget{}
+	for _, pdb := range pdbs {
+		if err := o.acquireDrainLease(pdb.Namespace, pdb.Name); err != nil {
+			o.releaseDrainLeases(acquired)
+			return nil, err
+		}
+		acquired = append(acquired, pdb)
+	}
+	return func() { o.releaseDrainLeases(acquired) }, nil
+}
+
+// acquireDrainLease polls until the lease annotation on the named
+// PodDisruptionBudget is free, abandoned or already ours, then writes our
+// holder identity. Update conflicts mean another drain raced us and are
+// retried.
+func (o *DrainOptions) acquireDrainLease(namespace, name string) error {
+	timeout := o.Timeout
+	if timeout == 0 {
+		timeout = time.Duration(math.MaxInt64)
+	}
+	return wait.PollImmediate(drainLeasePollInterval, timeout, func() (bool, error) {
+		pdb, err := o.client.PolicyV1beta1().PodDisruptionBudgets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				// The budget disappeared; nothing left to coordinate on.
+				return true, nil
+			}
+			return false, err
+		}
+		if raw, ok := pdb.Annotations[drainLeaseAnnotation]; ok {
+			lease := drainLease{}
+			if err := json.Unmarshal([]byte(raw), &lease); err == nil &&
+				lease.Holder != o.leaseHolder &&
+				time.Since(lease.Renewed.Time) < drainLeaseTTL {
+				return false, nil
+			}
+		}
+		data, err := json.Marshal(drainLease{Holder: o.leaseHolder, Renewed: metav1.Now()})
+		if err != nil {
+			return false, err
+		}
+		if pdb.Annotations == nil {
+			pdb.Annotations = map[string]string{}
+		}
+		pdb.Annotations[drainLeaseAnnotation] = string(data)
+		if _, err := o.client.PolicyV1beta1().PodDisruptionBudgets(namespace).Update(pdb); err != nil {
+			if apierrors.IsConflict(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+// releaseDrainLeases removes our lease annotation from the given
+// PodDisruptionBudgets. Leases we no longer hold are left alone; failures
+// only delay other drains until the lease expires, so they are reported as
+// warnings.
+func (o *DrainOptions) releaseDrainLeases(pdbs []policyv1beta1.PodDisruptionBudget) {
+	for _, pdb := range pdbs {
+		current, err := o.client.PolicyV1beta1().PodDisruptionBudgets(pdb.Namespace).Get(pdb.Name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		raw, ok := current.Annotations[drainLeaseAnnotation]
+		if !ok {
+			continue
+		}
+		lease := drainLease{}
+		if err := json.Unmarshal([]byte(raw), &lease); err == nil && lease.Holder != o.leaseHolder {
+			continue
+		}
+		delete(current.Annotations, drainLeaseAnnotation)
+		if _, err := o.client.PolicyV1beta1().PodDisruptionBudgets(pdb.Namespace).Update(current); err != nil {
+			fmt.Fprintf(o.ErrOut, "warning: unable to release drain lease on poddisruptionbudget %s/%s: %v\n", pdb.Namespace, pdb.Name, err)
+		}
+	}
+}
+
+// pdbsForPod returns the PodDisruptionBudgets whose selector matches the pod.
+func (o *DrainOptions) pdbsForPod(pod corev1.Pod) ([]policyv1beta1.PodDisruptionBudget, error) {
+	pdbList, err := o.client.PolicyV1beta1().PodDisruptionBudgets(pod.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var matching []policyv1beta1.PodDisruptionBudget
+	for _, pdb := range pdbList.Items {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		if selector.Matches(labels.Set(pod.Labels)) {
+			matching = append(matching, pdb)
+		}
+	}
+	return matching, nil
+}
+
 func (o *DrainOptions) deletePods(pods []corev1.Pod, getPodFn func(namespace, name string) (*corev1.Pod, error)) error {
 	// 0 timeout means infinite, we use MaxInt64 to represent it.
 	var globalTimeout time.Duration
@@ -667,7 +1316,11 @@ func (o *DrainOptions) waitForDelete(pods []corev1.Pod, interval, timeout time.D
 		for i, pod := range pods {
 			p, err := getPodFn(pod.Namespace, pod.Name)
 			if apierrors.IsNotFound(err) || (p != nil && p.ObjectMeta.UID != pod.ObjectMeta.UID) {
-				printObj(&pod, o.Out)
+				if o.jsonProgress {
+					o.emitProgress(drainProgressEvent{Event: verbStr, Node: pod.Spec.NodeName, Namespace: pod.Namespace, Pod: pod.Name})
+				} else {
+					printObj(&pod, o.Out)
+				}
 				continue
 			} else if err != nil {
 				return false, err
@@ -742,7 +1395,24 @@ func (o *DrainOptions) RunCordonOrUncordon(desired bool) error {
 				continue
 			}
 			unsched := node.Spec.Unschedulable
-			if unsched == desired {
+			taintRemoved := false
+			if !desired {
+				// A taint-based drain leaves the drain NoExecute taint on
+				// the node; uncordon must lift it too, or the node keeps
+				// evicting every pod scheduled onto it.
+				var taints []corev1.Taint
+				for _, taint := range node.Spec.Taints {
+					if taint.Key == drainTaintKey && taint.Effect == corev1.TaintEffectNoExecute {
+						taintRemoved = true
+						continue
+					}
+					taints = append(taints, taint)
+				}
+				if taintRemoved {
+					node.Spec.Taints = taints
+				}
+			}
+			if unsched == desired && !taintRemoved {
 				printObj, err := o.ToPrinter(already(desired))
 				if err != nil {
 					fmt.Fprintf(o.ErrOut, "error: %v\n", err)
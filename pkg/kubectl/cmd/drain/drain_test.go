@@ -42,9 +42,11 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/genericclioptions/printers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest/fake"
 	cmdtesting "k8s.io/kubernetes/pkg/kubectl/cmd/testing"
 	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
@@ -58,6 +60,7 @@ const (
 
 var node *corev1.Node
 var cordoned_node *corev1.Node
+var tainted_node *corev1.Node
 
 func boolptr(b bool) *bool { return &b }
 
@@ -74,6 +77,11 @@ func TestMain(m *testing.M) {
 	// A copy of the same node, but cordoned.
 	cordoned_node = node.DeepCopy()
 	cordoned_node.Spec.Unschedulable = true
+
+	// The cordoned node again, carrying the NoExecute taint a taint-based
+	// drain leaves behind.
+	tainted_node = cordoned_node.DeepCopy()
+	tainted_node.Spec.Taints = []corev1.Taint{{Key: drainTaintKey, Effect: corev1.TaintEffectNoExecute}}
 	os.Exit(m.Run())
 }
 
@@ -102,6 +110,14 @@ func TestCordon(t *testing.T) {
 			arg:         "node",
 			expectFatal: false,
 		},
+		{
+			description: "uncordon removes the drain taint",
+			node:        tainted_node,
+			expected:    node,
+			cmd:         NewCmdUncordon,
+			arg:         "node",
+			expectFatal: false,
+		},
 		{
 			description: "uncordon does nothing",
 			node:        node,
@@ -1006,3 +1022,150 @@ func (m *MyReq) isFor(method string, path string) bool {
 		req.URL.Path == strings.Join([]string{"/apis/extensions/v1beta1", path}, "") ||
 		req.URL.Path == strings.Join([]string{"/apis/batch/v1", path}, ""))
 }
+
+func TestFindVolumeMount(t *testing.T) {
+	pod := corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "first",
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "logs", MountPath: "/var/log/app"},
+					},
+				},
+				{
+					Name: "second",
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "scratch", MountPath: "/scratch"},
+					},
+				},
+			},
+		},
+	}
+
+	container, mountPath := findVolumeMount(pod, "scratch")
+	if container != "second" || mountPath != "/scratch" {
+		t.Errorf("expected scratch mounted by second at /scratch, got %q at %q", container, mountPath)
+	}
+	container, mountPath = findVolumeMount(pod, "unmounted")
+	if container != "" || mountPath != "" {
+		t.Errorf("expected no mount for unmounted volume, got %q at %q", container, mountPath)
+	}
+}
+
+func TestDrainLeases(t *testing.T) {
+	podLabels := map[string]string{"app": "coordinated"}
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "budget", Namespace: "default"},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+		},
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Labels: podLabels}}
+
+	// A free budget is leased and released again.
+	o := &DrainOptions{Coordinate: true, leaseHolder: "drain-1", Timeout: 5 * time.Second}
+	o.IOStreams = genericclioptions.NewTestIOStreamsDiscard()
+	o.client = k8sfake.NewSimpleClientset(pdb)
+	release, err := o.acquireDrainLeases(pod)
+	if err != nil {
+		t.Fatalf("unexpected error acquiring lease: %v", err)
+	}
+	held, err := o.client.PolicyV1beta1().PodDisruptionBudgets("default").Get("budget", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	raw, ok := held.Annotations[drainLeaseAnnotation]
+	if !ok {
+		t.Fatalf("expected the lease annotation to be set")
+	}
+	lease := drainLease{}
+	if err := utiljson.Unmarshal([]byte(raw), &lease); err != nil || lease.Holder != "drain-1" {
+		t.Errorf("expected lease held by drain-1, got %q (%v)", raw, err)
+	}
+	release()
+	released, err := o.client.PolicyV1beta1().PodDisruptionBudgets("default").Get("budget", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := released.Annotations[drainLeaseAnnotation]; ok {
+		t.Errorf("expected the lease annotation to be removed on release")
+	}
+
+	// A fresh lease held by another drain blocks until the timeout.
+	data, err := utiljson.Marshal(drainLease{Holder: "drain-2", Renewed: metav1.Now()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	heldPDB := pdb.DeepCopy()
+	heldPDB.Annotations = map[string]string{drainLeaseAnnotation: string(data)}
+	o.client = k8sfake.NewSimpleClientset(heldPDB)
+	o.Timeout = 100 * time.Millisecond
+	if _, err := o.acquireDrainLeases(pod); err == nil {
+		t.Errorf("expected a timeout while another drain holds the lease")
+	}
+
+	// An abandoned lease is taken over.
+	data, err = utiljson.Marshal(drainLease{Holder: "drain-2", Renewed: metav1.NewTime(time.Now().Add(-2 * drainLeaseTTL))})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stalePDB := pdb.DeepCopy()
+	stalePDB.Annotations = map[string]string{drainLeaseAnnotation: string(data)}
+	o.client = k8sfake.NewSimpleClientset(stalePDB)
+	o.Timeout = 5 * time.Second
+	if _, err := o.acquireDrainLeases(pod); err != nil {
+		t.Errorf("expected to take over an abandoned lease: %v", err)
+	}
+}
+
+func TestDrainProgressEvents(t *testing.T) {
+	ioStreams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := &DrainOptions{IOStreams: ioStreams}
+
+	// Without -o json the emitter is silent.
+	o.emitProgress(drainProgressEvent{Event: "evicted", Pod: "bar"})
+	if out.Len() != 0 {
+		t.Errorf("expected no output without -o json, got %q", out.String())
+	}
+
+	o.jsonProgress = true
+	o.emitProgress(drainProgressEvent{Event: "skipped", Node: "node", Namespace: "default", Pod: "bar", Reason: kDaemonsetWarning})
+	event := drainProgressEvent{}
+	if err := utiljson.Unmarshal(out.Bytes(), &event); err != nil {
+		t.Fatalf("expected one JSON event line, got %q: %v", out.String(), err)
+	}
+	if event.Event != "skipped" || event.Pod != "bar" || event.Reason != kDaemonsetWarning {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if event.Time.IsZero() {
+		t.Errorf("expected the event time to be set")
+	}
+
+	// waitForDelete reports terminated pods as events instead of printing
+	// them.
+	out.Reset()
+	o.ToPrinter = func(string) (printers.ResourcePrinterFunc, error) {
+		return func(obj runtime.Object, writer io.Writer) error {
+			t.Errorf("expected no printer output with -o json")
+			return nil
+		}, nil
+	}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bar"},
+		Spec:       corev1.PodSpec{NodeName: "node"},
+	}
+	pending, err := o.waitForDelete([]corev1.Pod{pod}, time.Millisecond, time.Second, true, func(namespace, name string) (*corev1.Pod, error) {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, name)
+	})
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("unexpected result waiting for deletion: %v, %v", pending, err)
+	}
+	event = drainProgressEvent{}
+	if err := utiljson.Unmarshal(out.Bytes(), &event); err != nil {
+		t.Fatalf("expected one JSON event line, got %q: %v", out.String(), err)
+	}
+	if event.Event != "evicted" || event.Pod != "bar" || event.Node != "node" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testing provides a fake apiserver simulating the server-side
+// behaviors kubectl drain has to cope with: PDB-driven 429 responses on
+// eviction, pods that are slow to disappear after deletion, and partial
+// failures. Downstream tools embedding the drain logic can test against the
+// same behaviors without standing up a real apiserver.
+package testing
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubernetes/pkg/kubectl/cmd/testing"
+	"k8s.io/kubernetes/pkg/kubectl/scheme"
+)
+
+// EvictionSubresource and EvictionKind mirror the constants the drain code
+// discovers eviction support with.
+const (
+	EvictionSubresource = "pods/eviction"
+	EvictionKind        = "Eviction"
+)
+
+// Request wraps an *http.Request with path matching that tolerates the
+// standard API prefixes, the same way drain's own tests match requests.
+type Request struct {
+	*http.Request
+}
+
+// IsFor reports whether the request is the given method against the given
+// resource path under any of the common group/version prefixes.
+func (r Request) IsFor(method string, path string) bool {
+	return method == r.Method && (r.URL.Path == path ||
+		r.URL.Path == strings.Join([]string{"/api/v1", path}, "") ||
+		r.URL.Path == strings.Join([]string{"/apis/extensions/v1beta1", path}, "") ||
+		r.URL.Path == strings.Join([]string{"/apis/batch/v1", path}, ""))
+}
+
+// FakeDrainServer is a configurable fake apiserver for drain scenarios. The
+// zero value accepts every eviction and deletion immediately; set the
+// behavior fields to simulate contention and failures.
+type FakeDrainServer struct {
+	// Node is returned for GET and patched in place by cordon/uncordon.
+	Node *corev1.Node
+	// Pods are returned by the node's pod listing.
+	Pods []corev1.Pod
+	// SupportEviction controls whether discovery advertises the eviction
+	// subresource; when false drain falls back to plain deletion.
+	SupportEviction bool
+
+	// PDBConflictsPerPod is how many 429 Too Many Requests responses each
+	// pod's eviction receives before one is accepted, simulating a disruption
+	// budget with no headroom that later drains down.
+	PDBConflictsPerPod int
+	// DeleteDelayPolls is how many GETs after a successful delete or
+	// eviction still return the pod before it 404s, simulating slow
+	// termination.
+	DeleteDelayPolls int
+	// FailedPods lists pod names whose eviction or deletion always fails
+	// with an internal error, simulating a partial failure.
+	FailedPods map[string]bool
+
+	mu               sync.Mutex
+	evictionAttempts map[string]int
+	remainingPolls   map[string]int
+	evicted          []string
+	deleted          []string
+}
+
+// Evicted returns the names of the pods successfully evicted so far.
+func (s *FakeDrainServer) Evicted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.evicted...)
+}
+
+// Deleted returns the names of the pods successfully deleted so far.
+func (s *FakeDrainServer) Deleted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.deleted...)
+}
+
+// Client returns a fake REST client serving the configured behaviors.
+// Requests the fake does not understand are answered with 404 so callers fail
+// visibly rather than hang.
+func (s *FakeDrainServer) Client() *fake.RESTClient {
+	s.evictionAttempts = map[string]int{}
+	s.remainingPolls = map[string]int{}
+	codec := scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups()...)
+
+	return &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Group: "", Version: "v1"},
+		NegotiatedSerializer: scheme.Codecs,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			r := Request{req}
+			switch {
+			case req.Method == "GET" && req.URL.Path == "/api":
+				return cmdtesting.GenResponseWithJsonEncodedBody(metav1.APIVersions{Versions: []string{"v1"}})
+			case req.Method == "GET" && req.URL.Path == "/apis":
+				return cmdtesting.GenResponseWithJsonEncodedBody(metav1.APIGroupList{
+					Groups: []metav1.APIGroup{{
+						Name: "policy",
+						PreferredVersion: metav1.GroupVersionForDiscovery{
+							GroupVersion: "policy/v1beta1",
+						},
+					}},
+				})
+			case req.Method == "GET" && req.URL.Path == "/api/v1":
+				resourceList := metav1.APIResourceList{GroupVersion: "v1"}
+				if s.SupportEviction {
+					resourceList.APIResources = []metav1.APIResource{{
+						Name: EvictionSubresource,
+						Kind: EvictionKind,
+					}}
+				}
+				return cmdtesting.GenResponseWithJsonEncodedBody(resourceList)
+			case r.IsFor("GET", "/nodes/"+s.Node.Name):
+				return &http.Response{StatusCode: 200, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, s.Node)}, nil
+			case r.IsFor("PATCH", "/nodes/"+s.Node.Name):
+				return &http.Response{StatusCode: 200, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, s.Node)}, nil
+			case r.IsFor("GET", "/pods"):
+				return &http.Response{StatusCode: 200, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, &corev1.PodList{Items: s.Pods})}, nil
+			case r.IsFor("GET", "/replicationcontrollers"):
+				return &http.Response{StatusCode: 200, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, &corev1.ReplicationControllerList{})}, nil
+			case req.Method == "POST" && strings.HasSuffix(req.URL.Path, "/eviction"):
+				return s.handleEviction(codec, req)
+			case req.Method == "DELETE" && strings.Contains(req.URL.Path, "/pods/"):
+				return s.handleDelete(codec, req)
+			case req.Method == "GET" && strings.Contains(req.URL.Path, "/pods/"):
+				return s.handlePodGet(codec, req)
+			default:
+				return &http.Response{StatusCode: 404, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.StringBody("")}, nil
+			}
+		}),
+	}
+}
+
+// podNameFromPath extracts the pod name from .../pods/<name>[/eviction].
+func podNameFromPath(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range parts {
+		if part == "pods" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+func (s *FakeDrainServer) findPod(name string) *corev1.Pod {
+	for i := range s.Pods {
+		if s.Pods[i].Name == name {
+			return &s.Pods[i]
+		}
+	}
+	return nil
+}
+
+func (s *FakeDrainServer) handleEviction(codec runtime.Codec, req *http.Request) (*http.Response, error) {
+	name := podNameFromPath(req.URL.Path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.FailedPods[name] {
+		return &http.Response{StatusCode: 500, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.StringBody("simulated eviction failure")}, nil
+	}
+	if s.evictionAttempts[name] < s.PDBConflictsPerPod {
+		s.evictionAttempts[name]++
+		return &http.Response{StatusCode: 429, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.StringBody("Cannot evict pod as it would violate the pod's disruption budget.")}, nil
+	}
+	s.evicted = append(s.evicted, name)
+	s.remainingPolls[name] = s.DeleteDelayPolls
+	return &http.Response{StatusCode: 201, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, &policyv1beta1.Eviction{})}, nil
+}
+
+func (s *FakeDrainServer) handleDelete(codec runtime.Codec, req *http.Request) (*http.Response, error) {
+	name := podNameFromPath(req.URL.Path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.FailedPods[name] {
+		return &http.Response{StatusCode: 500, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.StringBody("simulated deletion failure")}, nil
+	}
+	pod := s.findPod(name)
+	if pod == nil {
+		return &http.Response{StatusCode: 404, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, &corev1.Pod{})}, nil
+	}
+	s.deleted = append(s.deleted, name)
+	s.remainingPolls[name] = s.DeleteDelayPolls
+	return &http.Response{StatusCode: 204, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, pod)}, nil
+}
+
+// handlePodGet serves the waitForDelete polling: a pod that was deleted or
+// evicted keeps being returned for DeleteDelayPolls polls, then 404s.
+func (s *FakeDrainServer) handlePodGet(codec runtime.Codec, req *http.Request) (*http.Response, error) {
+	name := podNameFromPath(req.URL.Path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pod := s.findPod(name)
+	if pod == nil {
+		return &http.Response{StatusCode: 404, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, &corev1.Pod{})}, nil
+	}
+	if remaining, gone := s.remainingPolls[name]; gone {
+		if remaining <= 0 {
+			return &http.Response{StatusCode: 404, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, &corev1.Pod{})}, nil
+		}
+		s.remainingPolls[name] = remaining - 1
+	}
+	return &http.Response{StatusCode: 200, Header: cmdtesting.DefaultHeader(), Body: cmdtesting.ObjBody(codec, pod)}, nil
+}
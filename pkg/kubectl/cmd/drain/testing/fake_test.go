@@ -0,0 +1,101 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"net/http"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newServer() *FakeDrainServer {
+	return &FakeDrainServer{
+		Node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}},
+		Pods: []corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default"}},
+		},
+		SupportEviction: true,
+	}
+}
+
+func do(t *testing.T, client *http.Client, method, path string) *http.Response {
+	req, err := http.NewRequest(method, "http://localhost"+path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestPDBConflictsThenSuccess(t *testing.T) {
+	server := newServer()
+	server.PDBConflictsPerPod = 2
+	client := server.Client().Client
+
+	path := "/api/v1/namespaces/default/pods/bar/eviction"
+	for i := 0; i < 2; i++ {
+		if resp := do(t, client, "POST", path); resp.StatusCode != 429 {
+			t.Fatalf("attempt %d: expected 429, got %d", i+1, resp.StatusCode)
+		}
+	}
+	if resp := do(t, client, "POST", path); resp.StatusCode != 201 {
+		t.Fatalf("expected eviction to be accepted after conflicts, got %d", resp.StatusCode)
+	}
+	if evicted := server.Evicted(); len(evicted) != 1 || evicted[0] != "bar" {
+		t.Fatalf("expected bar to be recorded as evicted, got %v", evicted)
+	}
+}
+
+func TestSlowDeletion(t *testing.T) {
+	server := newServer()
+	server.DeleteDelayPolls = 2
+	client := server.Client().Client
+
+	if resp := do(t, client, "DELETE", "/api/v1/namespaces/default/pods/bar"); resp.StatusCode != 204 {
+		t.Fatalf("expected delete to succeed, got %d", resp.StatusCode)
+	}
+	for i := 0; i < 2; i++ {
+		if resp := do(t, client, "GET", "/api/v1/namespaces/default/pods/bar"); resp.StatusCode != 200 {
+			t.Fatalf("poll %d: expected pod to still exist, got %d", i+1, resp.StatusCode)
+		}
+	}
+	if resp := do(t, client, "GET", "/api/v1/namespaces/default/pods/bar"); resp.StatusCode != 404 {
+		t.Fatalf("expected pod to be gone after the delay, got %d", resp.StatusCode)
+	}
+}
+
+func TestPartialFailure(t *testing.T) {
+	server := newServer()
+	server.FailedPods = map[string]bool{"bar": true}
+	client := server.Client().Client
+
+	if resp := do(t, client, "POST", "/api/v1/namespaces/default/pods/bar/eviction"); resp.StatusCode != 500 {
+		t.Fatalf("expected eviction of failed pod to return 500, got %d", resp.StatusCode)
+	}
+	if resp := do(t, client, "DELETE", "/api/v1/namespaces/default/pods/bar"); resp.StatusCode != 500 {
+		t.Fatalf("expected deletion of failed pod to return 500, got %d", resp.StatusCode)
+	}
+	if len(server.Evicted()) != 0 || len(server.Deleted()) != 0 {
+		t.Fatalf("failed pod must not be recorded as evicted or deleted")
+	}
+}
@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"text/template"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -31,6 +33,7 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/rest"
 	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	"k8s.io/kubernetes/pkg/kubectl/logstreamer"
 	"k8s.io/kubernetes/pkg/kubectl/polymorphichelpers"
 	"k8s.io/kubernetes/pkg/kubectl/scheme"
 	"k8s.io/kubernetes/pkg/kubectl/util"
@@ -53,6 +56,9 @@ var (
 		# Return snapshot logs from all containers in pods defined by label app=nginx
 		kubectl logs -lapp=nginx --all-containers=true
 
+		# Return snapshot logs from all containers in pods defined by label app=nginx, labeling each line with its source
+		kubectl logs -lapp=nginx --all-containers=true --prefix
+
 		# Return snapshot of previous terminated ruby container logs from pod web-1
 		kubectl logs -p -c ruby web-1
 
@@ -65,6 +71,12 @@ var (
 		# Show all logs from pod nginx written in the last hour
 		kubectl logs --since=1h nginx
 
+		# Return snapshot logs from all init containers of pod nginx
+		kubectl logs nginx --init-containers
+
+		# Return snapshot logs from the init container setup of pod nginx
+		kubectl logs nginx --init-containers -c setup
+
 		# Return snapshot logs from first container of a job named hello
 		kubectl logs job/hello
 
@@ -77,6 +89,11 @@ var (
 
 const (
 	defaultPodLogsTimeout = 20 * time.Second
+
+	// prefixLineTemplate is the line template --prefix expands to. The
+	// kubelet-prepended timestamp, when requested, stays ahead of the line
+	// so the output remains sortable.
+	prefixLineTemplate = "[{{.PodName}}/{{.ContainerName}}] {{if .Timestamp}}{{.Timestamp}} {{end}}{{.Line}}"
 )
 
 type LogsOptions struct {
@@ -98,6 +115,36 @@ type LogsOptions struct {
 	Tail         int64
 	Container    string
 
+	// TotalLimitBytes caps the aggregate log output across all requests,
+	// unlike LimitBytes which is applied per request by the kubelet.
+	TotalLimitBytes int64
+
+	// LineTemplate is a Go template applied to every output line, rendering
+	// the pod/namespace/container/timestamp envelope around the raw line.
+	LineTemplate string
+	lineTemplate *template.Template
+
+	// Prefix labels each line with its [pod/container] source, shorthand for
+	// the corresponding line template. Useful when the output of several
+	// containers is interleaved, e.g. with a selector and --all-containers.
+	Prefix bool
+
+	// Grep filters the output client side to lines matching the regex,
+	// optionally with GrepContext lines of context around each match.
+	Grep        string
+	GrepContext int
+	grepRegexp  *regexp.Regexp
+
+	// VerboseRequests prints per-request diagnostics to stderr: the resolved
+	// pod/container list and the start, finish and any error of each stream,
+	// so a hanging retrieval can be traced to its backend request.
+	VerboseRequests bool
+
+	// InitContainers restricts the command to the pod's init containers:
+	// without -c the logs of every init container are fetched, with -c the
+	// named container must be an init container.
+	InitContainers bool
+
 	// whether or not a container name was given via --container
 	ContainerNameSpecified bool
 	Selector               string
@@ -144,13 +191,20 @@ func NewCmdLogs(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.C
 	cmd.Flags().BoolVarP(&o.Follow, "follow", "f", o.Follow, "Specify if the logs should be streamed.")
 	cmd.Flags().BoolVar(&o.Timestamps, "timestamps", o.Timestamps, "Include timestamps on each line in the log output")
 	cmd.Flags().Int64Var(&o.LimitBytes, "limit-bytes", o.LimitBytes, "Maximum bytes of logs to return. Defaults to no limit.")
+	cmd.Flags().Int64Var(&o.TotalLimitBytes, "total-limit-bytes", o.TotalLimitBytes, "Maximum total bytes of logs to return across all requested pods and containers. Defaults to no limit.")
 	cmd.Flags().BoolVarP(&o.Previous, "previous", "p", o.Previous, "If true, print the logs for the previous instance of the container in a pod if it exists.")
 	cmd.Flags().Int64Var(&o.Tail, "tail", o.Tail, "Lines of recent log file to display. Defaults to -1 with no selector, showing all log lines otherwise 10, if a selector is provided.")
 	cmd.Flags().StringVar(&o.SinceTime, "since-time", o.SinceTime, i18n.T("Only return logs after a specific date (RFC3339). Defaults to all logs. Only one of since-time / since may be used."))
 	cmd.Flags().DurationVar(&o.SinceSeconds, "since", o.SinceSeconds, "Only return logs newer than a relative duration like 5s, 2m, or 3h. Defaults to all logs. Only one of since-time / since may be used.")
 	cmd.Flags().StringVarP(&o.Container, "container", "c", o.Container, "Print the logs of this container")
+	cmd.Flags().BoolVar(&o.InitContainers, "init-containers", o.InitContainers, "Only consider the pod's init containers: get the logs of all of them, or of the one named with -c.")
 	cmdutil.AddPodRunningTimeoutFlag(cmd, defaultPodLogsTimeout)
 	cmd.Flags().StringVarP(&o.Selector, "selector", "l", o.Selector, "Selector (label query) to filter on.")
+	cmd.Flags().StringVar(&o.LineTemplate, "line-template", o.LineTemplate, "Go template applied to each log line. Available fields: .Namespace, .PodName, .ContainerName, .Timestamp (with --timestamps) and .Line.")
+	cmd.Flags().BoolVar(&o.Prefix, "prefix", o.Prefix, "Prefix each log line with the log source (pod name and container name)")
+	cmd.Flags().StringVar(&o.Grep, "grep", o.Grep, "Only print log lines matching the given regular expression (RE2 syntax).")
+	cmd.Flags().IntVar(&o.GrepContext, "grep-context", o.GrepContext, "Print this many lines of context around each line matched by --grep.")
+	cmd.Flags().BoolVar(&o.VerboseRequests, "verbose-requests", o.VerboseRequests, "Print the resolved pod/container list and per-request start/finish/error diagnostics to stderr.")
 	return cmd
 }
 
@@ -217,6 +271,28 @@ func (o *LogsOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []str
 	}
 
 	o.ConsumeRequestFn = DefaultConsumeRequest
+	if o.Follow {
+		// While following, kubelet log rotation can silently drop lines
+		// between the old and new log file. Scan the stream so a visible
+		// marker can be inserted where a discontinuity is detected.
+		o.ConsumeRequestFn = logstreamer.GapAnnotatingConsumeRequest(o.Timestamps)
+	}
+
+	if len(o.LineTemplate) > 0 {
+		o.lineTemplate, err = template.New("log-line").Parse(o.LineTemplate)
+		if err != nil {
+			return cmdutil.UsageErrorf(cmd, "unable to parse --line-template: %v", err)
+		}
+	} else if o.Prefix {
+		o.lineTemplate = template.Must(template.New("log-line").Parse(prefixLineTemplate))
+	}
+
+	if len(o.Grep) > 0 {
+		o.grepRegexp, err = regexp.Compile(o.Grep)
+		if err != nil {
+			return cmdutil.UsageErrorf(cmd, "unable to parse --grep: %v", err)
+		}
+	}
 
 	o.GetPodTimeout, err = cmdutil.GetPodRunningTimeoutFlag(cmd)
 	if err != nil {
@@ -272,6 +348,14 @@ func (o LogsOptions) Validate() error {
 		return fmt.Errorf("--all-containers=true should not be specified with container name %s", logsOptions.Container)
 	}
 
+	if o.Prefix && len(o.LineTemplate) > 0 {
+		return fmt.Errorf("only one of --prefix or --line-template is allowed")
+	}
+
+	if o.InitContainers && o.AllContainers {
+		return fmt.Errorf("--all-containers cannot be combined with --init-containers")
+	}
+
 	if o.ContainerNameSpecified && len(o.Resources) == 2 {
 		return fmt.Errorf("only one of -c or an inline [CONTAINER] arg is allowed")
 	}
@@ -280,6 +364,18 @@ func (o LogsOptions) Validate() error {
 		return fmt.Errorf("--limit-bytes must be greater than 0")
 	}
 
+	if o.TotalLimitBytes < 0 {
+		return fmt.Errorf("--total-limit-bytes must be greater than 0")
+	}
+
+	if o.GrepContext < 0 {
+		return fmt.Errorf("--grep-context must be greater than or equal to 0")
+	}
+
+	if o.GrepContext > 0 && len(o.Grep) == 0 {
+		return fmt.Errorf("--grep-context requires --grep")
+	}
+
 	if logsOptions.SinceSeconds != nil && *logsOptions.SinceSeconds < int64(0) {
 		return fmt.Errorf("--since must be greater than 0")
 	}
@@ -293,27 +389,76 @@ func (o LogsOptions) Validate() error {
 
 // RunLogs retrieves a pod log
 func (o LogsOptions) RunLogs() error {
-	requests, err := o.LogsForObject(o.RESTClientGetter, o.Object, o.Options, o.GetPodTimeout, o.AllContainers)
+	var requests []*rest.Request
+	var err error
+	if o.InitContainers {
+		requests, err = o.initContainerRequests()
+	} else {
+		requests, err = o.LogsForObject(o.RESTClientGetter, o.Object, o.Options, o.GetPodTimeout, o.AllContainers)
+	}
 	if err != nil {
 		return err
 	}
 
-	for _, request := range requests {
-		if err := o.ConsumeRequestFn(request, o.Out); err != nil {
-			return err
+	streamOptions := logstreamer.StreamOptions{
+		LineTemplate:    o.lineTemplate,
+		Timestamps:      o.Timestamps,
+		Grep:            o.grepRegexp,
+		GrepContext:     o.GrepContext,
+		TotalLimitBytes: o.TotalLimitBytes,
+		ConsumeRequest:  o.ConsumeRequestFn,
+	}
+	if o.VerboseRequests {
+		streamOptions.Diagnostics = o.ErrOut
+	}
+	return logstreamer.Stream(requests, o.Out, streamOptions)
+}
+
+// initContainerRequests builds the log requests for the pod's init
+// containers, resolving the -c name (if any) against the init containers
+// only.
+func (o LogsOptions) initContainerRequests() ([]*rest.Request, error) {
+	pod, ok := o.Object.(*corev1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("--init-containers can only be used with pods, not %T", o.Object)
+	}
+
+	containers := pod.Spec.InitContainers
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("pod %s/%s has no init containers", pod.Namespace, pod.Name)
+	}
+
+	if len(o.Container) > 0 {
+		names := make([]string, 0, len(containers))
+		for _, c := range containers {
+			if c.Name == o.Container {
+				return o.LogsForObject(o.RESTClientGetter, pod, o.Options, o.GetPodTimeout, false)
+			}
+			names = append(names, c.Name)
 		}
+		return nil, fmt.Errorf("container %s is not an init container of pod %s/%s (init containers: %v)", o.Container, pod.Namespace, pod.Name, names)
 	}
 
-	return nil
+	opts, ok := o.Options.(*corev1.PodLogOptions)
+	if !ok {
+		return nil, errors.New("unexpected logs options object")
+	}
+	requests := []*rest.Request{}
+	for _, c := range containers {
+		currOpts := opts.DeepCopy()
+		currOpts.Container = c.Name
+		currRequests, err := o.LogsForObject(o.RESTClientGetter, pod, currOpts, o.GetPodTimeout, false)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, currRequests...)
+	}
+	return requests, nil
 }
 
+// DefaultConsumeRequest streams a single request verbatim. Kept here for
+// callers of this package; it delegates to the reusable logstreamer package.
 func DefaultConsumeRequest(request *rest.Request, out io.Writer) error {
-	readCloser, err := request.Stream()
-	if err != nil {
-		return err
-	}
-	defer readCloser.Close()
-
-	_, err = io.Copy(out, readCloser)
-	return err
+	return logstreamer.DefaultConsumeRequest(request, out)
 }
+
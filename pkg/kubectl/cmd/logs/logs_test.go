@@ -199,6 +199,41 @@ func TestValidateLogOptions(t *testing.T) {
 			args:     []string{"my-pod", "my-container"},
 			expected: "only one of -c or an inline",
 		},
+		{
+			name: "init-containers combined with --all-containers",
+			opts: func(streams genericclioptions.IOStreams) *LogsOptions {
+				o := NewLogsOptions(streams, true)
+				o.InitContainers = true
+
+				var err error
+				o.Options, err = o.ToLogOptions()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				return o
+			},
+			args:     []string{"foo"},
+			expected: "--all-containers cannot be combined with --init-containers",
+		},
+		{
+			name: "prefix combined with line-template",
+			opts: func(streams genericclioptions.IOStreams) *LogsOptions {
+				o := NewLogsOptions(streams, false)
+				o.Prefix = true
+				o.LineTemplate = "{{.Line}}"
+
+				var err error
+				o.Options, err = o.ToLogOptions()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				return o
+			},
+			args:     []string{"foo"},
+			expected: "only one of --prefix or --line-template is allowed",
+		},
 		{
 			name: "follow and selector conflict",
 			opts: func(streams genericclioptions.IOStreams) *LogsOptions {
@@ -234,6 +269,38 @@ func TestValidateLogOptions(t *testing.T) {
 	}
 }
 
+func TestInitContainerRequests(t *testing.T) {
+	pod := testPod()
+	pod.Spec.InitContainers = []corev1.Container{{Name: "setup"}, {Name: "migrate"}}
+	mock := &logTestMock{}
+
+	newOptions := func() *LogsOptions {
+		o := NewLogsOptions(genericclioptions.NewTestIOStreamsDiscard(), false)
+		o.Object = pod
+		o.Options = &corev1.PodLogOptions{}
+		o.LogsForObject = mock.mockLogsForObject
+		return o
+	}
+
+	o := newOptions()
+	o.InitContainers = true
+	requests, err := o.initContainerRequests()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("expected a request per init container, got %d", len(requests))
+	}
+
+	o = newOptions()
+	o.InitContainers = true
+	o.Container = "bar"
+	o.Options = &corev1.PodLogOptions{Container: "bar"}
+	if _, err := o.initContainerRequests(); err == nil || !strings.Contains(err.Error(), "is not an init container") {
+		t.Errorf("expected an error naming the init containers, got %v", err)
+	}
+}
+
 func TestLogComplete(t *testing.T) {
 	f := cmdtesting.NewTestFactory()
 	defer f.Cleanup()
@@ -0,0 +1,428 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logstreamer streams container log requests to a writer with the
+// same orchestration kubectl logs uses: fan-out over multiple requests,
+// client-side filtering, line templating and an aggregate byte budget. It is
+// importable by other commands and plugins that want kubectl logs behavior
+// without exec'ing kubectl.
+package logstreamer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// StreamOptions controls how a set of log requests is rendered to the output
+// writer. The zero value streams every request verbatim.
+type StreamOptions struct {
+	// LineTemplate, when set, is applied to every output line, rendering the
+	// pod/namespace/container/timestamp envelope around the raw line.
+	LineTemplate *template.Template
+	// Timestamps indicates the streams carry kubelet-prepended RFC3339
+	// timestamps, which LineTemplate strips into the envelope.
+	Timestamps bool
+	// Grep, when set, filters the output to lines matching the regex, with
+	// GrepContext lines of context around each match.
+	Grep        *regexp.Regexp
+	GrepContext int
+	// TotalLimitBytes, when > 0, caps the aggregate output across all
+	// requests.
+	TotalLimitBytes int64
+	// ConsumeRequest reads a single request's stream into a writer.
+	// DefaultConsumeRequest is used when nil.
+	ConsumeRequest func(*rest.Request, io.Writer) error
+	// Diagnostics, when set, receives per-request progress: the resolved
+	// pod/container list up front, then the start, finish and any error of
+	// each stream. Useful for telling which backend request is stuck when
+	// log retrieval hangs.
+	Diagnostics io.Writer
+}
+
+// Stream consumes every log request in order and writes the result to out
+// according to the options.
+func Stream(requests []*rest.Request, out io.Writer, options StreamOptions) error {
+	consumeRequest := options.ConsumeRequest
+	if consumeRequest == nil {
+		consumeRequest = DefaultConsumeRequest
+	}
+
+	if options.TotalLimitBytes > 0 {
+		out = &totalLimitWriter{out: out, remaining: options.TotalLimitBytes}
+	}
+
+	if options.Diagnostics != nil {
+		fmt.Fprintf(options.Diagnostics, "resolved %d log stream(s):\n", len(requests))
+		for _, request := range requests {
+			namespace, podName, containerName := podInfoFromLogsRequest(request)
+			fmt.Fprintf(options.Diagnostics, "  %s/%s container %q: %s\n", namespace, podName, containerName, request.URL())
+		}
+	}
+
+	for _, request := range requests {
+		reqOut := out
+		var tw *templateWriter
+		if options.LineTemplate != nil {
+			namespace, podName, containerName := podInfoFromLogsRequest(request)
+			tw = &templateWriter{
+				out:        out,
+				tmpl:       options.LineTemplate,
+				timestamps: options.Timestamps,
+				envelope: logLineEnvelope{
+					Namespace:     namespace,
+					PodName:       podName,
+					ContainerName: containerName,
+				},
+			}
+			reqOut = tw
+		}
+		var gw *grepWriter
+		if options.Grep != nil {
+			gw = &grepWriter{
+				out:     reqOut,
+				re:      options.Grep,
+				context: options.GrepContext,
+			}
+			reqOut = gw
+		}
+		var started time.Time
+		if options.Diagnostics != nil {
+			namespace, podName, containerName := podInfoFromLogsRequest(request)
+			fmt.Fprintf(options.Diagnostics, "starting log stream for %s/%s container %q\n", namespace, podName, containerName)
+			started = time.Now()
+		}
+		err := consumeRequest(request, reqOut)
+		if err == nil && gw != nil {
+			err = gw.flush()
+		}
+		if err == nil && tw != nil {
+			err = tw.flush()
+		}
+		if options.Diagnostics != nil {
+			namespace, podName, containerName := podInfoFromLogsRequest(request)
+			if err != nil && err != errTotalLimitReached {
+				fmt.Fprintf(options.Diagnostics, "log stream for %s/%s container %q failed after %v: %v\n", namespace, podName, containerName, time.Since(started), err)
+			} else {
+				fmt.Fprintf(options.Diagnostics, "log stream for %s/%s container %q finished after %v\n", namespace, podName, containerName, time.Since(started))
+			}
+		}
+		if err != nil {
+			if err == errTotalLimitReached {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// logLineEnvelope is the data made available to the line template for every
+// log line.
+type logLineEnvelope struct {
+	Namespace     string
+	PodName       string
+	ContainerName string
+	// Timestamp is the kubelet-prepended RFC3339 timestamp, stripped from
+	// Line. Empty unless Timestamps is set.
+	Timestamp string
+	Line      string
+}
+
+// podInfoFromLogsRequest recovers the namespace, pod and container a log
+// request targets from its URL, since LogsForObject returns bare requests.
+func podInfoFromLogsRequest(request *rest.Request) (namespace, podName, containerName string) {
+	u := request.URL()
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i < len(parts)-1; i++ {
+		switch parts[i] {
+		case "namespaces":
+			namespace = parts[i+1]
+		case "pods":
+			podName = parts[i+1]
+		}
+	}
+	containerName = u.Query().Get("container")
+	return namespace, podName, containerName
+}
+
+// templateWriter renders every complete line written through it with the
+// configured template before passing it on.
+type templateWriter struct {
+	out        io.Writer
+	tmpl       *template.Template
+	timestamps bool
+	envelope   logLineEnvelope
+	buf        []byte
+}
+
+func (w *templateWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		line := string(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+		if err := w.writeLine(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// flush renders any trailing output that did not end with a newline.
+func (w *templateWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := string(w.buf)
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+func (w *templateWriter) writeLine(line string) error {
+	envelope := w.envelope
+	if w.timestamps {
+		if idx := strings.IndexByte(line, ' '); idx > 0 {
+			if _, err := time.Parse(time.RFC3339Nano, line[:idx]); err == nil {
+				envelope.Timestamp = line[:idx]
+				line = line[idx+1:]
+			}
+		}
+	}
+	envelope.Line = line
+
+	var rendered bytes.Buffer
+	if err := w.tmpl.Execute(&rendered, envelope); err != nil {
+		return fmt.Errorf("error executing line template: %v", err)
+	}
+	if rendered.Len() == 0 || rendered.Bytes()[rendered.Len()-1] != '\n' {
+		rendered.WriteByte('\n')
+	}
+	_, err := w.out.Write(rendered.Bytes())
+	return err
+}
+
+// grepWriter filters the lines written through it to those matching the
+// configured regex, plus up to context lines before and after each match.
+// Like grep, disjoint groups of context are separated by a "--" marker.
+type grepWriter struct {
+	out     io.Writer
+	re      *regexp.Regexp
+	context int
+
+	buf []byte
+	// before holds up to context unmatched lines preceding the current
+	// position, to be emitted if the next line matches.
+	before []string
+	// after counts context lines still owed after the last match.
+	after int
+	// printed and gap track whether a "--" separator is needed before the
+	// next group of output.
+	printed bool
+	gap     bool
+}
+
+func (w *grepWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		line := string(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+		if err := w.filterLine(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// flush filters any trailing output that did not end with a newline.
+func (w *grepWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := string(w.buf)
+	w.buf = nil
+	return w.filterLine(line)
+}
+
+func (w *grepWriter) filterLine(line string) error {
+	if w.re.MatchString(line) {
+		if w.gap && w.printed && w.context > 0 {
+			if _, err := fmt.Fprintln(w.out, "--"); err != nil {
+				return err
+			}
+		}
+		for _, held := range w.before {
+			if _, err := fmt.Fprintln(w.out, held); err != nil {
+				return err
+			}
+		}
+		w.before = w.before[:0]
+		w.after = w.context
+		w.printed = true
+		w.gap = false
+		_, err := fmt.Fprintln(w.out, line)
+		return err
+	}
+
+	if w.after > 0 {
+		w.after--
+		_, err := fmt.Fprintln(w.out, line)
+		return err
+	}
+
+	if w.context > 0 {
+		w.before = append(w.before, line)
+		if len(w.before) > w.context {
+			w.before = w.before[1:]
+			w.gap = true
+		}
+	} else {
+		w.gap = true
+	}
+	return nil
+}
+
+// errTotalLimitReached signals that the aggregate TotalLimitBytes budget is
+// exhausted and the remaining log streams should not be consumed.
+var errTotalLimitReached = errors.New("total byte limit reached")
+
+// totalLimitWriter enforces a shared byte budget across every log stream
+// written through it. Output past the budget is discarded and the write fails
+// with errTotalLimitReached.
+type totalLimitWriter struct {
+	out       io.Writer
+	remaining int64
+}
+
+func (w *totalLimitWriter) Write(p []byte) (int, error) {
+	if w.remaining <= 0 {
+		return 0, errTotalLimitReached
+	}
+
+	truncated := false
+	if int64(len(p)) > w.remaining {
+		p = p[:w.remaining]
+		truncated = true
+	}
+
+	n, err := w.out.Write(p)
+	w.remaining -= int64(n)
+	if err != nil {
+		return n, err
+	}
+	if truncated {
+		return n, errTotalLimitReached
+	}
+	return n, nil
+}
+
+// DefaultConsumeRequest copies a single request's stream to the writer
+// verbatim.
+func DefaultConsumeRequest(request *rest.Request, out io.Writer) error {
+	readCloser, err := request.Stream()
+	if err != nil {
+		return err
+	}
+	defer readCloser.Close()
+
+	_, err = io.Copy(out, readCloser)
+	return err
+}
+
+// logGapMarker is printed into the output stream when a discontinuity in the
+// followed log stream is detected, so missing lines are visible to the user
+// rather than silently absent.
+const logGapMarker = "==== kubectl logs: log discontinuity detected, some lines may be missing ===="
+
+// GapAnnotatingConsumeRequest returns a consume function that copies the log
+// stream line by line and inserts a marker when the stream shows signs of
+// kubelet log rotation: an explicit rotation/truncation error from the
+// kubelet, or (with timestamps) a timestamp that jumps backwards because
+// reading restarted in a new log file.
+func GapAnnotatingConsumeRequest(timestamps bool) func(*rest.Request, io.Writer) error {
+	return func(request *rest.Request, out io.Writer) error {
+		readCloser, err := request.Stream()
+		if err != nil {
+			return err
+		}
+		defer readCloser.Close()
+
+		r := bufio.NewReader(readCloser)
+		var lastTimestamp time.Time
+		for {
+			line, err := r.ReadString('\n')
+			if len(line) > 0 {
+				if isLogRotationError(line) {
+					if _, werr := fmt.Fprintln(out, logGapMarker); werr != nil {
+						return werr
+					}
+				} else if timestamps {
+					if ts, terr := parseLogLineTimestamp(line); terr == nil {
+						if !lastTimestamp.IsZero() && ts.Before(lastTimestamp) {
+							if _, werr := fmt.Fprintln(out, logGapMarker); werr != nil {
+								return werr
+							}
+						}
+						lastTimestamp = ts
+					}
+				}
+				if _, werr := out.Write([]byte(line)); werr != nil {
+					return werr
+				}
+			}
+			if err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+}
+
+// isLogRotationError reports whether a line in the log stream is an error
+// message from the kubelet indicating the underlying log file was rotated or
+// truncated while being followed.
+func isLogRotationError(line string) bool {
+	if !strings.Contains(line, "log file") {
+		return false
+	}
+	return strings.Contains(line, "rotated") || strings.Contains(line, "truncated")
+}
+
+// parseLogLineTimestamp parses the leading RFC3339 timestamp that the kubelet
+// prepends to each line when the timestamps option is set.
+func parseLogLineTimestamp(line string) (time.Time, error) {
+	idx := strings.IndexByte(line, ' ')
+	if idx < 0 {
+		idx = len(line)
+	}
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(line[:idx]))
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logstreamer
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestGrepWriter(t *testing.T) {
+	input := "one\ntwo match\nthree\nfour\nfive\nsix match\nseven\n"
+
+	tests := []struct {
+		name     string
+		pattern  string
+		context  int
+		expected string
+	}{
+		{
+			name:     "matches only",
+			pattern:  "match",
+			context:  0,
+			expected: "two match\nsix match\n",
+		},
+		{
+			name:     "matches with context and separator",
+			pattern:  "match",
+			context:  1,
+			expected: "one\ntwo match\nthree\n--\nfive\nsix match\nseven\n",
+		},
+		{
+			name:     "overlapping context has no separator",
+			pattern:  "match",
+			context:  3,
+			expected: "one\ntwo match\nthree\nfour\nfive\nsix match\nseven\n",
+		},
+		{
+			name:     "no matches",
+			pattern:  "nothing",
+			context:  2,
+			expected: "",
+		},
+	}
+	for _, test := range tests {
+		var out strings.Builder
+		w := &grepWriter{
+			out:     &out,
+			re:      regexp.MustCompile(test.pattern),
+			context: test.context,
+		}
+		if _, err := w.Write([]byte(input)); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := w.flush(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if out.String() != test.expected {
+			t.Errorf("%s: expected:\n%q\ngot:\n%q", test.name, test.expected, out.String())
+		}
+	}
+}
+
+func TestTotalLimitWriter(t *testing.T) {
+	var out strings.Builder
+	w := &totalLimitWriter{out: &out, remaining: 10}
+
+	n, err := w.Write([]byte("12345"))
+	if n != 5 || err != nil {
+		t.Fatalf("expected full write, got n=%d err=%v", n, err)
+	}
+	n, err = w.Write([]byte("6789012345"))
+	if n != 5 || err != errTotalLimitReached {
+		t.Fatalf("expected truncated write with errTotalLimitReached, got n=%d err=%v", n, err)
+	}
+	if out.String() != "1234567890" {
+		t.Errorf("expected exactly 10 bytes of output, got %q", out.String())
+	}
+	if _, err := w.Write([]byte("x")); err != errTotalLimitReached {
+		t.Errorf("expected errTotalLimitReached on exhausted budget, got %v", err)
+	}
+}
+
+func TestStreamDiagnostics(t *testing.T) {
+	var out, diag strings.Builder
+	requests := []*rest.Request{{}, {}}
+	err := Stream(requests, &out, StreamOptions{
+		Diagnostics: &diag,
+		ConsumeRequest: func(req *rest.Request, w io.Writer) error {
+			_, err := w.Write([]byte("line\n"))
+			return err
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "line\nline\n" {
+		t.Errorf("diagnostics must not alter the log output, got %q", out.String())
+	}
+	if !strings.Contains(diag.String(), "resolved 2 log stream(s):") {
+		t.Errorf("expected the resolved stream list, got %q", diag.String())
+	}
+	if got := strings.Count(diag.String(), "starting log stream"); got != 2 {
+		t.Errorf("expected 2 start records, got %d: %q", got, diag.String())
+	}
+	if got := strings.Count(diag.String(), "finished after"); got != 2 {
+		t.Errorf("expected 2 finish records, got %d: %q", got, diag.String())
+	}
+
+	// A stream error is recorded and still returned.
+	diag.Reset()
+	streamErr := errors.New("stream broke")
+	err = Stream(requests[:1], &out, StreamOptions{
+		Diagnostics: &diag,
+		ConsumeRequest: func(req *rest.Request, w io.Writer) error {
+			return streamErr
+		},
+	})
+	if err != streamErr {
+		t.Fatalf("expected the stream error to be returned, got %v", err)
+	}
+	if !strings.Contains(diag.String(), "failed after") || !strings.Contains(diag.String(), "stream broke") {
+		t.Errorf("expected a failure record with the error, got %q", diag.String())
+	}
+}
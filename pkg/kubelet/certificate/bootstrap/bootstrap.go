@@ -18,16 +18,26 @@ package bootstrap
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/types"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -43,36 +53,229 @@ import (
 
 const tmpPrivateKeyFile = "kubelet-client.key.tmp"
 
+const (
+	// clockSkewThreshold is how far the node clock may diverge from the
+	// apiserver clock before bootstrap logs an error. Issued certificates get
+	// a NotBefore near the CA's clock, so a node clock ahead by much more
+	// than this makes them appear not yet valid.
+	clockSkewThreshold = 30 * time.Second
+	// maxCertValidityWait bounds how long bootstrap waits for an issued
+	// certificate to become valid on a skewed clock before giving up.
+	maxCertValidityWait = 5 * time.Minute
+)
+
+// bootstrapSummaryFile is written into certDir after every bootstrap attempt
+// so node exporters and fleet dashboards can scrape bootstrap health without
+// parsing kubelet logs.
+const bootstrapSummaryFile = "kubelet-bootstrap-summary.json"
+
+const (
+	bootstrapOutcomeSkipped = "skipped"
+	bootstrapOutcomeSuccess = "success"
+	bootstrapOutcomeFailure = "failure"
+)
+
+// bootstrapSummary captures the outcome of a single bootstrap attempt.
+type bootstrapSummary struct {
+	NodeName        string    `json:"nodeName"`
+	StartTime       time.Time `json:"startTime"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	// Outcome is "skipped" if a valid kubeconfig already existed,
+	// otherwise "success" or "failure".
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// writeBootstrapSummary persists the summary next to the certificates. Errors
+// are logged only, the summary must never fail the bootstrap itself.
+func writeBootstrapSummary(certDir string, summary bootstrapSummary) {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		glog.Warningf("Unable to marshal bootstrap summary: %v", err)
+		return
+	}
+	path := filepath.Join(certDir, bootstrapSummaryFile)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		glog.Warningf("Unable to write bootstrap summary to %s: %v", path, err)
+	}
+}
+
+const (
+	// bootIDCSRAnnotation carries the node's current boot ID so approvers can
+	// correlate the CSR with a specific boot of the machine.
+	bootIDCSRAnnotation = "node.alpha.kubernetes.io/csr-boot-id"
+	// instanceIDCSRAnnotation carries the cloud instance ID as reported by the
+	// local hardware (DMI), so out-of-tree approvers can verify the request
+	// against the cloud API before approving it.
+	instanceIDCSRAnnotation = "node.alpha.kubernetes.io/csr-instance-id"
+)
+
+// CSRAnnotationProvider returns annotations to attach to the node's
+// certificate signing request. Providers run locally on the node before the
+// CSR is created; a failing provider only loses its annotations, it does not
+// fail the bootstrap.
+type CSRAnnotationProvider func(nodeName types.NodeName) (map[string]string, error)
+
+var csrAnnotationProviders = []CSRAnnotationProvider{
+	bootIDCSRAnnotations,
+	instanceIDCSRAnnotations,
+}
+
+// RegisterCSRAnnotationProvider adds a provider whose annotations are attached
+// to the bootstrap CSR, in addition to the built-in boot ID and instance ID
+// providers. It must be called before LoadClientCert.
+func RegisterCSRAnnotationProvider(provider CSRAnnotationProvider) {
+	csrAnnotationProviders = append(csrAnnotationProviders, provider)
+}
+
+// collectCSRAnnotations merges the annotations of all registered providers.
+// Provider failures are logged and skipped.
+func collectCSRAnnotations(nodeName types.NodeName) map[string]string {
+	annotations := map[string]string{}
+	for _, provider := range csrAnnotationProviders {
+		provided, err := provider(nodeName)
+		if err != nil {
+			glog.Warningf("Skipping CSR annotations from provider: %v", err)
+			continue
+		}
+		for k, v := range provided {
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
+
+func bootIDCSRAnnotations(nodeName types.NodeName) (map[string]string, error) {
+	bootID, err := ioutil.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read boot ID: %v", err)
+	}
+	return map[string]string{bootIDCSRAnnotation: strings.TrimSpace(string(bootID))}, nil
+}
+
+func instanceIDCSRAnnotations(nodeName types.NodeName) (map[string]string, error) {
+	// The board asset tag carries the instance ID on the major clouds; fall
+	// back to the SMBIOS system UUID where it does not.
+	for _, path := range []string{"/sys/class/dmi/id/board_asset_tag", "/sys/class/dmi/id/product_uuid"} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if id := strings.TrimSpace(string(data)); len(id) > 0 {
+			return map[string]string{instanceIDCSRAnnotation: id}, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to determine a local instance ID")
+}
+
+// CertificateRequestOptions customizes the CSR submitted during bootstrap.
+// The zero value keeps the legacy kubelet client signer semantics.
+type CertificateRequestOptions struct {
+	// SignerName asks the named signer to issue the certificate instead of
+	// the legacy kubelet client signer.
+	SignerName string
+	// ExpirationSeconds is the requested certificate duration; 0 leaves the
+	// duration up to the signer.
+	ExpirationSeconds int32
+	// StartupJitter, when > 0, delays the first contact with the apiserver by
+	// a random duration in [0, StartupJitter) so that a fleet-wide reboot does
+	// not stampede the apiserver with simultaneous CSR creation. The delay is
+	// only applied when a bootstrap is actually needed.
+	StartupJitter time.Duration
+	// ReadOnlyKubeconfigPath, when set, asks the bootstrap flow to also write
+	// a second kubeconfig for node-local companion agents. That kubeconfig
+	// points at the same cluster but authenticates with the token in
+	// ReadOnlyTokenFile rather than the node client certificate, so companion
+	// daemons do not have to reuse the kubelet's identity.
+	ReadOnlyKubeconfigPath string
+	// ReadOnlyTokenFile is the file holding the token referenced by the
+	// read-only kubeconfig. Required when ReadOnlyKubeconfigPath is set.
+	ReadOnlyTokenFile string
+	// ExpiryThreshold, when > 0, treats an existing client certificate that
+	// expires within the threshold as needing rotation: the CSR flow is
+	// re-run with the retained private key and the kubeconfig is replaced
+	// atomically, instead of the kubelet running into the expiry.
+	ExpiryThreshold time.Duration
+}
+
 // LoadClientCert requests a client cert for kubelet if the kubeconfigPath file does not exist.
 // The kubeconfig at bootstrapPath is used to request a client certificate from the API server.
 // On success, a kubeconfig file referencing the generated key and obtained certificate is written to kubeconfigPath.
-// The certificate and key file are stored in certDir.
+// The certificate and key file are stored in certDir, along with a summary
+// file recording the duration and outcome of the attempt.
 func LoadClientCert(kubeconfigPath string, bootstrapPath string, certDir string, nodeName types.NodeName) error {
-	// Short-circuit if the kubeconfig file exists and is valid.
-	ok, err := verifyBootstrapClientConfig(kubeconfigPath)
+	return LoadClientCertWithOptions(kubeconfigPath, bootstrapPath, certDir, nodeName, CertificateRequestOptions{})
+}
+
+// LoadClientCertWithOptions is LoadClientCert with control over the requested
+// signer and certificate duration.
+func LoadClientCertWithOptions(kubeconfigPath string, bootstrapPath string, certDir string, nodeName types.NodeName, options CertificateRequestOptions) error {
+	start := time.Now()
+	outcome, err := loadClientCert(kubeconfigPath, bootstrapPath, certDir, nodeName, options)
+
+	summary := bootstrapSummary{
+		NodeName:        string(nodeName),
+		StartTime:       start,
+		DurationSeconds: time.Since(start).Seconds(),
+		Outcome:         outcome,
+	}
 	if err != nil {
-		return err
+		summary.Error = err.Error()
+	}
+	writeBootstrapSummary(certDir, summary)
+
+	return err
+}
+
+func loadClientCert(kubeconfigPath string, bootstrapPath string, certDir string, nodeName types.NodeName, options CertificateRequestOptions) (string, error) {
+	if options.ReadOnlyKubeconfigPath != "" && options.ReadOnlyTokenFile == "" {
+		return bootstrapOutcomeFailure, fmt.Errorf("a read-only kubeconfig requires a token file")
+	}
+
+	// Short-circuit if the kubeconfig file exists and is valid, and its
+	// certificate is not about to expire.
+	ok, err := verifyBootstrapClientConfig(kubeconfigPath, options.ExpiryThreshold)
+	if err != nil {
+		return bootstrapOutcomeFailure, err
 	}
 	if ok {
 		glog.V(2).Infof("Kubeconfig %s exists and is valid, skipping bootstrap", kubeconfigPath)
-		return nil
+		// Companion agents still expect their kubeconfig to exist, e.g. after
+		// it was cleaned up while the kubelet's own credentials survived.
+		if err := ensureReadOnlyKubeconfig(bootstrapPath, options); err != nil {
+			return bootstrapOutcomeFailure, err
+		}
+		return bootstrapOutcomeSkipped, nil
 	}
 
 	glog.V(2).Info("Using bootstrap kubeconfig to generate TLS client cert, key and kubeconfig file")
 
-	bootstrapClientConfig, err := loadRESTClientConfig(bootstrapPath)
+	if options.StartupJitter > 0 {
+		delay := time.Duration(rand.Int63n(int64(options.StartupJitter)))
+		glog.V(2).Infof("Delaying bootstrap by %v to spread control plane load", delay)
+		time.Sleep(delay)
+	}
+
+	bootstrapClientConfig, proxyURL, err := loadRESTClientConfig(bootstrapPath)
 	if err != nil {
-		return fmt.Errorf("unable to load bootstrap kubeconfig: %v", err)
+		return bootstrapOutcomeFailure, fmt.Errorf("unable to load bootstrap kubeconfig: %v", err)
 	}
 
-	bootstrapClient, err := certificates.NewForConfig(bootstrapClientConfig)
+	// Build the network clients from a proxy-aware config; the original config
+	// keeps its TLS fields for the kubeconfigs written below.
+	connectConfig, err := configWithProxy(bootstrapClientConfig, proxyURL)
 	if err != nil {
-		return fmt.Errorf("unable to create certificates signing request client: %v", err)
+		return bootstrapOutcomeFailure, err
+	}
+
+	bootstrapClient, err := certificates.NewForConfig(connectConfig)
+	if err != nil {
+		return bootstrapOutcomeFailure, fmt.Errorf("unable to create certificates signing request client: %v", err)
 	}
 
 	store, err := certificate.NewFileStore("kubelet-client", certDir, certDir, "", "")
 	if err != nil {
-		return fmt.Errorf("unable to build bootstrap cert store")
+		return bootstrapOutcomeFailure, fmt.Errorf("unable to build bootstrap cert store")
 	}
 
 	var keyData []byte
@@ -94,20 +297,23 @@ func LoadClientCert(kubeconfigPath string, bootstrapPath string, certDir string,
 		// reused on next startup if CSR request fails.
 		keyData, _, err = certutil.LoadOrGenerateKeyFile(privKeyPath)
 		if err != nil {
-			return err
+			return bootstrapOutcomeFailure, err
 		}
 	}
 
-	if err := waitForServer(*bootstrapClientConfig, 1*time.Minute); err != nil {
+	if err := waitForServer(*connectConfig, 1*time.Minute); err != nil {
 		glog.Warningf("Error waiting for apiserver to come up: %v", err)
 	}
 
-	certData, err := csr.RequestNodeCertificate(bootstrapClient.CertificateSigningRequests(), keyData, nodeName)
+	certData, err := requestNodeCertificateWithRetry(bootstrapClient.CertificateSigningRequests(), keyData, nodeName, options)
 	if err != nil {
-		return err
+		return bootstrapOutcomeFailure, err
+	}
+	if err := waitForCertValidity(certData); err != nil {
+		return bootstrapOutcomeFailure, err
 	}
 	if _, err := store.Update(certData, keyData); err != nil {
-		return err
+		return bootstrapOutcomeFailure, err
 	}
 	if err := os.Remove(privKeyPath); err != nil && !os.IsNotExist(err) {
 		glog.V(2).Infof("failed cleaning up private key file %q: %v", privKeyPath, err)
@@ -129,6 +335,7 @@ func LoadClientCert(kubeconfigPath string, bootstrapPath string, certDir string,
 			InsecureSkipTLSVerify:    bootstrapClientConfig.Insecure,
 			CertificateAuthority:     caFile,
 			CertificateAuthorityData: caData,
+			ProxyURL:                 proxyURL,
 		}},
 		// Define auth based on the obtained client cert.
 		AuthInfos: map[string]*clientcmdapi.AuthInfo{"default-auth": {
@@ -144,30 +351,301 @@ func LoadClientCert(kubeconfigPath string, bootstrapPath string, certDir string,
 		CurrentContext: "default-context",
 	}
 
-	// Marshal to disk
-	return clientcmd.WriteToFile(kubeconfigData, kubeconfigPath)
+	// Marshal to disk. The write goes through a rename so a rotation never
+	// replaces a still-valid kubeconfig with a partially written one.
+	if err := writeKubeconfigAtomic(kubeconfigData, kubeconfigPath); err != nil {
+		return bootstrapOutcomeFailure, err
+	}
+
+	if options.ReadOnlyKubeconfigPath != "" {
+		if err := writeReadOnlyKubeconfig(bootstrapClientConfig, options); err != nil {
+			return bootstrapOutcomeFailure, err
+		}
+	}
+	return bootstrapOutcomeSuccess, nil
+}
+
+// writeKubeconfigAtomic writes the kubeconfig to a temporary file in the same
+// directory and renames it into place, so that a crash mid-write cannot leave
+// a truncated kubeconfig behind.
+func writeKubeconfigAtomic(config clientcmdapi.Config, kubeconfigPath string) error {
+	data, err := clientcmd.Write(config)
+	if err != nil {
+		return err
+	}
+	tmpPath := kubeconfigPath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, kubeconfigPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// ensureReadOnlyKubeconfig writes the companion agent kubeconfig if one was
+// requested and none exists yet. It is used on the path where the node's own
+// kubeconfig is valid and the certificate bootstrap is skipped.
+func ensureReadOnlyKubeconfig(bootstrapPath string, options CertificateRequestOptions) error {
+	if options.ReadOnlyKubeconfigPath == "" {
+		return nil
+	}
+	if _, err := os.Stat(options.ReadOnlyKubeconfigPath); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error reading existing read-only kubeconfig %s: %v", options.ReadOnlyKubeconfigPath, err)
+	}
+	bootstrapClientConfig, _, err := loadRESTClientConfig(bootstrapPath)
+	if err != nil {
+		return fmt.Errorf("unable to load bootstrap kubeconfig: %v", err)
+	}
+	return writeReadOnlyKubeconfig(bootstrapClientConfig, options)
 }
 
-func loadRESTClientConfig(kubeconfig string) (*restclient.Config, error) {
+// writeReadOnlyKubeconfig writes a kubeconfig for node-local companion agents.
+// It reuses the cluster stanza of the bootstrap kubeconfig but authenticates
+// with the token file named in the options instead of the node's client
+// certificate, so the credential can be scoped read-only server side.
+func writeReadOnlyKubeconfig(bootstrapClientConfig *restclient.Config, options CertificateRequestOptions) error {
+	caFile, caData := bootstrapClientConfig.CAFile, []byte{}
+	if len(caFile) == 0 {
+		caData = bootstrapClientConfig.CAData
+	}
+
+	kubeconfigData := clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{"default-cluster": {
+			Server:                   bootstrapClientConfig.Host,
+			InsecureSkipTLSVerify:    bootstrapClientConfig.Insecure,
+			CertificateAuthority:     caFile,
+			CertificateAuthorityData: caData,
+		}},
+		// Define auth based on the token file so the token can be rotated
+		// without rewriting this kubeconfig.
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{"default-auth": {
+			TokenFile: options.ReadOnlyTokenFile,
+		}},
+		Contexts: map[string]*clientcmdapi.Context{"default-context": {
+			Cluster:   "default-cluster",
+			AuthInfo:  "default-auth",
+			Namespace: "default",
+		}},
+		CurrentContext: "default-context",
+	}
+
+	return clientcmd.WriteToFile(kubeconfigData, options.ReadOnlyKubeconfigPath)
+}
+
+// requestNodeCertificateWithRetry submits the node CSR with a jittered
+// exponential backoff so that simultaneously booting nodes do not retry in
+// lockstep. A CSR that stays pending approval is retried indefinitely with
+// the backoff capped at csrPendingBackoffCap, since the approver may simply
+// be slow; other errors give up after a few attempts. When the apiserver
+// sheds load with 429 Too Many Requests, the server-suggested delay is
+// honored before the next attempt.
+func requestNodeCertificateWithRetry(client certificates.CertificateSigningRequestInterface, keyData []byte, nodeName types.NodeName, options CertificateRequestOptions) ([]byte, error) {
+	const (
+		initialDelay         = 5 * time.Second
+		csrPendingBackoffCap = 5 * time.Minute
+		maxFailures          = 5
+	)
+
+	delay := initialDelay
+	failures := 0
+	for {
+		certData, err := csr.RequestNodeCertificateWithOptions(client, keyData, nodeName, csr.NodeCertificateOptions{
+			Annotations:       collectCSRAnnotations(nodeName),
+			SignerName:        options.SignerName,
+			ExpirationSeconds: options.ExpirationSeconds,
+		})
+		if err == nil {
+			return certData, nil
+		}
+
+		switch {
+		case err == wait.ErrWaitTimeout:
+			// The CSR exists but has not been approved yet. That is not a
+			// failure of the request itself, so it does not count against
+			// maxFailures; resubmitting finds the same CSR by its digested
+			// name and resumes waiting.
+			glog.Warningf("Certificate signing request is still pending approval, retrying")
+		case apierrors.IsTooManyRequests(err):
+			if suggested, ok := apierrors.SuggestsClientDelay(err); ok && suggested > 0 {
+				glog.Warningf("Apiserver is overloaded, waiting %ds before retrying certificate signing request", suggested)
+				time.Sleep(time.Duration(suggested) * time.Second)
+				continue
+			}
+			glog.Warningf("Apiserver is overloaded, retrying certificate signing request: %v", err)
+		default:
+			failures++
+			if failures >= maxFailures {
+				return nil, err
+			}
+			glog.Warningf("Error requesting certificate: %v", err)
+		}
+
+		time.Sleep(wait.Jitter(delay, 0.5))
+		delay *= 2
+		if delay > csrPendingBackoffCap {
+			delay = csrPendingBackoffCap
+		}
+	}
+}
+
+// PreflightCheck runs the local validations LoadClientCert performs without
+// submitting a CSR: it parses the existing and bootstrap kubeconfigs, verifies
+// any cached private key, checks the apiserver named in the bootstrap
+// kubeconfig is reachable with its CA, and detects clock skew that would make
+// an issued certificate appear not yet valid. It never mutates on-disk state,
+// so provisioning pipelines can use it to pre-flight nodes.
+func PreflightCheck(kubeconfigPath string, bootstrapPath string, certDir string) error {
+	ok, err := verifyBootstrapClientConfig(kubeconfigPath, 0)
+	if err != nil {
+		return err
+	}
+	if ok {
+		glog.V(2).Infof("Kubeconfig %s exists and is valid, bootstrap would be skipped", kubeconfigPath)
+		return nil
+	}
+
+	bootstrapClientConfig, proxyURL, err := loadRESTClientConfig(bootstrapPath)
+	if err != nil {
+		return fmt.Errorf("unable to load bootstrap kubeconfig: %v", err)
+	}
+
+	// Verify any cached private key without generating a new one.
+	store, err := certificate.NewFileStore("kubelet-client", certDir, certDir, "", "")
+	if err != nil {
+		return fmt.Errorf("unable to build bootstrap cert store")
+	}
+	if cert, err := store.Current(); err == nil && cert.PrivateKey != nil {
+		keyData, err := certutil.MarshalPrivateKeyToPEM(cert.PrivateKey)
+		if err != nil || !verifyKeyData(keyData) {
+			return fmt.Errorf("existing private key in %s is not usable: %v", certDir, err)
+		}
+	}
+
+	// Detect clock skew: a node clock ahead of the CA makes issued
+	// certificates appear not yet valid and the kubelet crash-loop.
+	if certs, err := certsFromBootstrapConfig(bootstrapClientConfig); err == nil {
+		now := time.Now()
+		for _, cert := range certs {
+			if now.Before(cert.NotBefore) {
+				return fmt.Errorf("bootstrap client certificate is not yet valid (NotBefore %s); check the node clock for skew", cert.NotBefore)
+			}
+		}
+	}
+
+	connectConfig, err := configWithProxy(bootstrapClientConfig, proxyURL)
+	if err != nil {
+		return err
+	}
+	if err := waitForServer(*connectConfig, 30*time.Second); err != nil {
+		return fmt.Errorf("unable to reach apiserver with bootstrap credentials: %v", err)
+	}
+	return nil
+}
+
+// certsFromBootstrapConfig extracts the client certificates, if any, embedded
+// in or referenced by a bootstrap client config.
+func certsFromBootstrapConfig(cfg *restclient.Config) ([]*x509.Certificate, error) {
+	transportConfig, err := cfg.TransportConfig()
+	if err != nil {
+		return nil, err
+	}
+	// has side effect of populating transport config data fields
+	if _, err := transport.TLSConfigFor(transportConfig); err != nil {
+		return nil, err
+	}
+	if len(transportConfig.TLS.CertData) == 0 {
+		return nil, nil
+	}
+	return certutil.ParseCertsPEM(transportConfig.TLS.CertData)
+}
+
+// loadRESTClientConfig flattens the kubeconfig at the given path into a rest
+// config, and additionally returns the proxy-url, if any, of the cluster the
+// current context points at. The proxy is returned separately because
+// restclient.Config has no field for it; configWithProxy applies it where a
+// network client is actually built.
+func loadRESTClientConfig(kubeconfig string) (*restclient.Config, string, error) {
 	// Load structured kubeconfig data from the given path.
 	loader := &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}
 	loadedConfig, err := loader.Load()
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	proxyURL := ""
+	if currentContext := loadedConfig.Contexts[loadedConfig.CurrentContext]; currentContext != nil {
+		if cluster := loadedConfig.Clusters[currentContext.Cluster]; cluster != nil {
+			proxyURL = cluster.ProxyURL
+		}
 	}
 	// Flatten the loaded data to a particular restclient.Config based on the current context.
-	return clientcmd.NewNonInteractiveClientConfig(
+	cfg, err := clientcmd.NewNonInteractiveClientConfig(
 		*loadedConfig,
 		loadedConfig.CurrentContext,
 		&clientcmd.ConfigOverrides{},
 		loader,
 	).ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	return cfg, proxyURL, nil
+}
+
+// configWithProxy returns a client config for building network clients,
+// honoring a proxy-url from the bootstrap kubeconfig over the proxy
+// environment variables. A kubeconfig proxy is compiled into an explicit
+// transport on a copy, so the original config keeps its TLS fields for the
+// kubeconfigs written after bootstrap; without one the config is returned
+// unmodified and clients fall back to the environment. Either way the chosen
+// path is logged so air-gapped setups can verify how bootstrap traffic flows.
+func configWithProxy(cfg *restclient.Config, proxyURL string) (*restclient.Config, error) {
+	if len(proxyURL) == 0 {
+		if target, err := url.Parse(cfg.Host); err == nil {
+			if proxy, err := http.ProxyFromEnvironment(&http.Request{URL: target}); err == nil && proxy != nil {
+				glog.V(2).Infof("Bootstrapping through proxy %s from the environment for %s", proxy, cfg.Host)
+			} else {
+				glog.V(2).Infof("No bootstrap proxy configured, connecting directly to %s", cfg.Host)
+			}
+		}
+		return cfg, nil
+	}
+
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy-url %q in bootstrap kubeconfig: %v", proxyURL, err)
+	}
+	transportConfig, err := cfg.TransportConfig()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := transport.TLSConfigFor(transportConfig)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(2).Infof("Bootstrapping through proxy %s from the bootstrap kubeconfig for %s", proxy, cfg.Host)
+
+	proxiedConfig := *cfg
+	// An explicit transport may not be combined with the TLS fields; the TLS
+	// settings are already compiled into the transport above.
+	proxiedConfig.TLSClientConfig = restclient.TLSClientConfig{}
+	// CIDR entries in NO_PROXY still bypass the kubeconfig proxy, so
+	// individual apiservers can be exempted without editing the kubeconfig.
+	proxiedConfig.Transport = utilnet.SetTransportDefaults(&http.Transport{
+		Proxy:               utilnet.NewProxierWithNoProxyCIDR(http.ProxyURL(proxy)),
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+	})
+	return &proxiedConfig, nil
 }
 
 // verifyBootstrapClientConfig checks the provided kubeconfig to see if it has a valid
 // client certificate. It returns true if the kubeconfig is valid, or an error if bootstrapping
-// should stop immediately.
-func verifyBootstrapClientConfig(kubeconfigPath string) (bool, error) {
+// should stop immediately. A certificate that expires within expiryThreshold
+// is treated as invalid so that the caller rotates it before it lapses.
+func verifyBootstrapClientConfig(kubeconfigPath string, expiryThreshold time.Duration) (bool, error) {
 	_, err := os.Stat(kubeconfigPath)
 	if os.IsNotExist(err) {
 		return false, nil
@@ -175,7 +653,7 @@ func verifyBootstrapClientConfig(kubeconfigPath string) (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("error reading existing bootstrap kubeconfig %s: %v", kubeconfigPath, err)
 	}
-	bootstrapClientConfig, err := loadRESTClientConfig(kubeconfigPath)
+	bootstrapClientConfig, _, err := loadRESTClientConfig(kubeconfigPath)
 	if err != nil {
 		utilruntime.HandleError(fmt.Errorf("unable to read existing bootstrap client config: %v", err))
 		return false, nil
@@ -205,6 +683,10 @@ func verifyBootstrapClientConfig(kubeconfigPath string) (bool, error) {
 			utilruntime.HandleError(fmt.Errorf("part of the existing bootstrap client certificate is expired: %s", cert.NotAfter))
 			return false, nil
 		}
+		if expiryThreshold > 0 && now.Add(expiryThreshold).After(cert.NotAfter) {
+			glog.V(2).Infof("Existing bootstrap client certificate expires at %s, within the %v rotation threshold; requesting a new certificate", cert.NotAfter, expiryThreshold)
+			return false, nil
+		}
 	}
 	return true, nil
 }
@@ -218,9 +700,73 @@ func verifyKeyData(data []byte) bool {
 	return err == nil
 }
 
+// waitForCertValidity checks the issued certificate against the local clock.
+// When the node clock is ahead of the CA the certificate appears not yet
+// valid and the kubelet would crash-loop on it; waiting here turns a small
+// skew into a bounded delay with a clear log message.
+func waitForCertValidity(certData []byte) error {
+	certs, err := certutil.ParseCertsPEM(certData)
+	if err != nil || len(certs) == 0 {
+		return nil
+	}
+	delay := certs[0].NotBefore.Sub(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+	if delay > maxCertValidityWait {
+		return fmt.Errorf("issued certificate is not valid until %s, %v from now; the node clock is likely ahead of the cluster CA", certs[0].NotBefore, delay.Round(time.Second))
+	}
+	glog.Warningf("Issued certificate is not valid until %s; waiting %v for the node clock to catch up", certs[0].NotBefore, delay.Round(time.Second))
+	time.Sleep(delay)
+	return nil
+}
+
+// clockSkewRoundTripper samples the apiserver Date header so waitForServer
+// can compare the node clock against the control plane.
+type clockSkewRoundTripper struct {
+	delegate http.RoundTripper
+
+	mu      sync.Mutex
+	sampled bool
+	skew    time.Duration
+}
+
+func (t *clockSkewRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	if serverTime, perr := http.ParseTime(resp.Header.Get("Date")); perr == nil {
+		// Sample the local clock at the midpoint of the request to halve the
+		// latency bias; the Date header only has second granularity anyway.
+		local := start.Add(time.Since(start) / 2)
+		t.mu.Lock()
+		t.skew = local.Sub(serverTime)
+		t.sampled = true
+		t.mu.Unlock()
+	}
+	return resp, err
+}
+
+func (t *clockSkewRoundTripper) lastSkew() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.skew, t.sampled
+}
+
 func waitForServer(cfg restclient.Config, deadline time.Duration) error {
 	cfg.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: scheme.Codecs}
 	cfg.Timeout = 1 * time.Second
+	skewDetector := &clockSkewRoundTripper{}
+	existingWrap := cfg.WrapTransport
+	cfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if existingWrap != nil {
+			rt = existingWrap(rt)
+		}
+		skewDetector.delegate = rt
+		return skewDetector
+	}
 	cli, err := restclient.UnversionedRESTClientFor(&cfg)
 	if err != nil {
 		return fmt.Errorf("couldn't create client: %v", err)
@@ -242,5 +788,9 @@ func waitForServer(cfg restclient.Config, deadline time.Duration) error {
 	if !connected {
 		return errors.New("timed out waiting to connect to apiserver")
 	}
+
+	if skew, ok := skewDetector.lastSkew(); ok && (skew > clockSkewThreshold || skew < -clockSkewThreshold) {
+		glog.Errorf("Node clock differs from the apiserver clock by %v; certificates issued for this node may appear invalid until the clocks agree", skew.Round(time.Second))
+	}
 	return nil
 }
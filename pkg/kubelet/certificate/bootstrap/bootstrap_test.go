@@ -18,9 +18,12 @@ package bootstrap
 
 import (
 	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/diff"
 	restclient "k8s.io/client-go/rest"
@@ -36,8 +39,9 @@ clusters:
     server: https://cluster-a.com
   name: cluster-a
 - cluster:
-    certificate-authority-data: VGVzdA== 
+    certificate-authority-data: VGVzdA==
     server: https://cluster-b.com
+    proxy-url: http://proxy.example.com:3128
   name: cluster-b
 contexts:
 - context:
@@ -66,7 +70,7 @@ users:
 	defer os.Remove(f.Name())
 	ioutil.WriteFile(f.Name(), testData, os.FileMode(0755))
 
-	config, err := loadRESTClientConfig(f.Name())
+	config, proxyURL, err := loadRESTClientConfig(f.Name())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -82,4 +86,134 @@ users:
 	if !reflect.DeepEqual(config, expectedConfig) {
 		t.Errorf("Unexpected config: %s", diff.ObjectDiff(config, expectedConfig))
 	}
+	if proxyURL != "http://proxy.example.com:3128" {
+		t.Errorf("Unexpected proxy-url: %q", proxyURL)
+	}
+}
+
+func TestConfigWithProxy(t *testing.T) {
+	cfg := &restclient.Config{
+		Host: "https://cluster-a.com",
+		TLSClientConfig: restclient.TLSClientConfig{
+			CAData: []byte(`Test`),
+		},
+	}
+
+	// Without a kubeconfig proxy the config is used as is, so clients fall
+	// back to the proxy environment variables.
+	direct, err := configWithProxy(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if direct != cfg {
+		t.Errorf("expected the original config without a kubeconfig proxy")
+	}
+
+	proxied, err := configWithProxy(cfg, "http://proxy.example.com:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxied == cfg {
+		t.Fatalf("expected a copy of the config with a kubeconfig proxy")
+	}
+	if proxied.Transport == nil {
+		t.Errorf("expected an explicit transport carrying the proxy")
+	}
+	if !reflect.DeepEqual(proxied.TLSClientConfig, restclient.TLSClientConfig{}) {
+		t.Errorf("expected TLS fields to move into the transport")
+	}
+	if len(cfg.TLSClientConfig.CAData) == 0 {
+		t.Errorf("expected the original config to keep its TLS fields")
+	}
+
+	if _, err := configWithProxy(cfg, "http://proxy example com"); err == nil {
+		t.Errorf("expected an error for an unparsable proxy-url")
+	}
+}
+
+func TestWriteReadOnlyKubeconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bootstrap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "companion.token")
+	if err := ioutil.WriteFile(tokenFile, []byte("readonly-token"), os.FileMode(0600)); err != nil {
+		t.Fatal(err)
+	}
+
+	bootstrapClientConfig := &restclient.Config{
+		Host: "https://cluster-a.com",
+		TLSClientConfig: restclient.TLSClientConfig{
+			CAData: []byte(`Test`),
+		},
+	}
+	options := CertificateRequestOptions{
+		ReadOnlyKubeconfigPath: filepath.Join(dir, "companion.kubeconfig"),
+		ReadOnlyTokenFile:      tokenFile,
+	}
+	if err := writeReadOnlyKubeconfig(bootstrapClientConfig, options); err != nil {
+		t.Fatal(err)
+	}
+
+	config, _, err := loadRESTClientConfig(options.ReadOnlyKubeconfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedConfig := &restclient.Config{
+		Host: "https://cluster-a.com",
+		TLSClientConfig: restclient.TLSClientConfig{
+			CAData: []byte(`Test`),
+		},
+		BearerToken: "readonly-token",
+	}
+
+	if !reflect.DeepEqual(config, expectedConfig) {
+		t.Errorf("Unexpected config: %s", diff.ObjectDiff(config, expectedConfig))
+	}
+}
+
+type staticDateRoundTripper struct {
+	date string
+}
+
+func (rt *staticDateRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	if rt.date != "" {
+		resp.Header.Set("Date", rt.date)
+	}
+	return resp, nil
+}
+
+func TestClockSkewRoundTripper(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://localhost/healthz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No Date header leaves the detector unsampled.
+	detector := &clockSkewRoundTripper{delegate: &staticDateRoundTripper{}}
+	if _, err := detector.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, sampled := detector.lastSkew(); sampled {
+		t.Errorf("expected no skew sample without a Date header")
+	}
+
+	// A server clock an hour behind the local clock shows up as positive skew.
+	detector = &clockSkewRoundTripper{delegate: &staticDateRoundTripper{
+		date: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat),
+	}}
+	if _, err := detector.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	skew, sampled := detector.lastSkew()
+	if !sampled {
+		t.Fatalf("expected a skew sample")
+	}
+	if skew < 59*time.Minute || skew > 61*time.Minute {
+		t.Errorf("expected roughly one hour of skew, got %v", skew)
+	}
 }
@@ -34,6 +34,7 @@ import (
 	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager/errors"
 	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
 	"k8s.io/kubernetes/pkg/kubelet/dockershim/libdocker"
+	"k8s.io/kubernetes/pkg/kubelet/dockershim/network"
 	"k8s.io/kubernetes/pkg/kubelet/qos"
 	"k8s.io/kubernetes/pkg/kubelet/types"
 )
@@ -421,6 +422,11 @@ func (ds *dockerService) PodSandboxStatus(ctx context.Context, req *runtimeapi.P
 	}
 
 	labels, annotations := extractLabels(r.Config.Labels)
+	// Surface the network plugin's setup result (assigned IPs, interfaces,
+	// network name) so operators can inspect actual CNI results per pod.
+	if summary, ok := ds.network.PodNetworkSetupSummary(kubecontainer.BuildContainerID(runtimeName, podSandboxID)); ok {
+		annotations[network.PodNetworkSetupAnnotation] = summary
+	}
 	status := &runtimeapi.PodSandboxStatus{
 		Id:          r.ID,
 		State:       state,
@@ -30,6 +30,7 @@ import (
 	"github.com/golang/glog"
 
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	kubeletconfig "k8s.io/kubernetes/pkg/kubelet/apis/config"
 	runtimeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
@@ -71,6 +72,10 @@ const (
 	// The expiration time of version cache.
 	versionCacheTTL = 60 * time.Second
 
+	// How often the network plugin is asked to clean up resources belonging
+	// to sandboxes the runtime no longer knows about.
+	networkGCPeriod = 5 * time.Minute
+
 	defaultCgroupDriver = "cgroupfs"
 
 	// TODO: https://github.com/kubernetes/kubernetes/pull/31169 provides experimental
@@ -402,9 +407,29 @@ func (ds *dockerService) Start() error {
 			}
 		}()
 	}
+	// Let the network plugin periodically clean up after sandboxes that were
+	// removed without it noticing, e.g. while its binaries were broken.
+	go wait.Forever(ds.networkGarbageCollect, networkGCPeriod)
 	return ds.containerManager.Start()
 }
 
+// networkGarbageCollect passes the sandboxes the runtime still knows about to
+// the network plugin, which releases resources of attachments not in the list.
+func (ds *dockerService) networkGarbageCollect() {
+	resp, err := ds.ListPodSandbox(context.Background(), &runtimeapi.ListPodSandboxRequest{})
+	if err != nil {
+		glog.Warningf("Failed to list pod sandboxes for network garbage collection: %v", err)
+		return
+	}
+	validSandboxIDs := make([]string, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		validSandboxIDs = append(validSandboxIDs, item.Id)
+	}
+	if err := ds.network.GarbageCollect(validSandboxIDs); err != nil {
+		glog.Warningf("Network plugin garbage collection failed: %v", err)
+	}
+}
+
 // Status returns the status of the runtime.
 func (ds *dockerService) Status(_ context.Context, r *runtimeapi.StatusRequest) (*runtimeapi.StatusResponse, error) {
 	runtimeReady := &runtimeapi.RuntimeCondition{
@@ -17,18 +17,30 @@ limitations under the License.
 package cni
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/invoke"
 	cnitypes "github.com/containernetworking/cni/pkg/types"
+	cnicurrent "github.com/containernetworking/cni/pkg/types/current"
 	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	kubeletconfig "k8s.io/kubernetes/pkg/kubelet/apis/config"
 	kubecontainer "k8s.io/kubernetes/pkg/kubelet/container"
 	"k8s.io/kubernetes/pkg/kubelet/dockershim/network"
+	"k8s.io/kubernetes/pkg/kubelet/dockershim/network/metrics"
 	"k8s.io/kubernetes/pkg/util/bandwidth"
 	utilexec "k8s.io/utils/exec"
 )
@@ -51,12 +63,93 @@ type cniNetworkPlugin struct {
 	confDir     string
 	binDirs     []string
 	podCidr     string
+
+	// syncStatus records the outcome of the most recent network config
+	// sync; guarded by the embedded RWMutex.
+	syncStatus SyncStatus
+
+	// pendingTeardowns holds teardowns that failed and are retried in the
+	// background, keyed by sandbox ID; guarded by teardownsMutex.
+	teardownsMutex   sync.Mutex
+	pendingTeardowns map[string]*pendingTeardown
+
+	// setupResults holds the CNI result of the last successful SetUpPod per
+	// sandbox ID, for surfacing in the sandbox status; guarded by
+	// setupResultsMutex. Completed ADDs are additionally persisted under
+	// addStateDir so they survive a kubelet restart.
+	setupResultsMutex sync.Mutex
+	setupResults      map[string]*PodNetworkSetupResult
+
+	// inflightAdds tracks sandbox IDs whose CNI ADD has not finished yet, so
+	// a kubelet retry cannot allocate a second set of IPs for the same
+	// sandbox; guarded by inflightMutex.
+	inflightMutex sync.Mutex
+	inflightAdds  map[string]bool
+
+	// validation caches the result of validateNetworkPlugins; guarded by
+	// validationMutex. The VERSION probe execs every referenced plugin
+	// binary, which must not happen on every status poll and sandbox
+	// operation, so the result is reused until the config or one of the
+	// binaries changes.
+	validationMutex sync.Mutex
+	validation      *validationState
+}
+
+// validationState is a cached validateNetworkPlugins outcome, keyed by the
+// fingerprint of the config and plugin binaries it was computed from.
+type validationState struct {
+	key string
+	err error
+}
+
+// PodNetworkSetupResult summarizes the outcome of a pod's CNI ADD for
+// observability: the network used and what the plugins actually assigned.
+type PodNetworkSetupResult struct {
+	// NetworkName is the name of the CNI network the sandbox was added to.
+	NetworkName string `json:"networkName"`
+	// IPs are the IP addresses the plugins assigned to the sandbox.
+	IPs []string `json:"ips,omitempty"`
+	// Interfaces are the names of the sandbox-side interfaces created.
+	Interfaces []string `json:"interfaces,omitempty"`
+	// SetupTime is when the CNI ADD completed.
+	SetupTime time.Time `json:"setupTime"`
+}
+
+// pendingTeardown is a failed pod network teardown queued for retry. Entries
+// are retried until DelNetworkList succeeds; once the sandbox is garbage
+// collected the retry sees "no such file or directory" and the entry is
+// dropped.
+type pendingTeardown struct {
+	podNamespace string
+	podName      string
+	id           kubecontainer.ContainerID
+	netnsPath    string
+	attempts     int
+}
+
+// SyncStatus describes the result of the plugin's most recent attempt to load
+// the CNI configuration. It is intended for debug endpoints and node problem
+// detectors that want to alert on stale or broken CNI config.
+type SyncStatus struct {
+	// LastSyncTime is the time of the last successful config sync.
+	LastSyncTime time.Time `json:"lastSyncTime"`
+	// ConfHash is the SHA-256 of the config file chosen by the last
+	// successful sync.
+	ConfHash string `json:"confHash"`
+	// ConfFile is the path of that config file.
+	ConfFile string `json:"confFile"`
+	// LastError is the error of the most recent sync attempt; empty when
+	// the last attempt succeeded.
+	LastError string `json:"lastError"`
 }
 
 type cniNetwork struct {
 	name          string
 	NetworkConfig *libcni.NetworkConfigList
 	CNIConfig     libcni.CNI
+	// confFile and confHash identify the file the config was loaded from.
+	confFile string
+	confHash string
 }
 
 // cniPortMapping maps to the standard CNI portmapping Capability
@@ -104,11 +197,14 @@ func ProbeNetworkPlugins(confDir string, binDirs []string) []network.NetworkPlug
 	}
 
 	plugin := &cniNetworkPlugin{
-		defaultNetwork: nil,
-		loNetwork:      getLoNetwork(binDirs),
-		execer:         utilexec.New(),
-		confDir:        confDir,
-		binDirs:        binDirs,
+		defaultNetwork:   nil,
+		loNetwork:        getLoNetwork(binDirs),
+		execer:           utilexec.New(),
+		confDir:          confDir,
+		binDirs:          binDirs,
+		pendingTeardowns: map[string]*pendingTeardown{},
+		setupResults:     map[string]*PodNetworkSetupResult{},
+		inflightAdds:     map[string]bool{},
 	}
 
 	// sync NetworkConfig in best effort during probing.
@@ -164,6 +260,8 @@ func getDefaultCNINetwork(confDir string, binDirs []string) (*cniNetwork, error)
 			name:          confList.Name,
 			NetworkConfig: confList,
 			CNIConfig:     &libcni.CNIConfig{Path: binDirs},
+			confFile:      confFile,
+			confHash:      hashConfFile(confFile),
 		}
 		return network, nil
 	}
@@ -179,6 +277,7 @@ func (plugin *cniNetworkPlugin) Init(host network.Host, hairpinMode kubeletconfi
 	plugin.host = host
 
 	plugin.syncNetworkConfig()
+	go wait.Forever(plugin.retryPendingTeardowns, teardownRetryPeriod)
 	return nil
 }
 
@@ -186,9 +285,43 @@ func (plugin *cniNetworkPlugin) syncNetworkConfig() {
 	network, err := getDefaultCNINetwork(plugin.confDir, plugin.binDirs)
 	if err != nil {
 		glog.Warningf("Unable to update cni config: %s", err)
+		plugin.Lock()
+		plugin.syncStatus.LastError = err.Error()
+		plugin.Unlock()
 		return
 	}
 	plugin.setDefaultNetwork(network)
+	plugin.Lock()
+	confChanged := plugin.syncStatus.ConfHash != network.confHash
+	plugin.syncStatus = SyncStatus{
+		LastSyncTime: time.Now(),
+		ConfHash:     network.confHash,
+		ConfFile:     network.confFile,
+	}
+	plugin.Unlock()
+	// Version negotiation execs every plugin binary; Status() syncs on every
+	// runtime status poll, so only renegotiate when the config changed.
+	if confChanged {
+		plugin.negotiateVersions(network)
+	}
+}
+
+// LastSyncStatus returns the recorded outcome of the most recent CNI config
+// sync.
+func (plugin *cniNetworkPlugin) LastSyncStatus() SyncStatus {
+	plugin.RLock()
+	defer plugin.RUnlock()
+	return plugin.syncStatus
+}
+
+// hashConfFile returns the hex SHA-256 of the given config file, or an empty
+// string if it cannot be read.
+func hashConfFile(confFile string) string {
+	data, err := ioutil.ReadFile(confFile)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
 }
 
 func (plugin *cniNetworkPlugin) getDefaultNetwork() *cniNetwork {
@@ -204,12 +337,13 @@ func (plugin *cniNetworkPlugin) setDefaultNetwork(n *cniNetwork) {
 }
 
 func (plugin *cniNetworkPlugin) checkInitialized() error {
-	if plugin.getDefaultNetwork() == nil {
+	network := plugin.getDefaultNetwork()
+	if network == nil {
 		return errors.New("cni config uninitialized")
 	}
 
 	// If the CNI configuration has the ipRanges capability, we need a PodCIDR assigned
-	for _, p := range plugin.getDefaultNetwork().NetworkConfig.Plugins {
+	for _, p := range network.NetworkConfig.Plugins {
 		if p.Network.Capabilities["ipRanges"] {
 			if plugin.podCidr == "" {
 				return errors.New("no PodCIDR set")
@@ -217,9 +351,204 @@ func (plugin *cniNetworkPlugin) checkInitialized() error {
 			break
 		}
 	}
+
+	// A config that references missing or broken plugin binaries is as
+	// unusable as no config at all; every pod sandbox would fail. Verify the
+	// binaries up front so the node does not report Ready in that state. The
+	// result is cached, so this does not exec the binaries on every call.
+	return plugin.validateNetworkPluginsCached(network)
+}
+
+// validationKey fingerprints what validateNetworkPlugins depends on: the
+// chosen config file and the referenced plugin binaries' paths and
+// modification times. A missing binary contributes its lookup error, so the
+// cache is invalidated when the binary appears.
+func validationKey(network *cniNetwork) string {
+	parts := []string{network.confHash}
+	cniConfig, ok := network.CNIConfig.(*libcni.CNIConfig)
+	if !ok {
+		return parts[0]
+	}
+	for _, p := range network.NetworkConfig.Plugins {
+		path, err := invoke.FindInPath(p.Network.Type, cniConfig.Path)
+		if err != nil {
+			parts = append(parts, p.Network.Type+": "+err.Error())
+			continue
+		}
+		entry := path
+		if fi, err := os.Stat(path); err == nil {
+			entry += "@" + strconv.FormatInt(fi.ModTime().UnixNano(), 10)
+		}
+		parts = append(parts, entry)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// validateNetworkPluginsCached returns the cached validation result for the
+// network, re-running validateNetworkPlugins only when the config or one of
+// the plugin binaries changed since the last run.
+func (plugin *cniNetworkPlugin) validateNetworkPluginsCached(network *cniNetwork) error {
+	key := validationKey(network)
+	plugin.validationMutex.Lock()
+	defer plugin.validationMutex.Unlock()
+	if plugin.validation != nil && plugin.validation.key == key {
+		return plugin.validation.err
+	}
+	err := plugin.validateNetworkPlugins(network)
+	plugin.validation = &validationState{key: key, err: err}
+	return err
+}
+
+// validateNetworkPlugins verifies that every plugin referenced by the network
+// config resolves to a binary in binDirs and that the binary responds to a
+// dry-run VERSION call.
+func (plugin *cniNetworkPlugin) validateNetworkPlugins(network *cniNetwork) error {
+	cniConfig, ok := network.CNIConfig.(*libcni.CNIConfig)
+	if !ok {
+		return nil
+	}
+
+	for _, p := range network.NetworkConfig.Plugins {
+		if _, err := invoke.FindInPath(p.Network.Type, cniConfig.Path); err != nil {
+			return fmt.Errorf("cni plugin %q not found in %v: %v", p.Network.Type, cniConfig.Path, err)
+		}
+		if _, err := cniConfig.GetVersionInfo(p.Network.Type); err != nil {
+			return fmt.Errorf("cni plugin %q failed VERSION check: %v", p.Network.Type, err)
+		}
+	}
 	return nil
 }
 
+// negotiateVersions compares the spec version requested by the network config
+// against the versions each plugin binary advertises in its VERSION response,
+// and warns when a binary does not support the configured version. Running
+// this at sync time surfaces mismatches introduced by a plugin upgrade in the
+// kubelet log before pod setup starts failing.
+func (plugin *cniNetworkPlugin) negotiateVersions(network *cniNetwork) {
+	cniConfig, ok := network.CNIConfig.(*libcni.CNIConfig)
+	if !ok {
+		return
+	}
+
+	confVersion := network.NetworkConfig.CNIVersion
+	if confVersion == "" {
+		return
+	}
+	for _, p := range network.NetworkConfig.Plugins {
+		info, err := cniConfig.GetVersionInfo(p.Network.Type)
+		if err != nil {
+			// Missing or broken binaries are reported by checkInitialized.
+			glog.V(4).Infof("Unable to get version info for CNI plugin %q: %v", p.Network.Type, err)
+			continue
+		}
+		supported := false
+		for _, v := range info.SupportedVersions() {
+			if v == confVersion {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			glog.Warningf("CNI config %s requires spec version %s, but plugin %q only supports %v", network.confFile, confVersion, p.Network.Type, info.SupportedVersions())
+		}
+	}
+}
+
+// gcValidAttachment identifies an attachment the runtime still knows about,
+// as expected by the GC verb's cni.dev/valid-attachments key.
+type gcValidAttachment struct {
+	ContainerID string `json:"containerID"`
+	IfName      string `json:"ifname"`
+}
+
+// GarbageCollect issues the CNI GC verb to every plugin of the default
+// network whose binary supports it, passing the pod sandbox IDs the runtime
+// still knows about. Plugins delete resources belonging to attachments not in
+// that list, cleaning up after sandboxes removed while the plugin was broken
+// or being upgraded. Binaries predating the GC verb (spec 1.1.0) are skipped.
+func (plugin *cniNetworkPlugin) GarbageCollect(validSandboxIDs []string) error {
+	defaultNet := plugin.getDefaultNetwork()
+	if defaultNet == nil {
+		return errors.New("cni config uninitialized")
+	}
+	cniConfig, ok := defaultNet.CNIConfig.(*libcni.CNIConfig)
+	if !ok {
+		return nil
+	}
+
+	attachments := make([]gcValidAttachment, 0, len(validSandboxIDs))
+	for _, id := range validSandboxIDs {
+		attachments = append(attachments, gcValidAttachment{ContainerID: id, IfName: network.DefaultInterfaceName})
+	}
+
+	// Like DEL, GC runs the plugins in reverse order.
+	var errs []string
+	plugins := defaultNet.NetworkConfig.Plugins
+	for i := len(plugins) - 1; i >= 0; i-- {
+		p := plugins[i]
+		info, err := cniConfig.GetVersionInfo(p.Network.Type)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("plugin %q: %v", p.Network.Type, err))
+			continue
+		}
+		if !supportsGCVerb(info.SupportedVersions()) {
+			glog.V(4).Infof("CNI plugin %q does not support the GC verb, skipping", p.Network.Type)
+			continue
+		}
+
+		conf, err := libcni.InjectConf(p, map[string]interface{}{
+			"name":                      defaultNet.NetworkConfig.Name,
+			"cniVersion":                defaultNet.NetworkConfig.CNIVersion,
+			"cni.dev/valid-attachments": attachments,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("plugin %q: %v", p.Network.Type, err))
+			continue
+		}
+		pluginPath, err := invoke.FindInPath(p.Network.Type, cniConfig.Path)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("plugin %q: %v", p.Network.Type, err))
+			continue
+		}
+		// The vendored libcni predates the GC verb, so exec the plugin
+		// directly.
+		args := &invoke.Args{
+			Command: "GC",
+			Path:    strings.Join(cniConfig.Path, string(os.PathListSeparator)),
+		}
+		if err := invoke.ExecPluginWithoutResult(pluginPath, conf.Bytes, args); err != nil {
+			errs = append(errs, fmt.Sprintf("plugin %q: %v", p.Network.Type, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error garbage collecting CNI attachments: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// supportsGCVerb returns true if any of the spec versions advertised by a
+// plugin binary defines the GC verb, which was added in spec 1.1.0.
+func supportsGCVerb(versions []string) bool {
+	for _, v := range versions {
+		parts := strings.Split(v, ".")
+		if len(parts) < 2 {
+			continue
+		}
+		major, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		minor, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		if major > 1 || (major == 1 && minor >= 1) {
+			return true
+		}
+	}
+	return false
+}
+
 // Event handles any change events. The only event ever sent is the PodCIDR change.
 // No network plugins support changing an already-set PodCIDR
 func (plugin *cniNetworkPlugin) Event(name string, details map[string]interface{}) {
@@ -253,13 +582,38 @@ func (plugin *cniNetworkPlugin) Status() error {
 	plugin.syncNetworkConfig()
 
 	// Can't set up pods if we don't have any CNI network configs yet
-	return plugin.checkInitialized()
+	if err := plugin.checkInitialized(); err != nil {
+		// The last sync outcome is usually the fastest clue to why the
+		// node went NotReady, e.g. the conf file was edited or removed.
+		if status := plugin.LastSyncStatus(); status.LastError != "" {
+			if status.ConfFile != "" {
+				return fmt.Errorf("%v (last config sync error: %s; last good config %s at %s)", err, status.LastError, status.ConfFile, status.LastSyncTime.Format(time.RFC3339))
+			}
+			return fmt.Errorf("%v (last config sync error: %s)", err, status.LastError)
+		}
+		return err
+	}
+	return nil
 }
 
 func (plugin *cniNetworkPlugin) SetUpPod(namespace string, name string, id kubecontainer.ContainerID, annotations map[string]string) error {
 	if err := plugin.checkInitialized(); err != nil {
 		return err
 	}
+
+	// A kubelet retry may call SetUpPod again for a sandbox whose ADD is
+	// still running or has already completed; neither case may allocate a
+	// second set of IPs. The sandbox is only re-added after a successful DEL
+	// has cleared its record.
+	if !plugin.startAdd(id) {
+		return fmt.Errorf("CNI ADD for pod sandbox %q is already in progress", id.ID)
+	}
+	defer plugin.finishAdd(id)
+	if result := plugin.completedSetupResult(id); result != nil {
+		glog.V(2).Infof("CNI ADD for pod sandbox %q already completed on network %q, reusing its result", id.ID, result.NetworkName)
+		return nil
+	}
+
 	netnsPath, err := plugin.host.GetNetNS(id.ID)
 	if err != nil {
 		return fmt.Errorf("CNI failed to retrieve network namespace path: %v", err)
@@ -272,8 +626,202 @@ func (plugin *cniNetworkPlugin) SetUpPod(namespace string, name string, id kubec
 		}
 	}
 
-	_, err = plugin.addToNetwork(plugin.getDefaultNetwork(), name, namespace, id, netnsPath, annotations)
-	return err
+	defaultNet := plugin.getDefaultNetwork()
+	res, err := plugin.addToNetwork(defaultNet, name, namespace, id, netnsPath, annotations)
+	if err != nil {
+		return err
+	}
+
+	// A neighbor table entry that still binds one of the assigned IPs to a
+	// foreign MAC means an interface leaked by a previous sandbox claims the
+	// address. Put the sandbox back through DEL instead of reporting success
+	// with a duplicate IP on the network; the retry allocates afresh once the
+	// stale claim has been cleaned up.
+	if claim := plugin.staleIPClaim(res); claim != "" {
+		if delErr := plugin.deleteFromNetwork(defaultNet, name, namespace, id, netnsPath, nil); delErr != nil {
+			glog.Warningf("Error cleaning up pod sandbox %q after duplicate IP detection: %v", id.ID, delErr)
+		}
+		return fmt.Errorf("failed to set up pod %s_%s network: %s", namespace, name, claim)
+	}
+
+	plugin.recordSetupResult(id, defaultNet.name, res)
+	return nil
+}
+
+// staleIPClaim checks the node's neighbor table for evidence that an IP the
+// plugins just assigned is still claimed by another interface, typically one
+// leaked by a sandbox whose teardown never completed. The MACs the CNI result
+// reports for the sandbox's own interfaces are not conflicts. The check is
+// best effort: a missing ip(8) binary or an unconvertible result never fails
+// pod setup.
+func (plugin *cniNetworkPlugin) staleIPClaim(res cnitypes.Result) string {
+	converted, err := cnicurrent.NewResultFromResult(res)
+	if err != nil {
+		glog.V(4).Infof("Unable to convert CNI result for duplicate IP check: %v", err)
+		return ""
+	}
+	ownMACs := sets.NewString()
+	for _, iface := range converted.Interfaces {
+		if iface.Mac != "" {
+			ownMACs.Insert(strings.ToLower(iface.Mac))
+		}
+	}
+	for _, ip := range converted.IPs {
+		addr := ip.Address.IP.String()
+		out, err := plugin.execer.Command("ip", "neigh", "show", "to", addr).CombinedOutput()
+		if err != nil {
+			glog.V(4).Infof("Unable to check neighbor table for %s: %v", addr, err)
+			continue
+		}
+		if entry := staleNeighborEntry(string(out), ownMACs); entry != "" {
+			return fmt.Sprintf("IP %s is still claimed by a stale interface (%s)", addr, entry)
+		}
+	}
+	return ""
+}
+
+// staleNeighborEntry parses ip-neigh(8) output and returns the first entry
+// binding the address to a MAC other than the sandbox's own, or an empty
+// string. FAILED and INCOMPLETE entries carry no claim.
+func staleNeighborEntry(output string, ownMACs sets.String) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		lladdr := ""
+		for i, field := range fields {
+			if field == "lladdr" && i+1 < len(fields) {
+				lladdr = strings.ToLower(fields[i+1])
+			}
+		}
+		state := fields[len(fields)-1]
+		if lladdr == "" || state == "FAILED" || state == "INCOMPLETE" {
+			continue
+		}
+		if !ownMACs.Has(lladdr) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+// recordSetupResult keeps the outcome of a successful CNI ADD so it can be
+// surfaced in the sandbox status. Results the plugin cannot convert are
+// recorded with the network name only.
+func (plugin *cniNetworkPlugin) recordSetupResult(id kubecontainer.ContainerID, networkName string, res cnitypes.Result) {
+	result := &PodNetworkSetupResult{
+		NetworkName: networkName,
+		SetupTime:   time.Now(),
+	}
+	if converted, err := cnicurrent.NewResultFromResult(res); err == nil {
+		for _, ip := range converted.IPs {
+			result.IPs = append(result.IPs, ip.Address.String())
+		}
+		for _, iface := range converted.Interfaces {
+			// Host-side interfaces have no sandbox path.
+			if iface.Sandbox != "" {
+				result.Interfaces = append(result.Interfaces, iface.Name)
+			}
+		}
+	} else {
+		glog.V(4).Infof("Unable to convert CNI result for pod sandbox %q: %v", id.ID, err)
+	}
+
+	plugin.setupResultsMutex.Lock()
+	plugin.setupResults[id.ID] = result
+	plugin.setupResultsMutex.Unlock()
+
+	// Persist the record so a restarted kubelet does not re-ADD the sandbox.
+	// Failure to persist only loses the protection across restarts.
+	if data, err := json.Marshal(result); err == nil {
+		if err := os.MkdirAll(addStateDir, 0700); err != nil {
+			glog.Warningf("Unable to create CNI ADD state directory %s: %v", addStateDir, err)
+		} else if err := ioutil.WriteFile(addStatePath(id), data, 0600); err != nil {
+			glog.Warningf("Unable to persist CNI ADD state for sandbox %q: %v", id.ID, err)
+		}
+	}
+}
+
+// addStateDir is where completed CNI ADDs are persisted. Modified only in
+// unit tests.
+var addStateDir = "/var/lib/dockershim/cni/adds"
+
+// addStatePath returns the file recording a completed ADD for the sandbox.
+func addStatePath(id kubecontainer.ContainerID) string {
+	return filepath.Join(addStateDir, id.ID+".json")
+}
+
+// startAdd marks an ADD for the sandbox as in flight. It returns false when
+// another ADD for the same sandbox has not finished yet.
+func (plugin *cniNetworkPlugin) startAdd(id kubecontainer.ContainerID) bool {
+	plugin.inflightMutex.Lock()
+	defer plugin.inflightMutex.Unlock()
+	if plugin.inflightAdds[id.ID] {
+		return false
+	}
+	plugin.inflightAdds[id.ID] = true
+	return true
+}
+
+func (plugin *cniNetworkPlugin) finishAdd(id kubecontainer.ContainerID) {
+	plugin.inflightMutex.Lock()
+	defer plugin.inflightMutex.Unlock()
+	delete(plugin.inflightAdds, id.ID)
+}
+
+// completedSetupResult returns the record of a completed ADD for the sandbox,
+// if any, consulting the persisted state when the in-memory cache is empty
+// (e.g. after a kubelet restart).
+func (plugin *cniNetworkPlugin) completedSetupResult(id kubecontainer.ContainerID) *PodNetworkSetupResult {
+	plugin.setupResultsMutex.Lock()
+	result, ok := plugin.setupResults[id.ID]
+	plugin.setupResultsMutex.Unlock()
+	if ok {
+		return result
+	}
+
+	data, err := ioutil.ReadFile(addStatePath(id))
+	if err != nil {
+		return nil
+	}
+	result = &PodNetworkSetupResult{}
+	if err := json.Unmarshal(data, result); err != nil {
+		glog.Warningf("Unable to parse persisted CNI ADD state for sandbox %q: %v", id.ID, err)
+		return nil
+	}
+	plugin.setupResultsMutex.Lock()
+	plugin.setupResults[id.ID] = result
+	plugin.setupResultsMutex.Unlock()
+	return result
+}
+
+// clearSetupResult drops the completed-ADD record of a sandbox after a
+// successful DEL, allowing a later ADD for the same sandbox to run again.
+func (plugin *cniNetworkPlugin) clearSetupResult(id kubecontainer.ContainerID) {
+	plugin.setupResultsMutex.Lock()
+	delete(plugin.setupResults, id.ID)
+	plugin.setupResultsMutex.Unlock()
+	if err := os.Remove(addStatePath(id)); err != nil && !os.IsNotExist(err) {
+		glog.Warningf("Unable to remove persisted CNI ADD state for sandbox %q: %v", id.ID, err)
+	}
+}
+
+// PodNetworkSetupSummary returns a JSON summary of the last successful CNI ADD
+// for the sandbox, implementing network.SetupResultProvider.
+func (plugin *cniNetworkPlugin) PodNetworkSetupSummary(id kubecontainer.ContainerID) (string, bool) {
+	plugin.setupResultsMutex.Lock()
+	result, ok := plugin.setupResults[id.ID]
+	plugin.setupResultsMutex.Unlock()
+	if !ok {
+		return "", false
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		glog.Warningf("Unable to marshal network setup result for sandbox %q: %v", id.ID, err)
+		return "", false
+	}
+	return string(data), true
 }
 
 func (plugin *cniNetworkPlugin) TearDownPod(namespace string, name string, id kubecontainer.ContainerID) error {
@@ -287,7 +835,66 @@ func (plugin *cniNetworkPlugin) TearDownPod(namespace string, name string, id ku
 		glog.Warningf("CNI failed to retrieve network namespace path: %v", err)
 	}
 
-	return plugin.deleteFromNetwork(plugin.getDefaultNetwork(), name, namespace, id, netnsPath, nil)
+	if err := plugin.deleteFromNetwork(plugin.getDefaultNetwork(), name, namespace, id, netnsPath, nil); err != nil {
+		// Queue the teardown for background retries so a transient plugin
+		// failure does not leak IPAM allocations for the sandbox.
+		plugin.enqueueTeardown(&pendingTeardown{
+			podNamespace: namespace,
+			podName:      name,
+			id:           id,
+			netnsPath:    netnsPath,
+		})
+		return err
+	}
+	// The record of the completed ADD is only cleared after a successful DEL,
+	// so a duplicate SetUpPod for the sandbox keeps returning the cached
+	// result until the allocation is actually released.
+	plugin.clearSetupResult(id)
+	plugin.dequeueTeardown(id)
+	return nil
+}
+
+// teardownRetryPeriod is how often queued teardowns are retried.
+const teardownRetryPeriod = 30 * time.Second
+
+func (plugin *cniNetworkPlugin) enqueueTeardown(teardown *pendingTeardown) {
+	plugin.teardownsMutex.Lock()
+	defer plugin.teardownsMutex.Unlock()
+	if existing, ok := plugin.pendingTeardowns[teardown.id.ID]; ok {
+		teardown.attempts = existing.attempts
+	}
+	plugin.pendingTeardowns[teardown.id.ID] = teardown
+	metrics.NetworkPluginTeardownQueueDepth.Set(float64(len(plugin.pendingTeardowns)))
+}
+
+func (plugin *cniNetworkPlugin) dequeueTeardown(id kubecontainer.ContainerID) {
+	plugin.teardownsMutex.Lock()
+	defer plugin.teardownsMutex.Unlock()
+	delete(plugin.pendingTeardowns, id.ID)
+	metrics.NetworkPluginTeardownQueueDepth.Set(float64(len(plugin.pendingTeardowns)))
+}
+
+// retryPendingTeardowns retries every queued teardown once. Successful
+// deletes, including "no such file or directory" from an already garbage
+// collected sandbox, remove the entry from the queue.
+func (plugin *cniNetworkPlugin) retryPendingTeardowns() {
+	plugin.teardownsMutex.Lock()
+	teardowns := make([]*pendingTeardown, 0, len(plugin.pendingTeardowns))
+	for _, teardown := range plugin.pendingTeardowns {
+		teardowns = append(teardowns, teardown)
+	}
+	plugin.teardownsMutex.Unlock()
+
+	for _, teardown := range teardowns {
+		teardown.attempts++
+		if err := plugin.deleteFromNetwork(plugin.getDefaultNetwork(), teardown.podName, teardown.podNamespace, teardown.id, teardown.netnsPath, nil); err != nil {
+			glog.V(3).Infof("Retried teardown of pod %s_%s (attempt %d) failed: %v", teardown.podNamespace, teardown.podName, teardown.attempts, err)
+			continue
+		}
+		glog.V(2).Infof("Retried teardown of pod %s_%s succeeded after %d attempts", teardown.podNamespace, teardown.podName, teardown.attempts)
+		plugin.clearSetupResult(teardown.id)
+		plugin.dequeueTeardown(teardown.id)
+	}
 }
 
 func podDesc(namespace, name string, id kubecontainer.ContainerID) string {
@@ -34,6 +34,7 @@ import (
 	types020 "github.com/containernetworking/cni/pkg/types/020"
 	"github.com/stretchr/testify/mock"
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	clientset "k8s.io/client-go/kubernetes"
 	utiltesting "k8s.io/client-go/util/testing"
 	kubeletconfig "k8s.io/kubernetes/pkg/kubelet/apis/config"
@@ -72,6 +73,10 @@ func installPluginUnderTest(t *testing.T, testBinDir, testConfDir, testDataDir,
 	f, err = os.Create(pluginExec)
 
 	const execScriptTempl = `#!/usr/bin/env bash
+if [ "$CNI_COMMAND" = "VERSION" ]; then
+	echo -n "{ \"cniVersion\": \"0.3.1\", \"supportedVersions\": [\"0.1.0\", \"0.2.0\", \"0.3.0\", \"0.3.1\"] }"
+	exit 0
+fi
 cat > {{.InputFile}}
 env > {{.OutputEnv}}
 echo "%@" >> {{.OutputEnv}}
@@ -163,6 +168,17 @@ func TestCNIPlugin(t *testing.T) {
 	podIP := "10.0.0.2"
 	podIPOutput := fmt.Sprintf("4: eth0    inet %s/24 scope global dynamic eth0\\       valid_lft forever preferred_lft forever", podIP)
 	fakeCmds := []fakeexec.FakeCommandAction{
+		// The duplicate IP check during SetUpPod; an empty neighbor table
+		// means no stale claim.
+		func(cmd string, args ...string) exec.Cmd {
+			return fakeexec.InitFakeCmd(&fakeexec.FakeCmd{
+				CombinedOutputScript: []fakeexec.FakeCombinedOutputAction{
+					func() ([]byte, error) {
+						return []byte(""), nil
+					},
+				},
+			}, cmd, args...)
+		},
 		func(cmd string, args ...string) exec.Cmd {
 			return fakeexec.InitFakeCmd(&fakeexec.FakeCmd{
 				CombinedOutputScript: []fakeexec.FakeCombinedOutputAction{
@@ -189,6 +205,8 @@ func TestCNIPlugin(t *testing.T) {
 	testBinDir := path.Join(tmpDir, "opt", "cni", "bin")
 	testDataDir := path.Join(tmpDir, "output")
 	defer tearDownPlugin(tmpDir)
+	defer func(dir string) { addStateDir = dir }(addStateDir)
+	addStateDir = path.Join(tmpDir, "adds")
 	inputFile, outputFile, outputEnv := installPluginUnderTest(t, testBinDir, testConfDir, testDataDir, binName, netName)
 
 	containerID := kubecontainer.ContainerID{Type: "test", ID: "test_infra_container"}
@@ -306,6 +324,15 @@ func TestCNIPlugin(t *testing.T) {
 		t.Errorf("mismatch in expected ipRange. expected %v got %v", expectedIpRange, inputConfig.RuntimeConfig.IpRanges)
 	}
 
+	// A duplicate SetUpPod must reuse the recorded ADD instead of
+	// allocating again.
+	if _, err := os.Stat(path.Join(addStateDir, containerID.ID+".json")); err != nil {
+		t.Errorf("Expected persisted ADD state for sandbox %q: %v", containerID.ID, err)
+	}
+	if err := plug.SetUpPod("podNamespace", "podName", containerID, bandwidthAnnotation); err != nil {
+		t.Errorf("Expected nil from duplicate SetUpPod: %v", err)
+	}
+
 	// Get its IP address
 	status, err := plug.GetPodNetworkStatus("podNamespace", "podName", containerID)
 	if err != nil {
@@ -325,12 +352,74 @@ func TestCNIPlugin(t *testing.T) {
 	if string(output) != expectedOutput {
 		t.Errorf("Mismatch in expected output for setup hook. Expected '%s', got '%s'", expectedOutput, string(output))
 	}
+	if _, err := os.Stat(path.Join(addStateDir, containerID.ID+".json")); !os.IsNotExist(err) {
+		t.Errorf("Expected persisted ADD state for sandbox %q to be removed after teardown, got %v", containerID.ID, err)
+	}
 
 	mockLoCNI.AssertExpectations(t)
 }
 
+func TestStaleNeighborEntry(t *testing.T) {
+	ownMACs := sets.NewString("aa:bb:cc:dd:ee:01")
+	testCases := []struct {
+		name        string
+		output      string
+		expectStale bool
+	}{
+		{
+			name:   "empty neighbor table",
+			output: "",
+		},
+		{
+			name:   "entry for the sandbox's own interface",
+			output: "10.0.0.2 dev cbr0 lladdr AA:BB:CC:DD:EE:01 REACHABLE\n",
+		},
+		{
+			name:        "entry for a foreign interface",
+			output:      "10.0.0.2 dev cbr0 lladdr aa:bb:cc:dd:ee:02 STALE\n",
+			expectStale: true,
+		},
+		{
+			name:   "failed entry carries no claim",
+			output: "10.0.0.2 dev cbr0 FAILED\n",
+		},
+		{
+			name:   "incomplete entry carries no claim",
+			output: "10.0.0.2 dev cbr0 INCOMPLETE\n",
+		},
+	}
+	for _, tc := range testCases {
+		entry := staleNeighborEntry(tc.output, ownMACs)
+		if tc.expectStale && entry == "" {
+			t.Errorf("%s: expected a stale entry", tc.name)
+		}
+		if !tc.expectStale && entry != "" {
+			t.Errorf("%s: expected no stale entry, got %q", tc.name, entry)
+		}
+	}
+}
+
 func TestLoNetNonNil(t *testing.T) {
 	if conf := getLoNetwork(nil); conf == nil {
 		t.Error("Expected non-nil lo network")
 	}
 }
+
+func TestSupportsGCVerb(t *testing.T) {
+	testCases := []struct {
+		versions []string
+		expected bool
+	}{
+		{[]string{"0.1.0", "0.2.0", "0.3.1"}, false},
+		{[]string{"0.4.0", "1.0.0"}, false},
+		{[]string{"0.4.0", "1.0.0", "1.1.0"}, true},
+		{[]string{"2.0.0"}, true},
+		{[]string{"bogus"}, false},
+		{nil, false},
+	}
+	for _, tc := range testCases {
+		if result := supportsGCVerb(tc.versions); result != tc.expected {
+			t.Errorf("supportsGCVerb(%v) = %v, expected %v", tc.versions, result, tc.expected)
+		}
+	}
+}
@@ -28,6 +28,8 @@ const (
 	NetworkPluginOperationsKey = "network_plugin_operations"
 	// NetworkPluginOperationsLatencyKey is the key for the operation latency metrics.
 	NetworkPluginOperationsLatencyKey = "network_plugin_operations_latency_microseconds"
+	// NetworkPluginTeardownQueueDepthKey is the key for the pending teardown queue depth metric.
+	NetworkPluginTeardownQueueDepthKey = "network_plugin_pending_teardowns"
 
 	// Keep the "kubelet" subsystem for backward compatibility.
 	kubeletSubsystem = "kubelet"
@@ -44,6 +46,16 @@ var (
 		},
 		[]string{"operation_type"},
 	)
+
+	// NetworkPluginTeardownQueueDepth tracks the number of pod network
+	// teardowns that failed and are queued for retry.
+	NetworkPluginTeardownQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: kubeletSubsystem,
+			Name:      NetworkPluginTeardownQueueDepthKey,
+			Help:      "Number of pod network teardowns that failed and are queued for retry.",
+		},
+	)
 )
 
 var registerMetrics sync.Once
@@ -52,6 +64,7 @@ var registerMetrics sync.Once
 func Register() {
 	registerMetrics.Do(func() {
 		prometheus.MustRegister(NetworkPluginOperationsLatency)
+		prometheus.MustRegister(NetworkPluginTeardownQueueDepth)
 	})
 }
 
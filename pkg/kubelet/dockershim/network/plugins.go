@@ -300,6 +300,26 @@ func (pm *PluginManager) Status() error {
 	return pm.plugin.Status()
 }
 
+// SandboxGarbageCollector is an optional interface a NetworkPlugin can
+// implement to clean up network resources belonging to sandboxes the runtime
+// no longer knows about, e.g. sandboxes removed while the plugin was broken
+// or being upgraded.
+type SandboxGarbageCollector interface {
+	// GarbageCollect releases resources of any attachment whose sandbox ID
+	// is not in validSandboxIDs.
+	GarbageCollect(validSandboxIDs []string) error
+}
+
+// GarbageCollect asks the wrapped plugin to clean up after sandboxes not in
+// validSandboxIDs. Plugins that do not support garbage collection are a no-op.
+func (pm *PluginManager) GarbageCollect(validSandboxIDs []string) error {
+	gc, ok := pm.plugin.(SandboxGarbageCollector)
+	if !ok {
+		return nil
+	}
+	return gc.GarbageCollect(validSandboxIDs)
+}
+
 type podLock struct {
 	// Count of in-flight operations for this pod; when this reaches zero
 	// the lock can be removed from the pod map
@@ -368,6 +388,29 @@ func (pm *PluginManager) GetPodNetworkStatus(podNamespace, podName string, id ku
 	return netStatus, nil
 }
 
+// SetupResultProvider is an optional interface for network plugins that can
+// report what a pod's network setup actually produced (assigned IPs,
+// interfaces, network name), as a string suitable for a status annotation.
+type SetupResultProvider interface {
+	// PodNetworkSetupSummary returns the summary for the sandbox, or false
+	// if no setup result is recorded for it.
+	PodNetworkSetupSummary(id kubecontainer.ContainerID) (string, bool)
+}
+
+// PodNetworkSetupAnnotation is the sandbox status annotation under which the
+// runtime surfaces the plugin's setup result summary.
+const PodNetworkSetupAnnotation = "network.alpha.kubernetes.io/setup-result"
+
+// PodNetworkSetupSummary returns the plugin's setup result summary for the
+// sandbox, if the plugin records one.
+func (pm *PluginManager) PodNetworkSetupSummary(id kubecontainer.ContainerID) (string, bool) {
+	provider, ok := pm.plugin.(SetupResultProvider)
+	if !ok {
+		return "", false
+	}
+	return provider.PodNetworkSetupSummary(id)
+}
+
 func (pm *PluginManager) SetUpPod(podNamespace, podName string, id kubecontainer.ContainerID, annotations map[string]string) error {
 	defer recordOperation("set_up_pod", time.Now())
 	fullPodName := kubecontainer.BuildPodFullName(podName, podNamespace)
@@ -88,6 +88,11 @@ const (
 	// operation is waiting it only blocks other operations on the same device,
 	// other devices are not affected.
 	waitForAttachTimeout = 10 * time.Minute
+
+	// volumeHealthCheckPeriod is the amount of time the volume health monitor
+	// waits between successive probes of the mounted volumes whose plugins
+	// support health checking.
+	volumeHealthCheckPeriod = 1 * time.Minute
 )
 
 // VolumeManager runs a set of asynchronous loops that figure out which volumes
@@ -163,6 +168,7 @@ func NewVolumeManager(
 	vm := &volumeManager{
 		kubeClient:          kubeClient,
 		volumePluginMgr:     volumePluginMgr,
+		podManager:          podManager,
 		desiredStateOfWorld: cache.NewDesiredStateOfWorld(volumePluginMgr),
 		actualStateOfWorld:  cache.NewActualStateOfWorld(nodeName, volumePluginMgr),
 		operationExecutor: operationexecutor.NewOperationExecutor(operationexecutor.NewOperationGenerator(
@@ -197,6 +203,11 @@ func NewVolumeManager(
 		mounter,
 		volumePluginMgr,
 		kubeletPodsDir)
+	vm.volumeHealthMonitor = util.NewVolumeHealthMonitor(
+		kubeClient,
+		recorder,
+		vm.volumeHealthTargets,
+		volumeHealthCheckPeriod)
 
 	return vm
 }
@@ -211,6 +222,10 @@ type volumeManager struct {
 	// plugins. It must be pre-initialized.
 	volumePluginMgr *volume.VolumePluginMgr
 
+	// podManager is used to resolve the pods that mounted volumes belong to
+	// when reporting volume health.
+	podManager pod.Manager
+
 	// desiredStateOfWorld is a data structure containing the desired state of
 	// the world according to the volume manager: i.e. what volumes should be
 	// attached and which pods are referencing the volumes).
@@ -237,6 +252,11 @@ type volumeManager struct {
 	// desiredStateOfWorldPopulator runs an asynchronous periodic loop to
 	// populate the desiredStateOfWorld using the kubelet PodManager.
 	desiredStateOfWorldPopulator populator.DesiredStateOfWorldPopulator
+
+	// volumeHealthMonitor runs an asynchronous periodic loop that probes the
+	// mounted volumes in the actualStateOfWorld whose plugins support health
+	// checking and reports failures as pod events and claim conditions.
+	volumeHealthMonitor *util.VolumeHealthMonitor
 }
 
 func (vm *volumeManager) Run(sourcesReady config.SourcesReady, stopCh <-chan struct{}) {
@@ -248,6 +268,8 @@ func (vm *volumeManager) Run(sourcesReady config.SourcesReady, stopCh <-chan str
 	glog.Infof("Starting Kubelet Volume Manager")
 	go vm.reconciler.Run(stopCh)
 
+	go vm.volumeHealthMonitor.Run(stopCh)
+
 	metrics.Register(vm.actualStateOfWorld, vm.desiredStateOfWorld, vm.volumePluginMgr)
 
 	<-stopCh
@@ -384,6 +406,38 @@ func (vm *volumeManager) WaitForAttachAndMount(pod *v1.Pod) error {
 	return nil
 }
 
+// volumeHealthTargets lists the currently mounted volumes whose Mounter
+// implements volume.VolumeHealthChecker, resolving each back to the pod it is
+// mounted for and the claim backing it (if any).
+func (vm *volumeManager) volumeHealthTargets() []util.VolumeHealthTarget {
+	targets := []util.VolumeHealthTarget{}
+	for _, mountedVolume := range vm.actualStateOfWorld.GetMountedVolumes() {
+		checker, ok := mountedVolume.Mounter.(volume.VolumeHealthChecker)
+		if !ok {
+			continue
+		}
+		volumePod, ok := vm.podManager.GetPodByUID(mountedVolume.PodUID)
+		if !ok {
+			// The pod is already gone; its volumes are about to be
+			// unmounted, so there is nothing to report health on.
+			continue
+		}
+		target := util.VolumeHealthTarget{
+			Pod:        volumePod,
+			VolumeName: mountedVolume.OuterVolumeSpecName,
+			Checker:    checker,
+		}
+		for _, podVolume := range volumePod.Spec.Volumes {
+			if podVolume.Name == mountedVolume.OuterVolumeSpecName && podVolume.PersistentVolumeClaim != nil {
+				target.ClaimName = podVolume.PersistentVolumeClaim.ClaimName
+				break
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
 // getUnattachedVolumes returns a list of the volumes that are expected to be attached but
 // are not currently attached to the node
 func (vm *volumeManager) getUnattachedVolumes(expectedVolumes []string) []string {
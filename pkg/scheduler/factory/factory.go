@@ -50,6 +50,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
+	apiv1resource "k8s.io/kubernetes/pkg/api/v1/resource"
 	"k8s.io/kubernetes/pkg/apis/core/helper"
 	"k8s.io/kubernetes/pkg/features"
 	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
@@ -992,7 +993,7 @@ func (c *configFactory) invalidateCachedPredicatesOnNodeUpdate(newNode *v1.Node,
 		// TODO(resouer): think about lazily initialize this set
 		invalidPredicates := sets.NewString()
 
-		if !reflect.DeepEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable) {
+		if !apiv1resource.ResourceListEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable) {
 			invalidPredicates.Insert(predicates.GeneralPred) // "PodFitsResources"
 		}
 		if !reflect.DeepEqual(oldNode.GetLabels(), newNode.GetLabels()) {
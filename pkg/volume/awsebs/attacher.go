@@ -221,6 +221,16 @@ func (attacher *awsElasticBlockStoreAttacher) MountDevice(spec *volume.Spec, dev
 		return err
 	}
 
+	// Fence before the first write so a node the volume was just moved away
+	// from cannot corrupt it with in-flight writes.
+	if mode := fencingModeFromSpec(spec); mode == fencingModeNVMePR && !readOnly {
+		key := nvmeReservationKey(attacher.host.GetHostName())
+		if err := acquireNVMeReservation(attacher.host.GetExec(awsElasticBlockStorePluginName), devicePath, key); err != nil {
+			return fmt.Errorf("error fencing AWS Volume %q: %v", volumeSource.VolumeID, err)
+		}
+		recordFencedDevice(deviceMountPath, devicePath, key)
+	}
+
 	options := []string{}
 	if readOnly {
 		options = append(options, "ro")
@@ -239,6 +249,7 @@ func (attacher *awsElasticBlockStoreAttacher) MountDevice(spec *volume.Spec, dev
 
 type awsElasticBlockStoreDetacher struct {
 	mounter    mount.Interface
+	exec       mount.Exec
 	awsVolumes aws.Volumes
 }
 
@@ -254,6 +265,7 @@ func (plugin *awsElasticBlockStorePlugin) NewDetacher() (volume.Detacher, error)
 
 	return &awsElasticBlockStoreDetacher{
 		mounter:    plugin.host.GetMounter(plugin.GetPluginName()),
+		exec:       plugin.host.GetExec(plugin.GetPluginName()),
 		awsVolumes: awsCloud,
 	}, nil
 }
@@ -273,6 +285,13 @@ func (detacher *awsElasticBlockStoreDetacher) Detach(volumeName string, nodeName
 }
 
 func (detacher *awsElasticBlockStoreDetacher) UnmountDevice(deviceMountPath string) error {
+	if device, ok := popFencedDevice(deviceMountPath); ok {
+		// Release is best effort; a reservation left behind is preempted by
+		// the next node to fence the volume.
+		if err := releaseNVMeReservation(detacher.exec, device.devicePath, device.key); err != nil {
+			glog.Warningf("Error releasing NVMe reservation for %s: %v", deviceMountPath, err)
+		}
+	}
 	return volumeutil.UnmountPath(deviceMountPath, detacher.mounter)
 }
 
@@ -20,10 +20,11 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"k8s.io/api/core/v1"
@@ -173,7 +174,7 @@ func (plugin *awsElasticBlockStorePlugin) newMounterInternal(spec *volume.Spec,
 			manager:         manager,
 			mounter:         mounter,
 			plugin:          plugin,
-			MetricsProvider: volume.NewMetricsStatFS(getPath(podUID, spec.Name(), plugin.host)),
+			MetricsProvider: plugin.newMetricsProvider(volumeID, getPath(podUID, spec.Name(), plugin.host)),
 		},
 		fsType:       fsType,
 		readOnly:     readOnly,
@@ -213,7 +214,8 @@ func (plugin *awsElasticBlockStorePlugin) newDeleterInternal(spec *volume.Spec,
 			volumeID: aws.KubernetesVolumeID(spec.PersistentVolume.Spec.AWSElasticBlockStore.VolumeID),
 			manager:  manager,
 			plugin:   plugin,
-		}}, nil
+		},
+		ephemeral: isEphemeralSpec(spec)}, nil
 }
 
 func (plugin *awsElasticBlockStorePlugin) NewProvisioner(options volume.VolumeOptions) (volume.Provisioner, error) {
@@ -290,6 +292,13 @@ func (plugin *awsElasticBlockStorePlugin) RequiresFSResize() bool {
 	return true
 }
 
+// RequiresBlockResize returns false: once AWS reports the new size of a
+// block-mode volume, the larger device is visible to the guest as-is and no
+// node-side resize step is needed.
+func (plugin *awsElasticBlockStorePlugin) RequiresBlockResize() bool {
+	return false
+}
+
 func (plugin *awsElasticBlockStorePlugin) ExpandVolumeDevice(
 	spec *volume.Spec,
 	newSize resource.Quantity,
@@ -312,6 +321,7 @@ func (plugin *awsElasticBlockStorePlugin) ExpandVolumeDevice(
 }
 
 var _ volume.ExpandableVolumePlugin = &awsElasticBlockStorePlugin{}
+var _ volume.BlockExpandableVolumePlugin = &awsElasticBlockStorePlugin{}
 var _ volume.VolumePluginWithAttachLimits = &awsElasticBlockStorePlugin{}
 
 // Abstract interface to PD operations.
@@ -319,6 +329,37 @@ type ebsManager interface {
 	CreateVolume(provisioner *awsElasticBlockStoreProvisioner, node *v1.Node, allowedTopologies []v1.TopologySelectorTerm) (volumeID aws.KubernetesVolumeID, volumeSizeGB int, labels map[string]string, fstype string, err error)
 	// Deletes a volume
 	DeleteVolume(deleter *awsElasticBlockStoreDeleter) error
+	// Creates a snapshot of a volume
+	SnapshotVolume(plugin *awsElasticBlockStorePlugin, volumeID aws.KubernetesVolumeID, description string) (snapshotID string, err error)
+	// Deletes a snapshot
+	DeleteSnapshot(plugin *awsElasticBlockStorePlugin, snapshotID string) error
+}
+
+// VolumeSnapshotter is the snapshot interface the AWS EBS plugin exposes to
+// backup tooling. A snapshot is created from the volume behind a spec and can
+// later be restored through the "snapshotid" provisioner parameter.
+type VolumeSnapshotter interface {
+	SnapshotVolume(spec *volume.Spec, description string) (snapshotID string, err error)
+	DeleteSnapshot(snapshotID string) error
+}
+
+var _ VolumeSnapshotter = &awsElasticBlockStorePlugin{}
+
+// SnapshotVolume creates an EBS snapshot of the volume the spec references and
+// returns the snapshot ID.
+func (plugin *awsElasticBlockStorePlugin) SnapshotVolume(spec *volume.Spec, description string) (string, error) {
+	volumeSource, _, err := getVolumeSource(spec)
+	if err != nil {
+		return "", err
+	}
+	manager := &AWSDiskUtil{}
+	return manager.SnapshotVolume(plugin, aws.KubernetesVolumeID(volumeSource.VolumeID), description)
+}
+
+// DeleteSnapshot deletes the EBS snapshot with the given ID.
+func (plugin *awsElasticBlockStorePlugin) DeleteSnapshot(snapshotID string) error {
+	manager := &AWSDiskUtil{}
+	return manager.DeleteSnapshot(plugin, snapshotID)
 }
 
 // awsElasticBlockStore volumes are disk resources provided by Amazon Web Services
@@ -350,6 +391,14 @@ type awsElasticBlockStoreMounter struct {
 }
 
 var _ volume.Mounter = &awsElasticBlockStoreMounter{}
+var _ volume.VolumeHealthChecker = &awsElasticBlockStoreMounter{}
+
+// CheckVolumeHealth probes the pod's mount of the disk so corrupted or
+// read-only remounted volumes are detected while still in use, not only at
+// unmount.
+func (b *awsElasticBlockStoreMounter) CheckVolumeHealth() error {
+	return util.CheckVolumeHealth(b.mounter, b.GetPath(), b.readOnly)
+}
 
 func (b *awsElasticBlockStoreMounter) GetAttributes() volume.Attributes {
 	return volume.Attributes{
@@ -425,7 +474,7 @@ func (b *awsElasticBlockStoreMounter) SetUpAt(dir string, fsGroup *int64) error
 	}
 
 	if !b.readOnly {
-		volume.SetVolumeOwnership(b, fsGroup)
+		util.SetVolumeOwnershipOnRootMismatch(b, fsGroup)
 	}
 
 	glog.V(4).Infof("Successfully mounted %s", dir)
@@ -433,29 +482,16 @@ func (b *awsElasticBlockStoreMounter) SetUpAt(dir string, fsGroup *int64) error
 }
 
 func makeGlobalPDPath(host volume.VolumeHost, volumeID aws.KubernetesVolumeID) string {
-	// Clean up the URI to be more fs-friendly
-	name := string(volumeID)
-	name = strings.Replace(name, "://", "/", -1)
-	return filepath.Join(host.GetPluginDir(awsElasticBlockStorePluginName), mount.MountsInGlobalPDPath, name)
+	return util.EncodeGlobalMountPath(host.GetPluginDir(awsElasticBlockStorePluginName), string(volumeID))
 }
 
 // Reverses the mapping done in makeGlobalPDPath
 func getVolumeIDFromGlobalMount(host volume.VolumeHost, globalPath string) (string, error) {
-	basePath := filepath.Join(host.GetPluginDir(awsElasticBlockStorePluginName), mount.MountsInGlobalPDPath)
-	rel, err := filepath.Rel(basePath, globalPath)
+	volumeID, err := util.DecodeGlobalMountPath(host.GetPluginDir(awsElasticBlockStorePluginName), globalPath, "aws")
 	if err != nil {
 		glog.Errorf("Failed to get volume id from global mount %s - %v", globalPath, err)
 		return "", err
 	}
-	if strings.Contains(rel, "../") {
-		glog.Errorf("Unexpected mount path: %s", globalPath)
-		return "", fmt.Errorf("unexpected mount path: " + globalPath)
-	}
-	// Reverse the :// replacement done in makeGlobalPDPath
-	volumeID := rel
-	if strings.HasPrefix(volumeID, "aws/") {
-		volumeID = strings.Replace(volumeID, "aws/", "aws://", 1)
-	}
 	glog.V(2).Info("Mapping mount dir ", globalPath, " to volumeID ", volumeID)
 	return volumeID, nil
 }
@@ -483,6 +519,9 @@ func (c *awsElasticBlockStoreUnmounter) TearDownAt(dir string) error {
 
 type awsElasticBlockStoreDeleter struct {
 	*awsElasticBlockStore
+	// ephemeral is true for volumes provisioned for a pod-owned claim, whose
+	// deletion should not wait out the usual detach race backoffs.
+	ephemeral bool
 }
 
 var _ volume.Deleter = &awsElasticBlockStoreDeleter{}
@@ -558,6 +597,18 @@ func (c *awsElasticBlockStoreProvisioner) Provision(selectedNode *v1.Node, allow
 		pv.Spec.AccessModes = c.plugin.GetAccessModes()
 	}
 
+	if mode := fencingParameter(c.options.Parameters); mode == fencingModeNVMePR {
+		pv.Annotations[awsEBSFencingAnnotation] = mode
+	}
+
+	// A pod-owned claim provisions a scratch volume whose lifetime is tied to
+	// the pod: always reclaim by deletion so the volume goes away with the
+	// claim when the pod terminates.
+	if owner := ephemeralPodOwner(c.options.PVC); owner != nil {
+		pv.Spec.PersistentVolumeReclaimPolicy = v1.PersistentVolumeReclaimDelete
+		pv.Annotations[awsEBSEphemeralPodAnnotation] = fmt.Sprintf("%s/%s", c.options.PVC.Namespace, owner.Name)
+	}
+
 	requirements := make([]v1.NodeSelectorRequirement, 0)
 	if len(labels) != 0 {
 		if pv.Labels == nil {
@@ -578,3 +629,78 @@ func (c *awsElasticBlockStoreProvisioner) Provision(selectedNode *v1.Node, allow
 
 	return pv, nil
 }
+
+// cloudWatchStatsTTL is how long CloudWatch-sourced volume stats are cached
+// before they are fetched again on the next GetMetrics call.
+const cloudWatchStatsTTL = 5 * time.Minute
+
+// cloudWatchStatsProvider is implemented by the AWS cloud provider and serves
+// EBS metrics that are only visible through CloudWatch, such as the gp2 burst
+// credit balance.
+type cloudWatchStatsProvider interface {
+	DescribeVolumeCloudWatchStats(volumeName aws.KubernetesVolumeID) (aws.VolumeCloudWatchStats, error)
+}
+
+// metricsCloudWatch decorates the filesystem metrics of an EBS volume with
+// CloudWatch-sourced stats, giving users early warning of burst credit
+// exhaustion directly in kubelet volume stats. CloudWatch results are cached
+// so frequent stats scrapes do not turn into API calls.
+type metricsCloudWatch struct {
+	volume.MetricsProvider
+
+	volumeID aws.KubernetesVolumeID
+	provider cloudWatchStatsProvider
+
+	mutex       sync.Mutex
+	cachedStats aws.VolumeCloudWatchStats
+	cachedAt    time.Time
+}
+
+var _ volume.MetricsProvider = &metricsCloudWatch{}
+
+// GetMetrics returns the filesystem metrics of the underlying provider,
+// annotated with the most recent CloudWatch stats. CloudWatch failures only
+// lose the extra fields; the base metrics are still returned.
+func (m *metricsCloudWatch) GetMetrics() (*volume.Metrics, error) {
+	metrics, err := m.MetricsProvider.GetMetrics()
+	if err != nil {
+		return metrics, err
+	}
+	stats, err := m.volumeStats()
+	if err != nil {
+		glog.V(4).Infof("Could not fetch CloudWatch stats for volume %s: %v", m.volumeID, err)
+		return metrics, nil
+	}
+	if stats.BurstBalance != nil {
+		metrics.BurstBalance = resource.NewMilliQuantity(int64(*stats.BurstBalance*1000), resource.DecimalSI)
+	}
+	if stats.QueueLength != nil {
+		metrics.QueueLength = resource.NewMilliQuantity(int64(*stats.QueueLength*1000), resource.DecimalSI)
+	}
+	return metrics, nil
+}
+
+func (m *metricsCloudWatch) volumeStats() (aws.VolumeCloudWatchStats, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if time.Since(m.cachedAt) < cloudWatchStatsTTL {
+		return m.cachedStats, nil
+	}
+	stats, err := m.provider.DescribeVolumeCloudWatchStats(m.volumeID)
+	if err != nil {
+		return stats, err
+	}
+	m.cachedStats = stats
+	m.cachedAt = time.Now()
+	return stats, nil
+}
+
+// newMetricsProvider returns the statfs metrics provider for the volume,
+// extended with CloudWatch stats when the cloud provider supports them.
+func (plugin *awsElasticBlockStorePlugin) newMetricsProvider(volumeID aws.KubernetesVolumeID, path string) volume.MetricsProvider {
+	base := volume.NewMetricsStatFS(path)
+	if provider, ok := plugin.host.GetCloudProvider().(cloudWatchStatsProvider); ok {
+		return &metricsCloudWatch{MetricsProvider: base, volumeID: volumeID, provider: provider}
+	}
+	return base
+}
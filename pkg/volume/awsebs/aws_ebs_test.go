@@ -18,9 +18,11 @@ package awsebs
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"k8s.io/api/core/v1"
@@ -98,6 +100,14 @@ func (fake *fakePDManager) DeleteVolume(cd *awsElasticBlockStoreDeleter) error {
 	return nil
 }
 
+func (fake *fakePDManager) SnapshotVolume(plugin *awsElasticBlockStorePlugin, volumeID aws.KubernetesVolumeID, description string) (string, error) {
+	return "snap-fake", nil
+}
+
+func (fake *fakePDManager) DeleteSnapshot(plugin *awsElasticBlockStorePlugin, snapshotID string) error {
+	return nil
+}
+
 func TestPlugin(t *testing.T) {
 	tmpDir, err := utiltesting.MkTmpdir("awsebsTest")
 	if err != nil {
@@ -376,3 +386,97 @@ func TestMountOptions(t *testing.T) {
 		t.Errorf("Expected mount options to be %v got %v", expectedMountOptions, mountOptions)
 	}
 }
+
+func TestAcquireNVMeReservation(t *testing.T) {
+	commands := [][]string{}
+	exec := mount.NewFakeExec(func(cmd string, args ...string) ([]byte, error) {
+		commands = append(commands, append([]string{cmd}, args...))
+		return nil, nil
+	})
+
+	key := nvmeReservationKey("node-1")
+	if key != nvmeReservationKey("node-1") {
+		t.Errorf("expected reservation key to be stable for a node")
+	}
+	if key == nvmeReservationKey("node-2") {
+		t.Errorf("expected different nodes to derive different reservation keys")
+	}
+
+	if err := acquireNVMeReservation(exec, "/dev/xvdba", key); err == nil {
+		t.Errorf("expected error acquiring reservation on non-NVMe device")
+	}
+	if len(commands) != 0 {
+		t.Errorf("expected no commands for non-NVMe device, got %v", commands)
+	}
+
+	if err := acquireNVMeReservation(exec, "/dev/nvme1n1", key); err != nil {
+		t.Errorf("expected success, got: %v", err)
+	}
+	if len(commands) != 2 || commands[0][1] != "resv-register" || commands[1][1] != "resv-acquire" {
+		t.Errorf("expected register then acquire, got %v", commands)
+	}
+}
+
+func TestFindNvmeVolumeByIdentify(t *testing.T) {
+	tmpDir, err := utiltesting.MkTmpdir("awsebsNvmeTest")
+	if err != nil {
+		t.Fatalf("can't make a temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	writeController := func(name, model, serial string) {
+		dir := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Join(dir, name+"n1"), 0755); err != nil {
+			t.Fatalf("can't create controller dir: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "model"), []byte(model+"\n"), 0644); err != nil {
+			t.Fatalf("can't write model: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "serial"), []byte(serial+"\n"), 0644); err != nil {
+			t.Fatalf("can't write serial: %v", err)
+		}
+	}
+	writeController("nvme0", "Amazon EC2 NVMe Instance Storage", "AWS22FAB1D5E3F72A5E2")
+	writeController("nvme1", ebsNVMeModel, "vol0fab1d5e3f72a5e23")
+
+	oldPath := nvmeSysfsPath
+	nvmeSysfsPath = tmpDir
+	defer func() { nvmeSysfsPath = oldPath }()
+
+	device, err := findNvmeVolumeByIdentify("vol-0fab1d5e3f72a5e23")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if device != "/dev/nvme1n1" {
+		t.Errorf("expected /dev/nvme1n1, got %q", device)
+	}
+
+	device, err = findNvmeVolumeByIdentify("vol-doesnotexist")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if device != "" {
+		t.Errorf("expected no device for unknown volume, got %q", device)
+	}
+}
+
+func TestEphemeralPodOwner(t *testing.T) {
+	pvc := volumetest.CreateTestPVC("1Gi", []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce})
+	if owner := ephemeralPodOwner(pvc); owner != nil {
+		t.Errorf("expected no pod owner for a plain claim, got %v", owner)
+	}
+
+	isController := true
+	pvc.Namespace = "ns"
+	pvc.Name = "scratch"
+	pvc.OwnerReferences = []metav1.OwnerReference{{Kind: "Pod", Name: "worker-0", Controller: &isController}}
+	owner := ephemeralPodOwner(pvc)
+	if owner == nil || owner.Name != "worker-0" {
+		t.Fatalf("expected pod owner worker-0, got %v", owner)
+	}
+
+	name := ephemeralVolumeName("kubernetes", pvc, owner.Name)
+	if !strings.Contains(name, "ns-worker-0-scratch") {
+		t.Errorf("expected pod identity in volume name, got %q", name)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package awsebs
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/volume"
+	volumeutil "k8s.io/kubernetes/pkg/volume/util"
+)
+
+const (
+	// awsEBSEphemeralPodAnnotation marks a PV provisioned for a pod-owned
+	// claim, as created by the generic ephemeral volume mechanism. The value
+	// is "<namespace>/<pod name>".
+	awsEBSEphemeralPodAnnotation = "ebs.alpha.kubernetes.io/ephemeral-pod"
+
+	// ephemeralDeleteRetries and ephemeralDeleteInterval bound the quick
+	// delete retries for ephemeral volumes that are still detaching when
+	// their pod terminates.
+	ephemeralDeleteRetries  = 6
+	ephemeralDeleteInterval = 5 * time.Second
+)
+
+// ephemeralPodOwner returns the pod that controls the claim, if any. Claims
+// created through the generic ephemeral volume mechanism are owned by their
+// pod, tying the lifetime of the provisioned volume to the pod's.
+func ephemeralPodOwner(pvc *v1.PersistentVolumeClaim) *metav1.OwnerReference {
+	for i := range pvc.OwnerReferences {
+		ref := &pvc.OwnerReferences[i]
+		if ref.Kind == "Pod" && ref.Controller != nil && *ref.Controller {
+			return ref
+		}
+	}
+	return nil
+}
+
+// isEphemeralSpec reports whether the spec's PersistentVolume was provisioned
+// for a pod-owned claim.
+func isEphemeralSpec(spec *volume.Spec) bool {
+	return spec.PersistentVolume != nil && spec.PersistentVolume.Annotations[awsEBSEphemeralPodAnnotation] != ""
+}
+
+// ephemeralVolumeName builds the Name tag of an ephemeral volume so operators
+// can attribute scratch volumes to the pod that requested them.
+func ephemeralVolumeName(clusterName string, pvc *v1.PersistentVolumeClaim, podName string) string {
+	return volumeutil.GenerateVolumeName(clusterName, fmt.Sprintf("%s-%s-%s", pvc.Namespace, podName, pvc.Name), 255)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package awsebs
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"k8s.io/kubernetes/pkg/util/mount"
+	"k8s.io/kubernetes/pkg/volume"
+)
+
+const (
+	// awsEBSFencingAnnotation marks a provisioned PV whose StorageClass
+	// requested persistent reservation fencing via the "fencing" parameter.
+	// The value is the fencing mode.
+	awsEBSFencingAnnotation = "ebs.alpha.kubernetes.io/fencing"
+
+	fencingModeNone = "none"
+	// fencingModeNVMePR fences stale writers with an NVMe write-exclusive
+	// persistent reservation, preempting any reservation left behind by a
+	// node the volume was moved away from.
+	fencingModeNVMePR = "nvmepr"
+
+	// nvmeWriteExclusive is the NVMe reservation type acquired for fenced
+	// volumes: a single writer, registrants may still read.
+	nvmeWriteExclusive = "1"
+)
+
+// fencingParameter returns the value of the "fencing" StorageClass parameter,
+// or an empty string if it is not set. Parameters are matched
+// case-insensitively like in populateVolumeOptions.
+func fencingParameter(params map[string]string) string {
+	for k, v := range params {
+		if strings.ToLower(k) == "fencing" {
+			return strings.ToLower(v)
+		}
+	}
+	return ""
+}
+
+// fencingModeFromSpec returns the fencing mode recorded on the spec's
+// PersistentVolume at provisioning time. Inline volumes are never fenced.
+func fencingModeFromSpec(spec *volume.Spec) string {
+	if spec.PersistentVolume == nil {
+		return ""
+	}
+	return spec.PersistentVolume.Annotations[awsEBSFencingAnnotation]
+}
+
+// nvmeReservationKey derives a stable per-node registration key, so a node
+// re-acquiring a reservation it already holds is idempotent while
+// reservations held by other nodes can be preempted.
+func nvmeReservationKey(nodeName string) string {
+	h := fnv.New64a()
+	h.Write([]byte(nodeName))
+	return fmt.Sprintf("0x%x", h.Sum64())
+}
+
+// acquireNVMeReservation registers this node's key on the volume's NVMe
+// namespace and acquires a write-exclusive persistent reservation, preempting
+// any reservation held by a previous node so its in-flight writes can no
+// longer reach the volume.
+func acquireNVMeReservation(exec mount.Exec, devicePath, key string) error {
+	if !strings.HasPrefix(devicePath, "/dev/nvme") {
+		return fmt.Errorf("NVMe reservation fencing requested but %s is not an NVMe device", devicePath)
+	}
+	if out, err := exec.Run("nvme", "resv-register", devicePath, "--nrkey="+key, "--rrega=0"); err != nil {
+		return fmt.Errorf("error registering reservation key on %s: %v, output: %q", devicePath, err, string(out))
+	}
+	if out, err := exec.Run("nvme", "resv-acquire", devicePath, "--crkey="+key, "--rtype="+nvmeWriteExclusive, "--racqa=1"); err != nil {
+		return fmt.Errorf("error acquiring reservation on %s: %v, output: %q", devicePath, err, string(out))
+	}
+	return nil
+}
+
+// releaseNVMeReservation releases the reservation acquired by
+// acquireNVMeReservation and unregisters this node's key, so the next node
+// the volume is attached to can acquire without preempting.
+func releaseNVMeReservation(exec mount.Exec, devicePath, key string) error {
+	if out, err := exec.Run("nvme", "resv-release", devicePath, "--crkey="+key, "--rtype="+nvmeWriteExclusive, "--rrela=0"); err != nil {
+		return fmt.Errorf("error releasing reservation on %s: %v, output: %q", devicePath, err, string(out))
+	}
+	if out, err := exec.Run("nvme", "resv-register", devicePath, "--crkey="+key, "--rrega=1"); err != nil {
+		return fmt.Errorf("error unregistering reservation key on %s: %v, output: %q", devicePath, err, string(out))
+	}
+	return nil
+}
+
+// fencedDevice records a reservation acquired at MountDevice so UnmountDevice
+// can release it before the volume is detached.
+type fencedDevice struct {
+	devicePath string
+	key        string
+}
+
+// fencedDevices is keyed by device mount path. The map is node local and best
+// effort: a reservation acquired before a kubelet restart is not released
+// here, it is preempted by the next node that fences the volume.
+var fencedDevices = struct {
+	sync.Mutex
+	devices map[string]fencedDevice
+}{devices: map[string]fencedDevice{}}
+
+func recordFencedDevice(deviceMountPath, devicePath, key string) {
+	fencedDevices.Lock()
+	defer fencedDevices.Unlock()
+	fencedDevices.devices[deviceMountPath] = fencedDevice{devicePath: devicePath, key: key}
+}
+
+func popFencedDevice(deviceMountPath string) (fencedDevice, bool) {
+	fencedDevices.Lock()
+	defer fencedDevices.Unlock()
+	device, ok := fencedDevices.devices[deviceMountPath]
+	if ok {
+		delete(fencedDevices.devices, deviceMountPath)
+	}
+	return device, ok
+}
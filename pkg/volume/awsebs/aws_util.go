@@ -18,6 +18,7 @@ package awsebs
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -56,6 +57,14 @@ func (util *AWSDiskUtil) DeleteVolume(d *awsElasticBlockStoreDeleter) error {
 	}
 
 	deleted, err := cloud.DeleteDisk(d.volumeID)
+	// An ephemeral volume is deleted as its pod terminates and commonly races
+	// the detach; retry briefly instead of waiting for the PV controller's
+	// next resync.
+	for i := 0; i < ephemeralDeleteRetries && d.ephemeral && volume.IsDeletedVolumeInUse(err); i++ {
+		glog.V(2).Infof("Ephemeral EBS Disk volume %s is still in use, retrying deletion: %v", d.volumeID, err)
+		time.Sleep(ephemeralDeleteInterval)
+		deleted, err = cloud.DeleteDisk(d.volumeID)
+	}
 	if err != nil {
 		// AWS cloud provider returns volume.deletedVolumeInUseError when
 		// necessary, no handling needed here.
@@ -86,6 +95,11 @@ func (util *AWSDiskUtil) CreateVolume(c *awsElasticBlockStoreProvisioner, node *
 		tags = *c.options.CloudTags
 	}
 	tags["Name"] = volumeutil.GenerateVolumeName(c.options.ClusterName, c.options.PVName, 255) // AWS tags can have 255 characters
+	if owner := ephemeralPodOwner(c.options.PVC); owner != nil {
+		// Pod-owned (ephemeral) claims encode the pod identity in the name
+		// instead of the meaningless generated PV name.
+		tags["Name"] = ephemeralVolumeName(c.options.ClusterName, c.options.PVC, owner.Name)
+	}
 
 	capacity := c.options.PVC.Spec.Resources.Requests[v1.ResourceName(v1.ResourceStorage)]
 
@@ -126,6 +140,37 @@ func (util *AWSDiskUtil) CreateVolume(c *awsElasticBlockStoreProvisioner, node *
 	return name, volumeOptions.CapacityGB, labels, fstype, nil
 }
 
+// SnapshotVolume creates a snapshot of an AWS EBS volume and returns its ID.
+func (util *AWSDiskUtil) SnapshotVolume(plugin *awsElasticBlockStorePlugin, volumeID aws.KubernetesVolumeID, description string) (string, error) {
+	cloud, err := getCloudProvider(plugin.host.GetCloudProvider())
+	if err != nil {
+		return "", err
+	}
+
+	snapshotID, err := cloud.CreateSnapshot(volumeID, description)
+	if err != nil {
+		glog.V(2).Infof("Error creating snapshot of EBS Disk volume %s: %v", volumeID, err)
+		return "", err
+	}
+	glog.V(2).Infof("Successfully created snapshot %s of EBS Disk volume %s", snapshotID, volumeID)
+	return snapshotID, nil
+}
+
+// DeleteSnapshot deletes an AWS EBS snapshot.
+func (util *AWSDiskUtil) DeleteSnapshot(plugin *awsElasticBlockStorePlugin, snapshotID string) error {
+	cloud, err := getCloudProvider(plugin.host.GetCloudProvider())
+	if err != nil {
+		return err
+	}
+
+	if err := cloud.DeleteSnapshot(snapshotID); err != nil {
+		glog.V(2).Infof("Error deleting EBS snapshot %s: %v", snapshotID, err)
+		return err
+	}
+	glog.V(2).Infof("Successfully deleted EBS snapshot %s", snapshotID)
+	return nil
+}
+
 // returns volumeOptions for EBS based on storageclass parameters and node configuration
 func populateVolumeOptions(pluginName, pvcName string, capacityGB resource.Quantity, tags map[string]string, storageParams map[string]string, node *v1.Node, allowedTopologies []v1.TopologySelectorTerm, zonesWithNodes sets.String) (*aws.VolumeOptions, error) {
 	requestGiB, err := volumeutil.RoundUpToGiBInt(capacityGB)
@@ -169,6 +214,19 @@ func populateVolumeOptions(pluginName, pvcName string, capacityGB resource.Quant
 			}
 		case "kmskeyid":
 			volumeOptions.KmsKeyID = v
+		case "snapshotid":
+			// The new volume is restored from this EBS snapshot. AWS
+			// validates the ID and that the requested size is at least the
+			// snapshot's size.
+			volumeOptions.SnapshotID = v
+		case "fencing":
+			// The fencing mode is recorded on the PV by the provisioner and
+			// applied on the node, not by the cloud provider.
+			switch strings.ToLower(v) {
+			case fencingModeNone, fencingModeNVMePR:
+			default:
+				return nil, fmt.Errorf("invalid fencing mode %q, must be %q or %q", v, fencingModeNone, fencingModeNVMePR)
+			}
 		case volume.VolumeParameterFSType:
 			// Do nothing but don't make this fail
 		default:
@@ -176,6 +234,10 @@ func populateVolumeOptions(pluginName, pvcName string, capacityGB resource.Quant
 		}
 	}
 
+	if err = validateVolumeOptions(volumeOptions); err != nil {
+		return nil, err
+	}
+
 	volumeOptions.AvailabilityZone, err = volumeutil.SelectZoneForVolume(zonePresent, zonesPresent, zone, zones, zonesWithNodes, node, allowedTopologies, pvcName)
 	if err != nil {
 		return nil, err
@@ -183,6 +245,44 @@ func populateVolumeOptions(pluginName, pvcName string, capacityGB resource.Quant
 	return volumeOptions, nil
 }
 
+// Size and IOPS limits per EBS volume type, as documented by AWS. Requests
+// outside these bounds fail with confusing EC2 API errors, so validate them
+// up front with precise messages.
+const (
+	minSizeGiBST1SC1 = 500
+	maxSizeGiB       = 16384
+	minSizeGiBIO1    = 4
+	maxIOPSPerGBIO1  = 50
+)
+
+func validateVolumeOptions(volumeOptions *aws.VolumeOptions) error {
+	sizeGiB := volumeOptions.CapacityGB
+	if sizeGiB > maxSizeGiB {
+		return fmt.Errorf("invalid size %dGi for EBS volume: maximum size is %dGi", sizeGiB, maxSizeGiB)
+	}
+	switch volumeOptions.VolumeType {
+	case aws.VolumeTypeST1, aws.VolumeTypeSC1:
+		if sizeGiB < minSizeGiBST1SC1 {
+			return fmt.Errorf("invalid size %dGi for EBS volume type %q: minimum size is %dGi", sizeGiB, volumeOptions.VolumeType, minSizeGiBST1SC1)
+		}
+		if volumeOptions.IOPSPerGB != 0 {
+			return fmt.Errorf("iopsPerGB is not applicable to EBS volume type %q", volumeOptions.VolumeType)
+		}
+	case aws.VolumeTypeIO1:
+		if sizeGiB < minSizeGiBIO1 {
+			return fmt.Errorf("invalid size %dGi for EBS volume type %q: minimum size is %dGi", sizeGiB, volumeOptions.VolumeType, minSizeGiBIO1)
+		}
+		if volumeOptions.IOPSPerGB > maxIOPSPerGBIO1 {
+			return fmt.Errorf("invalid iopsPerGB %d for EBS volume type %q: maximum ratio is %d IOPS per GiB", volumeOptions.IOPSPerGB, volumeOptions.VolumeType, maxIOPSPerGBIO1)
+		}
+	case aws.VolumeTypeGP2, "":
+		if volumeOptions.IOPSPerGB != 0 {
+			return fmt.Errorf("iopsPerGB is only applicable to EBS volume type %q", aws.VolumeTypeIO1)
+		}
+	}
+	return nil
+}
+
 // Returns the first path that exists, or empty string if none exist.
 func verifyDevicePath(devicePaths []string) (string, error) {
 	for _, path := range devicePaths {
@@ -231,14 +331,24 @@ func getDiskByIDPaths(volumeID aws.KubernetesVolumeID, partition string, deviceP
 	if err != nil {
 		glog.Warningf("error mapping volume %q to AWS volume: %v", volumeID, err)
 	} else {
-		// This is the magic name on which AWS presents NVME devices under /dev/disk/by-id/
-		// For example, vol-0fab1d5e3f72a5e23 creates a symlink at /dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_vol0fab1d5e3f72a5e23
-		nvmeName := "nvme-Amazon_Elastic_Block_Store_" + strings.Replace(string(awsVolumeID), "-", "", -1)
-		nvmePath, err := findNvmeVolume(nvmeName)
+		// Match the volume ID against the NVMe controller identify data in
+		// sysfs first; this works as soon as the kernel sees the device,
+		// without waiting for udev to create the by-id symlink.
+		nvmePath, err := findNvmeVolumeByIdentify(string(awsVolumeID))
 		if err != nil {
-			glog.Warningf("error looking for nvme volume %q: %v", volumeID, err)
+			glog.Warningf("error identifying nvme volume %q: %v", volumeID, err)
 		} else if nvmePath != "" {
 			devicePaths = append(devicePaths, nvmePath)
+		} else {
+			// This is the magic name on which AWS presents NVME devices under /dev/disk/by-id/
+			// For example, vol-0fab1d5e3f72a5e23 creates a symlink at /dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_vol0fab1d5e3f72a5e23
+			nvmeName := "nvme-Amazon_Elastic_Block_Store_" + strings.Replace(string(awsVolumeID), "-", "", -1)
+			nvmePath, err = findNvmeVolume(nvmeName)
+			if err != nil {
+				glog.Warningf("error looking for nvme volume %q: %v", volumeID, err)
+			} else if nvmePath != "" {
+				devicePaths = append(devicePaths, nvmePath)
+			}
 		}
 	}
 
@@ -255,6 +365,48 @@ func getCloudProvider(cloudProvider cloudprovider.Interface) (*aws.Cloud, error)
 	return awsCloudProvider, nil
 }
 
+// nvmeSysfsPath is where the kernel exposes NVMe controller identify data.
+// It is a variable so tests can point it at a fixture tree.
+var nvmeSysfsPath = "/sys/class/nvme"
+
+// ebsNVMeModel is the model string EC2 reports in the NVMe identify
+// controller data for EBS volumes.
+const ebsNVMeModel = "Amazon Elastic Block Store"
+
+// findNvmeVolumeByIdentify resolves an EBS volume to its NVMe block device by
+// matching the volume ID against the controller serial number, which EC2
+// populates with the volume ID (without the dash) on Nitro instances. The
+// identify data is read from sysfs, so resolution is sub-second and does not
+// depend on udev. An empty device with nil error means no controller matched.
+func findNvmeVolumeByIdentify(awsVolumeID string) (string, error) {
+	serial := strings.Replace(awsVolumeID, "-", "", -1)
+	controllers, err := filepath.Glob(filepath.Join(nvmeSysfsPath, "nvme*"))
+	if err != nil {
+		return "", fmt.Errorf("error listing nvme controllers: %v", err)
+	}
+
+	for _, controller := range controllers {
+		model, err := ioutil.ReadFile(filepath.Join(controller, "model"))
+		if err != nil || !strings.Contains(string(model), ebsNVMeModel) {
+			continue
+		}
+		sn, err := ioutil.ReadFile(filepath.Join(controller, "serial"))
+		if err != nil || strings.TrimSpace(string(sn)) != serial {
+			continue
+		}
+
+		// An EBS controller exposes exactly one namespace; its name under
+		// the controller directory is the block device name.
+		namespaces, err := filepath.Glob(filepath.Join(controller, "nvme*n*"))
+		if err != nil || len(namespaces) == 0 {
+			glog.Warningf("nvme controller %q matches volume %q but exposes no namespace", controller, awsVolumeID)
+			continue
+		}
+		return "/dev/" + filepath.Base(namespaces[0]), nil
+	}
+	return "", nil
+}
+
 // findNvmeVolume looks for the nvme volume with the specified name
 // It follows the symlink (if it exists) and returns the absolute path to the device
 func findNvmeVolume(findName string) (device string, err error) {
@@ -93,10 +93,21 @@ func (a *azureDiskAttacher) Attach(spec *volume.Spec, nodeName types.NodeName) (
 		getLunMutex.LockKey(instanceid)
 		defer getLunMutex.UnlockKey(instanceid)
 
-		lun, err = diskController.GetNextDiskLun(nodeName)
-		if err != nil {
-			glog.Warningf("no LUN available for instance %q (%v)", nodeName, err)
-			return "", fmt.Errorf("all LUNs are used, cannot attach volume %q to instance %q (%v)", volumeSource.DiskName, instanceid, err)
+		// Assign from the node's pre-reserved LUN pool when possible; a
+		// pool miss lists the node's attachments once and re-fills the
+		// pool for subsequent attaches.
+		var pooled bool
+		lun, pooled = nodeLunPool.take(nodeName)
+		if pooled {
+			glog.V(4).Infof("azureDisk - assigned pre-reserved lun %d for node %q", lun, nodeName)
+		} else {
+			luns, err := diskController.GetNextDiskLuns(nodeName, lunPoolSize()+1)
+			if err != nil {
+				glog.Warningf("no LUN available for instance %q (%v)", nodeName, err)
+				return "", fmt.Errorf("all LUNs are used, cannot attach volume %q to instance %q (%v)", volumeSource.DiskName, instanceid, err)
+			}
+			lun = luns[0]
+			nodeLunPool.fill(nodeName, luns[1:])
 		}
 		glog.V(2).Infof("Trying to attach volume %q lun %d to node %q.", volumeSource.DataDiskURI, lun, nodeName)
 		isManagedDisk := (*volumeSource.Kind == v1.AzureManagedDisk)
@@ -104,6 +115,9 @@ func (a *azureDiskAttacher) Attach(spec *volume.Spec, nodeName types.NodeName) (
 		if err == nil {
 			glog.V(2).Infof("Attach operation successful: volume %q attached to node %q.", volumeSource.DataDiskURI, nodeName)
 		} else {
+			// The reservations may be stale (e.g. another actor attached
+			// to the same LUN); drop them so the next attach re-lists.
+			nodeLunPool.clear(nodeName)
 			glog.V(2).Infof("Attach volume %q to instance %q failed with %v", volumeSource.DataDiskURI, instanceid, err)
 			return "", fmt.Errorf("attach volume %q to instance %q failed with %v", volumeSource.DiskName, instanceid, err)
 		}
@@ -290,6 +304,15 @@ func (d *azureDiskDetacher) Detach(diskURI string, nodeName types.NodeName) erro
 
 	glog.V(2).Infof("detach %v from node %q", diskURI, nodeName)
 
+	// Detach proceeds regardless of the node's power state; a shutdown node
+	// cannot release the disk itself, so the detach here is what unblocks
+	// failover to another node. Log when that happens.
+	if providerID, err := cloudprovider.GetInstanceProviderID(context.TODO(), d.cloud, nodeName); err == nil {
+		if shutdown, err := d.cloud.InstanceShutdownByProviderID(context.TODO(), providerID); err == nil && shutdown {
+			glog.V(2).Infof("azureDisk - node %q is shutdown, force detaching disk %s", nodeName, diskURI)
+		}
+	}
+
 	diskController, err := getDiskController(d.plugin.host)
 	if err != nil {
 		return err
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-04-01/compute"
 	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2018-07-01/storage"
@@ -39,8 +40,8 @@ type DiskController interface {
 	CreateBlobDisk(dataDiskName string, storageAccountType storage.SkuName, sizeGB int) (string, error)
 	DeleteBlobDisk(diskUri string) error
 
-	CreateManagedDisk(options *azure.ManagedDiskOptions) (string, error)
-	DeleteManagedDisk(diskURI string) error
+	CreateManagedDisk(ctx context.Context, options *azure.ManagedDiskOptions) (string, error)
+	DeleteManagedDisk(ctx context.Context, diskURI string) error
 
 	// Attaches the disk to the host machine.
 	AttachDisk(isManagedDisk bool, diskName, diskUri string, nodeName types.NodeName, lun int32, cachingMode compute.CachingTypes) error
@@ -54,6 +55,8 @@ type DiskController interface {
 	GetDiskLun(diskName, diskUri string, nodeName types.NodeName) (int32, error)
 	// Get the next available LUN number to attach a new VHD
 	GetNextDiskLun(nodeName types.NodeName) (int32, error)
+	// Get up to count available LUN numbers with a single attachment listing
+	GetNextDiskLuns(nodeName types.NodeName, count int) ([]int32, error)
 
 	// Create a VHD blob
 	CreateVolume(name, storageAccount, storageAccountType, location string, requestGB int) (string, string, int, error)
@@ -61,7 +64,11 @@ type DiskController interface {
 	DeleteVolume(diskURI string) error
 
 	// Expand the disk to new size
-	ResizeDisk(diskURI string, oldSize resource.Quantity, newSize resource.Quantity) (resource.Quantity, error)
+	ResizeDisk(ctx context.Context, diskURI string, oldSize resource.Quantity, newSize resource.Quantity) (resource.Quantity, error)
+
+	// GetDiskPerformanceCaps returns the IOPS and bandwidth (MB/s) caps
+	// Azure enforces for a managed disk, derived from its SKU and size.
+	GetDiskPerformanceCaps(ctx context.Context, diskURI string) (int64, int64, error)
 
 	// GetAzureDiskLabels gets availability zone labels for Azuredisk.
 	GetAzureDiskLabels(diskURI string) (map[string]string, error)
@@ -92,6 +99,12 @@ var vmSizeList *[]compute.VirtualMachineSize
 const (
 	azureDataDiskPluginName = "kubernetes.io/azure-disk"
 	defaultAzureVolumeLimit = 16
+
+	// diskOperationTimeout bounds the ARM calls of a single disk operation,
+	// matching the deadline the volume operation executor applies to the
+	// surrounding operation, so a stuck ARM request cannot pin a goroutine
+	// indefinitely.
+	diskOperationTimeout = 10 * time.Minute
 )
 
 func ProbeVolumePlugins() []volume.VolumePlugin {
@@ -269,6 +282,7 @@ func (plugin *azureDataDiskPlugin) NewMounter(spec *volume.Spec, pod *v1.Pod, op
 		spec:     spec,
 		options:  options,
 		dataDisk: disk,
+		pod:      pod,
 	}, nil
 }
 
@@ -298,7 +312,9 @@ func (plugin *azureDataDiskPlugin) ExpandVolumeDevice(
 		return oldSize, err
 	}
 
-	return diskController.ResizeDisk(spec.PersistentVolume.Spec.AzureDisk.DataDiskURI, oldSize, newSize)
+	ctx, cancel := context.WithTimeout(context.Background(), diskOperationTimeout)
+	defer cancel()
+	return diskController.ResizeDisk(ctx, spec.PersistentVolume.Spec.AzureDisk.DataDiskURI, oldSize, newSize)
 }
 
 func (plugin *azureDataDiskPlugin) ConstructVolumeSpec(volumeName, mountPath string) (*volume.Spec, error) {
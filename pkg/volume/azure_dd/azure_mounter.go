@@ -19,10 +19,12 @@ package azure_dd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 
 	"github.com/golang/glog"
 	"k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/util/mount"
 	"k8s.io/kubernetes/pkg/volume"
 	"k8s.io/kubernetes/pkg/volume/util"
 )
@@ -32,6 +34,7 @@ type azureDiskMounter struct {
 	spec    *volume.Spec
 	plugin  *azureDataDiskPlugin
 	options volume.VolumeOptions
+	pod     *v1.Pod
 }
 
 type azureDiskUnmounter struct {
@@ -41,6 +44,15 @@ type azureDiskUnmounter struct {
 
 var _ volume.Unmounter = &azureDiskUnmounter{}
 var _ volume.Mounter = &azureDiskMounter{}
+var _ volume.VolumeHealthChecker = &azureDiskMounter{}
+
+// CheckVolumeHealth probes the pod's mount of the disk so corrupted or
+// read-only remounted volumes are detected while still in use, not only at
+// unmount.
+func (m *azureDiskMounter) CheckVolumeHealth() error {
+	mounter := m.plugin.host.GetMounter(m.plugin.GetPluginName())
+	return util.CheckVolumeHealth(mounter, m.GetPath(), m.GetAttributes().ReadOnly)
+}
 
 func (m *azureDiskMounter) GetAttributes() volume.Attributes {
 	readOnly := false
@@ -160,7 +172,18 @@ func (m *azureDiskMounter) SetUpAt(dir string, fsGroup *int64) error {
 	}
 
 	if volumeSource.ReadOnly == nil || !*volumeSource.ReadOnly {
-		volume.SetVolumeOwnership(m, fsGroup)
+		util.SetVolumeOwnershipOnRootMismatch(m, fsGroup)
+	}
+
+	if isManagedDisk && runtime.GOOS == "linux" && m.pod != nil {
+		// Watch the guest-side IO rate of the disk so hitting the SKU caps
+		// surfaces as a pod event instead of silent slowness.
+		if device, _, err := mount.GetDeviceNameFromMount(mounter, globalPDPath); err == nil && device != "" {
+			key := throttlingMonitorKey(m.dataDisk.podUID, m.dataDisk.volumeName)
+			m.plugin.startDiskThrottlingMonitor(key, m.pod, diskName, volumeSource.DataDiskURI, filepath.Base(device))
+		} else {
+			glog.V(4).Infof("azureDisk - cannot resolve device of disk %s, throttling detection disabled: %v", diskName, err)
+		}
 	}
 
 	glog.V(2).Infof("azureDisk - successfully mounted disk %s on %s", diskName, dir)
@@ -180,6 +203,7 @@ func (u *azureDiskUnmounter) TearDownAt(dir string) error {
 	}
 
 	glog.V(4).Infof("azureDisk - TearDownAt: %s", dir)
+	diskThrottlingMonitor.Stop(throttlingMonitorKey(u.dataDisk.podUID, u.dataDisk.volumeName))
 	mounter := u.plugin.host.GetMounter(u.plugin.GetPluginName())
 	mountPoint, err := mounter.IsLikelyNotMountPoint(dir)
 	if err != nil {
@@ -17,6 +17,7 @@ limitations under the License.
 package azure_dd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -66,7 +67,9 @@ func (d *azureDiskDeleter) Delete() error {
 	managed := (*volumeSource.Kind == v1.AzureManagedDisk)
 
 	if managed {
-		return diskController.DeleteManagedDisk(volumeSource.DataDiskURI)
+		ctx, cancel := context.WithTimeout(context.Background(), diskOperationTimeout)
+		defer cancel()
+		return diskController.DeleteManagedDisk(ctx, volumeSource.DataDiskURI)
 	}
 
 	return diskController.DeleteBlobDisk(volumeSource.DataDiskURI)
@@ -127,6 +130,10 @@ func (p *azureDiskProvisioner) Provision(selectedNode *v1.Node, allowedTopologie
 		availabilityZone         string
 		availabilityZones        sets.String
 		selectedAvailabilityZone string
+
+		performanceTier         string
+		enableBursting          bool
+		writeAcceleratorEnabled string
 	)
 	// maxLength = 79 - (4 for ".vhd") = 75
 	name := util.GenerateVolumeName(p.options.ClusterName, p.options.PVName, 75)
@@ -165,6 +172,15 @@ func (p *azureDiskProvisioner) Provision(selectedNode *v1.Node, allowedTopologie
 			}
 		case "zoned":
 			strZoned = v
+		case "performancetier":
+			performanceTier = v
+		case "enablebursting":
+			enableBursting, err = strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse 'enableBursting': %v", err)
+			}
+		case "writeacceleratorenabled":
+			writeAcceleratorEnabled = v
 		default:
 			return nil, fmt.Errorf("invalid option %s in storage class", k)
 		}
@@ -194,6 +210,18 @@ func (p *azureDiskProvisioner) Provision(selectedNode *v1.Node, allowedTopologie
 		if zoned {
 			return nil, errors.New("storage class option 'zoned' parameter is only supported for managed disks")
 		}
+
+		if performanceTier != "" {
+			return nil, errors.New("storage class option 'performanceTier' can be used only for managed disks")
+		}
+
+		if enableBursting {
+			return nil, errors.New("storage class option 'enableBursting' can be used only for managed disks")
+		}
+
+		if writeAcceleratorEnabled != "" {
+			return nil, errors.New("storage class option 'writeAcceleratorEnabled' can be used only for managed disks")
+		}
 	}
 
 	if !zoned && (zonePresent || zonesPresent || len(allowedTopologies) > 0) {
@@ -233,6 +261,16 @@ func (p *azureDiskProvisioner) Provision(selectedNode *v1.Node, allowedTopologie
 			tags = *(p.options.CloudTags)
 		}
 
+		if writeAcceleratorEnabled != "" {
+			enabled, err := strconv.ParseBool(writeAcceleratorEnabled)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse 'writeAcceleratorEnabled': %v", err)
+			}
+			// The attach path reads this tag off the disk and mirrors it into
+			// the VM's data disk settings.
+			tags[azure.WriteAcceleratorEnabled] = strconv.FormatBool(enabled)
+		}
+
 		volumeOptions := &azure.ManagedDiskOptions{
 			DiskName:           name,
 			StorageAccountType: skuName,
@@ -241,8 +279,12 @@ func (p *azureDiskProvisioner) Provision(selectedNode *v1.Node, allowedTopologie
 			SizeGB:             requestGiB,
 			Tags:               tags,
 			AvailabilityZone:   selectedAvailabilityZone,
+			PerformanceTier:    performanceTier,
+			EnableBursting:     enableBursting,
 		}
-		diskURI, err = diskController.CreateManagedDisk(volumeOptions)
+		ctx, cancel := context.WithTimeout(context.Background(), diskOperationTimeout)
+		defer cancel()
+		diskURI, err = diskController.CreateManagedDisk(ctx, volumeOptions)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,203 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure_dd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// throttlingSampleInterval is how often the IO counters of a mounted
+	// disk are sampled.
+	throttlingSampleInterval = 1 * time.Minute
+	// throttlingUtilization is the fraction of the SKU cap at which a
+	// sustained IO rate is reported as throttled. Azure enforces the caps
+	// exactly, so an observed rate pinned just below the cap means extra IO
+	// is queueing in the platform.
+	throttlingUtilization = 0.90
+	// throttlingEventInterval rate-limits throttling events per disk.
+	throttlingEventInterval = 10 * time.Minute
+
+	diskSectorSize = 512
+)
+
+// sysBlockPath is the sysfs directory holding per-device IO statistics,
+// overridable for testing.
+var sysBlockPath = "/sys/block"
+
+// diskIOStats holds the cumulative IO counters of a block device as read from
+// /sys/block/<dev>/stat.
+type diskIOStats struct {
+	readOps      uint64
+	readSectors  uint64
+	writeOps     uint64
+	writeSectors uint64
+}
+
+func (s diskIOStats) ops() uint64 {
+	return s.readOps + s.writeOps
+}
+
+func (s diskIOStats) sectors() uint64 {
+	return s.readSectors + s.writeSectors
+}
+
+// readDiskStats reads the IO counters of a block device, e.g. "sdc".
+func readDiskStats(device string) (diskIOStats, error) {
+	stats := diskIOStats{}
+	data, err := ioutil.ReadFile(filepath.Join(sysBlockPath, device, "stat"))
+	if err != nil {
+		return stats, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 8 {
+		return stats, fmt.Errorf("unexpected format of %s stat: %q", device, string(data))
+	}
+	for i, target := range map[int]*uint64{
+		0: &stats.readOps,
+		2: &stats.readSectors,
+		4: &stats.writeOps,
+		6: &stats.writeSectors,
+	} {
+		value, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return stats, fmt.Errorf("unexpected value in %s stat field %d: %v", device, i, err)
+		}
+		*target = value
+	}
+	return stats, nil
+}
+
+// throttlingMonitor watches the guest-side IO rate of mounted managed disks
+// and emits a warning event on the pod when a disk runs at the IOPS or
+// bandwidth cap of its SKU, so users can tell disk SKU limits apart from
+// application slowness. One goroutine runs per mounted disk, stopped on
+// unmount.
+type throttlingMonitor struct {
+	mutex sync.Mutex
+	stops map[string]chan struct{}
+}
+
+var diskThrottlingMonitor = &throttlingMonitor{stops: make(map[string]chan struct{})}
+
+// Start begins monitoring the device under the given key. A monitor already
+// running under the key is left untouched. resolveCaps is called from the
+// monitor goroutine, so a slow cap lookup cannot delay the caller.
+func (tm *throttlingMonitor) Start(key, device string, resolveCaps func() (iopsCap, throughputCap int64, err error), emit func(observedIOPS, observedMBps float64)) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if _, running := tm.stops[key]; running {
+		return
+	}
+	stop := make(chan struct{})
+	tm.stops[key] = stop
+	go tm.run(device, resolveCaps, emit, stop)
+}
+
+// Stop ends the monitor running under the given key, if any.
+func (tm *throttlingMonitor) Stop(key string) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if stop, running := tm.stops[key]; running {
+		close(stop)
+		delete(tm.stops, key)
+	}
+}
+
+func (tm *throttlingMonitor) run(device string, resolveCaps func() (int64, int64, error), emit func(observedIOPS, observedMBps float64), stop <-chan struct{}) {
+	iopsCap, throughputCap, err := resolveCaps()
+	if err != nil {
+		glog.Warningf("azureDisk - cannot get performance caps of %s, throttling detection disabled: %v", device, err)
+		return
+	}
+
+	last, err := readDiskStats(device)
+	if err != nil {
+		glog.Warningf("azureDisk - cannot read IO statistics of %s, throttling detection disabled: %v", device, err)
+		return
+	}
+
+	var lastEvent time.Time
+	ticker := time.NewTicker(throttlingSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		curr, err := readDiskStats(device)
+		if err != nil {
+			// The device disappeared, e.g. it was detached.
+			glog.V(4).Infof("azureDisk - stopping throttling detection of %s: %v", device, err)
+			return
+		}
+		seconds := throttlingSampleInterval.Seconds()
+		observedIOPS := float64(curr.ops()-last.ops()) / seconds
+		observedMBps := float64(curr.sectors()-last.sectors()) * diskSectorSize / (1024 * 1024) / seconds
+		last = curr
+
+		throttled := observedIOPS >= float64(iopsCap)*throttlingUtilization ||
+			observedMBps >= float64(throughputCap)*throttlingUtilization
+		if throttled && time.Since(lastEvent) >= throttlingEventInterval {
+			lastEvent = time.Now()
+			emit(observedIOPS, observedMBps)
+		}
+	}
+}
+
+// throttlingMonitorKey identifies the monitor of one pod's use of a volume,
+// matching what the unmounter knows at teardown.
+func throttlingMonitorKey(podUID types.UID, volumeName string) string {
+	return string(podUID) + "/" + volumeName
+}
+
+// startDiskThrottlingMonitor starts watching the IO rate of a just-mounted
+// managed disk. The SKU caps are resolved asynchronously by the monitor
+// itself, so neither a slow ARM endpoint nor a lookup failure can delay or
+// fail the mount; failures only disable the detection.
+func (plugin *azureDataDiskPlugin) startDiskThrottlingMonitor(key string, pod *v1.Pod, diskName, diskURI, device string) {
+	diskController, err := getDiskController(plugin.host)
+	if err != nil {
+		glog.Warningf("azureDisk - cannot get disk controller, throttling detection disabled for %s: %v", diskName, err)
+		return
+	}
+
+	recorder := plugin.host.GetEventRecorder()
+	resolveCaps := func() (int64, int64, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), diskOperationTimeout)
+		defer cancel()
+		return diskController.GetDiskPerformanceCaps(ctx, diskURI)
+	}
+	diskThrottlingMonitor.Start(key, device, resolveCaps, func(observedIOPS, observedMBps float64) {
+		recorder.Eventf(pod, v1.EventTypeWarning, "AzureDiskThrottling",
+			"Disk %s is running at the limits of its SKU (observed %.0f IOPS, %.0f MB/s; caps %d IOPS, %d MB/s); IO beyond the caps is throttled by Azure",
+			diskName, observedIOPS, observedMBps, iopsCap, throughputCap)
+	})
+}
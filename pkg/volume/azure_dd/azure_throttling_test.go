@@ -0,0 +1,58 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure_dd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadDiskStats(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "azure-dd-stats")
+	if err != nil {
+		t.Fatalf("cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "sdc"), 0755); err != nil {
+		t.Fatalf("cannot create device dir: %v", err)
+	}
+	stat := "  120    30  2048   500   80    10  1024   300        0      700      800\n"
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "sdc", "stat"), []byte(stat), 0644); err != nil {
+		t.Fatalf("cannot write stat file: %v", err)
+	}
+
+	defer func(old string) { sysBlockPath = old }(sysBlockPath)
+	sysBlockPath = tmpDir
+
+	stats, err := readDiskStats("sdc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.readOps != 120 || stats.readSectors != 2048 || stats.writeOps != 80 || stats.writeSectors != 1024 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.ops() != 200 || stats.sectors() != 3072 {
+		t.Errorf("unexpected totals: ops=%d sectors=%d", stats.ops(), stats.sectors())
+	}
+
+	if _, err := readDiskStats("sdd"); err == nil {
+		t.Errorf("expected an error for an unknown device")
+	}
+}
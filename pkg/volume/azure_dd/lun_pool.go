@@ -0,0 +1,83 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure_dd
+
+import (
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// defaultLunPoolSize is how many unused LUNs are reserved per node when the
+// attacher has to list the node's disk attachments anyway. Subsequent attaches
+// take a LUN from the pool instead of re-listing attachments under
+// getLunMutex. It can be overridden with the AZURE_DISK_LUN_POOL_SIZE
+// environment variable; 0 disables pooling.
+const defaultLunPoolSize = 3
+
+func lunPoolSize() int {
+	if v := os.Getenv("AZURE_DISK_LUN_POOL_SIZE"); v != "" {
+		if size, err := strconv.Atoi(v); err == nil && size >= 0 {
+			return size
+		}
+		glog.Warningf("azureDisk - invalid AZURE_DISK_LUN_POOL_SIZE %q, using default %d", v, defaultLunPoolSize)
+	}
+	return defaultLunPoolSize
+}
+
+// lunPool holds pre-reserved unused LUNs per node. Access is expected to
+// happen under getLunMutex for the node, which serializes attaches per
+// instance; the internal mutex only guards the map itself.
+type lunPool struct {
+	mutex sync.Mutex
+	free  map[types.NodeName][]int32
+}
+
+var nodeLunPool = &lunPool{free: map[types.NodeName][]int32{}}
+
+// take removes and returns a reserved LUN for the node, if any.
+func (p *lunPool) take(nodeName types.NodeName) (int32, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	luns := p.free[nodeName]
+	if len(luns) == 0 {
+		return -1, false
+	}
+	lun := luns[0]
+	p.free[nodeName] = luns[1:]
+	return lun, true
+}
+
+// fill replaces the node's reserved LUNs.
+func (p *lunPool) fill(nodeName types.NodeName, luns []int32) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.free[nodeName] = luns
+}
+
+// clear drops all reserved LUNs for the node. Called when an attach fails, as
+// the reservations may no longer reflect the node's real attachments.
+func (p *lunPool) clear(nodeName types.NodeName) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.free, nodeName)
+}
@@ -164,12 +164,12 @@ func (plugin *azureFilePlugin) ExpandVolumeDevice(
 		return oldSize, err
 	}
 
-	accountName, accountKey, err := (&azureSvc{}).GetAzureCredentials(plugin.host, secretNamespace, secretName)
+	creds, err := (&azureSvc{}).GetAzureCredentials(plugin.host, secretNamespace, secretName)
 	if err != nil {
 		return oldSize, err
 	}
 
-	if err := azure.ResizeFileShare(accountName, accountKey, shareName, int(volutil.RoundUpToGiB(newSize))); err != nil {
+	if err := azure.ResizeFileShareWithEndpointSuffix(creds.endpointSuffix, creds.accountName, creds.accountKey, shareName, int(volutil.RoundUpToGiB(newSize))); err != nil {
 		return oldSize, err
 	}
 
@@ -256,15 +256,31 @@ func (b *azureFileMounter) SetUpAt(dir string, fsGroup *int64) error {
 		notMnt = true
 	}
 
-	var accountKey, accountName string
-	if accountName, accountKey, err = b.util.GetAzureCredentials(b.plugin.host, b.secretNamespace, b.secretName); err != nil {
+	creds, err := b.util.GetAzureCredentials(b.plugin.host, b.secretNamespace, b.secretName)
+	if err != nil {
 		return err
 	}
+	accountName, accountKey := creds.accountName, creds.accountKey
+
+	// an endpoint suffix in the secret points at a storage account outside
+	// the cluster's subscription or cloud environment
+	endpointSuffix := creds.endpointSuffix
+	if endpointSuffix == "" {
+		endpointSuffix = getStorageEndpointSuffix(b.plugin.host.GetCloudProvider())
+	}
 
 	mountOptions := []string{}
 	source := ""
 	osSeparator := string(os.PathSeparator)
-	source = fmt.Sprintf("%s%s%s.file.%s%s%s", osSeparator, osSeparator, accountName, getStorageEndpointSuffix(b.plugin.host.GetCloudProvider()), osSeparator, b.shareName)
+	source = fmt.Sprintf("%s%s%s.file.%s%s%s", osSeparator, osSeparator, accountName, endpointSuffix, osSeparator, b.shareName)
+
+	// The seal mount option requests SMB encryption in transit, which only
+	// SMB 3.x can provide; refuse options that could downgrade below it.
+	if hasSealMountOption(b.mountOptions) {
+		if err := validateSecureMountOptions(b.mountOptions); err != nil {
+			return err
+		}
+	}
 
 	if runtime.GOOS == "windows" {
 		mountOptions = []string{fmt.Sprintf("AZURE\\%s", accountName), accountKey}
@@ -233,8 +233,8 @@ func TestPersistentClaimReadOnlyFlag(t *testing.T) {
 
 type fakeAzureSvc struct{}
 
-func (s *fakeAzureSvc) GetAzureCredentials(host volume.VolumeHost, nameSpace, secretName string) (string, string, error) {
-	return "name", "key", nil
+func (s *fakeAzureSvc) GetAzureCredentials(host volume.VolumeHost, nameSpace, secretName string) (*azureCredentials, error) {
+	return &azureCredentials{accountName: "name", accountKey: "key"}, nil
 }
 func (s *fakeAzureSvc) SetAzureCredentials(host volume.VolumeHost, nameSpace, accountName, accountKey string) (string, error) {
 	return "secret", nil
@@ -412,3 +412,72 @@ func TestAppendDefaultMountOptions(t *testing.T) {
 		}
 	}
 }
+
+func TestAppendSecureMountOptions(t *testing.T) {
+	tests := []struct {
+		options  []string
+		expected []string
+	}{
+		{
+			options:  nil,
+			expected: []string{fmt.Sprintf("%s=%s", vers, defaultVers), seal},
+		},
+		{
+			options:  []string{"vers=3.1.1"},
+			expected: []string{"vers=3.1.1", seal},
+		},
+		{
+			options:  []string{seal},
+			expected: []string{seal, fmt.Sprintf("%s=%s", vers, defaultVers)},
+		},
+		{
+			options:  []string{"vers=3.0", seal},
+			expected: []string{"vers=3.0", seal},
+		},
+	}
+
+	for _, test := range tests {
+		result := appendSecureMountOptions(test.options)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("input: %q, appendSecureMountOptions result: %q, expected: %q", test.options, result, test.expected)
+		}
+	}
+}
+
+func TestValidateSecureMountOptions(t *testing.T) {
+	tests := []struct {
+		options     []string
+		expectError bool
+	}{
+		{
+			options:     nil,
+			expectError: false,
+		},
+		{
+			options:     []string{"vers=3.0", seal},
+			expectError: false,
+		},
+		{
+			options:     []string{"vers=3.1.1", seal},
+			expectError: false,
+		},
+		{
+			options:     []string{"vers=2.1", seal},
+			expectError: true,
+		},
+		{
+			options:     []string{"vers=1.0"},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		err := validateSecureMountOptions(test.options)
+		if test.expectError && err == nil {
+			t.Errorf("input: %q, expected validateSecureMountOptions to fail", test.options)
+		}
+		if !test.expectError && err != nil {
+			t.Errorf("input: %q, unexpected validateSecureMountOptions error: %v", test.options, err)
+		}
+	}
+}
@@ -18,7 +18,9 @@ package azure_file
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2018-07-01/storage"
 	"github.com/golang/glog"
@@ -41,10 +43,19 @@ var _ volume.ProvisionableVolumePlugin = &azureFilePlugin{}
 type azureCloudProvider interface {
 	// create a file share
 	CreateFileShare(shareName, accountName, accountType, accountKind, resourceGroup, location string, requestGiB int) (string, string, error)
+	// create a file share in a new storage account only reachable from the
+	// cluster VNET subnet
+	CreateFileShareInVnet(shareName, accountType, accountKind, resourceGroup, location string, requestGiB int) (string, string, error)
+	// create a file share in a pre-existing storage account using the given
+	// access key, tagging the share with metadata
+	CreateFileShareInAccount(accountName, accountKey, shareName string, requestGiB int, metadata map[string]string) error
 	// delete a file share
 	DeleteFileShare(accountName, accountKey, shareName string) error
-	// resize a file share
-	ResizeFileShare(accountName, accountKey, name string, sizeGiB int) error
+	// resize a file share, optionally against a different storage endpoint
+	// suffix than the in-cluster environment (empty means the cluster default)
+	ResizeFileShareWithEndpointSuffix(endpointSuffix, accountName, accountKey, name string, sizeGiB int) error
+	// create a snapshot of a file share, returning the snapshot DateTime
+	SnapshotFileShare(accountName, accountKey, shareName string) (string, error)
 }
 
 type azureFileDeleter struct {
@@ -73,7 +84,7 @@ func (plugin *azureFilePlugin) newDeleterInternal(spec *volume.Spec, util azureU
 		return nil, err
 	}
 	shareName := spec.PersistentVolume.Spec.AzureFile.ShareName
-	if accountName, accountKey, err := util.GetAzureCredentials(plugin.host, secretNamespace, secretName); err != nil {
+	if creds, err := util.GetAzureCredentials(plugin.host, secretNamespace, secretName); err != nil {
 		return nil, err
 	} else {
 		return &azureFileDeleter{
@@ -82,8 +93,8 @@ func (plugin *azureFilePlugin) newDeleterInternal(spec *volume.Spec, util azureU
 				plugin:  plugin,
 			},
 			shareName:     shareName,
-			accountName:   accountName,
-			accountKey:    accountKey,
+			accountName:   creds.accountName,
+			accountKey:    creds.accountKey,
 			azureProvider: azure,
 		}, nil
 	}
@@ -142,6 +153,10 @@ func (a *azureFileProvisioner) Provision(selectedNode *v1.Node, allowedTopologie
 	}
 
 	var sku, resourceGroup, location, account string
+	var existingAccountName, existingSecretName string
+	restrictToVnet := false
+	secureTransfer := false
+	useExistingAccount := false
 
 	// File share name has a length limit of 63, and it cannot contain two consecutive '-'s.
 	name := util.GenerateVolumeName(a.options.ClusterName, a.options.PVName, 63)
@@ -162,8 +177,30 @@ func (a *azureFileProvisioner) Provision(selectedNode *v1.Node, allowedTopologie
 			account = v
 		case "secretnamespace":
 			secretNamespace = v
+		case "storageaccountname":
+			existingAccountName = v
+		case "secretname":
+			existingSecretName = v
+		case "useexistingaccount":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for useExistingAccount: %v", v, err)
+			}
+			useExistingAccount = b
 		case "resourcegroup":
 			resourceGroup = v
+		case "restricttovnet":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for restrictToVnet: %v", v, err)
+			}
+			restrictToVnet = b
+		case "securetransfer":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for secureTransfer: %v", v, err)
+			}
+			secureTransfer = b
 		default:
 			return nil, fmt.Errorf("invalid option %q for volume plugin %s", k, a.plugin.GetPluginName())
 		}
@@ -173,12 +210,58 @@ func (a *azureFileProvisioner) Provision(selectedNode *v1.Node, allowedTopologie
 		return nil, fmt.Errorf("claim.Spec.Selector is not supported for dynamic provisioning on Azure file")
 	}
 
+	// An existing account is referenced by name and by the secret holding its
+	// access key; the account management parameters make no sense for it.
+	if useExistingAccount {
+		if existingAccountName == "" || existingSecretName == "" {
+			return nil, fmt.Errorf("useExistingAccount requires both storageAccountName and secretName for volume plugin %s", a.plugin.GetPluginName())
+		}
+		if sku != "" || location != "" || account != "" || restrictToVnet {
+			return nil, fmt.Errorf("useExistingAccount cannot be combined with skuName, location, storageAccount or restrictToVnet for volume plugin %s", a.plugin.GetPluginName())
+		}
+	} else if existingAccountName != "" || existingSecretName != "" {
+		return nil, fmt.Errorf("storageAccountName and secretName are only valid together with useExistingAccount for volume plugin %s", a.plugin.GetPluginName())
+	}
+
+	// secureTransfer mandates SMB 3.x with encryption in transit on every
+	// mount of the provisioned volume, so a StorageClass that also pins an
+	// older protocol is rejected up front instead of failing at mount time.
+	mountOptions := a.options.MountOptions
+	if secureTransfer {
+		if err := validateSecureMountOptions(mountOptions); err != nil {
+			return nil, err
+		}
+		mountOptions = appendSecureMountOptions(mountOptions)
+	}
+
+	if a.options.PVC.Spec.DataSource != nil {
+		if useExistingAccount {
+			return nil, fmt.Errorf("useExistingAccount is not supported together with a DataSource for volume plugin %s", a.plugin.GetPluginName())
+		}
+		return a.provisionFromSource(requestGiB, mountOptions)
+	}
+
+	if useExistingAccount {
+		return a.provisionInExistingAccount(name, existingAccountName, existingSecretName, secretNamespace, requestGiB, mountOptions)
+	}
+
 	// when use azure file premium, account kind should be specified as FileStorage
 	accountKind := string(storage.StorageV2)
 	if strings.HasPrefix(strings.ToLower(sku), "premium") {
 		accountKind = string(storage.FileStorage)
 	}
-	account, key, err := a.azureProvider.CreateFileShare(name, account, sku, accountKind, resourceGroup, location, requestGiB)
+	var key string
+	var err error
+	if restrictToVnet {
+		// An existing account may be publicly reachable, so a VNET-restricted
+		// share always goes into a newly created account.
+		if account != "" {
+			return nil, fmt.Errorf("restrictToVnet cannot be combined with storageAccount for volume plugin %s", a.plugin.GetPluginName())
+		}
+		account, key, err = a.azureProvider.CreateFileShareInVnet(name, sku, accountKind, resourceGroup, location, requestGiB)
+	} else {
+		account, key, err = a.azureProvider.CreateFileShare(name, account, sku, accountKind, resourceGroup, location, requestGiB)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -210,12 +293,157 @@ func (a *azureFileProvisioner) Provision(selectedNode *v1.Node, allowedTopologie
 					SecretNamespace: &secretNamespace,
 				},
 			},
-			MountOptions: a.options.MountOptions,
+			MountOptions: mountOptions,
+		},
+	}
+	return pv, nil
+}
+
+// provisionInExistingAccount creates the share in a storage account the
+// cluster admin provisioned out of band, for example one only reachable over
+// a private endpoint. The account's access key is read from the referenced
+// secret, which the returned PV also points at, so no new secret is created.
+// The share is tagged with the claim and volume names so the account owner
+// can trace it back to its consumer.
+func (a *azureFileProvisioner) provisionInExistingAccount(shareName, accountName, secretName, secretNamespace string, requestGiB int, mountOptions []string) (*v1.PersistentVolume, error) {
+	creds, err := a.util.GetAzureCredentials(a.plugin.host, secretNamespace, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if creds.accountName != accountName {
+		return nil, fmt.Errorf("secret %s/%s holds credentials for storage account %s, not %s", secretNamespace, secretName, creds.accountName, accountName)
+	}
+
+	metadata := map[string]string{
+		"created_by":    "azure-file-dynamic-provisioner",
+		"pvc_namespace": a.options.PVC.Namespace,
+		"pvc_name":      a.options.PVC.Name,
+		"pv_name":       a.options.PVName,
+	}
+	if err := a.azureProvider.CreateFileShareInAccount(accountName, creds.accountKey, shareName, requestGiB, metadata); err != nil {
+		return nil, err
+	}
+	glog.V(2).Infof("provisioned share %s in existing account %s", shareName, accountName)
+
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   a.options.PVName,
+			Labels: map[string]string{},
+			Annotations: map[string]string{
+				util.VolumeDynamicallyCreatedByKey: "azure-file-dynamic-provisioner",
+			},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: a.options.PersistentVolumeReclaimPolicy,
+			AccessModes:                   a.options.PVC.Spec.AccessModes,
+			Capacity: v1.ResourceList{
+				v1.ResourceName(v1.ResourceStorage): resource.MustParse(fmt.Sprintf("%dGi", requestGiB)),
+			},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				AzureFile: &v1.AzureFilePersistentVolumeSource{
+					SecretName:      secretName,
+					ShareName:       shareName,
+					SecretNamespace: &secretNamespace,
+				},
+			},
+			MountOptions: mountOptions,
 		},
 	}
 	return pv, nil
 }
 
+// shareSnapshotAnnotation records the snapshot DateTime a provisioned PV was
+// restored from.
+const shareSnapshotAnnotation = "volume.beta.kubernetes.io/azure-file-share-snapshot"
+
+// provisionFromSource handles a claim whose DataSource references another
+// azure-file claim: a snapshot of the source share is taken and the new PV
+// mounts that snapshot. Share snapshots are read-only, so the returned PV is
+// marked ReadOnly.
+func (a *azureFileProvisioner) provisionFromSource(requestGiB int, mountOptions []string) (*v1.PersistentVolume, error) {
+	dataSource := a.options.PVC.Spec.DataSource
+	if dataSource.Kind != "PersistentVolumeClaim" || (dataSource.APIGroup != nil && len(*dataSource.APIGroup) > 0) {
+		return nil, fmt.Errorf("%s only supports a DataSource of kind PersistentVolumeClaim", a.plugin.GetPluginName())
+	}
+
+	kubeClient := a.plugin.host.GetKubeClient()
+	if kubeClient == nil {
+		return nil, fmt.Errorf("cannot get kube client")
+	}
+	sourcePVC, err := kubeClient.CoreV1().PersistentVolumeClaims(a.options.PVC.Namespace).Get(dataSource.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source claim %s/%s: %v", a.options.PVC.Namespace, dataSource.Name, err)
+	}
+	if sourcePVC.Status.Phase != v1.ClaimBound || len(sourcePVC.Spec.VolumeName) == 0 {
+		return nil, fmt.Errorf("source claim %s/%s is not bound", sourcePVC.Namespace, sourcePVC.Name)
+	}
+	sourcePV, err := kubeClient.CoreV1().PersistentVolumes().Get(sourcePVC.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source volume %s: %v", sourcePVC.Spec.VolumeName, err)
+	}
+	azureSource := sourcePV.Spec.AzureFile
+	if azureSource == nil {
+		return nil, fmt.Errorf("source claim %s/%s is not an azure-file volume", sourcePVC.Namespace, sourcePVC.Name)
+	}
+
+	secretNamespace := a.options.PVC.Namespace
+	if azureSource.SecretNamespace != nil && len(*azureSource.SecretNamespace) > 0 {
+		secretNamespace = *azureSource.SecretNamespace
+	}
+	creds, err := a.util.GetAzureCredentials(a.plugin.host, secretNamespace, azureSource.SecretName)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotTimestamp, err := a.azureProvider.SnapshotFileShare(creds.accountName, creds.accountKey, azureSource.ShareName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot file share %s: %v", azureSource.ShareName, err)
+	}
+	snapshotOption, err := snapshotMountOption(snapshotTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(2).Infof("provisioning %s from snapshot %s of file share %s", a.options.PVName, snapshotTimestamp, azureSource.ShareName)
+
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   a.options.PVName,
+			Labels: map[string]string{},
+			Annotations: map[string]string{
+				util.VolumeDynamicallyCreatedByKey: "azure-file-dynamic-provisioner",
+				shareSnapshotAnnotation:            snapshotTimestamp,
+			},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: a.options.PersistentVolumeReclaimPolicy,
+			AccessModes:                   a.options.PVC.Spec.AccessModes,
+			Capacity: v1.ResourceList{
+				v1.ResourceName(v1.ResourceStorage): resource.MustParse(fmt.Sprintf("%dGi", requestGiB)),
+			},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				AzureFile: &v1.AzureFilePersistentVolumeSource{
+					SecretName:      azureSource.SecretName,
+					ShareName:       azureSource.ShareName,
+					SecretNamespace: azureSource.SecretNamespace,
+					ReadOnly:        true,
+				},
+			},
+			MountOptions: append(mountOptions, snapshotOption),
+		},
+	}
+	return pv, nil
+}
+
+// snapshotMountOption converts the snapshot DateTime returned by the file
+// service into the @GMT token form the cifs snapshot= mount option expects.
+func snapshotMountOption(snapshotTimestamp string) (string, error) {
+	t, err := time.Parse(time.RFC3339Nano, snapshotTimestamp)
+	if err != nil {
+		return "", fmt.Errorf("unexpected snapshot timestamp %q: %v", snapshotTimestamp, err)
+	}
+	return fmt.Sprintf("snapshot=@GMT-%s", t.UTC().Format("2006.01.02-15.04.05")), nil
+}
+
 // Return cloud provider
 func getAzureCloudProvider(cloudProvider cloudprovider.Interface) (azureCloudProvider, error) {
 	azureCloudProvider, ok := cloudProvider.(*azure.Cloud)
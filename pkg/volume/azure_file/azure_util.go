@@ -24,6 +24,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/kubernetes/pkg/volume"
+	volutil "k8s.io/kubernetes/pkg/volume/util"
 )
 
 const (
@@ -31,42 +32,59 @@ const (
 	dirMode         = "dir_mode"
 	gid             = "gid"
 	vers            = "vers"
+	seal            = "seal"
 	defaultFileMode = "0777"
 	defaultDirMode  = "0777"
 	defaultVers     = "3.0"
 )
 
+// azureCredentials holds the storage account access information extracted
+// from the volume's credentials secret. EndpointSuffix is optional; when set
+// it overrides the storage endpoint suffix of the in-cluster cloud
+// environment so that shares in a different subscription or national cloud
+// can be mounted and resized.
+type azureCredentials struct {
+	accountName    string
+	accountKey     string
+	endpointSuffix string
+}
+
 // Abstract interface to azure file operations.
 type azureUtil interface {
-	GetAzureCredentials(host volume.VolumeHost, nameSpace, secretName string) (string, string, error)
+	GetAzureCredentials(host volume.VolumeHost, nameSpace, secretName string) (*azureCredentials, error)
 	SetAzureCredentials(host volume.VolumeHost, nameSpace, accountName, accountKey string) (string, error)
 }
 
 type azureSvc struct{}
 
-func (s *azureSvc) GetAzureCredentials(host volume.VolumeHost, nameSpace, secretName string) (string, string, error) {
-	var accountKey, accountName string
+func (s *azureSvc) GetAzureCredentials(host volume.VolumeHost, nameSpace, secretName string) (*azureCredentials, error) {
+	creds := &azureCredentials{}
 	kubeClient := host.GetKubeClient()
 	if kubeClient == nil {
-		return "", "", fmt.Errorf("cannot get kube client")
+		return nil, fmt.Errorf("cannot get kube client")
 	}
 
-	keys, err := kubeClient.CoreV1().Secrets(nameSpace).Get(secretName, metav1.GetOptions{})
+	// The same secret is read on every mount of the share; the shared cached
+	// provider keeps mass pod creation from issuing one GET per mount.
+	keys, err := volutil.SharedSecretProvider(kubeClient).GetSecret(nameSpace, secretName)
 	if err != nil {
-		return "", "", fmt.Errorf("couldn't get secret %v/%v", nameSpace, secretName)
+		return nil, fmt.Errorf("couldn't get secret %v/%v", nameSpace, secretName)
 	}
 	for name, data := range keys.Data {
 		if name == "azurestorageaccountname" {
-			accountName = string(data)
+			creds.accountName = string(data)
 		}
 		if name == "azurestorageaccountkey" {
-			accountKey = string(data)
+			creds.accountKey = string(data)
+		}
+		if name == "azurestorageaccountendpointsuffix" {
+			creds.endpointSuffix = string(data)
 		}
 	}
-	if accountName == "" || accountKey == "" {
-		return "", "", fmt.Errorf("invalid %v/%v, couldn't extract azurestorageaccountname or azurestorageaccountkey", nameSpace, secretName)
+	if creds.accountName == "" || creds.accountKey == "" {
+		return nil, fmt.Errorf("invalid %v/%v, couldn't extract azurestorageaccountname or azurestorageaccountkey", nameSpace, secretName)
 	}
-	return accountName, accountKey, nil
+	return creds, nil
 }
 
 func (s *azureSvc) SetAzureCredentials(host volume.VolumeHost, nameSpace, accountName, accountKey string) (string, error) {
@@ -136,3 +154,56 @@ func appendDefaultMountOptions(mountOptions []string, fsGroup *int64) []string {
 	}
 	return allMountOptions
 }
+
+// hasSealMountOption reports whether the mount options request SMB encryption
+// in transit via the cifs seal option.
+func hasSealMountOption(mountOptions []string) bool {
+	for _, mountOption := range mountOptions {
+		if mountOption == seal {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSecureMountOptions rejects mount options that could negotiate an SMB
+// protocol older than 3.x, which cannot provide encryption in transit. It is
+// enforced whenever the seal option requests encryption, on both the Linux and
+// Windows mount paths.
+func validateSecureMountOptions(mountOptions []string) error {
+	for _, mountOption := range mountOptions {
+		if !strings.HasPrefix(mountOption, vers+"=") {
+			continue
+		}
+		value := strings.TrimPrefix(mountOption, vers+"=")
+		if !strings.HasPrefix(value, "3") {
+			return fmt.Errorf("mount option %q would fall back to SMB %s, but encryption in transit (%s) requires SMB 3.x", mountOption, value, seal)
+		}
+	}
+	return nil
+}
+
+// appendSecureMountOptions adds the seal (encryption in transit) and SMB 3.x
+// protocol options unless they are already present.
+func appendSecureMountOptions(mountOptions []string) []string {
+	sealFlag := false
+	versFlag := false
+
+	for _, mountOption := range mountOptions {
+		if mountOption == seal {
+			sealFlag = true
+		}
+		if strings.HasPrefix(mountOption, vers) {
+			versFlag = true
+		}
+	}
+
+	allMountOptions := mountOptions
+	if !versFlag {
+		allMountOptions = append(allMountOptions, fmt.Sprintf("%s=%s", vers, defaultVers))
+	}
+	if !sealFlag {
+		allMountOptions = append(allMountOptions, seal)
+	}
+	return allMountOptions
+}
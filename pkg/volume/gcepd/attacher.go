@@ -214,9 +214,14 @@ func (attacher *gcePersistentDiskAttacher) MountDevice(spec *volume.Spec, device
 	if readOnly {
 		options = append(options, "ro")
 	}
+	// Block device tuning options never reach mount(8); they are applied to
+	// the attached device's queue settings below.
+	tuning, mountOptions, err := filterTuningOptions(volumeutil.MountOptionFromSpec(spec, options...))
+	if err != nil {
+		return err
+	}
 	if notMnt {
 		diskMounter := volumeutil.NewSafeFormatAndMountFromHost(gcePersistentDiskPluginName, attacher.host)
-		mountOptions := volumeutil.MountOptionFromSpec(spec, options...)
 		err = diskMounter.FormatAndMount(devicePath, deviceMountPath, volumeSource.FSType, mountOptions)
 		if err != nil {
 			os.Remove(deviceMountPath)
@@ -224,6 +229,13 @@ func (attacher *gcePersistentDiskAttacher) MountDevice(spec *volume.Spec, device
 		}
 		glog.V(4).Infof("formatting spec %v devicePath %v deviceMountPath %v fs %v with options %+v", spec.Name(), devicePath, deviceMountPath, volumeSource.FSType, options)
 	}
+	if len(tuning) != 0 {
+		// Best effort: a kernel that rejects a whitelisted setting should not
+		// keep the workload's data offline.
+		if err := applyDeviceTuning(devicePath, tuning); err != nil {
+			glog.Warningf("Failed to apply block device tuning for %s: %v", devicePath, err)
+		}
+	}
 	return nil
 }
 
@@ -20,7 +20,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path"
 	"strconv"
 	"strings"
 
@@ -34,6 +33,7 @@ import (
 	"k8s.io/kubernetes/pkg/features"
 	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
 	"k8s.io/kubernetes/pkg/util/mount"
+	"k8s.io/kubernetes/pkg/util/resizefs"
 	kstrings "k8s.io/kubernetes/pkg/util/strings"
 	"k8s.io/kubernetes/pkg/volume"
 	"k8s.io/kubernetes/pkg/volume/util"
@@ -53,6 +53,7 @@ var _ volume.PersistentVolumePlugin = &gcePersistentDiskPlugin{}
 var _ volume.DeletableVolumePlugin = &gcePersistentDiskPlugin{}
 var _ volume.ProvisionableVolumePlugin = &gcePersistentDiskPlugin{}
 var _ volume.ExpandableVolumePlugin = &gcePersistentDiskPlugin{}
+var _ volume.FSResizableVolumePlugin = &gcePersistentDiskPlugin{}
 var _ volume.VolumePluginWithAttachLimits = &gcePersistentDiskPlugin{}
 
 const (
@@ -284,6 +285,18 @@ func (plugin *gcePersistentDiskPlugin) ExpandVolumeDevice(
 	return updatedQuantity, nil
 }
 
+// ExpandFS grows the file system of a mounted, in-use PD in place.
+// resize2fs and xfs_growfs both support online expansion, so pods keep
+// running while the file system catches up with the grown disk.
+func (plugin *gcePersistentDiskPlugin) ExpandFS(spec *volume.Spec, devicePath, deviceMountPath string, newSize, oldSize resource.Quantity) error {
+	fsResizer := resizefs.NewResizeFs(&mount.SafeFormatAndMount{
+		Interface: plugin.host.GetMounter(plugin.GetPluginName()),
+		Exec:      plugin.host.GetExec(plugin.GetPluginName()),
+	})
+	_, err := fsResizer.Resize(devicePath, deviceMountPath)
+	return err
+}
+
 func (plugin *gcePersistentDiskPlugin) ConstructVolumeSpec(volumeName, mountPath string) (*volume.Spec, error) {
 	mounter := plugin.host.GetMounter(plugin.GetPluginName())
 	pluginDir := plugin.host.GetPluginDir(plugin.GetPluginName())
@@ -335,6 +348,14 @@ type gcePersistentDiskMounter struct {
 }
 
 var _ volume.Mounter = &gcePersistentDiskMounter{}
+var _ volume.VolumeHealthChecker = &gcePersistentDiskMounter{}
+
+// CheckVolumeHealth probes the pod's mount of the disk so corrupted or
+// read-only remounted volumes are detected while still in use, not only at
+// unmount.
+func (b *gcePersistentDiskMounter) CheckVolumeHealth() error {
+	return util.CheckVolumeHealth(b.mounter, b.GetPath(), b.readOnly)
+}
 
 func (b *gcePersistentDiskMounter) GetAttributes() volume.Attributes {
 	return volume.Attributes{
@@ -414,7 +435,7 @@ func (b *gcePersistentDiskMounter) SetUpAt(dir string, fsGroup *int64) error {
 	}
 
 	if !b.readOnly {
-		volume.SetVolumeOwnership(b, fsGroup)
+		util.SetVolumeOwnershipOnRootMismatch(b, fsGroup)
 	}
 
 	glog.V(4).Infof("Successfully mounted %s", dir)
@@ -422,7 +443,7 @@ func (b *gcePersistentDiskMounter) SetUpAt(dir string, fsGroup *int64) error {
 }
 
 func makeGlobalPDName(host volume.VolumeHost, devName string) string {
-	return path.Join(host.GetPluginDir(gcePersistentDiskPluginName), mount.MountsInGlobalPDPath, devName)
+	return util.EncodeGlobalMountPath(host.GetPluginDir(gcePersistentDiskPluginName), devName)
 }
 
 func (b *gcePersistentDiskMounter) GetPath() string {
@@ -472,8 +493,14 @@ type gcePersistentDiskProvisioner struct {
 var _ volume.Provisioner = &gcePersistentDiskProvisioner{}
 
 func (c *gcePersistentDiskProvisioner) Provision(selectedNode *v1.Node, allowedTopologies []v1.TopologySelectorTerm) (*v1.PersistentVolume, error) {
-	if !util.AccessModesContainedInAll(c.plugin.GetAccessModes(), c.options.PVC.Spec.AccessModes) {
-		return nil, fmt.Errorf("invalid AccessModes %v: only AccessModes %v are supported", c.options.PVC.Spec.AccessModes, c.plugin.GetAccessModes())
+	// Multi-writer disks can be attached read-write to several nodes, so
+	// ReadWriteMany becomes a valid access mode for them.
+	supportedModes := c.plugin.GetAccessModes()
+	if multiWriterRequested(c.options.Parameters) {
+		supportedModes = append(supportedModes, v1.ReadWriteMany)
+	}
+	if !util.AccessModesContainedInAll(supportedModes, c.options.PVC.Spec.AccessModes) {
+		return nil, fmt.Errorf("invalid AccessModes %v: only AccessModes %v are supported", c.options.PVC.Spec.AccessModes, supportedModes)
 	}
 
 	volumeID, sizeGB, labels, fstype, err := c.manager.CreateVolume(c, selectedNode, allowedTopologies)
@@ -544,6 +571,12 @@ func (c *gcePersistentDiskProvisioner) Provision(selectedNode *v1.Node, allowedT
 		}
 	}
 
+	extraRequirements, err := nodeAffinityRequirementsFromParameters(c.options.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	requirements = append(requirements, extraRequirements...)
+
 	if utilfeature.DefaultFeatureGate.Enabled(features.VolumeScheduling) && len(requirements) > 0 {
 		pv.Spec.NodeAffinity = new(v1.VolumeNodeAffinity)
 		pv.Spec.NodeAffinity.Required = new(v1.NodeSelector)
@@ -39,6 +39,9 @@ var _ volume.DeletableVolumePlugin = &gcePersistentDiskPlugin{}
 var _ volume.ProvisionableVolumePlugin = &gcePersistentDiskPlugin{}
 var _ volume.ExpandableVolumePlugin = &gcePersistentDiskPlugin{}
 
+// ConstructBlockVolumeSpec rebuilds a raw block volume spec during kubelet
+// volume reconstruction after restart: the pd name is recovered from the
+// global map path referenced by the pod's device map path.
 func (plugin *gcePersistentDiskPlugin) ConstructBlockVolumeSpec(podUID types.UID, volumeName, mapPath string) (*volume.Spec, error) {
 	pluginDir := plugin.host.GetVolumeDevicePluginDir(gcePersistentDiskPluginName)
 	blkutil := volumepathhandler.NewBlockVolumePathHandler()
@@ -116,6 +119,8 @@ func (plugin *gcePersistentDiskPlugin) newBlockVolumeMapperInternal(spec *volume
 		readOnly: readOnly}, nil
 }
 
+// NewBlockVolumeUnmapper creates a new volume.BlockVolumeUnmapper from a
+// volume name and pod UID; no spec is available during unmap.
 func (plugin *gcePersistentDiskPlugin) NewBlockVolumeUnmapper(volName string, podUID types.UID) (volume.BlockVolumeUnmapper, error) {
 	return plugin.newUnmapperInternal(volName, podUID, &GCEDiskUtil{})
 }
@@ -131,6 +136,8 @@ func (plugin *gcePersistentDiskPlugin) newUnmapperInternal(volName string, podUI
 		}}, nil
 }
 
+// TearDownDevice is a no-op: the attach controller detaches the disk and the
+// generic unmap path removes the symlinks.
 func (c *gcePersistentDiskUnmapper) TearDownDevice(mapPath, devicePath string) error {
 	return nil
 }
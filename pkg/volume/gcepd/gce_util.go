@@ -18,14 +18,18 @@ package gcepd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang/glog"
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	cloudprovider "k8s.io/cloud-provider"
@@ -53,9 +57,24 @@ const (
 	replicationTypeNone       = "none"
 	replicationTypeRegionalPD = "regional-pd"
 
+	// Supported PVC data source kinds.
+	pvcDataSourceKind          = "PersistentVolumeClaim"
+	snapshotDataSourceKind     = "VolumeSnapshot"
+	snapshotDataSourceAPIGroup = "snapshot.storage.k8s.io"
+
 	// scsi_id output should be in the form of:
 	// 0Google PersistentDisk <disk name>
 	scsiPattern = `^0Google\s+PersistentDisk\s+([\S]+)\s*$`
+
+	// Block device tuning keys accepted in mount options. They never reach
+	// mount(8); they are stripped from the option list and applied to the
+	// device's sysfs queue settings after attach instead.
+	tuningOptionReadAheadKB = "read_ahead_kb"
+	tuningOptionScheduler   = "scheduler"
+
+	// Upper bound accepted for read_ahead_kb. Generous enough for database
+	// tuning while keeping a typo from dedicating gigabytes to read-ahead.
+	maxReadAheadKB = 65536
 )
 
 var (
@@ -65,6 +84,15 @@ var (
 
 	// regex to parse scsi_id output and extract the serial
 	scsiRegex = regexp.MustCompile(scsiPattern)
+
+	// sysBlockPath is modified only in unit tests and should be constant
+	// otherwise.
+	sysBlockPath = "/sys/block"
+
+	// validSchedulers are the io schedulers the scheduler mount option may
+	// select. Whether the running kernel offers a given scheduler is only
+	// known when the setting is applied.
+	validSchedulers = sets.NewString("none", "noop", "deadline", "mq-deadline", "cfq", "kyber", "bfq")
 )
 
 // GCEDiskUtil provides operation for GCE PD
@@ -112,6 +140,8 @@ func (util *GCEDiskUtil) CreateVolume(c *gcePersistentDiskProvisioner, node *v1.
 	zonesPresent := false
 	replicationType := replicationTypeNone
 	fstype := ""
+	multiWriter := false
+	zoneSpread := false
 	for k, v := range c.options.Parameters {
 		switch strings.ToLower(k) {
 		case "type":
@@ -134,25 +164,86 @@ func (util *GCEDiskUtil) CreateVolume(c *gcePersistentDiskProvisioner, node *v1.
 			replicationType = strings.ToLower(v)
 		case volume.VolumeParameterFSType:
 			fstype = v
+		case "multiwriter":
+			multiWriter, err = strconv.ParseBool(v)
+			if err != nil {
+				return "", 0, nil, "", fmt.Errorf("invalid value %q for multiWriter parameter of volume plugin %s", v, c.plugin.GetPluginName())
+			}
+		case "zone-spread":
+			zoneSpread, err = strconv.ParseBool(v)
+			if err != nil {
+				return "", 0, nil, "", fmt.Errorf("invalid value %q for zone-spread parameter of volume plugin %s", v, c.plugin.GetPluginName())
+			}
+		case "node-affinity-labels":
+			// Applied to the PV by the provisioner; validated here so a bad
+			// value fails before the disk is created.
+			if _, err = parseNodeAffinityLabels(v); err != nil {
+				return "", 0, nil, "", err
+			}
 		default:
 			return "", 0, nil, "", fmt.Errorf("invalid option %q for volume plugin %s", k, c.plugin.GetPluginName())
 		}
 	}
 
+	if multiWriter {
+		if replicationType != replicationTypeNone {
+			return "", 0, nil, "", fmt.Errorf("multiWriter is not supported with replication-type %q", replicationType)
+		}
+		if diskType != gcecloud.DiskTypeSSD {
+			return "", 0, nil, "", fmt.Errorf("multiWriter requires disk type %q", gcecloud.DiskTypeSSD)
+		}
+	}
+
 	// TODO: implement PVC.Selector parsing
 	if c.options.PVC.Spec.Selector != nil {
 		return "", 0, nil, "", fmt.Errorf("claim.Spec.Selector is not supported for dynamic provisioning on GCE")
 	}
 
+	// Honor a PVC data source: another PVC is cloned, a VolumeSnapshot is
+	// restored into the new disk.
+	sourcePDName := ""
+	sourceSnapshotName := ""
+	if dataSource := c.options.PVC.Spec.DataSource; dataSource != nil {
+		apiGroup := ""
+		if dataSource.APIGroup != nil {
+			apiGroup = *dataSource.APIGroup
+		}
+		switch {
+		case dataSource.Kind == pvcDataSourceKind && apiGroup == "":
+			sourcePDName, err = sourcePDFromDataSource(c, int64(requestGB))
+		case dataSource.Kind == snapshotDataSourceKind && apiGroup == snapshotDataSourceAPIGroup:
+			sourceSnapshotName, err = sourceSnapshotFromDataSource(c)
+		default:
+			err = fmt.Errorf("dataSource of kind %q is not supported by volume plugin %s", dataSource.Kind, c.plugin.GetPluginName())
+		}
+		if err != nil {
+			return "", 0, nil, "", err
+		}
+		if replicationType != replicationTypeNone {
+			return "", 0, nil, "", fmt.Errorf("data sources are not supported with replication-type %q", replicationType)
+		}
+		if multiWriter {
+			return "", 0, nil, "", fmt.Errorf("data sources are not supported together with multiWriter")
+		}
+	}
+
 	var activezones sets.String
 	activezones, err = cloud.GetAllCurrentZones()
 	if err != nil {
 		return "", 0, nil, "", err
 	}
 
+	// With zone-spread enabled, the claim's namespace and UID are mixed into
+	// the placement hash so identically-suffixed claims of different
+	// StatefulSets do not collide onto the same zones.
+	zoneSalt := ""
+	if zoneSpread {
+		zoneSalt = volumeutil.ClaimZoneSpreadSalt(c.options.PVC)
+	}
+
 	switch replicationType {
 	case replicationTypeRegionalPD:
-		selectedZones, err := volumeutil.SelectZonesForVolume(zonePresent, zonesPresent, configuredZone, configuredZones, activezones, node, allowedTopologies, c.options.PVC.Name, maxRegionalPDZones)
+		selectedZones, err := volumeutil.SelectZonesForVolumeWithSalt(zonePresent, zonesPresent, configuredZone, configuredZones, activezones, node, allowedTopologies, c.options.PVC.Name, zoneSalt, maxRegionalPDZones)
 		if err != nil {
 			glog.V(2).Infof("Error selecting zones for regional GCE PD volume: %v", err)
 			return "", 0, nil, "", err
@@ -169,16 +260,47 @@ func (util *GCEDiskUtil) CreateVolume(c *gcePersistentDiskProvisioner, node *v1.
 		glog.V(2).Infof("Successfully created Regional GCE PD volume %s", name)
 
 	case replicationTypeNone:
-		selectedZone, err := volumeutil.SelectZoneForVolume(zonePresent, zonesPresent, configuredZone, configuredZones, activezones, node, allowedTopologies, c.options.PVC.Name)
+		if sourcePDName != "" {
+			// The clone is created in the source disk's zone, so no zone
+			// selection is done here.
+			if err := cloud.CreateClonedDisk(
+				name,
+				diskType,
+				int64(requestGB),
+				*c.options.CloudTags,
+				sourcePDName); err != nil {
+				glog.V(2).Infof("Error cloning GCE PD volume from %s: %v", sourcePDName, err)
+				return "", 0, nil, "", err
+			}
+			glog.V(2).Infof("Successfully created GCE PD volume %s cloned from %s", name, sourcePDName)
+			break
+		}
+		selectedZone, err := volumeutil.SelectZoneForVolumeWithSalt(zonePresent, zonesPresent, configuredZone, configuredZones, activezones, node, allowedTopologies, c.options.PVC.Name, zoneSalt)
 		if err != nil {
 			return "", 0, nil, "", err
 		}
-		if err := cloud.CreateDisk(
+		if sourceSnapshotName != "" {
+			// Snapshots are global, so the zone selected above is kept.
+			if err := cloud.CreateSnapshotRestoredDisk(
+				name,
+				diskType,
+				selectedZone,
+				int64(requestGB),
+				*c.options.CloudTags,
+				sourceSnapshotName); err != nil {
+				glog.V(2).Infof("Error restoring GCE PD volume from snapshot %s: %v", sourceSnapshotName, err)
+				return "", 0, nil, "", err
+			}
+			glog.V(2).Infof("Successfully created GCE PD volume %s restored from snapshot %s", name, sourceSnapshotName)
+			break
+		}
+		if err := cloud.CreateMultiWriterDisk(
 			name,
 			diskType,
 			selectedZone,
 			int64(requestGB),
-			*c.options.CloudTags); err != nil {
+			*c.options.CloudTags,
+			multiWriter); err != nil {
 			glog.V(2).Infof("Error creating single-zone GCE PD volume: %v", err)
 			return "", 0, nil, "", err
 		}
@@ -350,6 +472,61 @@ func udevadmChangeToDrive(drivePath string) error {
 	return nil
 }
 
+// filterTuningOptions splits block device tuning keys out of the given mount
+// options. The tuning keys are validated here, so a bad value in a PV or
+// StorageClass fails the mount instead of being silently passed to sysfs.
+// The remaining options are meant for mount(8) and are returned untouched.
+func filterTuningOptions(options []string) (map[string]string, []string, error) {
+	tuning := map[string]string{}
+	remaining := make([]string, 0, len(options))
+	for _, option := range options {
+		parts := strings.SplitN(option, "=", 2)
+		if len(parts) != 2 {
+			remaining = append(remaining, option)
+			continue
+		}
+		switch parts[0] {
+		case tuningOptionReadAheadKB:
+			kb, err := strconv.Atoi(parts[1])
+			if err != nil || kb < 0 || kb > maxReadAheadKB {
+				return nil, nil, fmt.Errorf("invalid value %q for mount option %s, expected an integer between 0 and %d", parts[1], tuningOptionReadAheadKB, maxReadAheadKB)
+			}
+			tuning[tuningOptionReadAheadKB] = parts[1]
+		case tuningOptionScheduler:
+			if !validSchedulers.Has(parts[1]) {
+				return nil, nil, fmt.Errorf("invalid value %q for mount option %s, expected one of %v", parts[1], tuningOptionScheduler, validSchedulers.List())
+			}
+			tuning[tuningOptionScheduler] = parts[1]
+		default:
+			remaining = append(remaining, option)
+		}
+	}
+	return tuning, remaining, nil
+}
+
+// applyDeviceTuning writes the requested queue settings for the block device
+// behind devicePath. The settings are per device rather than per mount, which
+// is safe for GCE PDs because a disk attached read-write belongs to a single
+// workload.
+func applyDeviceTuning(devicePath string, tuning map[string]string) error {
+	device, err := filepath.EvalSymlinks(devicePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve device path %q: %v", devicePath, err)
+	}
+	return writeDeviceTuning(path.Join(sysBlockPath, filepath.Base(device), "queue"), tuning)
+}
+
+func writeDeviceTuning(queueDir string, tuning map[string]string) error {
+	for setting, value := range tuning {
+		settingPath := path.Join(queueDir, setting)
+		if err := ioutil.WriteFile(settingPath, []byte(value), 0644); err != nil {
+			return fmt.Errorf("failed to write %q to %s: %v", value, settingPath, err)
+		}
+		glog.V(4).Infof("Set %s to %q", settingPath, value)
+	}
+	return nil
+}
+
 // Checks whether the given GCE PD volume spec is associated with a regional PD.
 func isRegionalPD(spec *volume.Spec) bool {
 	if spec.PersistentVolume != nil {
@@ -359,3 +536,107 @@ func isRegionalPD(spec *volume.Spec) bool {
 	}
 	return false
 }
+
+// sourcePDFromDataSource resolves a PVC data source of kind
+// PersistentVolumeClaim to the name of the GCE PD backing the source claim and
+// validates the requested size against the source claim's capacity. Cloning
+// from any other data source kind is rejected.
+func sourcePDFromDataSource(c *gcePersistentDiskProvisioner, requestGB int64) (string, error) {
+	dataSource := c.options.PVC.Spec.DataSource
+	if dataSource.Kind != "PersistentVolumeClaim" || (dataSource.APIGroup != nil && *dataSource.APIGroup != "") {
+		return "", fmt.Errorf("dataSource of kind %q is not supported by volume plugin %s", dataSource.Kind, c.plugin.GetPluginName())
+	}
+
+	kubeClient := c.gcePersistentDisk.plugin.host.GetKubeClient()
+	if kubeClient == nil {
+		return "", fmt.Errorf("failed to get kube client to resolve dataSource")
+	}
+
+	namespace := c.options.PVC.Namespace
+	sourcePVC, err := kubeClient.CoreV1().PersistentVolumeClaims(namespace).Get(dataSource.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get source PVC %s/%s: %v", namespace, dataSource.Name, err)
+	}
+	if sourcePVC.Status.Phase != v1.ClaimBound || sourcePVC.Spec.VolumeName == "" {
+		return "", fmt.Errorf("source PVC %s/%s is not bound", namespace, dataSource.Name)
+	}
+
+	sourcePV, err := kubeClient.CoreV1().PersistentVolumes().Get(sourcePVC.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get source PV %s: %v", sourcePVC.Spec.VolumeName, err)
+	}
+	if sourcePV.Spec.GCEPersistentDisk == nil {
+		return "", fmt.Errorf("source PVC %s/%s is not backed by a GCE PD", namespace, dataSource.Name)
+	}
+
+	sourceCapacity := sourcePVC.Status.Capacity[v1.ResourceName(v1.ResourceStorage)]
+	if sourceGB := volumeutil.RoundUpToGiB(sourceCapacity); requestGB < sourceGB {
+		return "", fmt.Errorf("requested size %dGi is smaller than source PVC %s/%s (%dGi)", requestGB, namespace, dataSource.Name, sourceGB)
+	}
+
+	return sourcePV.Spec.GCEPersistentDisk.PDName, nil
+}
+
+// sourceSnapshotFromDataSource resolves a PVC data source of kind
+// VolumeSnapshot (snapshot.storage.k8s.io) to the name of the GCE snapshot to
+// restore from. The snapshot objects live in CRDs this provisioner has no
+// typed client for, so the data source name is used as the GCE snapshot name
+// directly; the cloud provider validates the requested size against the
+// snapshot when the disk is created.
+func sourceSnapshotFromDataSource(c *gcePersistentDiskProvisioner) (string, error) {
+	name := c.options.PVC.Spec.DataSource.Name
+	if name == "" {
+		return "", fmt.Errorf("snapshot dataSource of claim %s/%s has no name", c.options.PVC.Namespace, c.options.PVC.Name)
+	}
+	return name, nil
+}
+
+// parseNodeAffinityLabels parses the "node-affinity-labels" provisioner
+// parameter, a comma separated list of key=value pairs. A key may appear more
+// than once to allow several values; the resulting requirements are returned
+// in sorted key order so the generated PV is deterministic.
+func parseNodeAffinityLabels(value string) ([]v1.NodeSelectorRequirement, error) {
+	valuesByKey := map[string][]string{}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, fmt.Errorf("invalid node-affinity-labels entry %q, expected key=value", pair)
+		}
+		valuesByKey[parts[0]] = append(valuesByKey[parts[0]], parts[1])
+	}
+	keys := make([]string, 0, len(valuesByKey))
+	for k := range valuesByKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	requirements := make([]v1.NodeSelectorRequirement, 0, len(keys))
+	for _, k := range keys {
+		requirements = append(requirements, v1.NodeSelectorRequirement{Key: k, Operator: v1.NodeSelectorOpIn, Values: valuesByKey[k]})
+	}
+	return requirements, nil
+}
+
+// nodeAffinityRequirementsFromParameters returns the extra node affinity
+// requirements requested via the "node-affinity-labels" parameter, if any.
+// These let a StorageClass constrain pods to nodes that are compatible with
+// the zone's machine families (e.g. GPU zones), beyond the zone itself.
+func nodeAffinityRequirementsFromParameters(parameters map[string]string) ([]v1.NodeSelectorRequirement, error) {
+	for k, v := range parameters {
+		if strings.ToLower(k) == "node-affinity-labels" {
+			return parseNodeAffinityLabels(v)
+		}
+	}
+	return nil, nil
+}
+
+// multiWriterRequested returns whether the provisioner parameters request a
+// multi-writer disk. Invalid values are rejected during volume creation.
+func multiWriterRequested(parameters map[string]string) bool {
+	for k, v := range parameters {
+		if strings.ToLower(k) == "multiwriter" {
+			enabled, err := strconv.ParseBool(v)
+			return err == nil && enabled
+		}
+	}
+	return false
+}
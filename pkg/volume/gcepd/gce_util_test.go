@@ -16,7 +16,13 @@ limitations under the License.
 
 package gcepd
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
 
 func TestParseScsiSerial(t *testing.T) {
 	cases := []struct {
@@ -60,3 +66,139 @@ func TestParseScsiSerial(t *testing.T) {
 		}
 	}
 }
+
+func TestParseNodeAffinityLabels(t *testing.T) {
+	cases := []struct {
+		name      string
+		value     string
+		expectErr bool
+		numReqs   int
+	}{
+		{
+			name:    "single pair",
+			value:   "cloud.google.com/machine-family=a2",
+			numReqs: 1,
+		},
+		{
+			name:    "repeated key collects values",
+			value:   "family=a2,family=a3,gpu=true",
+			numReqs: 2,
+		},
+		{
+			name:      "missing value",
+			value:     "family=",
+			expectErr: true,
+		},
+		{
+			name:      "missing separator",
+			value:     "family",
+			expectErr: true,
+		},
+	}
+	for _, tc := range cases {
+		requirements, err := parseNodeAffinityLabels(tc.value)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got requirements %v", tc.name, requirements)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if len(requirements) != tc.numReqs {
+			t.Errorf("%s: expected %d requirements, got %v", tc.name, tc.numReqs, requirements)
+		}
+	}
+}
+
+func TestFilterTuningOptions(t *testing.T) {
+	cases := []struct {
+		name            string
+		options         []string
+		expectErr       bool
+		expectTuning    map[string]string
+		expectRemaining []string
+	}{
+		{
+			name:            "no tuning options",
+			options:         []string{"ro", "noatime"},
+			expectTuning:    map[string]string{},
+			expectRemaining: []string{"ro", "noatime"},
+		},
+		{
+			name:            "tuning options stripped",
+			options:         []string{"noatime", "read_ahead_kb=4096", "scheduler=deadline"},
+			expectTuning:    map[string]string{"read_ahead_kb": "4096", "scheduler": "deadline"},
+			expectRemaining: []string{"noatime"},
+		},
+		{
+			name:            "unrelated key=value options kept",
+			options:         []string{"data=ordered"},
+			expectTuning:    map[string]string{},
+			expectRemaining: []string{"data=ordered"},
+		},
+		{
+			name:      "read_ahead_kb not a number",
+			options:   []string{"read_ahead_kb=lots"},
+			expectErr: true,
+		},
+		{
+			name:      "read_ahead_kb out of range",
+			options:   []string{"read_ahead_kb=10000000"},
+			expectErr: true,
+		},
+		{
+			name:      "unknown scheduler",
+			options:   []string{"scheduler=fifo"},
+			expectErr: true,
+		},
+	}
+	for _, tc := range cases {
+		tuning, remaining, err := filterTuningOptions(tc.options)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got tuning %v", tc.name, tuning)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(tuning, tc.expectTuning) {
+			t.Errorf("%s: expected tuning %v, got %v", tc.name, tc.expectTuning, tuning)
+		}
+		if !reflect.DeepEqual(remaining, tc.expectRemaining) {
+			t.Errorf("%s: expected remaining options %v, got %v", tc.name, tc.expectRemaining, remaining)
+		}
+	}
+}
+
+func TestWriteDeviceTuning(t *testing.T) {
+	queueDir, err := ioutil.TempDir("", "gcepd-tuning")
+	if err != nil {
+		t.Fatalf("can't make a temp dir: %v", err)
+	}
+	defer os.RemoveAll(queueDir)
+
+	tuning := map[string]string{"read_ahead_kb": "4096", "scheduler": "deadline"}
+	if err := writeDeviceTuning(queueDir, tuning); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for setting, expected := range tuning {
+		content, err := ioutil.ReadFile(filepath.Join(queueDir, setting))
+		if err != nil {
+			t.Errorf("failed to read %s back: %v", setting, err)
+			continue
+		}
+		if string(content) != expected {
+			t.Errorf("expected %s to contain %q, got %q", setting, expected, string(content))
+		}
+	}
+
+	if err := writeDeviceTuning(filepath.Join(queueDir, "missing"), tuning); err == nil {
+		t.Errorf("expected error writing to a missing queue directory")
+	}
+}
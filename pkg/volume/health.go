@@ -0,0 +1,32 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+// VolumeHealthChecker is an optional interface a Mounter can implement when
+// its plugin can assess the health of a mount while the volume is in use.
+// Corrupted mounts, stale network file handles and unexpected read-only
+// remounts are otherwise only discovered during unmount; a health checker
+// allows them to be detected continuously and surfaced as events on the pod
+// and conditions on the claim.
+type VolumeHealthChecker interface {
+	// CheckVolumeHealth probes the mounted volume and returns an error
+	// describing the problem when the mount is no longer usable. A nil
+	// error means the volume was healthy at the time of the check. The
+	// probe must be cheap enough to run periodically for every mounted
+	// volume on a node.
+	CheckVolumeHealth() error
+}
@@ -225,6 +225,26 @@ type ExpandableVolumePlugin interface {
 	RequiresFSResize() bool
 }
 
+// FSResizableVolumePlugin is an extension of ExpandableVolumePlugin and is used for volumes
+// that require extra node-side steps for an expansion to complete, e.g. growing the file
+// system of a mounted, in-use volume without a remount.
+type FSResizableVolumePlugin interface {
+	ExpandableVolumePlugin
+	ExpandFS(spec *Spec, devicePath, deviceMountPath string, newSize, oldSize resource.Quantity) error
+}
+
+// BlockExpandableVolumePlugin is an extension of ExpandableVolumePlugin for
+// plugins whose raw block volumes are fully usable at their new size as soon
+// as ExpandVolumeDevice succeeds. For such volumes the expand controller can
+// mark the resize complete directly instead of waiting for a node-side
+// resize step.
+type BlockExpandableVolumePlugin interface {
+	ExpandableVolumePlugin
+	// RequiresBlockResize reports whether a block-mode volume of this plugin
+	// still needs node involvement after the controller expansion is done.
+	RequiresBlockResize() bool
+}
+
 // VolumePluginWithAttachLimits is an extended interface of VolumePlugin that restricts number of
 // volumes that can be attached to a node.
 type VolumePluginWithAttachLimits interface {
@@ -819,6 +839,32 @@ func (pm *VolumePluginMgr) FindExpandablePluginByName(name string) (ExpandableVo
 	return nil, nil
 }
 
+// FindFSResizablePluginBySpec fetches a persistent volume plugin by spec.
+func (pm *VolumePluginMgr) FindFSResizablePluginBySpec(spec *Spec) (FSResizableVolumePlugin, error) {
+	volumePlugin, err := pm.FindPluginBySpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if fsResizablePlugin, ok := volumePlugin.(FSResizableVolumePlugin); ok {
+		return fsResizablePlugin, nil
+	}
+	return nil, nil
+}
+
+// FindFSResizablePluginByName fetches a persistent volume plugin by name.
+func (pm *VolumePluginMgr) FindFSResizablePluginByName(name string) (FSResizableVolumePlugin, error) {
+	volumePlugin, err := pm.FindPluginByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if fsResizablePlugin, ok := volumePlugin.(FSResizableVolumePlugin); ok {
+		return fsResizablePlugin, nil
+	}
+	return nil, nil
+}
+
 // FindMapperPluginBySpec fetches a block volume plugin by spec.
 func (pm *VolumePluginMgr) FindMapperPluginBySpec(spec *Spec) (BlockVolumePlugin, error) {
 	volumePlugin, err := pm.FindPluginBySpec(spec)
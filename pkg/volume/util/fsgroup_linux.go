@@ -0,0 +1,148 @@
+// +build linux
+
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/volume"
+)
+
+const (
+	fsGroupRWMask = os.FileMode(0660)
+	fsGroupROMask = os.FileMode(0440)
+
+	// fsGroupOwnershipWorkers is how many goroutines apply ownership
+	// concurrently when the volume does need a recursive change.
+	fsGroupOwnershipWorkers = 4
+)
+
+// SetVolumeOwnershipOnRootMismatch modifies the given volume to be owned by
+// fsGroup with SetGid, like volume.SetVolumeOwnership, with two differences
+// aimed at large block volumes: the recursive walk is skipped entirely when
+// the volume root already carries the expected group ownership and
+// permissions, and when a change is needed it is applied by a small pool of
+// workers instead of a single sequential pass. If fsGroup is nil nothing is
+// done.
+func SetVolumeOwnershipOnRootMismatch(mounter volume.Mounter, fsGroup *int64) error {
+	if fsGroup == nil {
+		return nil
+	}
+
+	rootPath := mounter.GetPath()
+	readOnly := mounter.GetAttributes().ReadOnly
+	if rootOwnershipMatches(rootPath, *fsGroup, readOnly) {
+		glog.V(4).Infof("Skipping recursive ownership change of %v: root already owned by group %d", rootPath, *fsGroup)
+		return nil
+	}
+
+	type walkItem struct {
+		path string
+		info os.FileInfo
+	}
+	items := []walkItem{}
+	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		items = append(items, walkItem{path: path, info: info})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	itemCh := make(chan walkItem)
+	var wg sync.WaitGroup
+	for i := 0; i < fsGroupOwnershipWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range itemCh {
+				applyOwnership(item.path, item.info, *fsGroup, readOnly)
+			}
+		}()
+	}
+	for _, item := range items {
+		itemCh <- item
+	}
+	close(itemCh)
+	wg.Wait()
+	return nil
+}
+
+// rootOwnershipMatches reports whether the volume root already has the
+// expected group, permission mask and SetGid bit, in which case the contents
+// are assumed to be owned correctly from a previous mount.
+func rootOwnershipMatches(rootPath string, fsGroup int64, readOnly bool) bool {
+	info, err := os.Lstat(rootPath)
+	if err != nil {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat == nil {
+		return false
+	}
+	if int64(stat.Gid) != fsGroup {
+		return false
+	}
+
+	mask := fsGroupRWMask
+	if readOnly {
+		mask = fsGroupROMask
+	}
+	mask |= os.ModeSetgid
+	return info.Mode()&mask == mask
+}
+
+// applyOwnership chowns and chmods a single entry, mirroring the per-file
+// behavior of volume.SetVolumeOwnership. Failures are logged, not returned.
+func applyOwnership(path string, info os.FileInfo, fsGroup int64, readOnly bool) {
+	// chown and chmod pass through to the underlying file for symlinks;
+	// skip them so the mode of the target is not clobbered, matching
+	// volume.SetVolumeOwnership.
+	if info.Mode()&os.ModeSymlink != 0 {
+		return
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat == nil {
+		return
+	}
+
+	if err := os.Chown(path, int(stat.Uid), int(fsGroup)); err != nil {
+		glog.Errorf("Chown failed on %v: %v", path, err)
+	}
+
+	mask := fsGroupRWMask
+	if readOnly {
+		mask = fsGroupROMask
+	}
+	if info.IsDir() {
+		mask |= os.ModeSetgid
+	}
+	if err := os.Chmod(path, info.Mode()|mask); err != nil {
+		glog.Errorf("Chmod failed on %v: %v", path, err)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/util/mount"
+)
+
+// EncodeGlobalMountPath returns the global mount path of a volume under the
+// given plugin directory. URL-ish volume IDs ("aws://zone/vol-123") are
+// flattened into filesystem-friendly relative paths ("aws/zone/vol-123");
+// plain IDs are used as-is. Plugins should use this together with
+// DecodeGlobalMountPath instead of hand-rolling the mapping, so the path
+// format cannot drift between them.
+func EncodeGlobalMountPath(pluginDir, volumeID string) string {
+	name := strings.Replace(volumeID, "://", "/", -1)
+	return filepath.Join(pluginDir, mount.MountsInGlobalPDPath, name)
+}
+
+// DecodeGlobalMountPath reverses EncodeGlobalMountPath: it extracts the volume
+// ID from a global mount path, restoring the "://" separator for IDs starting
+// with one of the given URL schemes (e.g. "aws"). An error is returned when
+// the path does not lie under the plugin's global mount directory.
+func DecodeGlobalMountPath(pluginDir, globalPath string, schemes ...string) (string, error) {
+	basePath := filepath.Join(pluginDir, mount.MountsInGlobalPDPath)
+	rel, err := filepath.Rel(basePath, globalPath)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("unexpected mount path: %s", globalPath)
+	}
+	for _, scheme := range schemes {
+		if strings.HasPrefix(rel, scheme+"/") {
+			return scheme + "://" + strings.TrimPrefix(rel, scheme+"/"), nil
+		}
+	}
+	return rel, nil
+}
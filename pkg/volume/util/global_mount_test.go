@@ -0,0 +1,69 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+)
+
+func TestEncodeDecodeGlobalMountPath(t *testing.T) {
+	pluginDir := "/var/lib/kubelet/plugins/kubernetes.io~aws-ebs"
+
+	tests := []struct {
+		name         string
+		volumeID     string
+		schemes      []string
+		expectedPath string
+	}{
+		{
+			name:         "plain volume ID",
+			volumeID:     "vol-0123456789abcdef0",
+			expectedPath: pluginDir + "/mounts/vol-0123456789abcdef0",
+		},
+		{
+			name:         "URL-ish volume ID",
+			volumeID:     "aws://us-east-1a/vol-0123456789abcdef0",
+			schemes:      []string{"aws"},
+			expectedPath: pluginDir + "/mounts/aws/us-east-1a/vol-0123456789abcdef0",
+		},
+	}
+	for _, test := range tests {
+		path := EncodeGlobalMountPath(pluginDir, test.volumeID)
+		if path != test.expectedPath {
+			t.Errorf("%s: expected path %q, got %q", test.name, test.expectedPath, path)
+		}
+		volumeID, err := DecodeGlobalMountPath(pluginDir, path, test.schemes...)
+		if err != nil {
+			t.Errorf("%s: unexpected decode error: %v", test.name, err)
+		}
+		if volumeID != test.volumeID {
+			t.Errorf("%s: expected volume ID %q, got %q", test.name, test.volumeID, volumeID)
+		}
+	}
+}
+
+func TestDecodeGlobalMountPathRejectsForeignPaths(t *testing.T) {
+	pluginDir := "/var/lib/kubelet/plugins/kubernetes.io~gce-pd"
+	for _, path := range []string{
+		"/var/lib/kubelet/plugins/other/mounts/disk",
+		"/var/lib/kubelet/plugins/kubernetes.io~gce-pd/mounts/../../escape",
+	} {
+		if volumeID, err := DecodeGlobalMountPath(pluginDir, path); err == nil {
+			t.Errorf("expected an error decoding %q, got volume ID %q", path, volumeID)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/util/mount"
+	"k8s.io/kubernetes/pkg/volume"
+)
+
+const (
+	// PVCConditionVolumeHealthy is the claim condition maintained by the
+	// volume health monitor. It is True while the mounted volume passes its
+	// health checks and False with a describing message when it does not.
+	PVCConditionVolumeHealthy v1.PersistentVolumeClaimConditionType = "VolumeHealthy"
+
+	// VolumeUnhealthyReason is the reason of the pod event and claim
+	// condition recorded for a failed volume health check.
+	VolumeUnhealthyReason = "VolumeUnhealthy"
+)
+
+// CheckVolumeHealth is the common probe behind plugin health checkers for
+// locally mounted block-backed volumes (awsebs, gcepd, azure_dd, ...). It
+// verifies that the path is still a usable mount point: present in the mount
+// table, readable, and not remounted read-only behind the user's back, which
+// the kernel does on some filesystem errors.
+func CheckVolumeHealth(mounter mount.Interface, path string, readOnly bool) error {
+	pathExists, pathErr := PathExists(path)
+	if IsCorruptedMnt(pathErr) {
+		return fmt.Errorf("mount point %q is corrupted: %v", path, pathErr)
+	}
+	if pathErr != nil {
+		return fmt.Errorf("failed to check mount point %q: %v", path, pathErr)
+	}
+	if !pathExists {
+		return fmt.Errorf("mount point %q no longer exists", path)
+	}
+
+	notMnt, err := mounter.IsLikelyNotMountPoint(path)
+	if err != nil {
+		if IsCorruptedMnt(err) {
+			return fmt.Errorf("mount point %q is corrupted: %v", path, err)
+		}
+		return fmt.Errorf("failed to check mount point %q: %v", path, err)
+	}
+	if notMnt {
+		return fmt.Errorf("%q is no longer a mount point", path)
+	}
+
+	// Reading the directory catches stale network file handles the mount
+	// table still lists.
+	if _, err := ioutil.ReadDir(path); err != nil {
+		return fmt.Errorf("mount point %q is not readable: %v", path, err)
+	}
+
+	if !readOnly {
+		mountPoints, err := mounter.List()
+		if err != nil {
+			return fmt.Errorf("failed to list mount points: %v", err)
+		}
+		for _, mp := range mountPoints {
+			if mp.Path != path {
+				continue
+			}
+			for _, opt := range mp.Opts {
+				if opt == "ro" {
+					return fmt.Errorf("mount point %q was remounted read-only", path)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// VolumeHealthTarget ties a mounted volume to the objects its health is
+// reported on.
+type VolumeHealthTarget struct {
+	// Pod is the pod using the volume; health events are recorded on it.
+	Pod *v1.Pod
+	// VolumeName is the name of the volume within the pod spec.
+	VolumeName string
+	// ClaimName is the name of the PersistentVolumeClaim in the pod's
+	// namespace backing the volume. Empty for volumes not backed by a
+	// claim, in which case only pod events are recorded.
+	ClaimName string
+	// Checker probes the mounted volume.
+	Checker volume.VolumeHealthChecker
+}
+
+// VolumeHealthTargetLister returns the currently mounted volumes whose
+// plugins support health checking. It is called once per monitor pass.
+type VolumeHealthTargetLister func() []VolumeHealthTarget
+
+// VolumeHealthMonitor periodically probes mounted volumes through their
+// plugin's VolumeHealthChecker and publishes the outcome as events on the
+// pod and a VolumeHealthy condition on the backing claim.
+type VolumeHealthMonitor struct {
+	kubeClient clientset.Interface
+	recorder   record.EventRecorder
+	lister     VolumeHealthTargetLister
+	interval   time.Duration
+}
+
+// NewVolumeHealthMonitor returns a monitor that probes the volumes returned
+// by lister every interval once Run is called.
+func NewVolumeHealthMonitor(kubeClient clientset.Interface, recorder record.EventRecorder, lister VolumeHealthTargetLister, interval time.Duration) *VolumeHealthMonitor {
+	return &VolumeHealthMonitor{
+		kubeClient: kubeClient,
+		recorder:   recorder,
+		lister:     lister,
+		interval:   interval,
+	}
+}
+
+// Run probes all listed volumes every interval until stopCh is closed.
+func (m *VolumeHealthMonitor) Run(stopCh <-chan struct{}) {
+	wait.Until(m.checkAll, m.interval, stopCh)
+}
+
+func (m *VolumeHealthMonitor) checkAll() {
+	for _, target := range m.lister() {
+		m.check(target)
+	}
+}
+
+func (m *VolumeHealthMonitor) check(target VolumeHealthTarget) {
+	healthErr := target.Checker.CheckVolumeHealth()
+	if healthErr != nil {
+		glog.V(2).Infof("Volume %q of pod %s/%s failed its health check: %v", target.VolumeName, target.Pod.Namespace, target.Pod.Name, healthErr)
+		if m.recorder != nil {
+			m.recorder.Eventf(target.Pod, v1.EventTypeWarning, VolumeUnhealthyReason, "Volume %q is unhealthy: %v", target.VolumeName, healthErr)
+		}
+	}
+	if err := m.updateClaimCondition(target, healthErr); err != nil {
+		glog.Warningf("Failed to update %s condition of claim %s/%s: %v", PVCConditionVolumeHealthy, target.Pod.Namespace, target.ClaimName, err)
+	}
+}
+
+// updateClaimCondition keeps the VolumeHealthy condition of the backing claim
+// in sync with the latest probe result. The claim is only written when the
+// condition actually changes.
+func (m *VolumeHealthMonitor) updateClaimCondition(target VolumeHealthTarget, healthErr error) error {
+	if m.kubeClient == nil || target.ClaimName == "" {
+		return nil
+	}
+
+	claim, err := m.kubeClient.CoreV1().PersistentVolumeClaims(target.Pod.Namespace).Get(target.ClaimName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	condition := v1.PersistentVolumeClaimCondition{
+		Type:               PVCConditionVolumeHealthy,
+		Status:             v1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+	}
+	if healthErr != nil {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = VolumeUnhealthyReason
+		condition.Message = healthErr.Error()
+	}
+
+	replaced := false
+	for i := range claim.Status.Conditions {
+		existing := &claim.Status.Conditions[i]
+		if existing.Type != PVCConditionVolumeHealthy {
+			continue
+		}
+		if existing.Status == condition.Status && existing.Message == condition.Message {
+			return nil
+		}
+		claim.Status.Conditions[i] = condition
+		replaced = true
+		break
+	}
+	if !replaced {
+		claim.Status.Conditions = append(claim.Status.Conditions, condition)
+	}
+
+	_, err = m.kubeClient.CoreV1().PersistentVolumeClaims(claim.Namespace).UpdateStatus(claim)
+	return err
+}
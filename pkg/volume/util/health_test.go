@@ -0,0 +1,136 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/util/mount"
+)
+
+func TestCheckVolumeHealth(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "health")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		desc        string
+		mountPoints []mount.MountPoint
+		readOnly    bool
+		expectError bool
+	}{
+		{
+			desc:        "healthy read-write mount",
+			mountPoints: []mount.MountPoint{{Path: tmpDir, Opts: []string{"rw"}}},
+			expectError: false,
+		},
+		{
+			desc:        "path is no longer a mount point",
+			mountPoints: nil,
+			expectError: true,
+		},
+		{
+			desc:        "read-write volume remounted read-only",
+			mountPoints: []mount.MountPoint{{Path: tmpDir, Opts: []string{"ro"}}},
+			expectError: true,
+		},
+		{
+			desc:        "read-only volume mounted read-only is healthy",
+			mountPoints: []mount.MountPoint{{Path: tmpDir, Opts: []string{"ro"}}},
+			readOnly:    true,
+			expectError: false,
+		},
+	}
+
+	for _, test := range tests {
+		mounter := &mount.FakeMounter{MountPoints: test.mountPoints}
+		err := CheckVolumeHealth(mounter, tmpDir, test.readOnly)
+		if test.expectError && err == nil {
+			t.Errorf("test %q: expected an error", test.desc)
+		}
+		if !test.expectError && err != nil {
+			t.Errorf("test %q: unexpected error: %v", test.desc, err)
+		}
+	}
+}
+
+type fakeHealthChecker struct {
+	err error
+}
+
+func (f *fakeHealthChecker) CheckVolumeHealth() error {
+	return f.err
+}
+
+func TestVolumeHealthMonitorClaimCondition(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns"}}
+	claim := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "claim", Namespace: "ns"}}
+	client := fake.NewSimpleClientset(claim)
+	recorder := record.NewFakeRecorder(10)
+	monitor := NewVolumeHealthMonitor(client, recorder, nil, time.Minute)
+
+	target := VolumeHealthTarget{
+		Pod:        pod,
+		VolumeName: "data",
+		ClaimName:  claim.Name,
+		Checker:    &fakeHealthChecker{err: errors.New("stale file handle")},
+	}
+	monitor.check(target)
+
+	updated, err := client.CoreV1().PersistentVolumeClaims("ns").Get(claim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updated.Status.Conditions) != 1 {
+		t.Fatalf("expected one claim condition, got %v", updated.Status.Conditions)
+	}
+	condition := updated.Status.Conditions[0]
+	if condition.Type != PVCConditionVolumeHealthy || condition.Status != v1.ConditionFalse {
+		t.Errorf("unexpected condition for unhealthy volume: %+v", condition)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, VolumeUnhealthyReason) {
+			t.Errorf("unexpected event for unhealthy volume: %s", event)
+		}
+	default:
+		t.Errorf("expected a pod event for the unhealthy volume")
+	}
+
+	// A healthy probe flips the condition back.
+	target.Checker = &fakeHealthChecker{}
+	monitor.check(target)
+
+	updated, err = client.CoreV1().PersistentVolumeClaims("ns").Get(claim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updated.Status.Conditions) != 1 || updated.Status.Conditions[0].Status != v1.ConditionTrue {
+		t.Errorf("expected the condition to turn true, got %v", updated.Status.Conditions)
+	}
+}
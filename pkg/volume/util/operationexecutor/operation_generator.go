@@ -632,22 +632,35 @@ func (og *operationGenerator) resizeFileSystem(volumeToMount VolumeToMount, devi
 				return nil, nil
 			}
 
-			diskFormatter := &mount.SafeFormatAndMount{
-				Interface: mounter,
-				Exec:      og.volumePluginMgr.Host.GetExec(expandableVolumePlugin.GetPluginName()),
-			}
-
-			resizer := resizefs.NewResizeFs(diskFormatter)
-			resizeStatus, resizeErr := resizer.Resize(devicePath, deviceMountPath)
-
-			if resizeErr != nil {
-				return volumeToMount.GenerateError("MountVolume.resizeFileSystem failed", resizeErr)
-			}
-
-			if resizeStatus {
+			// Plugins implementing FSResizableVolumePlugin own the node-side
+			// expansion themselves, e.g. to grow the file system of an in-use
+			// volume online.
+			fsResizablePlugin, _ := og.volumePluginMgr.FindFSResizablePluginBySpec(volumeToMount.VolumeSpec)
+			if fsResizablePlugin != nil {
+				if resizeErr := fsResizablePlugin.ExpandFS(volumeToMount.VolumeSpec, devicePath, deviceMountPath, pvSpecCap, pvcStatusCap); resizeErr != nil {
+					return volumeToMount.GenerateError("MountVolume.resizeFileSystem failed", resizeErr)
+				}
 				simpleMsg, detailedMsg := volumeToMount.GenerateMsg("MountVolume.resizeFileSystem succeeded", "")
 				og.recorder.Eventf(volumeToMount.Pod, v1.EventTypeNormal, kevents.FileSystemResizeSuccess, simpleMsg)
 				glog.Infof(detailedMsg)
+			} else {
+				diskFormatter := &mount.SafeFormatAndMount{
+					Interface: mounter,
+					Exec:      og.volumePluginMgr.Host.GetExec(expandableVolumePlugin.GetPluginName()),
+				}
+
+				resizer := resizefs.NewResizeFs(diskFormatter)
+				resizeStatus, resizeErr := resizer.Resize(devicePath, deviceMountPath)
+
+				if resizeErr != nil {
+					return volumeToMount.GenerateError("MountVolume.resizeFileSystem failed", resizeErr)
+				}
+
+				if resizeStatus {
+					simpleMsg, detailedMsg := volumeToMount.GenerateMsg("MountVolume.resizeFileSystem succeeded", "")
+					og.recorder.Eventf(volumeToMount.Pod, v1.EventTypeNormal, kevents.FileSystemResizeSuccess, simpleMsg)
+					glog.Infof(detailedMsg)
+				}
 			}
 
 			// File system resize succeeded, now update the PVC's Capacity to match the PV's
@@ -1301,10 +1314,22 @@ func (og *operationGenerator) GenerateExpandVolumeFunc(
 			glog.Infof("ExpandVolume.UpdatePV succeeded for volume %s", pvcWithResizeRequest.QualifiedName())
 		}
 
+		// A raw block volume has no file system to grow, so a plugin can
+		// declare the volume fully expanded once the cloud provider resize
+		// succeeds.
+		fsResizeRequired := volumePlugin.RequiresFSResize()
+		if fsResizeRequired {
+			if fsVolume, modeErr := util.CheckVolumeModeFilesystem(volumeSpec); modeErr == nil && !fsVolume {
+				if blockPlugin, ok := volumePlugin.(volume.BlockExpandableVolumePlugin); ok && !blockPlugin.RequiresBlockResize() {
+					fsResizeRequired = false
+				}
+			}
+		}
+
 		// No Cloudprovider resize needed, lets mark resizing as done
 		// Rest of the volume expand controller code will assume PVC as *not* resized until pvc.Status.Size
 		// reflects user requested size.
-		if !volumePlugin.RequiresFSResize() {
+		if !fsResizeRequired {
 			glog.V(4).Infof("Controller resizing done for PVC %s", pvcWithResizeRequest.QualifiedName())
 			err := resizeMap.MarkAsResized(pvcWithResizeRequest, newSize)
 
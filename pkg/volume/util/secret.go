@@ -0,0 +1,98 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// secretCacheTTL bounds how stale a cached secret may be. Rotated
+	// credentials are picked up after at most this long.
+	secretCacheTTL = 1 * time.Minute
+	// secretCacheSize bounds how many secrets are kept; the least recently
+	// used entries are evicted first.
+	secretCacheSize = 1000
+)
+
+// SecretProvider fetches secrets for volume plugins. Implementations may
+// serve from a cache, so callers must treat the returned secret as
+// read-only.
+type SecretProvider interface {
+	GetSecret(namespace, name string) (*v1.Secret, error)
+}
+
+// cachedSecretProvider serves secret lookups from a TTL cache in front of
+// the API server, so mass pod creation does not issue one GET per mount.
+type cachedSecretProvider struct {
+	kubeClient clientset.Interface
+	cache      *utilcache.LRUExpireCache
+	ttl        time.Duration
+}
+
+// NewCachedSecretProvider returns a SecretProvider that caches fetched
+// secrets for a short TTL. Lookup errors are not cached, so a failed mount
+// retries against the API server.
+func NewCachedSecretProvider(kubeClient clientset.Interface) SecretProvider {
+	return &cachedSecretProvider{
+		kubeClient: kubeClient,
+		cache:      utilcache.NewLRUExpireCache(secretCacheSize),
+		ttl:        secretCacheTTL,
+	}
+}
+
+func (p *cachedSecretProvider) GetSecret(namespace, name string) (*v1.Secret, error) {
+	key := namespace + "/" + name
+	if obj, ok := p.cache.Get(key); ok {
+		return obj.(*v1.Secret), nil
+	}
+	if p.kubeClient == nil {
+		return nil, fmt.Errorf("cannot get kube client")
+	}
+	secret, err := p.kubeClient.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	p.cache.Add(key, secret, p.ttl)
+	return secret, nil
+}
+
+var (
+	sharedSecretProvidersLock sync.Mutex
+	sharedSecretProviders     = map[clientset.Interface]SecretProvider{}
+)
+
+// SharedSecretProvider returns a process-wide cached SecretProvider for the
+// given client, so that plugins sharing a kube client also share one secret
+// cache.
+func SharedSecretProvider(kubeClient clientset.Interface) SecretProvider {
+	sharedSecretProvidersLock.Lock()
+	defer sharedSecretProvidersLock.Unlock()
+	if provider, ok := sharedSecretProviders[kubeClient]; ok {
+		return provider
+	}
+	provider := NewCachedSecretProvider(kubeClient)
+	sharedSecretProviders[kubeClient] = provider
+	return provider
+}
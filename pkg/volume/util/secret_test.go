@@ -0,0 +1,78 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCachedSecretProvider(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "nsA", Name: "secretA"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	})
+	provider := NewCachedSecretProvider(client)
+
+	secret, err := provider.GetSecret("nsA", "secretA")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(secret.Data["key"]) != "value" {
+		t.Errorf("unexpected secret data: %v", secret.Data)
+	}
+	if _, err := provider.GetSecret("nsA", "secretA"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actions := client.Actions(); len(actions) != 1 {
+		t.Errorf("expected exactly one API call for repeated lookups, got %d: %v", len(actions), actions)
+	}
+
+	// Errors must not be cached, so a secret created after a failed lookup
+	// is found on the next attempt.
+	if _, err := provider.GetSecret("nsA", "missing"); err == nil {
+		t.Errorf("expected error for missing secret")
+	}
+	if _, err := client.CoreV1().Secrets("nsA").Create(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "nsA", Name: "missing"},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := provider.GetSecret("nsA", "missing"); err != nil {
+		t.Errorf("expected lookup to succeed after the secret was created: %v", err)
+	}
+}
+
+func TestCachedSecretProviderNoClient(t *testing.T) {
+	provider := NewCachedSecretProvider(nil)
+	if _, err := provider.GetSecret("nsA", "secretA"); err == nil {
+		t.Errorf("expected error without a kube client")
+	}
+}
+
+func TestSharedSecretProvider(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	if SharedSecretProvider(client) != SharedSecretProvider(client) {
+		t.Errorf("expected the same provider for the same client")
+	}
+	if SharedSecretProvider(client) == SharedSecretProvider(fake.NewSimpleClientset()) {
+		t.Errorf("expected distinct providers for distinct clients")
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/util/mount"
+)
+
+// SafeResolveSubPath joins subPath to baseDir, resolving any symlinks along
+// the way and verifying that the result stays under baseDir. Trailing
+// components that do not exist yet are appended unresolved so the returned
+// path can still be created by the caller. On Linux the resolved path is
+// additionally re-verified with openat-style checks to guard against a
+// component being swapped for a symlink after resolution.
+func SafeResolveSubPath(baseDir, subPath string) (string, error) {
+	if filepath.IsAbs(subPath) {
+		return "", fmt.Errorf("subpath %q must be a relative path", subPath)
+	}
+	for _, item := range strings.Split(filepath.Clean(subPath), string(filepath.Separator)) {
+		if item == ".." {
+			return "", fmt.Errorf("subpath %q must not contain '..'", subPath)
+		}
+	}
+
+	resolvedBase, err := filepath.EvalSymlinks(baseDir)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve volume root %q: %v", baseDir, err)
+	}
+
+	// Resolve the subpath component by component so that a symlink anywhere
+	// in the chain is checked against the volume root.
+	resolved := resolvedBase
+	segments := strings.Split(filepath.Clean(subPath), string(filepath.Separator))
+	for i, segment := range segments {
+		candidate := filepath.Join(resolved, segment)
+		info, err := os.Lstat(candidate)
+		if os.IsNotExist(err) {
+			// The rest of the subpath does not exist yet and cannot contain
+			// symlinks, append it as-is.
+			resolved = filepath.Join(append([]string{resolved}, segments[i:]...)...)
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot inspect subpath component %q: %v", candidate, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			resolved, err = filepath.EvalSymlinks(candidate)
+			if err != nil {
+				return "", fmt.Errorf("cannot resolve subpath component %q: %v", candidate, err)
+			}
+		} else {
+			resolved = candidate
+		}
+		if !mount.PathWithinBase(resolved, resolvedBase) {
+			return "", fmt.Errorf("subpath %q escapes the volume root %q", subPath, baseDir)
+		}
+	}
+
+	if !mount.PathWithinBase(resolved, resolvedBase) {
+		return "", fmt.Errorf("subpath %q escapes the volume root %q", subPath, baseDir)
+	}
+
+	if err := verifyResolvedSubPath(resolvedBase, resolved); err != nil {
+		return "", err
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,75 @@
+// +build linux
+
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/golang/glog"
+)
+
+// verifyResolvedSubPath walks the already-resolved path from base with
+// Openat(O_NOFOLLOW) to make sure none of the existing components has been
+// replaced by a symlink after resolution. Components that do not exist yet
+// terminate the walk without error.
+func verifyResolvedSubPath(base, resolvedPath string) error {
+	rel, err := filepath.Rel(base, resolvedPath)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+
+	fd, err := syscall.Open(base, syscall.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return fmt.Errorf("cannot open volume root %q: %v", base, err)
+	}
+
+	currentPath := base
+	for _, segment := range strings.Split(rel, string(filepath.Separator)) {
+		currentPath = filepath.Join(currentPath, segment)
+		childFD, err := syscall.Openat(fd, segment, syscall.O_RDONLY|syscall.O_NOFOLLOW, 0)
+		if err != nil {
+			closeSubPathFD(fd, currentPath)
+			if err == syscall.ENOENT {
+				// Not created yet, nothing left to verify.
+				return nil
+			}
+			if err == syscall.ELOOP {
+				return fmt.Errorf("subpath component %q is a symlink", currentPath)
+			}
+			return fmt.Errorf("cannot verify subpath component %q: %v", currentPath, err)
+		}
+		closeSubPathFD(fd, currentPath)
+		fd = childFD
+	}
+	closeSubPathFD(fd, currentPath)
+
+	return nil
+}
+
+func closeSubPathFD(fd int, pathname string) {
+	if err := syscall.Close(fd); err != nil {
+		glog.V(4).Infof("Closing FD %v failed while verifying subpath %q: %v", fd, pathname, err)
+	}
+}
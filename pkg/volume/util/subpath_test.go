@@ -0,0 +1,113 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSafeResolveSubPath(t *testing.T) {
+	base, err := ioutil.TempDir("", "safe-resolve-subpath")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(base)
+
+	if err := os.MkdirAll(filepath.Join(base, "dir1", "dir2"), 0755); err != nil {
+		t.Fatalf("failed to create test dirs: %v", err)
+	}
+	outside, err := ioutil.TempDir("", "safe-resolve-subpath-outside")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(outside)
+
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink(filepath.Join(base, "dir1"), filepath.Join(base, "linkinside")); err != nil {
+			t.Fatalf("failed to create symlink: %v", err)
+		}
+		if err := os.Symlink(outside, filepath.Join(base, "linkoutside")); err != nil {
+			t.Fatalf("failed to create symlink: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		subPath     string
+		expectError bool
+		skipWindows bool
+	}{
+		{
+			name:    "simple existing subpath",
+			subPath: filepath.Join("dir1", "dir2"),
+		},
+		{
+			name:    "subpath that does not exist yet",
+			subPath: filepath.Join("dir1", "new", "deep"),
+		},
+		{
+			name:        "absolute subpath",
+			subPath:     base,
+			expectError: true,
+		},
+		{
+			name:        "subpath with backsteps",
+			subPath:     filepath.Join("dir1", "..", "..", "escape"),
+			expectError: true,
+		},
+		{
+			name:        "symlink inside the volume",
+			subPath:     filepath.Join("linkinside", "dir2"),
+			skipWindows: true,
+		},
+		{
+			name:        "symlink escaping the volume",
+			subPath:     filepath.Join("linkoutside", "file"),
+			expectError: true,
+			skipWindows: true,
+		},
+	}
+
+	for _, test := range tests {
+		if test.skipWindows && runtime.GOOS == "windows" {
+			continue
+		}
+		resolved, err := SafeResolveSubPath(base, test.subPath)
+		if test.expectError {
+			if err == nil {
+				t.Errorf("test %q: expected error, got path %q", test.name, resolved)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q: unexpected error: %v", test.name, err)
+			continue
+		}
+		resolvedBase, err := filepath.EvalSymlinks(base)
+		if err != nil {
+			t.Fatalf("failed to resolve base: %v", err)
+		}
+		if resolved != resolvedBase && !strings.HasPrefix(resolved, resolvedBase+string(filepath.Separator)) {
+			t.Errorf("test %q: resolved path %q is not under %q", test.name, resolved, resolvedBase)
+		}
+	}
+}
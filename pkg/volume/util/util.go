@@ -324,7 +324,13 @@ func LoadPodFromFile(filePath string) (*v1.Pod, error) {
 // SelectZoneForVolume is a wrapper around SelectZonesForVolume
 // to select a single zone for a volume based on parameters
 func SelectZoneForVolume(zoneParameterPresent, zonesParameterPresent bool, zoneParameter string, zonesParameter, zonesWithNodes sets.String, node *v1.Node, allowedTopologies []v1.TopologySelectorTerm, pvcName string) (string, error) {
-	zones, err := SelectZonesForVolume(zoneParameterPresent, zonesParameterPresent, zoneParameter, zonesParameter, zonesWithNodes, node, allowedTopologies, pvcName, 1)
+	return SelectZoneForVolumeWithSalt(zoneParameterPresent, zonesParameterPresent, zoneParameter, zonesParameter, zonesWithNodes, node, allowedTopologies, pvcName, "")
+}
+
+// SelectZoneForVolumeWithSalt is SelectZoneForVolume with an additional salt
+// mixed into the claim name hash; see ChooseZoneForVolumeWithSalt.
+func SelectZoneForVolumeWithSalt(zoneParameterPresent, zonesParameterPresent bool, zoneParameter string, zonesParameter, zonesWithNodes sets.String, node *v1.Node, allowedTopologies []v1.TopologySelectorTerm, pvcName, salt string) (string, error) {
+	zones, err := SelectZonesForVolumeWithSalt(zoneParameterPresent, zonesParameterPresent, zoneParameter, zonesParameter, zonesWithNodes, node, allowedTopologies, pvcName, salt, 1)
 	if err != nil {
 		return "", err
 	}
@@ -339,6 +345,12 @@ func SelectZoneForVolume(zoneParameterPresent, zonesParameterPresent bool, zoneP
 // node.zone, allowedTopologies, zone/zones parameters from storageclass,
 // zones with active nodes from the cluster. The number of zones = replicas.
 func SelectZonesForVolume(zoneParameterPresent, zonesParameterPresent bool, zoneParameter string, zonesParameter, zonesWithNodes sets.String, node *v1.Node, allowedTopologies []v1.TopologySelectorTerm, pvcName string, numReplicas uint32) (sets.String, error) {
+	return SelectZonesForVolumeWithSalt(zoneParameterPresent, zonesParameterPresent, zoneParameter, zonesParameter, zonesWithNodes, node, allowedTopologies, pvcName, "", numReplicas)
+}
+
+// SelectZonesForVolumeWithSalt is SelectZonesForVolume with an additional
+// salt mixed into the claim name hash; see ChooseZoneForVolumeWithSalt.
+func SelectZonesForVolumeWithSalt(zoneParameterPresent, zonesParameterPresent bool, zoneParameter string, zonesParameter, zonesWithNodes sets.String, node *v1.Node, allowedTopologies []v1.TopologySelectorTerm, pvcName, salt string, numReplicas uint32) (sets.String, error) {
 	if zoneParameterPresent && zonesParameterPresent {
 		return nil, fmt.Errorf("both zone and zones StorageClass parameters must not be used at the same time")
 	}
@@ -380,7 +392,7 @@ func SelectZonesForVolume(zoneParameterPresent, zonesParameterPresent bool, zone
 		}
 		// scheduler will guarantee if node != null above, zoneFromNode is member of allowedZones.
 		// so if zoneFromNode != "", we can safely assume it is part of allowedZones.
-		zones, err := chooseZonesForVolumeIncludingZone(allowedZones, pvcName, zoneFromNode, numReplicas)
+		zones, err := chooseZonesForVolumeIncludingZone(allowedZones, pvcName, salt, zoneFromNode, numReplicas)
 		if err != nil {
 			return nil, fmt.Errorf("cannot process zones in allowedTopologies: %v", err)
 		}
@@ -400,13 +412,13 @@ func SelectZonesForVolume(zoneParameterPresent, zonesParameterPresent bool, zone
 			return nil, fmt.Errorf("not enough zones found in zones parameter to provision a volume with %d replicas. Found %d zones, need %d zones", numReplicas, zonesParameter.Len(), numReplicas)
 		}
 		// directly choose from zones parameter; no zone from node need to be considered
-		return ChooseZonesForVolume(zonesParameter, pvcName, numReplicas), nil
+		return ChooseZonesForVolumeWithSalt(zonesParameter, pvcName, salt, numReplicas), nil
 	}
 
 	// pick zone from zones with nodes
 	if zonesWithNodes.Len() > 0 {
 		// If node != null (and thus zoneFromNode != ""), zoneFromNode will be member of zonesWithNodes
-		zones, err := chooseZonesForVolumeIncludingZone(zonesWithNodes, pvcName, zoneFromNode, numReplicas)
+		zones, err := chooseZonesForVolumeIncludingZone(zonesWithNodes, pvcName, salt, zoneFromNode, numReplicas)
 		if err != nil {
 			return nil, fmt.Errorf("cannot process zones where nodes exist in the cluster: %v", err)
 		}
@@ -587,6 +599,16 @@ func GetPath(mounter volume.Mounter) (string, error) {
 // This means that a StatefulSet's volumes (`claimname-statefulsetname-id`) will spread across available zones,
 // assuming the id values are consecutive.
 func ChooseZoneForVolume(zones sets.String, pvcName string) string {
+	return ChooseZoneForVolumeWithSalt(zones, pvcName, "")
+}
+
+// ChooseZoneForVolumeWithSalt is ChooseZoneForVolume with an additional salt
+// mixed into the name hash, typically the claim's namespace and UID. The
+// trailing-segment heuristic means differently-named StatefulSets with
+// identical suffixes collide onto the same zones; a salt separates them. An
+// empty salt keeps the legacy placement, so existing volumes are unaffected
+// unless a provisioner opts in.
+func ChooseZoneForVolumeWithSalt(zones sets.String, pvcName, salt string) string {
 	// No zones available, return empty string.
 	if zones.Len() == 0 {
 		return ""
@@ -594,7 +616,7 @@ func ChooseZoneForVolume(zones sets.String, pvcName string) string {
 
 	// We create the volume in a zone determined by the name
 	// Eventually the scheduler will coordinate placement into an available zone
-	hash, index := getPVCNameHashAndIndexOffset(pvcName)
+	hash, index := getPVCNameHashAndIndexOffset(pvcName, salt)
 
 	// Zones.List returns zones in a consistent order (sorted)
 	// We do have a potential failure case where volumes will not be properly spread,
@@ -614,7 +636,7 @@ func ChooseZoneForVolume(zones sets.String, pvcName string) string {
 // chooseZonesForVolumeIncludingZone is a wrapper around ChooseZonesForVolume that ensures zoneToInclude is chosen
 // zoneToInclude can either be empty in which case it is ignored. If non-empty, zoneToInclude is expected to be member of zones.
 // numReplicas is expected to be > 0 and <= zones.Len()
-func chooseZonesForVolumeIncludingZone(zones sets.String, pvcName, zoneToInclude string, numReplicas uint32) (sets.String, error) {
+func chooseZonesForVolumeIncludingZone(zones sets.String, pvcName, salt, zoneToInclude string, numReplicas uint32) (sets.String, error) {
 	if numReplicas == 0 {
 		return nil, fmt.Errorf("invalid number of replicas passed")
 	}
@@ -631,7 +653,7 @@ func chooseZonesForVolumeIncludingZone(zones sets.String, pvcName, zoneToInclude
 		zones.Delete(zoneToInclude)
 		numReplicas = numReplicas - 1
 	}
-	zonesChosen := ChooseZonesForVolume(zones, pvcName, numReplicas)
+	zonesChosen := ChooseZonesForVolumeWithSalt(zones, pvcName, salt, numReplicas)
 	if zoneToInclude != "" {
 		zonesChosen.Insert(zoneToInclude)
 	}
@@ -640,6 +662,14 @@ func chooseZonesForVolumeIncludingZone(zones sets.String, pvcName, zoneToInclude
 
 // ChooseZonesForVolume is identical to ChooseZoneForVolume, but selects a multiple zones, for multi-zone disks.
 func ChooseZonesForVolume(zones sets.String, pvcName string, numZones uint32) sets.String {
+	return ChooseZonesForVolumeWithSalt(zones, pvcName, "", numZones)
+}
+
+// ChooseZonesForVolumeWithSalt is ChooseZonesForVolume with an additional
+// salt mixed into the name hash; see ChooseZoneForVolumeWithSalt. The salt
+// shifts only the hash, not the index derived from a trailing ordinal, so
+// consecutive StatefulSet members still round-robin across zones.
+func ChooseZonesForVolumeWithSalt(zones sets.String, pvcName, salt string, numZones uint32) sets.String {
 	// No zones available, return empty set.
 	replicaZones := sets.NewString()
 	if zones.Len() == 0 {
@@ -648,7 +678,7 @@ func ChooseZonesForVolume(zones sets.String, pvcName string, numZones uint32) se
 
 	// We create the volume in a zone determined by the name
 	// Eventually the scheduler will coordinate placement into an available zone
-	hash, index := getPVCNameHashAndIndexOffset(pvcName)
+	hash, index := getPVCNameHashAndIndexOffset(pvcName, salt)
 
 	// Zones.List returns zones in a consistent order (sorted)
 	// We do have a potential failure case where volumes will not be properly spread,
@@ -671,7 +701,18 @@ func ChooseZonesForVolume(zones sets.String, pvcName string, numZones uint32) se
 	return replicaZones
 }
 
-func getPVCNameHashAndIndexOffset(pvcName string) (hash uint32, index uint32) {
+// ClaimZoneSpreadSalt is the salt a provisioner passes to the WithSalt zone
+// choosers to opt in to per-claim spreading: namespace and UID together are
+// unique per claim, yet stable across retries of the same provisioning.
+func ClaimZoneSpreadSalt(pvc *v1.PersistentVolumeClaim) string {
+	return pvc.Namespace + "/" + string(pvc.UID)
+}
+
+// getPVCNameHashAndIndexOffset derives the zone hash and round-robin index
+// from the claim name. A non-empty salt (namespace and UID of the claim) is
+// mixed into the hash only, so it changes which zones are picked without
+// disturbing how consecutive ordinals spread across them.
+func getPVCNameHashAndIndexOffset(pvcName, salt string) (hash uint32, index uint32) {
 	if pvcName == "" {
 		// We should always be called with a name; this shouldn't happen
 		glog.Warningf("No name defined during volume create; choosing random zone")
@@ -716,6 +757,9 @@ func getPVCNameHashAndIndexOffset(pvcName string) (hash uint32, index uint32) {
 
 		// We hash the (base) volume name, so we don't bias towards the first N zones
 		h := fnv.New32()
+		if salt != "" {
+			h.Write([]byte(salt))
+		}
 		h.Write([]byte(hashString))
 		hash = h.Sum32()
 	}
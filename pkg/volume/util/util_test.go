@@ -17,6 +17,7 @@ limitations under the License.
 package util
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"runtime"
@@ -2303,7 +2304,7 @@ func TestChooseZonesForVolumeIncludingZone(t *testing.T) {
 			continue
 		}
 
-		zones, err := chooseZonesForVolumeIncludingZone(zonesParameter, test.Name, test.ZoneToInclude, test.ReplicaCount)
+		zones, err := chooseZonesForVolumeIncludingZone(zonesParameter, test.Name, "", test.ZoneToInclude, test.ReplicaCount)
 		if test.Reject && err == nil {
 			t.Errorf("Unexpected zones from chooseZonesForVolumeIncludingZone in %s. Zones: %v", test.Name, zones)
 			continue
@@ -2426,3 +2427,60 @@ func TestMakeAbsolutePath(t *testing.T) {
 		}
 	}
 }
+
+func TestGetPVCNameHashAndIndexOffsetWithSalt(t *testing.T) {
+	// The trailing-segment heuristic makes differently-named StatefulSets
+	// with identical suffixes collide without a salt.
+	unsaltedHash, unsaltedIndex := getPVCNameHashAndIndexOffset("data-web-3", "")
+	collidingHash, _ := getPVCNameHashAndIndexOffset("logs-web-3", "")
+	if unsaltedHash != collidingHash {
+		t.Errorf("expected identical suffixes to collide without a salt, got %d and %d", unsaltedHash, collidingHash)
+	}
+	if unsaltedIndex != 3 {
+		t.Errorf("expected index 3 from the trailing ordinal, got %d", unsaltedIndex)
+	}
+
+	// The salt changes the hash but not the ordinal-derived index.
+	saltedHashA, saltedIndexA := getPVCNameHashAndIndexOffset("data-web-3", "default/uid-a")
+	saltedHashB, _ := getPVCNameHashAndIndexOffset("data-web-3", "default/uid-b")
+	if saltedHashA == unsaltedHash {
+		t.Errorf("expected the salt to change the hash")
+	}
+	if saltedHashA == saltedHashB {
+		t.Errorf("expected distinct salts to produce distinct hashes")
+	}
+	if saltedIndexA != 3 {
+		t.Errorf("expected the salt to leave the index untouched, got %d", saltedIndexA)
+	}
+
+	// The salted hash is stable.
+	repeatHash, _ := getPVCNameHashAndIndexOffset("data-web-3", "default/uid-a")
+	if repeatHash != saltedHashA {
+		t.Errorf("expected the salted hash to be deterministic, got %d and %d", saltedHashA, repeatHash)
+	}
+}
+
+func TestChooseZonesForVolumeWithSalt(t *testing.T) {
+	zones := sets.NewString("zone-a", "zone-b", "zone-c", "zone-d", "zone-e")
+
+	// An empty salt preserves the legacy placement.
+	for _, name := range []string{"solo", "data-web-0", "data-web-7"} {
+		legacy := ChooseZonesForVolume(zones, name, 2)
+		if salted := ChooseZonesForVolumeWithSalt(zones, name, "", 2); !legacy.Equal(salted) {
+			t.Errorf("%s: expected an empty salt to keep the legacy zones %v, got %v", name, legacy.List(), salted.List())
+		}
+		if legacySingle, saltedSingle := ChooseZoneForVolume(zones, name), ChooseZoneForVolumeWithSalt(zones, name, ""); legacySingle != saltedSingle {
+			t.Errorf("%s: expected an empty salt to keep the legacy zone %s, got %s", name, legacySingle, saltedSingle)
+		}
+	}
+
+	// Consecutive ordinals still round-robin across zones with a salt.
+	salt := "default/uid-a"
+	chosen := sets.NewString()
+	for i := 0; i < 5; i++ {
+		chosen.Insert(ChooseZoneForVolumeWithSalt(zones, fmt.Sprintf("data-web-%d", i), salt))
+	}
+	if chosen.Len() != 5 {
+		t.Errorf("expected 5 consecutive ordinals to land in 5 distinct zones, got %v", chosen.List())
+	}
+}
@@ -92,6 +92,16 @@ type Metrics struct {
 	// a filesystem with the host (e.g. emptydir, hostpath), this is the free inodes
 	// on the underlying storage, and is shared with host processes and other volumes
 	InodesFree *resource.Quantity
+
+	// BurstBalance is the remaining burst credit balance, in percent, for
+	// cloud volumes that meter burst credits (e.g. AWS EBS gp2). Only set by
+	// plugins with a cloud-side stats source.
+	BurstBalance *resource.Quantity
+
+	// QueueLength is the cloud-reported average number of pending I/O
+	// requests for the volume. Only set by plugins with a cloud-side stats
+	// source.
+	QueueLength *resource.Quantity
 }
 
 // Attributes represents the attributes of this mounter.
@@ -19,6 +19,7 @@ package clientcmd
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"reflect"
 	"strings"
@@ -190,6 +191,11 @@ func validateClusterInfo(clusterName string, clusterInfo clientcmdapi.Cluster) [
 			validationErrors = append(validationErrors, fmt.Errorf("unable to read certificate-authority %v for %v due to %v", clusterInfo.CertificateAuthority, clusterName, err))
 		}
 	}
+	if len(clusterInfo.ProxyURL) != 0 {
+		if _, err := url.Parse(clusterInfo.ProxyURL); err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("invalid proxy-url %v for %v due to %v", clusterInfo.ProxyURL, clusterName, err))
+		}
+	}
 
 	return validationErrors
 }
@@ -51,6 +51,45 @@ import (
 // will return an error. This is intended for use on nodes (kubelet and
 // kubeadm).
 func RequestNodeCertificate(client certificatesclient.CertificateSigningRequestInterface, privateKeyData []byte, nodeName types.NodeName) (certData []byte, err error) {
+	return RequestNodeCertificateWithAnnotations(client, privateKeyData, nodeName, nil)
+}
+
+// RequestNodeCertificateWithAnnotations is RequestNodeCertificate with an
+// additional set of annotations attached to the created CSR object, allowing
+// out-of-band approvers to verify the request (e.g. against a cloud API).
+func RequestNodeCertificateWithAnnotations(client certificatesclient.CertificateSigningRequestInterface, privateKeyData []byte, nodeName types.NodeName, annotations map[string]string) (certData []byte, err error) {
+	return RequestNodeCertificateWithOptions(client, privateKeyData, nodeName, NodeCertificateOptions{Annotations: annotations})
+}
+
+// Annotations carrying CSR options the certificates.k8s.io/v1beta1 spec cannot
+// express. Signers that support custom signer names or bounded certificate
+// lifetimes read them from the CSR object.
+const (
+	// SignerNameCSRAnnotation names the signer that should issue the
+	// certificate, for clusters running a signer other than the legacy
+	// kubelet client signer.
+	SignerNameCSRAnnotation = "alpha.certificates.k8s.io/signer-name"
+	// ExpirationSecondsCSRAnnotation is the requested certificate lifetime
+	// in seconds. The signer may issue a shorter-lived certificate.
+	ExpirationSecondsCSRAnnotation = "alpha.certificates.k8s.io/expiration-seconds"
+)
+
+// NodeCertificateOptions customizes the CSR created for a node.
+type NodeCertificateOptions struct {
+	// Annotations are attached to the created CSR object.
+	Annotations map[string]string
+	// SignerName selects the signer that should issue the certificate.
+	// Empty keeps the legacy kubelet client signer semantics.
+	SignerName string
+	// ExpirationSeconds is the requested certificate duration; 0 leaves the
+	// duration up to the signer.
+	ExpirationSeconds int32
+}
+
+// RequestNodeCertificateWithOptions is RequestNodeCertificate with control
+// over the CSR annotations, the requested signer and the requested
+// certificate duration.
+func RequestNodeCertificateWithOptions(client certificatesclient.CertificateSigningRequestInterface, privateKeyData []byte, nodeName types.NodeName, options NodeCertificateOptions) (certData []byte, err error) {
 	subject := &pkix.Name{
 		Organization: []string{"system:nodes"},
 		CommonName:   "system:node:" + string(nodeName),
@@ -65,13 +104,27 @@ func RequestNodeCertificate(client certificatesclient.CertificateSigningRequestI
 		return nil, fmt.Errorf("unable to generate certificate request: %v", err)
 	}
 
+	annotations := map[string]string{}
+	for k, v := range options.Annotations {
+		annotations[k] = v
+	}
+	if len(options.SignerName) > 0 {
+		annotations[SignerNameCSRAnnotation] = options.SignerName
+	}
+	if options.ExpirationSeconds > 0 {
+		annotations[ExpirationSecondsCSRAnnotation] = fmt.Sprintf("%d", options.ExpirationSeconds)
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+
 	usages := []certificates.KeyUsage{
 		certificates.UsageDigitalSignature,
 		certificates.UsageKeyEncipherment,
 		certificates.UsageClientAuth,
 	}
-	name := digestedName(privateKeyData, subject, usages)
-	req, err := RequestCertificate(client, csrData, name, usages, privateKey)
+	name := digestedName(privateKeyData, subject, usages, options.SignerName)
+	req, err := requestCertificate(client, csrData, name, usages, annotations, privateKey)
 	if err != nil {
 		return nil, err
 	}
@@ -85,11 +138,16 @@ func RequestNodeCertificate(client certificatesclient.CertificateSigningRequestI
 // certificate (pem-encoded). If there is any errors, or the watch timeouts, it
 // will return an error.
 func RequestCertificate(client certificatesclient.CertificateSigningRequestInterface, csrData []byte, name string, usages []certificates.KeyUsage, privateKey interface{}) (req *certificates.CertificateSigningRequest, err error) {
+	return requestCertificate(client, csrData, name, usages, nil, privateKey)
+}
+
+func requestCertificate(client certificatesclient.CertificateSigningRequestInterface, csrData []byte, name string, usages []certificates.KeyUsage, annotations map[string]string, privateKey interface{}) (req *certificates.CertificateSigningRequest, err error) {
 	csr := &certificates.CertificateSigningRequest{
 		// Username, UID, Groups will be injected by API server.
 		TypeMeta: metav1.TypeMeta{Kind: "CertificateSigningRequest"},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
+			Name:        name,
+			Annotations: annotations,
 		},
 		Spec: certificates.CertificateSigningRequestSpec{
 			Request: csrData,
@@ -173,7 +231,7 @@ func WaitForCertificate(client certificatesclient.CertificateSigningRequestInter
 // regenerate every loop and we include usages which are not contained in the
 // CSR. This needs to be kept up to date as we add new fields to the node
 // certificates and with ensureCompatible.
-func digestedName(privateKeyData []byte, subject *pkix.Name, usages []certificates.KeyUsage) string {
+func digestedName(privateKeyData []byte, subject *pkix.Name, usages []certificates.KeyUsage, signerName string) string {
 	hash := sha512.New512_256()
 
 	// Here we make sure two different inputs can't write the same stream
@@ -196,6 +254,11 @@ func digestedName(privateKeyData []byte, subject *pkix.Name, usages []certificat
 	for _, v := range usages {
 		write([]byte(v))
 	}
+	// Only hash the signer when one was requested so names for the default
+	// signer stay stable across upgrades.
+	if len(signerName) > 0 {
+		write([]byte(signerName))
+	}
 
 	return "node-csr-" + encode(hash.Sum(nil))
 }
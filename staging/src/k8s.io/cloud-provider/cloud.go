@@ -135,6 +135,36 @@ type LoadBalancer interface {
 	EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error
 }
 
+// WeightedNodesLoadBalancer is an optional interface for LoadBalancer
+// implementations that can distribute traffic according to per-node weights.
+// The service controller prefers UpdateLoadBalancerWithWeights over
+// UpdateLoadBalancer when the provider implements this interface and a node
+// weight function is configured.
+type WeightedNodesLoadBalancer interface {
+	// UpdateLoadBalancerWithWeights updates hosts under the specified load
+	// balancer together with a relative traffic weight per node, keyed by
+	// node name. Implementations must treat the *v1.Service and *v1.Node
+	// parameters as read-only and not modify them.
+	// Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager
+	UpdateLoadBalancerWithWeights(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node, weights map[types.NodeName]int32) error
+}
+
+// ZoneAffinityLoadBalancer is an optional interface for LoadBalancer
+// implementations that can keep load balancer traffic within the client's
+// zone. The service controller calls EnsureLoadBalancerZoneAffinity after the
+// backends of a load balancer have been ensured, for services that request
+// topology-aware routing, so providers can reduce cross-zone data transfer.
+type ZoneAffinityLoadBalancer interface {
+	// EnsureLoadBalancerZoneAffinity configures zone affinity on the
+	// backends of the service's load balancer. zoneNodeCounts holds the
+	// number of backend nodes per zone, keyed by the node's failure-domain
+	// zone label; nodes without the label are counted under the empty key.
+	// Implementations must treat the *v1.Service parameter as read-only and
+	// not modify it.
+	// Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager
+	EnsureLoadBalancerZoneAffinity(ctx context.Context, clusterName string, service *v1.Service, zoneNodeCounts map[string]int) error
+}
+
 // Instances is an abstract, pluggable interface for sets of instances.
 type Instances interface {
 	// NodeAddresses returns the addresses of the specified instance.